@@ -0,0 +1,205 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import "fmt"
+import "log"
+import "strings"
+
+import "github.com/docopt/docopt-go"
+
+import "github.com/scs-solution/go.pkt2/capture"
+import "github.com/scs-solution/go.pkt2/capture/pcap"
+import "github.com/scs-solution/go.pkt2/capture/file"
+import "github.com/scs-solution/go.pkt2/filter"
+import "github.com/scs-solution/go.pkt2/layers"
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/cdp"
+import "github.com/scs-solution/go.pkt2/packet/lldp"
+
+type neighbor struct {
+	proto string
+	name  string
+	port  string
+	addrs []string
+	caps  string
+	desc  string
+}
+
+func main() {
+	log.SetFlags(0)
+
+	usage := `Usage: neighbors [options]
+
+List the LLDP and CDP neighbors seen on the network.
+
+Options:
+  -i <iface>  Listen on interface.
+  -r <file>   Read packets from file.`
+
+	args, err := docopt.Parse(usage, nil, true, "", false)
+	if err != nil {
+		log.Fatalf("Invalid arguments: %s", err)
+	}
+
+	var src capture.Handle
+
+	if args["-i"] != nil {
+		src, err = pcap.Open(args["-i"].(string))
+		if err != nil {
+			log.Fatalf("Error opening iface: %s", err)
+		}
+	} else if args["-r"] != nil {
+		src, err = file.Open(args["-r"].(string))
+		if err != nil {
+			log.Fatalf("Error opening file: %s", err)
+		}
+	} else {
+		log.Fatalf("Must select a source (either -i or -r)")
+	}
+	defer src.Close()
+
+	err = src.Activate()
+	if err != nil {
+		log.Fatalf("Error activating source: %s", err)
+	}
+
+	flt, err := filter.Compile(
+		"ether proto 0x88cc or ether dst 01:00:0c:cc:cc:cc",
+		src.LinkType(), false,
+	)
+	if err != nil {
+		log.Fatalf("Error parsing filter: %s", err)
+	}
+	defer flt.Cleanup()
+
+	err = src.ApplyFilter(flt)
+	if err != nil {
+		log.Fatalf("Error appying filter: %s", err)
+	}
+
+	seen := make(map[string]bool)
+
+	for {
+		buf, err := src.Capture()
+		if err != nil {
+			log.Fatalf("Error: %s", err)
+			break
+		}
+
+		if buf == nil {
+			break
+		}
+
+		rcv_pkt, err := layers.UnpackAll(buf, src.LinkType())
+		if err != nil {
+			log.Printf("Error: %s\n", err)
+		}
+
+		n := parse_neighbor(rcv_pkt)
+		if n == nil {
+			continue
+		}
+
+		key := n.proto + "|" + n.name + "|" + n.port
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+
+		fmt.Printf("%-4s %-24s port %-16s %s\n", n.proto, n.name, n.port,
+			strings.Join(n.addrs, ","))
+
+		if n.caps != "" {
+			fmt.Printf("     capabilities: %s\n", n.caps)
+		}
+
+		if n.desc != "" {
+			fmt.Printf("     %s\n", first_line(n.desc))
+		}
+	}
+}
+
+func parse_neighbor(pkt packet.Packet) *neighbor {
+	if p, ok := layers.FindLayer(pkt, packet.LLDP).(*lldp.Packet); ok {
+		n := &neighbor{
+			proto: "lldp",
+			name:  p.SysName,
+			port:  p.PortIDString(),
+			caps:  p.Enabled.String(),
+			desc:  p.SysDesc,
+		}
+
+		if n.name == "" {
+			n.name = p.ChassisIDString()
+		}
+
+		for i := range p.MgmtAddrs {
+			if ip := p.MgmtAddrs[i].IP(); ip != nil {
+				n.addrs = append(n.addrs, ip.String())
+			}
+		}
+
+		return n
+	}
+
+	if p, ok := layers.FindLayer(pkt, packet.CDP).(*cdp.Packet); ok {
+		n := &neighbor{
+			proto: "cdp",
+			name:  p.DeviceID,
+			port:  p.PortID,
+			caps:  p.Capabilities.String(),
+			desc:  p.Platform,
+		}
+
+		addrs := p.MgmtAddrs
+		if len(addrs) == 0 {
+			addrs = p.Addresses
+		}
+
+		for _, ip := range addrs {
+			n.addrs = append(n.addrs, ip.String())
+		}
+
+		return n
+	}
+
+	return nil
+}
+
+func first_line(s string) string {
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		return s[:i]
+	}
+
+	return s
+}
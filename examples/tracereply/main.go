@@ -98,6 +98,7 @@ Reply to ICMPv6 traceroutes.`
 				reply_pkt.Type = icmpv6.EchoReply
 				reply_pkt.Code = 0
 				reply_pkt.Body = ip_pkt.Payload().(*icmpv6.Packet).Body
+				reply_pkt.Data = ip_pkt.Payload().(*icmpv6.Packet).Data
 
 				reply_pkts = append(reply_pkts, reply_pkt)
 
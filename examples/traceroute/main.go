@@ -30,10 +30,12 @@
 
 package main
 
+import "fmt"
 import "log"
 import "math"
 import "math/rand"
 import "net"
+import "strings"
 import "time"
 
 import "github.com/docopt/docopt-go"
@@ -159,7 +161,20 @@ Options:
 
 		ipv4_rsp := layers.FindLayer(pkt, packet.IPv4).(*ipv4.Packet)
 
-		log.Println(ipv4_rsp.SrcAddr)
+		icmp_rsp, _ := layers.FindLayer(pkt, packet.ICMPv4).(*icmpv4.Packet)
+
+		if icmp_rsp != nil && len(icmp_rsp.MPLSLabels()) > 0 {
+			var labels []string
+
+			for _, label := range icmp_rsp.MPLSLabels() {
+				labels = append(labels, fmt.Sprintf("L=%d,E=%d,S=%t,TTL=%d",
+					label.Label, label.TC, label.S, label.TTL))
+			}
+
+			log.Println(ipv4_rsp.SrcAddr, strings.Join(labels, " "))
+		} else {
+			log.Println(ipv4_rsp.SrcAddr)
+		}
 
 		if ipv4_rsp.SrcAddr.Equal(addr_ip) {
 			return
@@ -0,0 +1,100 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package main
+
+import "log"
+import "net"
+
+import "github.com/docopt/docopt-go"
+
+import "github.com/scs-solution/go.pkt2/capture/pcap"
+
+import "github.com/scs-solution/go.pkt2/network"
+import "github.com/scs-solution/go.pkt2/routing"
+
+func main() {
+	log.SetFlags(0)
+
+	usage := `Usage: wol [options] <hwaddr> <addr>
+
+Wake up the host with the given MAC address, by sending a Wake-on-LAN magic
+packet out of the interface used to reach the given IP address (e.g. the
+broadcast address of the host's network).
+
+Options:
+  -p <pass>  SecureOn password, in MAC address format.`
+
+	args, err := docopt.Parse(usage, nil, true, "", false)
+	if err != nil {
+		log.Fatalf("Invalid arguments: %s", err)
+	}
+
+	hwaddr, err := net.ParseMAC(args["<hwaddr>"].(string))
+	if err != nil {
+		log.Fatalf("Invalid MAC address: %s", err)
+	}
+
+	var password []byte
+
+	if args["-p"] != nil {
+		password, err = net.ParseMAC(args["-p"].(string))
+		if err != nil {
+			log.Fatalf("Invalid password: %s", err)
+		}
+	}
+
+	addr_ip := net.ParseIP(args["<addr>"].(string))
+
+	route, err := routing.RouteTo(addr_ip)
+	if err != nil {
+		log.Fatalf("Error: %s", err)
+	}
+
+	if route == nil {
+		log.Fatalf("No route found")
+	}
+
+	c, err := pcap.Open(route.Iface.Name)
+	if err != nil {
+		log.Fatalf("Error opening interface: %s", err)
+	}
+	defer c.Close()
+
+	err = c.Activate()
+	if err != nil {
+		log.Fatalf("Error activating source: %s", err)
+	}
+
+	err = network.WakeOnLAN(c, route, hwaddr, password)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
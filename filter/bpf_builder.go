@@ -33,6 +33,8 @@ package filter
 // #include "bpf_filter.h"
 import "C"
 
+import "fmt"
+
 // A Builder is used to compile a BPF filter from basic BPF instructions.
 type Builder struct {
     filter    *Filter
@@ -57,7 +59,22 @@ func NewBuilder() *Builder {
 }
 
 // Generate and return the Filter associated with the Builder.
+//
+// Any error in resolving the jump labels is ignored: jumps to undefined labels,
+// backwards or out of range are silently left pointing to the next instruction.
+// Use Resolve() instead to have such errors reported.
 func (b *Builder) Build() *Filter {
+    f, _ := b.Resolve()
+    return f
+}
+
+// Generate and return the Filter associated with the Builder, resolving all
+// the jump labels. An error is returned if a jump refers to an undefined label,
+// or if its target is not a following instruction or is too far away to be
+// encoded in the instruction.
+func (b *Builder) Resolve() (*Filter, error) {
+    var err error
+
     prog := (*C.struct_bpf_program)(b.filter.Program())
     flen := int(C.bpf_get_len(prog))
 
@@ -65,28 +82,57 @@ func (b *Builder) Build() *Filter {
         insn := C.bpf_get_insn(prog, C.int(i))
 
         if lbl, ok := b.jumps_k[i]; ok {
-            addr := b.labels[lbl]
-            if addr != 0 {
-                insn.k = C.bpf_u_int32(addr - i - 1)
+            off, e := b.jump_off(i, lbl, false)
+            if e == nil {
+                insn.k = C.bpf_u_int32(off)
+            } else if err == nil {
+                err = e
             }
         }
 
         if lbl, ok := b.jumps_jt[i]; ok {
-            addr := b.labels[lbl]
-            if addr != 0 {
-                insn.jt = C.u_char(addr - i - 1)
+            off, e := b.jump_off(i, lbl, true)
+            if e == nil {
+                insn.jt = C.u_char(off)
+            } else if err == nil {
+                err = e
             }
         }
 
         if lbl, ok := b.jumps_jf[i]; ok {
-            addr := b.labels[lbl]
-            if addr != 0  {
-                insn.jf = C.u_char(addr - i - 1)
+            off, e := b.jump_off(i, lbl, true)
+            if e == nil {
+                insn.jf = C.u_char(off)
+            } else if err == nil {
+                err = e
             }
         }
     }
 
-    return b.filter
+    return b.filter, err
+}
+
+func (b *Builder) jump_off(i int, lbl string, short bool) (int, error) {
+    /* an empty label means "next instruction" */
+    if lbl == "" {
+        return 0, nil
+    }
+
+    addr, ok := b.labels[lbl]
+    if !ok {
+        return 0, fmt.Errorf("Undefined label '%s' at %d", lbl, i)
+    }
+
+    if addr <= i || addr >= b.filter.Len() {
+        return 0, fmt.Errorf("Invalid jump to '%s' at %d", lbl, i)
+    }
+
+    off := addr - i - 1
+    if short && off > 0xff {
+        return 0, fmt.Errorf("Jump to '%s' at %d out of range", lbl, i)
+    }
+
+    return off, nil
 }
 
 // Define a new label at the next instruction position. Labels are used in jump
@@ -0,0 +1,534 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package filter
+
+import "fmt"
+import "net"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/eth"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+
+// An Expr is a filter expression that can be combined with other expressions
+// (see And(), Or() and Not()) and compiled to a BPF filter for a specific link
+// type with CompileExpr().
+//
+// Filters themselves (e.g. created by Compile() or by a Builder) can be used as
+// expressions too, so that they can be combined with other filters and
+// expressions. Such filters must have been generated for the same link type
+// the expression is compiled for.
+type Expr interface {
+    /* Append the code matching the expression to the compiler's Builder,
+     * jumping to the ok label on match and to the fail label otherwise */
+    emit(c *compiler, ok, fail string) error
+}
+
+type compiler struct {
+    bld    *Builder
+    link   packet.Type
+    vlan   uint32
+    labels int
+}
+
+// Compile the given expression to a BPF filter for packets of the given link
+// type. Supported link types are Eth, SLL, IPv4 and IPv6, though any link type
+// can be used if the expression is only made of Filters.
+func CompileExpr(e Expr, link_type packet.Type) (*Filter, error) {
+    c := &compiler{bld: NewBuilder(), link: link_type}
+
+    ok   := c.label()
+    fail := c.label()
+
+    err := e.emit(c, ok, fail)
+    if err != nil {
+        return nil, err
+    }
+
+    c.bld.Label(ok).RET(Const, 0x40000).Label(fail).RET(Const, 0x0)
+
+    return c.bld.Resolve()
+}
+
+func (c *compiler) label() string {
+    c.labels++
+    return fmt.Sprintf("expr_%d", c.labels)
+}
+
+/* Return the offset of the EtherType field, if the link type has one */
+func (c *compiler) ethertype_off() (uint32, bool) {
+    switch c.link {
+    case packet.Eth:
+        return 12 + c.vlan, true
+
+    case packet.SLL:
+        return 14 + c.vlan, true
+    }
+
+    return 0, false
+}
+
+/* Return the offset of the network layer header */
+func (c *compiler) network_off() (uint32, error) {
+    switch c.link {
+    case packet.Eth, packet.SLL:
+        off, _ := c.ethertype_off()
+        return off + 2, nil
+
+    case packet.IPv4, packet.IPv6:
+        return 0, nil
+    }
+
+    return 0, fmt.Errorf("Unsupported link type %s", c.link)
+}
+
+/* Check that the network layer protocol is the given one */
+func (c *compiler) ethertype(t eth.EtherType, ok, fail string) error {
+    off, has_type := c.ethertype_off()
+    if has_type {
+        c.bld.LD(Half, ABS, off).JEQ(Const, ok, fail, uint32(t))
+        return nil
+    }
+
+    switch {
+    case c.link == packet.IPv4 && t == eth.IPv4,
+         c.link == packet.IPv6 && t == eth.IPv6:
+        c.bld.JA(ok)
+
+    case c.link == packet.IPv4, c.link == packet.IPv6:
+        c.bld.JA(fail)
+
+    default:
+        return fmt.Errorf("Unsupported link type %s", c.link)
+    }
+
+    return nil
+}
+
+type and_expr []Expr
+
+// Match packets that match all the given expressions.
+func And(exprs ...Expr) Expr {
+    return and_expr(exprs)
+}
+
+func (e and_expr) emit(c *compiler, ok, fail string) error {
+    if len(e) == 0 {
+        c.bld.JA(ok)
+        return nil
+    }
+
+    for _, sub := range e[:len(e) - 1] {
+        next := c.label()
+
+        err := sub.emit(c, next, fail)
+        if err != nil {
+            return err
+        }
+
+        c.bld.Label(next)
+    }
+
+    return e[len(e) - 1].emit(c, ok, fail)
+}
+
+type or_expr []Expr
+
+// Match packets that match at least one of the given expressions.
+func Or(exprs ...Expr) Expr {
+    return or_expr(exprs)
+}
+
+func (e or_expr) emit(c *compiler, ok, fail string) error {
+    if len(e) == 0 {
+        c.bld.JA(fail)
+        return nil
+    }
+
+    for _, sub := range e[:len(e) - 1] {
+        next := c.label()
+
+        err := sub.emit(c, ok, next)
+        if err != nil {
+            return err
+        }
+
+        c.bld.Label(next)
+    }
+
+    return e[len(e) - 1].emit(c, ok, fail)
+}
+
+type not_expr struct {
+    expr Expr
+}
+
+// Match packets that don't match the given expression.
+func Not(e Expr) Expr {
+    return not_expr{e}
+}
+
+func (e not_expr) emit(c *compiler, ok, fail string) error {
+    return e.expr.emit(c, fail, ok)
+}
+
+/* Embed the filter's code, with its RET instructions turned into jumps to the
+ * ok label (if the packet is accepted) or to the fail label (otherwise). Every
+ * instruction is replaced with a single one, so the filter's own jumps don't
+ * need to be relocated. */
+func (f *Filter) emit(c *compiler, ok, fail string) error {
+    insns := f.Instructions()
+
+    if len(insns) == 0 {
+        c.bld.JA(ok)
+        return nil
+    }
+
+    if !f.Validate() {
+        return fmt.Errorf("Invalid filter")
+    }
+
+    for i, insn := range insns {
+        if insn.Code & 0x07 != RET {
+            c.bld.AppendInstruction(insn.Code, insn.Jt, insn.Jf, insn.K)
+            continue
+        }
+
+        switch Src(insn.Code & 0x18) {
+        case Const:
+            if insn.K != 0 {
+                c.bld.JA(ok)
+            } else {
+                c.bld.JA(fail)
+            }
+
+        case Acc:
+            c.bld.JEQ(Const, fail, ok, 0)
+
+        default:
+            return fmt.Errorf("Invalid RET instruction at %d", i)
+        }
+    }
+
+    return nil
+}
+
+// Combine the filter with the given one, returning a new filter that matches
+// packets that match both. The filters (e.g. created by Compile() or by a
+// Builder) must have been generated for the same link type.
+func (f *Filter) And(g *Filter) (*Filter, error) {
+    return CompileExpr(And(f, g), packet.None)
+}
+
+// Combine the filter with the given one, returning a new filter that matches
+// packets that match at least one of them. The filters must have been
+// generated for the same link type.
+func (f *Filter) Or(g *Filter) (*Filter, error) {
+    return CompileExpr(Or(f, g), packet.None)
+}
+
+// Return a new filter that matches packets that don't match the filter.
+func (f *Filter) Not() (*Filter, error) {
+    return CompileExpr(Not(f), packet.None)
+}
+
+type ethertype_expr eth.EtherType
+
+// Match packets with the given network layer protocol.
+func EtherType(t eth.EtherType) Expr {
+    return ethertype_expr(t)
+}
+
+// Match ARP packets.
+func ARP() Expr {
+    return ethertype_expr(eth.ARP)
+}
+
+// Match IPv4 packets.
+func IPv4() Expr {
+    return ethertype_expr(eth.IPv4)
+}
+
+// Match IPv6 packets.
+func IPv6() Expr {
+    return ethertype_expr(eth.IPv6)
+}
+
+func (e ethertype_expr) emit(c *compiler, ok, fail string) error {
+    return c.ethertype(eth.EtherType(e), ok, fail)
+}
+
+type vlan_expr struct {
+    any  bool
+    id   uint16
+    expr Expr
+}
+
+// Match 802.1Q and 802.1ad tagged packets whose encapsulated packet matches
+// the given expression. VLAN expressions can be nested to match stacked tags.
+func VLAN(e Expr) Expr {
+    return vlan_expr{any: true, expr: e}
+}
+
+// Like VLAN(), but only match packets with the given VLAN identifier.
+func VLANID(id uint16, e Expr) Expr {
+    return vlan_expr{id: id, expr: e}
+}
+
+func (e vlan_expr) emit(c *compiler, ok, fail string) error {
+    off, has_type := c.ethertype_off()
+    if !has_type {
+        return fmt.Errorf("VLAN not supported on link type %s", c.link)
+    }
+
+    tagged := c.label()
+    qinq   := c.label()
+
+    c.bld.LD(Half, ABS, off).
+          JEQ(Const, tagged, qinq, uint32(eth.VLAN)).
+          Label(qinq).
+          JEQ(Const, tagged, fail, uint32(eth.QinQ)).
+          Label(tagged)
+
+    if !e.any {
+        match := c.label()
+
+        c.bld.LD(Half, ABS, off + 2).
+              AND(Const, 0x0fff).
+              JEQ(Const, match, fail, uint32(e.id)).
+              Label(match)
+    }
+
+    c.vlan += 4
+    err := e.expr.emit(c, ok, fail)
+    c.vlan -= 4
+
+    return err
+}
+
+type ipv4_addr_expr struct {
+    off  uint32
+    addr *net.IPNet
+}
+
+// Match IPv4 packets whose source address belongs to the given network.
+func IPv4Src(n *net.IPNet) Expr {
+    return ipv4_addr_expr{off: 12, addr: n}
+}
+
+// Match IPv4 packets whose destination address belongs to the given network.
+func IPv4Dst(n *net.IPNet) Expr {
+    return ipv4_addr_expr{off: 16, addr: n}
+}
+
+// Match IPv4 packets whose source or destination address belongs to the given
+// network.
+func IPv4Host(n *net.IPNet) Expr {
+    return Or(IPv4Src(n), IPv4Dst(n))
+}
+
+func (e ipv4_addr_expr) emit(c *compiler, ok, fail string) error {
+    addr := e.addr.IP.To4()
+    if addr == nil || len(e.addr.Mask) != net.IPv4len {
+        return fmt.Errorf("Invalid IPv4 network %s", e.addr)
+    }
+
+    nl, err := c.network_off()
+    if err != nil {
+        return err
+    }
+
+    is_ipv4 := c.label()
+
+    err = c.ethertype(eth.IPv4, is_ipv4, fail)
+    if err != nil {
+        return err
+    }
+
+    mask := be32(e.addr.Mask)
+
+    c.bld.Label(is_ipv4).LD(Word, ABS, nl + e.off)
+
+    if mask != 0xffffffff {
+        c.bld.AND(Const, mask)
+    }
+
+    c.bld.JEQ(Const, ok, fail, be32(addr) & mask)
+
+    return nil
+}
+
+type proto_expr ipv4.Protocol
+
+// Match IPv4 and IPv6 packets carrying the given protocol. IPv6 extension
+// headers are not followed, so only the IPv6 Next Header field is checked.
+func Proto(p ipv4.Protocol) Expr {
+    return proto_expr(p)
+}
+
+// Match TCP packets.
+func TCP() Expr {
+    return proto_expr(ipv4.TCP)
+}
+
+// Match UDP packets.
+func UDP() Expr {
+    return proto_expr(ipv4.UDP)
+}
+
+// Match ICMPv4 packets.
+func ICMPv4() Expr {
+    return proto_expr(ipv4.ICMPv4)
+}
+
+// Match ICMPv6 packets.
+func ICMPv6() Expr {
+    return proto_expr(ipv4.ICMPv6)
+}
+
+func (e proto_expr) emit(c *compiler, ok, fail string) error {
+    nl, err := c.network_off()
+    if err != nil {
+        return err
+    }
+
+    is_ipv4 := c.label()
+    not_ipv4 := c.label()
+    is_ipv6 := c.label()
+
+    err = c.ethertype(eth.IPv4, is_ipv4, not_ipv4)
+    if err != nil {
+        return err
+    }
+
+    c.bld.Label(is_ipv4).
+          LD(Byte, ABS, nl + 9).
+          JEQ(Const, ok, fail, uint32(e)).
+          Label(not_ipv4)
+
+    err = c.ethertype(eth.IPv6, is_ipv6, fail)
+    if err != nil {
+        return err
+    }
+
+    c.bld.Label(is_ipv6).
+          LD(Byte, ABS, nl + 6).
+          JEQ(Const, ok, fail, uint32(e))
+
+    return nil
+}
+
+type port_expr struct {
+    proto ipv4.Protocol
+    off   uint32
+    port  uint16
+}
+
+// Match TCP packets with the given source port.
+func TCPSrcPort(port uint16) Expr {
+    return port_expr{proto: ipv4.TCP, off: 0, port: port}
+}
+
+// Match TCP packets with the given destination port.
+func TCPDstPort(port uint16) Expr {
+    return port_expr{proto: ipv4.TCP, off: 2, port: port}
+}
+
+// Match TCP packets with the given source or destination port.
+func TCPPort(port uint16) Expr {
+    return Or(TCPSrcPort(port), TCPDstPort(port))
+}
+
+// Match UDP packets with the given source port.
+func UDPSrcPort(port uint16) Expr {
+    return port_expr{proto: ipv4.UDP, off: 0, port: port}
+}
+
+// Match UDP packets with the given destination port.
+func UDPDstPort(port uint16) Expr {
+    return port_expr{proto: ipv4.UDP, off: 2, port: port}
+}
+
+// Match UDP packets with the given source or destination port.
+func UDPPort(port uint16) Expr {
+    return Or(UDPSrcPort(port), UDPDstPort(port))
+}
+
+func (e port_expr) emit(c *compiler, ok, fail string) error {
+    nl, err := c.network_off()
+    if err != nil {
+        return err
+    }
+
+    is_ipv4 := c.label()
+    ipv4_proto := c.label()
+    ipv4_first := c.label()
+    not_ipv4 := c.label()
+    is_ipv6 := c.label()
+    ipv6_proto := c.label()
+
+    err = c.ethertype(eth.IPv4, is_ipv4, not_ipv4)
+    if err != nil {
+        return err
+    }
+
+    /* the IPv4 header length is variable, so use MSH to load it into the
+     * index register and address the transport header relatively to it */
+    c.bld.Label(is_ipv4).
+          LD(Byte, ABS, nl + 9).
+          JEQ(Const, ipv4_proto, fail, uint32(e.proto)).
+          Label(ipv4_proto).
+          LD(Half, ABS, nl + 6).
+          JSET(Const, fail, ipv4_first, 0x1fff).
+          Label(ipv4_first).
+          LDX(Byte, MSH, nl).
+          LD(Half, IND, nl + e.off).
+          JEQ(Const, ok, fail, uint32(e.port)).
+          Label(not_ipv4)
+
+    err = c.ethertype(eth.IPv6, is_ipv6, fail)
+    if err != nil {
+        return err
+    }
+
+    c.bld.Label(is_ipv6).
+          LD(Byte, ABS, nl + 6).
+          JEQ(Const, ipv6_proto, fail, uint32(e.proto)).
+          Label(ipv6_proto).
+          LD(Half, ABS, nl + 40 + e.off).
+          JEQ(Const, ok, fail, uint32(e.port))
+
+    return nil
+}
+
+func be32(b []byte) uint32 {
+    return uint32(b[0]) << 24 | uint32(b[1]) << 16 |
+           uint32(b[2]) << 8 | uint32(b[3])
+}
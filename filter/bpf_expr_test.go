@@ -0,0 +1,345 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package filter_test
+
+import "log"
+import "net"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/filter"
+import "github.com/scs-solution/go.pkt2/packet"
+
+var test_eth_vlan_ipv4_udp = []byte{
+	0x00, 0x21, 0x96, 0x6e, 0xf0, 0x70, 0x4c, 0x72, 0xb9, 0x54, 0xe5, 0x3d,
+	0x81, 0x00, 0x00, 0x87, 0x08, 0x00, 0x45, 0x00, 0x00, 0x1c, 0x00, 0x01,
+	0x00, 0x00, 0x40, 0x11, 0x27, 0x60, 0xc0, 0xa8, 0x01, 0x87, 0xc1, 0x1b,
+	0xd0, 0x25, 0xa2, 0x5a, 0x20, 0x92, 0x00, 0x08, 0xe9, 0x80,
+}
+
+func compile_expr(t *testing.T, e filter.Expr, link_type packet.Type) *filter.Filter {
+	flt, err := filter.CompileExpr(e, link_type)
+	if err != nil {
+		t.Fatalf("Error compiling: %s", err)
+	}
+
+	if !flt.Validate() {
+		t.Fatalf("Invalid filter:\n%s", flt)
+	}
+
+	return flt
+}
+
+func TestExprProto(t *testing.T) {
+	arp := compile_expr(t, filter.ARP(), packet.Eth)
+	udp := compile_expr(t, filter.UDP(), packet.Eth)
+
+	if !arp.Match(test_eth_arp) {
+		t.Fatalf("ARP mismatch")
+	}
+
+	if arp.Match(test_eth_ipv4_udp) {
+		t.Fatalf("ARP matched (but it shouldn't have)")
+	}
+
+	if !udp.Match(test_eth_ipv4_udp) {
+		t.Fatalf("UDP mismatch")
+	}
+
+	if udp.Match(test_eth_ipv4_tcp) {
+		t.Fatalf("UDP matched (but it shouldn't have)")
+	}
+}
+
+func TestExprAndOrNot(t *testing.T) {
+	_, src, _ := net.ParseCIDR("192.168.1.0/24")
+	_, dst, _ := net.ParseCIDR("193.27.208.37/32")
+
+	and := compile_expr(t,
+		filter.And(filter.IPv4Src(src), filter.TCPDstPort(8338)),
+		packet.Eth,
+	)
+
+	or := compile_expr(t,
+		filter.Or(filter.ARP(), filter.UDPPort(8338)),
+		packet.Eth,
+	)
+
+	not := compile_expr(t,
+		filter.Not(filter.IPv4Dst(dst)),
+		packet.Eth,
+	)
+
+	if !and.Match(test_eth_ipv4_tcp) {
+		t.Fatalf("And mismatch")
+	}
+
+	if and.Match(test_eth_ipv4_udp) {
+		t.Fatalf("And matched (but it shouldn't have)")
+	}
+
+	if !or.Match(test_eth_arp) || !or.Match(test_eth_ipv4_udp) {
+		t.Fatalf("Or mismatch")
+	}
+
+	if or.Match(test_eth_ipv4_tcp) {
+		t.Fatalf("Or matched (but it shouldn't have)")
+	}
+
+	if !not.Match(test_eth_arp) {
+		t.Fatalf("Not mismatch")
+	}
+
+	if not.Match(test_eth_ipv4_udp) {
+		t.Fatalf("Not matched (but it shouldn't have)")
+	}
+}
+
+func TestExprVLAN(t *testing.T) {
+	udp := compile_expr(t, filter.UDPDstPort(8338), packet.Eth)
+	any := compile_expr(t, filter.VLAN(filter.UDPDstPort(8338)), packet.Eth)
+	id := compile_expr(t, filter.VLANID(135, filter.ARP()), packet.Eth)
+	bad := compile_expr(t, filter.VLANID(136, filter.ARP()), packet.Eth)
+
+	if udp.Match(test_eth_vlan_ipv4_udp) {
+		t.Fatalf("UDP matched (but it shouldn't have)")
+	}
+
+	if !any.Match(test_eth_vlan_ipv4_udp) {
+		t.Fatalf("VLAN UDP mismatch")
+	}
+
+	if any.Match(test_eth_ipv4_udp) {
+		t.Fatalf("VLAN matched (but it shouldn't have)")
+	}
+
+	if !id.Match(test_eth_vlan_arp) {
+		t.Fatalf("VLAN ID mismatch")
+	}
+
+	if bad.Match(test_eth_vlan_arp) {
+		t.Fatalf("VLAN ID matched (but it shouldn't have)")
+	}
+}
+
+func TestExprIPv4Link(t *testing.T) {
+	_, src, _ := net.ParseCIDR("110.52.109.140/32")
+
+	flt := compile_expr(t,
+		filter.And(filter.IPv4Src(src), filter.TCPDstPort(80)),
+		packet.IPv4,
+	)
+
+	if !flt.Match(test_ipv4_tcp_single_byte) {
+		t.Fatalf("IPv4 mismatch")
+	}
+
+	_, err := filter.CompileExpr(filter.VLAN(filter.TCP()), packet.IPv4)
+	if err == nil {
+		t.Fatalf("VLAN compiled on IPv4 link type")
+	}
+
+	_, err = filter.CompileExpr(filter.TCP(), packet.RadioTap)
+	if err == nil {
+		t.Fatalf("Compiled on unsupported link type")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	_, err := filter.NewBuilder().
+		LD(filter.Half, filter.ABS, 12).
+		JEQ(filter.Const, "", "fail", 0x806).
+		RET(filter.Const, 0x40000).
+		Resolve()
+	if err == nil {
+		t.Fatalf("Undefined label not reported")
+	}
+
+	_, err = filter.NewBuilder().
+		Label("back").
+		LD(filter.Half, filter.ABS, 12).
+		JEQ(filter.Const, "", "back", 0x806).
+		RET(filter.Const, 0x40000).
+		Resolve()
+	if err == nil {
+		t.Fatalf("Backward jump not reported")
+	}
+
+	bld := filter.NewBuilder().
+		LD(filter.Half, filter.ABS, 12).
+		JEQ(filter.Const, "", "fail", 0x806)
+
+	for i := 0; i < 300; i++ {
+		bld.LD(filter.Half, filter.ABS, 12)
+	}
+
+	_, err = bld.Label("fail").RET(filter.Const, 0x0).Resolve()
+	if err == nil {
+		t.Fatalf("Out of range jump not reported")
+	}
+}
+
+func ExampleCompileExpr() {
+	_, mgmt, _ := net.ParseCIDR("10.0.0.0/8")
+
+	// Match HTTPS traffic not coming from the management network
+	flt, err := filter.CompileExpr(
+		filter.And(filter.Not(filter.IPv4Src(mgmt)), filter.TCPDstPort(443)),
+		packet.Eth,
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if flt.Match([]byte("random data")) {
+		log.Println("MATCH!!!")
+	}
+}
+
+func TestExprFilters(t *testing.T) {
+	arp, _ := filter.Compile("arp", packet.Eth, false)
+	udp, _ := filter.Compile("udp", packet.Eth, false)
+	port, _ := filter.Compile("port 8338", packet.Eth, false)
+
+	arp_bld := filter.NewBuilder().
+		LD(filter.Half, filter.ABS, 12).
+		JEQ(filter.Const, "", "fail", 0x806).
+		RET(filter.Const, 0x40000).
+		Label("fail").
+		RET(filter.Const, 0x0).
+		Build()
+
+	/* accept packets whose first byte is 0xff by returning A */
+	acc_bld := filter.NewBuilder().
+		LD(filter.Byte, filter.ABS, 0).
+		JEQ(filter.Const, "", "fail", 0xff).
+		RET(filter.Acc, 0).
+		Label("fail").
+		LD(filter.Word, filter.IMM, 0).
+		RET(filter.Acc, 0).
+		Build()
+
+	or := compile_expr(t, filter.Or(arp, udp), packet.Eth)
+	and := compile_expr(t, filter.And(udp, port), packet.Eth)
+	not := compile_expr(t, filter.Not(arp_bld), packet.Eth)
+	mix := compile_expr(t, filter.And(acc_bld, filter.TCP()), packet.Eth)
+
+	if !or.Match(test_eth_arp) || !or.Match(test_eth_ipv4_udp) {
+		t.Fatalf("Or mismatch")
+	}
+
+	if or.Match(test_eth_ipv4_tcp) {
+		t.Fatalf("Or matched (but it shouldn't have)")
+	}
+
+	if !and.Match(test_eth_ipv4_udp) {
+		t.Fatalf("And mismatch")
+	}
+
+	if and.Match(test_eth_ipv4_tcp) {
+		t.Fatalf("And matched (but it shouldn't have)")
+	}
+
+	if !not.Match(test_eth_ipv4_tcp) {
+		t.Fatalf("Not mismatch")
+	}
+
+	if not.Match(test_eth_arp) {
+		t.Fatalf("Not matched (but it shouldn't have)")
+	}
+
+	if mix.Match(test_eth_ipv4_udp) || mix.Match(test_eth_ipv4_tcp) {
+		t.Fatalf("Mixed matched (but it shouldn't have)")
+	}
+
+	tcp_bcast := append([]byte{}, test_eth_ipv4_tcp...)
+	copy(tcp_bcast, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	if !mix.Match(tcp_bcast) {
+		t.Fatalf("Mixed mismatch")
+	}
+}
+
+func TestFilterAndOrNot(t *testing.T) {
+	udp, err := filter.Compile("udp", packet.Eth, true)
+	if err != nil {
+		t.Fatalf("Error compiling: %s", err)
+	}
+
+	port, err := filter.Compile("port 8338", packet.Eth, false)
+	if err != nil {
+		t.Fatalf("Error compiling: %s", err)
+	}
+
+	and, err := udp.And(port)
+	if err != nil {
+		t.Fatalf("Error combining: %s", err)
+	}
+
+	or, err := udp.Or(port)
+	if err != nil {
+		t.Fatalf("Error combining: %s", err)
+	}
+
+	not, err := udp.Not()
+	if err != nil {
+		t.Fatalf("Error combining: %s", err)
+	}
+
+	for _, flt := range []*filter.Filter{and, or, not} {
+		if !flt.Validate() {
+			t.Fatalf("Invalid filter:\n%s", flt)
+		}
+	}
+
+	if !and.Match(test_eth_ipv4_udp) {
+		t.Fatalf("And mismatch")
+	}
+
+	if and.Match(test_eth_ipv4_tcp) || and.Match(test_eth_arp) {
+		t.Fatalf("And matched (but it shouldn't have)")
+	}
+
+	if !or.Match(test_eth_ipv4_udp) || !or.Match(test_eth_ipv4_tcp) {
+		t.Fatalf("Or mismatch")
+	}
+
+	if or.Match(test_eth_arp) {
+		t.Fatalf("Or matched (but it shouldn't have)")
+	}
+
+	if !not.Match(test_eth_ipv4_tcp) || !not.Match(test_eth_arp) {
+		t.Fatalf("Not mismatch")
+	}
+
+	if not.Match(test_eth_ipv4_udp) {
+		t.Fatalf("Not matched (but it shouldn't have)")
+	}
+}
@@ -87,6 +87,14 @@ const (
     Acc       = syscall.BPF_A
 )
 
+// Instruction is a single BPF instruction.
+type Instruction struct {
+    Code Code
+    Jt   uint8
+    Jf   uint8
+    K    uint32
+}
+
 // Try to match the given buffer against the filter.
 func (f *Filter) Match(buf []byte) bool {
     cbuf := (*C.char)(unsafe.Pointer(&buf[0]))
@@ -140,6 +148,27 @@ func (f *Filter) Program() unsafe.Pointer {
     return unsafe.Pointer(&f.program)
 }
 
+// Return a copy of the instructions of the filter.
+func (f *Filter) Instructions() []Instruction {
+    prog := (*C.struct_bpf_program)(f.Program())
+    flen := int(C.bpf_get_len(prog))
+
+    insns := make([]Instruction, flen)
+
+    for i := 0; i < flen; i++ {
+        insn := C.bpf_get_insn(prog, C.int(i))
+
+        insns[i] = Instruction{
+            Code: Code(insn.code),
+            Jt:   uint8(insn.jt),
+            Jf:   uint8(insn.jf),
+            K:    uint32(insn.k),
+        }
+    }
+
+    return insns
+}
+
 func (f *Filter) String() string {
     var insns []string
 
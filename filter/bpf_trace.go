@@ -0,0 +1,427 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package filter
+
+import "fmt"
+import "strings"
+
+// A Step represents a single instruction executed by a filter, together with
+// the values of the accumulator and index registers after its execution.
+type Step struct {
+    PC   int
+    Insn Instruction
+    A    uint32
+    X    uint32
+}
+
+// A Trace records the execution of a filter against a packet.
+type Trace struct {
+    Steps  []Step
+    Result uint32
+}
+
+// Like Match(), but also return the trace of the instructions executed by the
+// filter. The filter is run by an interpreter implemented in Go that follows
+// the semantics of the one used by Match(), so it's considerably slower and
+// should only be used for debugging.
+func (f *Filter) MatchTrace(buf []byte) (bool, *Trace) {
+    t := &Trace{}
+
+    t.Result = run(f.Instructions(), buf, func(s Step) {
+        t.Steps = append(t.Steps, s)
+    })
+
+    return t.Result > 0, t
+}
+
+// Return the instruction path followed by the filter.
+func (t *Trace) Path() []int {
+    path := make([]int, len(t.Steps))
+
+    for i, s := range t.Steps {
+        path[i] = s.PC
+    }
+
+    return path
+}
+
+func (t *Trace) String() string {
+    var steps []string
+
+    for _, s := range t.Steps {
+        str := fmt.Sprintf(
+            "(%03d) { 0x%.2x, %3d, %3d, 0x%.8x } A=0x%.8x X=0x%.8x",
+            s.PC, s.Insn.Code, s.Insn.Jt, s.Insn.Jf, s.Insn.K, s.A, s.X,
+        )
+
+        steps = append(steps, str)
+    }
+
+    steps = append(steps, fmt.Sprintf("result=%d", t.Result))
+
+    return strings.Join(steps, "\n")
+}
+
+// A Source is a packet source, such as a capture.Handle.
+type Source interface {
+    Capture() ([]byte, error)
+}
+
+// Coverage aggregates the executions of a filter against multiple packets, to
+// show which instructions are executed and which branches are taken.
+type Coverage struct {
+    Packets  uint64
+    Matches  uint64
+
+    /* number of executions of each instruction */
+    Hits     []uint64
+
+    /* number of times the true and false branches of each conditional
+     * jump have been taken */
+    True     []uint64
+    False    []uint64
+
+    insns    []Instruction
+}
+
+// A Branch identifies one of the two targets of a conditional jump.
+type Branch struct {
+    PC   int
+    Cond bool
+}
+
+// Create a new Coverage for the given filter.
+func NewCoverage(f *Filter) *Coverage {
+    insns := f.Instructions()
+
+    return &Coverage{
+        Hits:  make([]uint64, len(insns)),
+        True:  make([]uint64, len(insns)),
+        False: make([]uint64, len(insns)),
+        insns: insns,
+    }
+}
+
+// Run the filter on the given buffer, record the coverage and return whether
+// the buffer matched.
+func (c *Coverage) Add(buf []byte) bool {
+    var prev *Step
+
+    record := func(s Step) {
+        if prev != nil {
+            c.branch(prev.PC, s.PC)
+        }
+
+        c.Hits[s.PC]++
+        prev = &s
+    }
+
+    c.Packets++
+
+    if run(c.insns, buf, record) > 0 {
+        c.Matches++
+        return true
+    }
+
+    return false
+}
+
+// Run the filter on all the packets returned by the given source, until it
+// returns no more packets (e.g. at the end of a capture file).
+func (c *Coverage) AddSource(src Source) error {
+    for {
+        buf, err := src.Capture()
+        if err != nil {
+            return err
+        }
+
+        if buf == nil {
+            return nil
+        }
+
+        c.Add(buf)
+    }
+}
+
+/* Record the branch taken by the conditional jump at pc, given that the
+ * next instruction executed is at next */
+func (c *Coverage) branch(pc, next int) {
+    insn := c.insns[pc]
+
+    if insn.Code & 0x07 != JMP || insn.Code == JMP {
+        return
+    }
+
+    switch next {
+    case pc + 1 + int(insn.Jt):
+        c.True[pc]++
+
+        /* both branches lead to the same instruction */
+        if insn.Jt == insn.Jf {
+            c.False[pc]++
+        }
+
+    case pc + 1 + int(insn.Jf):
+        c.False[pc]++
+    }
+}
+
+// Return the instructions that have never been executed.
+func (c *Coverage) Unreached() []int {
+    var pcs []int
+
+    for pc, hits := range c.Hits {
+        if hits == 0 {
+            pcs = append(pcs, pc)
+        }
+    }
+
+    return pcs
+}
+
+// Return the conditional jump branches that have never been taken. Branches
+// of jumps that have never been executed are not included (see Unreached()).
+func (c *Coverage) Untaken() []Branch {
+    var branches []Branch
+
+    for pc, insn := range c.insns {
+        if insn.Code & 0x07 != JMP || insn.Code == JMP || c.Hits[pc] == 0 {
+            continue
+        }
+
+        if c.True[pc] == 0 {
+            branches = append(branches, Branch{PC: pc, Cond: true})
+        }
+
+        if c.False[pc] == 0 {
+            branches = append(branches, Branch{PC: pc, Cond: false})
+        }
+    }
+
+    return branches
+}
+
+func (c *Coverage) String() string {
+    var lines []string
+
+    lines = append(lines,
+        fmt.Sprintf("packets=%d matches=%d", c.Packets, c.Matches))
+
+    for pc, insn := range c.insns {
+        str := fmt.Sprintf(
+            "(%03d) { 0x%.2x, %3d, %3d, 0x%.8x } hits=%d",
+            pc, insn.Code, insn.Jt, insn.Jf, insn.K, c.Hits[pc],
+        )
+
+        if insn.Code & 0x07 == JMP && insn.Code != JMP {
+            str += fmt.Sprintf(" jt=%d jf=%d", c.True[pc], c.False[pc])
+        }
+
+        lines = append(lines, str)
+    }
+
+    return strings.Join(lines, "\n")
+}
+
+func (b Branch) String() string {
+    if b.Cond {
+        return fmt.Sprintf("%d:jt", b.PC)
+    }
+
+    return fmt.Sprintf("%d:jf", b.PC)
+}
+
+/* Execute the given program on the buffer, calling step after every executed
+ * instruction. This follows the semantics of bpf_filter(). */
+func run(insns []Instruction, buf []byte, step func(s Step)) uint32 {
+    var A, X uint32
+    var mem [16]uint32
+
+    if len(insns) == 0 {
+        return 0xffffffff
+    }
+
+    blen := uint32(len(buf))
+
+    load := func(k uint32, size uint32) (uint32, bool) {
+        if k > blen || size > blen - k {
+            return 0, false
+        }
+
+        var v uint32
+
+        for i := uint32(0); i < size; i++ {
+            v = v << 8 | uint32(buf[k + i])
+        }
+
+        return v, true
+    }
+
+    for pc := 0; pc < len(insns); pc++ {
+        var ok = true
+
+        insn := insns[pc]
+
+        switch insn.Code & 0x07 {
+        case LD, LDX:
+            var v uint32
+
+            size := uint32(4)
+
+            switch Size(insn.Code & 0x18) {
+            case Half:
+                size = 2
+            case Byte:
+                size = 1
+            }
+
+            switch Mode(insn.Code & 0xe0) {
+            case IMM:
+                v = insn.K
+
+            case ABS:
+                v, ok = load(insn.K, size)
+
+            case IND:
+                if X > 0xffffffff - insn.K {
+                    ok = false
+                } else {
+                    v, ok = load(X + insn.K, size)
+                }
+
+            case MEM:
+                v = mem[insn.K & 0xf]
+
+            case LEN:
+                v = blen
+
+            case MSH:
+                v, ok = load(insn.K, 1)
+                v = (v & 0xf) << 2
+            }
+
+            if insn.Code & 0x07 == LD {
+                A = v
+            } else {
+                X = v
+            }
+
+        case ST:
+            mem[insn.K & 0xf] = A
+
+        case STX:
+            mem[insn.K & 0xf] = X
+
+        case ALU:
+            v := insn.K
+            if Src(insn.Code & 0x08) == Index {
+                v = X
+            }
+
+            switch insn.Code & 0xf0 {
+            case 0x00: A += v
+            case 0x10: A -= v
+            case 0x20: A *= v
+            case 0x30:
+                if v == 0 {
+                    ok = false
+                } else {
+                    A /= v
+                }
+            case 0x40: A |= v
+            case 0x50: A &= v
+            case 0x60: A <<= v
+            case 0x70: A >>= v
+            case 0x80: A = -A
+            case 0x90:
+                if v == 0 {
+                    ok = false
+                } else {
+                    A %= v
+                }
+            case 0xa0: A ^= v
+            }
+
+        case JMP:
+            v := insn.K
+            if Src(insn.Code & 0x08) == Index {
+                v = X
+            }
+
+            var cond bool
+
+            switch insn.Code & 0xf0 {
+            case 0x00:
+                step(Step{PC: pc, Insn: insn, A: A, X: X})
+                pc += int(insn.K)
+                continue
+            case 0x10: cond = A == v
+            case 0x20: cond = A > v
+            case 0x30: cond = A >= v
+            case 0x40: cond = A & v != 0
+            }
+
+            step(Step{PC: pc, Insn: insn, A: A, X: X})
+
+            if cond {
+                pc += int(insn.Jt)
+            } else {
+                pc += int(insn.Jf)
+            }
+
+            continue
+
+        case RET:
+            step(Step{PC: pc, Insn: insn, A: A, X: X})
+
+            if Src(insn.Code & 0x18) == Acc {
+                return A
+            }
+
+            return insn.K
+
+        case MISC:
+            if insn.Code & 0xf8 == 0x80 {
+                A = X
+            } else {
+                X = A
+            }
+        }
+
+        step(Step{PC: pc, Insn: insn, A: A, X: X})
+
+        if !ok {
+            return 0
+        }
+    }
+
+    return 0
+}
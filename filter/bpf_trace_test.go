@@ -0,0 +1,174 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package filter_test
+
+import "log"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/capture/file"
+import "github.com/scs-solution/go.pkt2/filter"
+import "github.com/scs-solution/go.pkt2/packet"
+
+func TestMatchTrace(t *testing.T) {
+	port, err := filter.Compile("port 8338", packet.Eth, false)
+	if err != nil {
+		t.Fatalf("Error compiling port")
+	}
+
+	pkts := [][]byte{
+		test_eth_arp, test_eth_vlan_arp,
+		test_eth_ipv4_udp, test_eth_ipv4_tcp,
+		test_eth_ipv4_udp[:20],
+	}
+
+	for _, buf := range pkts {
+		match, trace := port.MatchTrace(buf)
+
+		if match != port.Match(buf) {
+			t.Fatalf("Match mismatch:\n%s", trace)
+		}
+
+		if uint(trace.Result) != port.Filter(buf) {
+			t.Fatalf("Result mismatch:\n%s", trace)
+		}
+	}
+
+	_, trace := port.MatchTrace(test_eth_ipv4_tcp)
+
+	path := trace.Path()
+	expected := []int{0, 1, 10, 11, 12, 13, 15, 16, 17, 18, 19, 20, 21, 22}
+
+	if len(path) != len(expected) {
+		t.Fatalf("Path mismatch: %v", path)
+	}
+
+	for i := range path {
+		if path[i] != expected[i] {
+			t.Fatalf("Path mismatch: %v", path)
+		}
+	}
+
+	step := trace.Steps[8]
+	if step.X != 20 {
+		t.Fatalf("Index register mismatch: %d", step.X)
+	}
+
+	step = trace.Steps[9]
+	if step.A != 0xa25a {
+		t.Fatalf("Accumulator mismatch: %x", step.A)
+	}
+}
+
+func TestMatchTraceALU(t *testing.T) {
+	flt := filter.NewBuilder().
+		LD(filter.Byte, filter.ABS, 0).
+		MUL(filter.Const, 3).
+		TAX().
+		DIV(filter.Index, 0).
+		ADD(filter.Const, 0xf0).
+		RET(filter.Acc, 0).
+		Build()
+
+	match, trace := flt.MatchTrace([]byte{0x05})
+	if !match || trace.Result != 0xf1 {
+		t.Fatalf("Result mismatch:\n%s", trace)
+	}
+
+	match, trace = flt.MatchTrace([]byte{0x00})
+	if match || trace.Result != 0 {
+		t.Fatalf("Division by zero not handled:\n%s", trace)
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	src, err := file.Open("../capture/file/capture_test.pcap")
+	if err != nil {
+		t.Fatalf("Error opening: %s", err)
+	}
+	defer src.Close()
+
+	arp, err := filter.Compile("arp", packet.Eth, false)
+	if err != nil {
+		t.Fatalf("Error compiling arp")
+	}
+
+	cov := filter.NewCoverage(arp)
+
+	err = cov.AddSource(src)
+	if err != nil {
+		t.Fatalf("Error reading: %s", err)
+	}
+
+	if cov.Packets != 16 || cov.Matches != 2 {
+		t.Fatalf("Count mismatch:\n%s", cov)
+	}
+
+	if len(cov.Unreached()) != 0 || len(cov.Untaken()) != 0 {
+		t.Fatalf("Coverage mismatch:\n%s", cov)
+	}
+
+	cov = filter.NewCoverage(arp)
+	cov.Add(test_eth_ipv4_udp)
+
+	unreached := cov.Unreached()
+	if len(unreached) != 1 || unreached[0] != 2 {
+		t.Fatalf("Unreached mismatch: %v", unreached)
+	}
+
+	untaken := cov.Untaken()
+	if len(untaken) != 1 || untaken[0].PC != 1 || !untaken[0].Cond {
+		t.Fatalf("Untaken mismatch: %v", untaken)
+	}
+}
+
+func ExampleCoverage() {
+	src, err := file.Open("/path/to/file/dump.pcap")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer src.Close()
+
+	flt, err := filter.Compile("udp or tcp", src.LinkType(), false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cov := filter.NewCoverage(flt)
+
+	err = cov.AddSource(src)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, b := range cov.Untaken() {
+		log.Printf("branch %s never taken", b)
+	}
+}
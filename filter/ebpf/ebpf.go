@@ -0,0 +1,433 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides translation of classic BPF filters (see the filter package) to eBPF
+// programs, so that existing filters can be used where eBPF is required (e.g.
+// socket filters using maps, tc or XDP pipelines). Translated programs can be
+// verified by running them with the included emulator, and loaded into the
+// kernel as socket filters where privileges allow.
+//
+// The translation follows the one done by the Linux kernel for classic BPF
+// socket filters: the accumulator is mapped to R0, the index register to R7,
+// the socket buffer context to R6 and the scratch memory to the stack.
+package ebpf
+
+import "encoding/binary"
+import "fmt"
+import "strings"
+
+import "github.com/scs-solution/go.pkt2/filter"
+
+// Instruction is a single eBPF instruction.
+type Instruction struct {
+	Op  uint8
+	Dst uint8
+	Src uint8
+	Off int16
+	Imm int32
+}
+
+// Program is a sequence of eBPF instructions.
+type Program []Instruction
+
+/* instruction classes */
+const (
+	class_ld    = 0x00
+	class_ldx   = 0x01
+	class_st    = 0x02
+	class_stx   = 0x03
+	class_alu   = 0x04
+	class_jmp   = 0x05
+	class_jmp32 = 0x06
+	class_alu64 = 0x07
+)
+
+/* load/store sizes and modes */
+const (
+	size_w  = 0x00
+	size_h  = 0x08
+	size_b  = 0x10
+	size_dw = 0x18
+
+	mode_imm = 0x00
+	mode_abs = 0x20
+	mode_ind = 0x40
+	mode_mem = 0x60
+)
+
+/* source operand */
+const (
+	src_k = 0x00
+	src_x = 0x08
+)
+
+/* alu operations */
+const (
+	alu_add  = 0x00
+	alu_sub  = 0x10
+	alu_mul  = 0x20
+	alu_div  = 0x30
+	alu_or   = 0x40
+	alu_and  = 0x50
+	alu_lsh  = 0x60
+	alu_rsh  = 0x70
+	alu_neg  = 0x80
+	alu_mod  = 0x90
+	alu_xor  = 0xa0
+	alu_mov  = 0xb0
+	alu_arsh = 0xc0
+)
+
+/* jump operations */
+const (
+	jmp_ja   = 0x00
+	jmp_jeq  = 0x10
+	jmp_jgt  = 0x20
+	jmp_jge  = 0x30
+	jmp_jset = 0x40
+	jmp_jne  = 0x50
+	jmp_jsgt = 0x60
+	jmp_jsge = 0x70
+	jmp_call = 0x80
+	jmp_exit = 0x90
+	jmp_jlt  = 0xa0
+	jmp_jle  = 0xb0
+	jmp_jslt = 0xc0
+	jmp_jsle = 0xd0
+)
+
+/* register mapping */
+const (
+	reg_a   = 0
+	reg_ctx = 1
+	reg_tmp = 2
+	reg_skb = 6
+	reg_x   = 7
+	reg_fp  = 10
+)
+
+/* number of classic BPF scratch memory words */
+const mem_words = 16
+
+type pending struct {
+	insn Instruction
+
+	/* index of the classic BPF instruction targeted by the jump, or -1 */
+	target int
+}
+
+// Translate the given classic BPF filter to an eBPF program. The resulting
+// program can be loaded as a socket filter, and returns the same value as the
+// original filter.
+func Translate(f *filter.Filter) (Program, error) {
+	insns := f.Instructions()
+
+	if len(insns) == 0 {
+		return nil, fmt.Errorf("Empty filter")
+	}
+
+	if !f.Validate() {
+		return nil, fmt.Errorf("Invalid filter")
+	}
+
+	var out []pending
+
+	emit := func(insn Instruction) {
+		out = append(out, pending{insn: insn, target: -1})
+	}
+
+	jump := func(insn Instruction, target int) {
+		out = append(out, pending{insn: insn, target: target})
+	}
+
+	/* save the context, needed by packet loads */
+	emit(Instruction{Op: class_alu64 | alu_mov | src_x, Dst: reg_skb, Src: reg_ctx})
+
+	emit(Instruction{Op: class_alu | alu_mov | src_k, Dst: reg_a})
+	emit(Instruction{Op: class_alu | alu_mov | src_k, Dst: reg_x})
+
+	/* the scratch memory is zeroed by classic BPF, while the eBPF verifier
+	 * refuses reads of uninitialized stack slots */
+	if uses_mem(insns) {
+		for k := uint32(0); k < mem_words; k++ {
+			emit(Instruction{
+				Op:  class_st | mode_mem | size_w,
+				Dst: reg_fp,
+				Off: mem_off(k),
+			})
+		}
+	}
+
+	start := make([]int, len(insns)+1)
+
+	for i, insn := range insns {
+		start[i] = len(out)
+
+		code := uint8(insn.Code)
+
+		switch filter.Code(code & 0x07) {
+		case filter.LD, filter.LDX:
+			dst := uint8(reg_a)
+			if filter.Code(code&0x07) == filter.LDX {
+				dst = reg_x
+			}
+
+			size := code & 0x18
+
+			switch filter.Mode(code & 0xe0) {
+			case filter.IMM:
+				emit(Instruction{
+					Op:  class_alu | alu_mov | src_k,
+					Dst: dst,
+					Imm: int32(insn.K),
+				})
+
+			case filter.ABS:
+				emit(Instruction{
+					Op:  class_ld | mode_abs | size,
+					Imm: int32(insn.K),
+				})
+
+			case filter.IND:
+				emit(Instruction{
+					Op:  class_ld | mode_ind | size,
+					Src: reg_x,
+					Imm: int32(insn.K),
+				})
+
+			case filter.MEM:
+				emit(Instruction{
+					Op:  class_ldx | mode_mem | size_w,
+					Dst: dst,
+					Src: reg_fp,
+					Off: mem_off(insn.K),
+				})
+
+			case filter.LEN:
+				/* offset of len in struct __sk_buff */
+				emit(Instruction{
+					Op:  class_ldx | mode_mem | size_w,
+					Dst: dst,
+					Src: reg_skb,
+				})
+
+			case filter.MSH:
+				/* the packet load clobbers R0-R5, so the
+				 * accumulator is saved in the index register */
+				emit(Instruction{Op: class_alu64 | alu_mov | src_x, Dst: reg_x, Src: reg_a})
+				emit(Instruction{Op: class_ld | mode_abs | size_b, Imm: int32(insn.K)})
+				emit(Instruction{Op: class_alu | alu_and | src_k, Dst: reg_a, Imm: 0xf})
+				emit(Instruction{Op: class_alu | alu_lsh | src_k, Dst: reg_a, Imm: 2})
+				emit(Instruction{Op: class_alu64 | alu_mov | src_x, Dst: reg_tmp, Src: reg_x})
+				emit(Instruction{Op: class_alu64 | alu_mov | src_x, Dst: reg_x, Src: reg_a})
+				emit(Instruction{Op: class_alu64 | alu_mov | src_x, Dst: reg_a, Src: reg_tmp})
+
+			default:
+				return nil, fmt.Errorf("Invalid instruction at %d", i)
+			}
+
+		case filter.ST, filter.STX:
+			src := uint8(reg_a)
+			if filter.Code(code&0x07) == filter.STX {
+				src = reg_x
+			}
+
+			emit(Instruction{
+				Op:  class_stx | mode_mem | size_w,
+				Dst: reg_fp,
+				Src: src,
+				Off: mem_off(insn.K),
+			})
+
+		case filter.ALU:
+			op := code & 0xf0
+
+			if op == alu_neg {
+				emit(Instruction{Op: class_alu | alu_neg, Dst: reg_a})
+				break
+			}
+
+			if code&0x08 == src_x {
+				/* classic BPF returns 0 on division by zero */
+				if op == alu_div || op == alu_mod {
+					emit(Instruction{Op: class_jmp | jmp_jne | src_k, Dst: reg_x, Off: 2})
+					emit(Instruction{Op: class_alu | alu_mov | src_k, Dst: reg_a})
+					emit(Instruction{Op: class_jmp | jmp_exit})
+				}
+
+				emit(Instruction{Op: class_alu | op | src_x, Dst: reg_a, Src: reg_x})
+			} else {
+				emit(Instruction{Op: class_alu | op | src_k, Dst: reg_a, Imm: int32(insn.K)})
+			}
+
+		case filter.JMP:
+			op := code & 0xf0
+
+			if op == jmp_ja {
+				jump(Instruction{Op: class_jmp | jmp_ja}, i+1+int(insn.K))
+				break
+			}
+
+			if insn.Jt == insn.Jf {
+				jump(Instruction{Op: class_jmp | jmp_ja}, i+1+int(insn.Jt))
+				break
+			}
+
+			cond := Instruction{Op: class_jmp | op | src_x, Dst: reg_a}
+
+			if code&0x08 == src_x {
+				cond.Src = reg_x
+			} else if int32(insn.K) >= 0 {
+				cond.Op = class_jmp | op | src_k
+				cond.Imm = int32(insn.K)
+			} else {
+				/* immediates are sign extended to 64 bit, so use a
+				 * zero extended register instead */
+				emit(Instruction{
+					Op:  class_alu | alu_mov | src_k,
+					Dst: reg_tmp,
+					Imm: int32(insn.K),
+				})
+
+				cond.Src = reg_tmp
+			}
+
+			switch {
+			case insn.Jf == 0:
+				jump(cond, i+1+int(insn.Jt))
+
+			case insn.Jt == 0:
+				cond.Off = 1
+				emit(cond)
+				jump(Instruction{Op: class_jmp | jmp_ja}, i+1+int(insn.Jf))
+
+			default:
+				jump(cond, i+1+int(insn.Jt))
+				jump(Instruction{Op: class_jmp | jmp_ja}, i+1+int(insn.Jf))
+			}
+
+		case filter.RET:
+			switch filter.Src(code & 0x18) {
+			case filter.Const:
+				emit(Instruction{
+					Op:  class_alu | alu_mov | src_k,
+					Dst: reg_a,
+					Imm: int32(insn.K),
+				})
+
+			case filter.Acc:
+
+			default:
+				return nil, fmt.Errorf("Invalid instruction at %d", i)
+			}
+
+			emit(Instruction{Op: class_jmp | jmp_exit})
+
+		case filter.MISC:
+			if code&0xf8 == 0x80 {
+				emit(Instruction{Op: class_alu64 | alu_mov | src_x, Dst: reg_a, Src: reg_x})
+			} else {
+				emit(Instruction{Op: class_alu64 | alu_mov | src_x, Dst: reg_x, Src: reg_a})
+			}
+		}
+	}
+
+	start[len(insns)] = len(out)
+
+	prog := make(Program, len(out))
+
+	for i, p := range out {
+		prog[i] = p.insn
+
+		if p.target < 0 {
+			continue
+		}
+
+		off := start[p.target] - i - 1
+		if off > 0x7fff {
+			return nil, fmt.Errorf("Jump out of range at %d", i)
+		}
+
+		prog[i].Off = int16(off)
+	}
+
+	return prog, nil
+}
+
+func uses_mem(insns []filter.Instruction) bool {
+	for _, insn := range insns {
+		switch insn.Code {
+		case filter.LD | filter.Code(filter.MEM),
+			filter.LDX | filter.Code(filter.MEM):
+			return true
+		}
+	}
+
+	return false
+}
+
+/* Return the stack offset of the given scratch memory word */
+func mem_off(k uint32) int16 {
+	return -int16((mem_words - k%mem_words) * 4)
+}
+
+// Encode the program to its binary form, as expected by the bpf(2) syscall on
+// little endian hosts.
+func (p Program) Bytes() []byte {
+	buf := make([]byte, len(p)*8)
+
+	for i, insn := range p {
+		b := buf[i*8:]
+
+		b[0] = insn.Op
+		b[1] = insn.Src<<4 | insn.Dst&0x0f
+		binary.LittleEndian.PutUint16(b[2:], uint16(insn.Off))
+		binary.LittleEndian.PutUint32(b[4:], uint32(insn.Imm))
+	}
+
+	return buf
+}
+
+func (p Program) String() string {
+	var insns []string
+
+	for _, insn := range p {
+		insns = append(insns, insn.String())
+	}
+
+	return strings.Join(insns, "\n")
+}
+
+func (i Instruction) String() string {
+	return fmt.Sprintf(
+		"{ 0x%.2x, r%d, r%d, %6d, 0x%.8x },",
+		i.Op, i.Dst, i.Src, i.Off, uint32(i.Imm),
+	)
+}
@@ -0,0 +1,190 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ebpf_test
+
+import "log"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/filter"
+import "github.com/scs-solution/go.pkt2/filter/ebpf"
+import "github.com/scs-solution/go.pkt2/packet"
+
+var test_eth_arp = []byte{
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x4c, 0x72, 0xb9, 0x54, 0xe5, 0x3d,
+	0x08, 0x06, 0x00, 0x01, 0x08, 0x00, 0x06, 0x04, 0x00, 0x01, 0x4c, 0x72,
+	0xb9, 0x54, 0xe5, 0x3d, 0xc0, 0xa8, 0x01, 0x87, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0xc1, 0x1b, 0xd0, 0x25,
+}
+
+var test_eth_ipv4_udp = []byte{
+	0x00, 0x21, 0x96, 0x6e, 0xf0, 0x70, 0x4c, 0x72, 0xb9, 0x54, 0xe5, 0x3d,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x1c, 0x00, 0x01, 0x00, 0x00, 0x40, 0x11,
+	0x27, 0x60, 0xc0, 0xa8, 0x01, 0x87, 0xc1, 0x1b, 0xd0, 0x25, 0xa2, 0x5a,
+	0x20, 0x92, 0x00, 0x08, 0xe9, 0x80,
+}
+
+var test_eth_ipv4_tcp = []byte{
+	0x00, 0x21, 0x96, 0x6e, 0xf0, 0x70, 0x4c, 0x72, 0xb9, 0x54, 0xe5, 0x3d,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x28, 0x00, 0x01, 0x00, 0x00, 0x40, 0x06,
+	0x27, 0x5f, 0xc0, 0xa8, 0x01, 0x87, 0xc1, 0x1b, 0xd0, 0x25, 0xa2, 0x5a,
+	0x20, 0x92, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x50, 0x02,
+	0x20, 0x00, 0x79, 0x85, 0x00, 0x00,
+}
+
+var test_pkts = [][]byte{
+	test_eth_arp,
+	test_eth_ipv4_udp,
+	test_eth_ipv4_tcp,
+	test_eth_ipv4_tcp[:30],
+	{0x00},
+}
+
+/* Check that the translated program returns the same values as the original */
+func check(t *testing.T, flt *filter.Filter) {
+	prog, err := ebpf.Translate(flt)
+	if err != nil {
+		t.Fatalf("Error translating: %s\n%s", err, flt)
+	}
+
+	for _, buf := range test_pkts {
+		res, err := prog.Run(buf)
+		if err != nil {
+			t.Fatalf("Error running: %s\n%s", err, prog)
+		}
+
+		if uint(res) != flt.Filter(buf) {
+			t.Fatalf("Result mismatch: %d != %d\n%s\n%s",
+				res, flt.Filter(buf), flt, prog)
+		}
+	}
+}
+
+func TestTranslateCompiled(t *testing.T) {
+	for _, expr := range []string{"arp", "udp", "tcp", "port 8338"} {
+		flt, err := filter.Compile(expr, packet.Eth, false)
+		if err != nil {
+			t.Fatalf("Error compiling %s", expr)
+		}
+
+		check(t, flt)
+	}
+}
+
+func TestTranslateExpr(t *testing.T) {
+	flt, err := filter.CompileExpr(
+		filter.Or(filter.ARP(), filter.TCPDstPort(8338)), packet.Eth,
+	)
+	if err != nil {
+		t.Fatalf("Error compiling: %s", err)
+	}
+
+	check(t, flt)
+}
+
+func TestTranslateBuilder(t *testing.T) {
+	/* scratch memory, index register, divisions and large constants */
+	flt := filter.NewBuilder().
+		LD(filter.Byte, filter.ABS, 0).
+		ST(3).
+		LDX(filter.Byte, filter.MSH, 14).
+		TXA().
+		SUB(filter.Const, 20).
+		TAX().
+		LD(filter.Word, filter.MEM, 3).
+		DIV(filter.Index, 0).
+		JEQ(filter.Const, "", "big", 0).
+		LD(filter.Word, filter.LEN, 0).
+		RET(filter.Acc, 0).
+		Label("big").
+		LD(filter.Word, filter.ABS, 0).
+		JGT(filter.Const, "fail", "", 0xf0000000).
+		JSET(filter.Const, "", "fail", 0x80000000).
+		LDX(filter.Word, filter.IMM, 4).
+		LD(filter.Half, filter.IND, 8).
+		MOD(filter.Index, 0).
+		RET(filter.Acc, 0).
+		Label("fail").
+		RET(filter.Const, 0).
+		Build()
+
+	if !flt.Validate() {
+		t.Fatalf("Invalid filter:\n%s", flt)
+	}
+
+	check(t, flt)
+
+	bcast := append([]byte{}, test_eth_ipv4_tcp...)
+	bcast[0] = 0xff
+
+	prog, _ := ebpf.Translate(flt)
+
+	res, _ := prog.Run(bcast)
+	if uint(res) != flt.Filter(bcast) {
+		t.Fatalf("Result mismatch: %d != %d", res, flt.Filter(bcast))
+	}
+}
+
+func TestTranslateInvalid(t *testing.T) {
+	flt := filter.NewBuilder().Build()
+
+	_, err := ebpf.Translate(flt)
+	if err == nil {
+		t.Fatalf("Empty filter translated")
+	}
+
+	flt = filter.NewBuilder().LD(filter.Byte, filter.ABS, 0).Build()
+
+	_, err = ebpf.Translate(flt)
+	if err == nil {
+		t.Fatalf("Invalid filter translated")
+	}
+}
+
+func ExampleTranslate() {
+	flt, err := filter.Compile("udp or tcp", packet.Eth, false)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	prog, err := ebpf.Translate(flt)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	res, err := prog.Run([]byte("random data"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if res > 0 {
+		log.Println("MATCH!!!")
+	}
+}
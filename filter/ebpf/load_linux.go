@@ -0,0 +1,151 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ebpf
+
+import "fmt"
+import "runtime"
+import "syscall"
+import "unsafe"
+
+/* bpf(2) syscall numbers, not defined by the syscall package */
+var sys_bpf = map[string]uintptr{
+	"386":     357,
+	"amd64":   321,
+	"arm":     386,
+	"arm64":   280,
+	"ppc64le": 361,
+	"riscv64": 280,
+}
+
+const (
+	bpf_prog_load               = 5
+	bpf_prog_type_socket_filter = 1
+
+	so_attach_bpf = 50
+)
+
+type prog_load_attr struct {
+	prog_type    uint32
+	insn_cnt     uint32
+	insns        uint64
+	license      uint64
+	log_level    uint32
+	log_size     uint32
+	log_buf      uint64
+	kern_version uint32
+	prog_flags   uint32
+}
+
+// Load the program into the kernel as a socket filter and return the file
+// descriptor referring to it, which can be attached to sockets with AttachFd()
+// and must be closed when not needed anymore. This may require privileges,
+// depending on the system configuration. On failure the verifier log is
+// included in the returned error.
+func (p Program) Load() (int, error) {
+	nr, ok := sys_bpf[runtime.GOARCH]
+	if !ok {
+		return -1, fmt.Errorf("Unsupported architecture")
+	}
+
+	if len(p) == 0 {
+		return -1, fmt.Errorf("Empty program")
+	}
+
+	insns := p.Bytes()
+	license := []byte("BSD\x00")
+	log_buf := make([]byte, 65536)
+
+	attr := prog_load_attr{
+		prog_type: bpf_prog_type_socket_filter,
+		insn_cnt:  uint32(len(p)),
+		insns:     uint64(uintptr(unsafe.Pointer(&insns[0]))),
+		license:   uint64(uintptr(unsafe.Pointer(&license[0]))),
+		log_level: 1,
+		log_size:  uint32(len(log_buf)),
+		log_buf:   uint64(uintptr(unsafe.Pointer(&log_buf[0]))),
+	}
+
+	fd, _, errno := syscall.Syscall(
+		nr, bpf_prog_load,
+		uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr),
+	)
+
+	runtime.KeepAlive(insns)
+	runtime.KeepAlive(license)
+	runtime.KeepAlive(log_buf)
+
+	if errno != 0 {
+		log := string(log_buf[:clen(log_buf)])
+		if log != "" {
+			return -1, fmt.Errorf("Could not load program: %s\n%s",
+				errno, log)
+		}
+
+		return -1, fmt.Errorf("Could not load program: %s", errno)
+	}
+
+	return int(fd), nil
+}
+
+// Attach the loaded program referred by prog_fd to the socket identified by the
+// given file descriptor. The program can be detached with filter.DetachFd().
+func AttachFd(fd int, prog_fd int) error {
+	err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, so_attach_bpf, prog_fd)
+	if err != nil {
+		return fmt.Errorf("Could not attach program: %s", err)
+	}
+
+	return nil
+}
+
+// Like AttachFd(), but take the socket from the given connection.
+func Attach(conn syscall.RawConn, prog_fd int) error {
+	var attach_err error
+
+	err := conn.Control(func(fd uintptr) {
+		attach_err = AttachFd(int(fd), prog_fd)
+	})
+	if err != nil {
+		return err
+	}
+
+	return attach_err
+}
+
+func clen(b []byte) int {
+	for i := 0; i < len(b); i++ {
+		if b[i] == 0 {
+			return i
+		}
+	}
+
+	return len(b)
+}
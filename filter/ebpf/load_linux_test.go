@@ -0,0 +1,93 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ebpf_test
+
+import "net"
+import "testing"
+import "time"
+
+import "github.com/scs-solution/go.pkt2/filter"
+import "github.com/scs-solution/go.pkt2/filter/ebpf"
+
+func TestLoad(t *testing.T) {
+	/* only accept UDP datagrams whose payload starts with 0x42 */
+	flt := filter.NewBuilder().
+		LD(filter.Byte, filter.ABS, 8).
+		JEQ(filter.Const, "", "fail", 0x42).
+		RET(filter.Const, 0xffff).
+		Label("fail").
+		RET(filter.Const, 0x0).
+		Build()
+
+	prog, err := ebpf.Translate(flt)
+	if err != nil {
+		t.Fatalf("Error translating: %s", err)
+	}
+
+	prog_fd, err := prog.Load()
+	if err != nil {
+		t.Skipf("Could not load: %s", err)
+	}
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("Could not listen: %s", err)
+	}
+	defer conn.Close()
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatalf("Error getting raw conn: %s", err)
+	}
+
+	err = ebpf.Attach(raw, prog_fd)
+	if err != nil {
+		t.Fatalf("Error attaching: %s", err)
+	}
+
+	out, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("Error dialing: %s", err)
+	}
+	defer out.Close()
+
+	out.Write([]byte{0x41})
+	out.Write([]byte{0x42})
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+
+	buf := make([]byte, 64)
+
+	n, err := conn.Read(buf)
+	if err != nil || n != 1 || buf[0] != 0x42 {
+		t.Fatalf("Filtered packet mismatch: %x", buf[:n])
+	}
+}
@@ -0,0 +1,364 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ebpf
+
+import "encoding/binary"
+import "fmt"
+
+/* base addresses of the memory regions available to the emulated program */
+const (
+	ctx_addr   = 0x10000000
+	ctx_size   = 192 /* sizeof(struct __sk_buff) */
+	stack_addr = 0x20000000
+	stack_size = 512
+)
+
+/* maximum number of instructions executed before giving up */
+const max_steps = 1 << 20
+
+type vm struct {
+	regs  [11]uint64
+	ctx   [ctx_size]byte
+	stack [stack_size]byte
+	pkt   []byte
+}
+
+// Run the program on the given packet data with an eBPF emulator, as if it was
+// attached to a socket as socket filter, and return its result. An error is
+// returned if the program performs an operation that the emulator doesn't
+// support (e.g. calling helper functions) or an invalid memory access.
+func (p Program) Run(buf []byte) (uint32, error) {
+	m := &vm{pkt: buf}
+
+	/* the only __sk_buff field supported is len */
+	binary.LittleEndian.PutUint32(m.ctx[0:], uint32(len(buf)))
+
+	m.regs[reg_ctx] = ctx_addr
+	m.regs[reg_fp] = stack_addr + stack_size
+
+	steps := 0
+
+	for pc := 0; pc < len(p); pc++ {
+		steps++
+		if steps > max_steps {
+			return 0, fmt.Errorf("Too many instructions executed")
+		}
+
+		insn := p[pc]
+
+		if insn.Dst > reg_fp || insn.Src > reg_fp {
+			return 0, fmt.Errorf("Invalid register at %d", pc)
+		}
+
+		switch insn.Op & 0x07 {
+		case class_alu, class_alu64:
+			err := m.alu(insn)
+			if err != nil {
+				return 0, fmt.Errorf("%s at %d", err, pc)
+			}
+
+		case class_jmp, class_jmp32:
+			switch insn.Op & 0xf0 {
+			case jmp_exit:
+				return uint32(m.regs[reg_a]), nil
+
+			case jmp_call:
+				return 0, fmt.Errorf("Unsupported call at %d", pc)
+			}
+
+			if m.cond(insn) {
+				pc += int(insn.Off)
+			}
+
+			if pc+1 < 0 || pc+1 > len(p) {
+				return 0, fmt.Errorf("Invalid jump at %d", pc)
+			}
+
+		case class_ld:
+			switch insn.Op & 0xe0 {
+			case mode_imm:
+				if insn.Op&0x18 != size_dw || pc+1 >= len(p) {
+					return 0, fmt.Errorf("Invalid load at %d", pc)
+				}
+
+				pc++
+				m.regs[insn.Dst] =
+					uint64(uint32(insn.Imm)) |
+						uint64(uint32(p[pc].Imm))<<32
+
+			case mode_abs, mode_ind:
+				off := uint64(uint32(insn.Imm))
+				if insn.Op&0xe0 == mode_ind {
+					off = uint64(uint32(m.regs[insn.Src] + off))
+				}
+
+				val, ok := m.load_pkt(off, size_bytes(insn.Op))
+				if !ok {
+					/* out of bounds packet loads terminate the
+					 * program returning 0 */
+					return 0, nil
+				}
+
+				m.regs[reg_a] = val
+
+				/* R1-R5 are clobbered like by helper calls */
+				for r := 1; r <= 5; r++ {
+					m.regs[r] = 0
+				}
+
+			default:
+				return 0, fmt.Errorf("Invalid load at %d", pc)
+			}
+
+		case class_ldx:
+			addr := m.regs[insn.Src] + uint64(int64(insn.Off))
+
+			val, err := m.load(addr, size_bytes(insn.Op))
+			if err != nil {
+				return 0, fmt.Errorf("%s at %d", err, pc)
+			}
+
+			m.regs[insn.Dst] = val
+
+		case class_st, class_stx:
+			addr := m.regs[insn.Dst] + uint64(int64(insn.Off))
+
+			val := uint64(int64(insn.Imm))
+			if insn.Op&0x07 == class_stx {
+				val = m.regs[insn.Src]
+			}
+
+			err := m.store(addr, size_bytes(insn.Op), val)
+			if err != nil {
+				return 0, fmt.Errorf("%s at %d", err, pc)
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("Program terminated without exit")
+}
+
+func size_bytes(op uint8) int {
+	switch op & 0x18 {
+	case size_b:
+		return 1
+	case size_h:
+		return 2
+	case size_dw:
+		return 8
+	default:
+		return 4
+	}
+}
+
+func (m *vm) alu(insn Instruction) error {
+	is64 := insn.Op&0x07 == class_alu64
+
+	dst := m.regs[insn.Dst]
+
+	src := uint64(int64(insn.Imm))
+	if insn.Op&0x08 == src_x {
+		src = m.regs[insn.Src]
+	}
+
+	if !is64 {
+		dst = uint64(uint32(dst))
+		src = uint64(uint32(src))
+	}
+
+	shift_mask := uint64(31)
+	if is64 {
+		shift_mask = 63
+	}
+
+	switch insn.Op & 0xf0 {
+	case alu_add:
+		dst += src
+	case alu_sub:
+		dst -= src
+	case alu_mul:
+		dst *= src
+	case alu_div:
+		if src == 0 {
+			dst = 0
+		} else {
+			dst /= src
+		}
+	case alu_or:
+		dst |= src
+	case alu_and:
+		dst &= src
+	case alu_lsh:
+		dst <<= src & shift_mask
+	case alu_rsh:
+		dst >>= src & shift_mask
+	case alu_neg:
+		dst = -dst
+	case alu_mod:
+		if src != 0 {
+			dst %= src
+		}
+	case alu_xor:
+		dst ^= src
+	case alu_mov:
+		dst = src
+	case alu_arsh:
+		if is64 {
+			dst = uint64(int64(dst) >> (src & shift_mask))
+		} else {
+			dst = uint64(uint32(int32(dst) >> (src & shift_mask)))
+		}
+	default:
+		return fmt.Errorf("Unsupported ALU operation 0x%x", insn.Op)
+	}
+
+	if !is64 {
+		dst = uint64(uint32(dst))
+	}
+
+	m.regs[insn.Dst] = dst
+
+	return nil
+}
+
+func (m *vm) cond(insn Instruction) bool {
+	dst := m.regs[insn.Dst]
+
+	src := uint64(int64(insn.Imm))
+	if insn.Op&0x08 == src_x {
+		src = m.regs[insn.Src]
+	}
+
+	sdst := int64(dst)
+	ssrc := int64(src)
+
+	if insn.Op&0x07 == class_jmp32 {
+		dst = uint64(uint32(dst))
+		src = uint64(uint32(src))
+		sdst = int64(int32(dst))
+		ssrc = int64(int32(src))
+	}
+
+	switch insn.Op & 0xf0 {
+	case jmp_ja:
+		return true
+	case jmp_jeq:
+		return dst == src
+	case jmp_jgt:
+		return dst > src
+	case jmp_jge:
+		return dst >= src
+	case jmp_jset:
+		return dst&src != 0
+	case jmp_jne:
+		return dst != src
+	case jmp_jsgt:
+		return sdst > ssrc
+	case jmp_jsge:
+		return sdst >= ssrc
+	case jmp_jlt:
+		return dst < src
+	case jmp_jle:
+		return dst <= src
+	case jmp_jslt:
+		return sdst < ssrc
+	case jmp_jsle:
+		return sdst <= ssrc
+	}
+
+	return false
+}
+
+/* Load a big endian value from the packet data */
+func (m *vm) load_pkt(off uint64, size int) (uint64, bool) {
+	if off > uint64(len(m.pkt)) || uint64(size) > uint64(len(m.pkt))-off {
+		return 0, false
+	}
+
+	var val uint64
+
+	for _, b := range m.pkt[off : off+uint64(size)] {
+		val = val<<8 | uint64(b)
+	}
+
+	return val, true
+}
+
+/* Return the slice of emulated memory at the given address */
+func (m *vm) mem(addr uint64, size int) ([]byte, error) {
+	switch {
+	case addr >= ctx_addr && addr+uint64(size) <= ctx_addr+ctx_size:
+		return m.ctx[addr-ctx_addr:][:size], nil
+
+	case addr >= stack_addr && addr+uint64(size) <= stack_addr+stack_size:
+		return m.stack[addr-stack_addr:][:size], nil
+	}
+
+	return nil, fmt.Errorf("Invalid memory access 0x%x", addr)
+}
+
+func (m *vm) load(addr uint64, size int) (uint64, error) {
+	b, err := m.mem(addr, size)
+	if err != nil {
+		return 0, err
+	}
+
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(b)), nil
+	default:
+		return binary.LittleEndian.Uint64(b), nil
+	}
+}
+
+func (m *vm) store(addr uint64, size int, val uint64) error {
+	b, err := m.mem(addr, size)
+	if err != nil {
+		return err
+	}
+
+	switch size {
+	case 1:
+		b[0] = uint8(val)
+	case 2:
+		binary.LittleEndian.PutUint16(b, uint16(val))
+	case 4:
+		binary.LittleEndian.PutUint32(b, uint32(val))
+	default:
+		binary.LittleEndian.PutUint64(b, val)
+	}
+
+	return nil
+}
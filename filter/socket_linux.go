@@ -0,0 +1,139 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package filter
+
+import "fmt"
+import "runtime"
+import "syscall"
+import "unsafe"
+
+/* not defined by the syscall package */
+const so_lock_filter = 44
+
+// Attach the filter to the socket identified by the given file descriptor, so
+// that only packets matching the filter will be received on it. This works on
+// ordinary sockets (e.g. raw or UDP) as well as packet sockets. The packet data
+// seen by the filter starts at the header of the protocol of the socket (e.g.
+// the UDP payload for UDP sockets).
+func (f *Filter) AttachFd(fd int) error {
+    insns := f.Instructions()
+    if len(insns) == 0 {
+        return fmt.Errorf("Empty filter")
+    }
+
+    if !f.Validate() {
+        return fmt.Errorf("Invalid filter")
+    }
+
+    prog := make([]syscall.SockFilter, len(insns))
+
+    for i, insn := range insns {
+        prog[i] = syscall.SockFilter{
+            Code: uint16(insn.Code),
+            Jt:   insn.Jt,
+            Jf:   insn.Jf,
+            K:    insn.K,
+        }
+    }
+
+    fprog := syscall.SockFprog{
+        Len:    uint16(len(prog)),
+        Filter: &prog[0],
+    }
+
+    _, _, errno := syscall.Syscall6(
+        syscall.SYS_SETSOCKOPT, uintptr(fd),
+        syscall.SOL_SOCKET, syscall.SO_ATTACH_FILTER,
+        uintptr(unsafe.Pointer(&fprog)), unsafe.Sizeof(fprog), 0,
+    )
+
+    runtime.KeepAlive(prog)
+
+    if errno != 0 {
+        return fmt.Errorf("Could not attach filter: %s", errno)
+    }
+
+    return nil
+}
+
+// Like AttachFd(), but take the socket from the given connection (see the
+// SyscallConn() method of the net package's connections).
+func (f *Filter) Attach(conn syscall.RawConn) error {
+    return control(conn, f.AttachFd)
+}
+
+// Detach the filter attached to the socket identified by the given file
+// descriptor.
+func DetachFd(fd int) error {
+    err := syscall.SetsockoptInt(
+        fd, syscall.SOL_SOCKET, syscall.SO_DETACH_FILTER, 0,
+    )
+    if err != nil {
+        return fmt.Errorf("Could not detach filter: %s", err)
+    }
+
+    return nil
+}
+
+// Like DetachFd(), but take the socket from the given connection.
+func Detach(conn syscall.RawConn) error {
+    return control(conn, DetachFd)
+}
+
+// Lock the filter attached to the socket identified by the given file
+// descriptor, so that it can't be replaced or detached anymore (not even by
+// privileged processes).
+func LockFd(fd int) error {
+    err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, so_lock_filter, 1)
+    if err != nil {
+        return fmt.Errorf("Could not lock filter: %s", err)
+    }
+
+    return nil
+}
+
+// Like LockFd(), but take the socket from the given connection.
+func Lock(conn syscall.RawConn) error {
+    return control(conn, LockFd)
+}
+
+func control(conn syscall.RawConn, fn func(fd int) error) error {
+    var fn_err error
+
+    err := conn.Control(func(fd uintptr) {
+        fn_err = fn(int(fd))
+    })
+    if err != nil {
+        return err
+    }
+
+    return fn_err
+}
@@ -0,0 +1,138 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package filter_test
+
+import "net"
+import "testing"
+import "time"
+
+import "github.com/scs-solution/go.pkt2/filter"
+
+func listen_udp(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Skipf("Could not listen: %s", err)
+	}
+
+	return conn
+}
+
+/* Send the given payload to the connection and check whether it's received */
+func recv_udp(t *testing.T, conn *net.UDPConn, payload []byte) bool {
+	out, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatalf("Error dialing: %s", err)
+	}
+	defer out.Close()
+
+	_, err = out.Write(payload)
+	if err != nil {
+		t.Fatalf("Error sending: %s", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+
+	buf := make([]byte, 64)
+
+	_, err = conn.Read(buf)
+	return err == nil
+}
+
+func TestAttach(t *testing.T) {
+	conn := listen_udp(t)
+	defer conn.Close()
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatalf("Error getting raw conn: %s", err)
+	}
+
+	/* UDP socket filters see the UDP header first, so this only accepts
+	 * datagrams whose payload starts with 0x42 */
+	flt := filter.NewBuilder().
+		LD(filter.Byte, filter.ABS, 8).
+		JEQ(filter.Const, "", "fail", 0x42).
+		RET(filter.Const, 0xffff).
+		Label("fail").
+		RET(filter.Const, 0x0).
+		Build()
+	defer flt.Cleanup()
+
+	err = flt.Attach(raw)
+	if err != nil {
+		t.Fatalf("Error attaching: %s", err)
+	}
+
+	if recv_udp(t, conn, []byte{0x41}) {
+		t.Fatalf("Filtered packet received")
+	}
+
+	if !recv_udp(t, conn, []byte{0x42}) {
+		t.Fatalf("Packet not received")
+	}
+
+	err = filter.Detach(raw)
+	if err != nil {
+		t.Fatalf("Error detaching: %s", err)
+	}
+
+	if !recv_udp(t, conn, []byte{0x41}) {
+		t.Fatalf("Packet not received after detaching")
+	}
+}
+
+func TestLock(t *testing.T) {
+	conn := listen_udp(t)
+	defer conn.Close()
+
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		t.Fatalf("Error getting raw conn: %s", err)
+	}
+
+	flt := filter.NewBuilder().RET(filter.Const, 0x0).Build()
+	defer flt.Cleanup()
+
+	err = flt.Attach(raw)
+	if err != nil {
+		t.Fatalf("Error attaching: %s", err)
+	}
+
+	err = filter.Lock(raw)
+	if err != nil {
+		t.Fatalf("Error locking: %s", err)
+	}
+
+	err = filter.Detach(raw)
+	if err == nil {
+		t.Fatalf("Locked filter detached")
+	}
+}
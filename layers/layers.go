@@ -36,19 +36,39 @@ package layers
 import "github.com/scs-solution/go.pkt2/packet"
 
 import "github.com/scs-solution/go.pkt2/packet/arp"
+import "github.com/scs-solution/go.pkt2/packet/att"
+import "github.com/scs-solution/go.pkt2/packet/bluetooth"
+import "github.com/scs-solution/go.pkt2/packet/btle"
+import "github.com/scs-solution/go.pkt2/packet/cdp"
+import "github.com/scs-solution/go.pkt2/packet/dns"
+import "github.com/scs-solution/go.pkt2/packet/erspan"
 import "github.com/scs-solution/go.pkt2/packet/eth"
+import "github.com/scs-solution/go.pkt2/packet/gre"
 import "github.com/scs-solution/go.pkt2/packet/icmpv4"
 import "github.com/scs-solution/go.pkt2/packet/icmpv6"
+import "github.com/scs-solution/go.pkt2/packet/igmp"
+import "github.com/scs-solution/go.pkt2/packet/ipsec"
 import "github.com/scs-solution/go.pkt2/packet/ipv4"
 import "github.com/scs-solution/go.pkt2/packet/ipv6"
+import "github.com/scs-solution/go.pkt2/packet/isis"
+import "github.com/scs-solution/go.pkt2/packet/l2cap"
+import "github.com/scs-solution/go.pkt2/packet/l2tp"
 import "github.com/scs-solution/go.pkt2/packet/llc"
+import "github.com/scs-solution/go.pkt2/packet/lldp"
+import "github.com/scs-solution/go.pkt2/packet/ospf"
+import "github.com/scs-solution/go.pkt2/packet/ppp"
 import "github.com/scs-solution/go.pkt2/packet/radiotap"
 import "github.com/scs-solution/go.pkt2/packet/raw"
+import "github.com/scs-solution/go.pkt2/packet/sctp"
 import "github.com/scs-solution/go.pkt2/packet/sll"
 import "github.com/scs-solution/go.pkt2/packet/snap"
 import "github.com/scs-solution/go.pkt2/packet/tcp"
+import "github.com/scs-solution/go.pkt2/packet/trill"
 import "github.com/scs-solution/go.pkt2/packet/udp"
+import "github.com/scs-solution/go.pkt2/packet/udplite"
 import "github.com/scs-solution/go.pkt2/packet/vlan"
+import "github.com/scs-solution/go.pkt2/packet/wifi"
+import "github.com/scs-solution/go.pkt2/packet/wol"
 
 // Compose packets into a chain and update their values (e.g. length, payload
 // protocol) accordingly.
@@ -166,30 +186,89 @@ func UnpackAll(buf []byte, link_type packet.Type) (packet.Packet, error) {
 		switch link_type {
 		case packet.ARP:
 			p = &arp.Packet{}
+		case packet.ATT:
+			p = &att.Packet{}
+		case packet.BTLE:
+			p = &btle.Packet{}
+		case packet.Bluetooth:
+			p = &bluetooth.Packet{}
+		case packet.CDP:
+			p = &cdp.Packet{}
+		case packet.DNS:
+			/* DNS messages over TCP are prefixed by their length */
+			p = &dns.Packet{
+				TCP: prev_pkt != nil && prev_pkt.GetType() == packet.TCP,
+			}
+		case packet.DestOpts:
+			p = &ipv6.DestOpts{}
+		case packet.ERSPAN:
+			p = &erspan.Packet{}
 		case packet.Eth:
 			p = &eth.Packet{}
+		case packet.Fragment:
+			p = &ipv6.Fragment{}
+		case packet.GRE:
+			p = &gre.Packet{}
+		case packet.HopByHop:
+			p = &ipv6.HopByHop{}
 		case packet.ICMPv4:
 			p = &icmpv4.Packet{}
 		case packet.ICMPv6:
 			p = &icmpv6.Packet{}
+		case packet.IGMP:
+			p = &igmp.Packet{}
+		case packet.IPSecAH:
+			p = &ipsec.AH{IPv6: prev_pkt != nil && is_ipv6(prev_pkt)}
+		case packet.IPSecESP:
+			p = &ipsec.ESP{}
 		case packet.IPv4:
 			p = &ipv4.Packet{}
 		case packet.IPv6:
 			p = &ipv6.Packet{}
+		case packet.ISIS:
+			p = &isis.Packet{}
+		case packet.L2CAP:
+			p = &l2cap.Packet{}
+		case packet.L2TP:
+			p = &l2tp.Packet{}
+		case packet.L2TPv3:
+			p = &l2tp.Packet{IP: true}
 		case packet.LLC:
 			p = &llc.Packet{}
+		case packet.LLDP:
+			p = &lldp.Packet{}
+		case packet.Mobility:
+			p = &ipv6.Mobility{}
+		case packet.OSPF:
+			p = &ospf.Packet{}
+		case packet.PPP:
+			p = &ppp.Packet{}
 		case packet.RadioTap:
 			p = &radiotap.Packet{}
+		case packet.Routing:
+			p = &ipv6.Routing{}
+		case packet.SCTP:
+			p = &sctp.Packet{}
 		case packet.SLL:
 			p = &sll.Packet{}
 		case packet.SNAP:
 			p = &snap.Packet{}
 		case packet.TCP:
 			p = &tcp.Packet{}
+		case packet.TRILL:
+			p = &trill.Packet{}
 		case packet.UDP:
 			p = &udp.Packet{}
+		case packet.UDPLite:
+			p = &udplite.Packet{}
 		case packet.VLAN:
 			p = &vlan.Packet{}
+		case packet.WiFi:
+			/* the radiotap header tells whether the FCS is present */
+			rt, ok := prev_pkt.(*radiotap.Packet)
+			p = &wifi.Packet{HasFCS: ok && rt.HasFCS()}
+		case packet.WoL:
+			p = &wol.Packet{}
 		default:
 			p = &raw.Packet{}
 		}
@@ -218,6 +297,17 @@ func UnpackAll(buf []byte, link_type packet.Type) (packet.Packet, error) {
 	return first_pkt, nil
 }
 
+/* Check whether the layer is an IPv6 header or extension header */
+func is_ipv6(p packet.Packet) bool {
+	switch p.GetType() {
+	case packet.IPv6, packet.HopByHop, packet.DestOpts, packet.Routing,
+		packet.Fragment:
+		return true
+	}
+
+	return false
+}
+
 // Return the first layer of the given type in the packet. If no suitable layer
 // is found, return nil.
 func FindLayer(p packet.Packet, layer packet.Type) packet.Packet {
@@ -38,12 +38,28 @@ import "testing"
 import "github.com/scs-solution/go.pkt2/layers"
 import "github.com/scs-solution/go.pkt2/packet"
 import "github.com/scs-solution/go.pkt2/packet/arp"
+import "github.com/scs-solution/go.pkt2/packet/att"
+import "github.com/scs-solution/go.pkt2/packet/bluetooth"
+import "github.com/scs-solution/go.pkt2/packet/btle"
+import "github.com/scs-solution/go.pkt2/packet/cdp"
+import "github.com/scs-solution/go.pkt2/packet/dns"
 import "github.com/scs-solution/go.pkt2/packet/eth"
+import "github.com/scs-solution/go.pkt2/packet/gre"
 import "github.com/scs-solution/go.pkt2/packet/ipv4"
+import "github.com/scs-solution/go.pkt2/packet/ipv6"
+import "github.com/scs-solution/go.pkt2/packet/isis"
+import "github.com/scs-solution/go.pkt2/packet/l2cap"
+import "github.com/scs-solution/go.pkt2/packet/l2tp"
+import "github.com/scs-solution/go.pkt2/packet/llc"
+import "github.com/scs-solution/go.pkt2/packet/lldp"
+import "github.com/scs-solution/go.pkt2/packet/ppp"
 import "github.com/scs-solution/go.pkt2/packet/raw"
+import "github.com/scs-solution/go.pkt2/packet/sctp"
 import "github.com/scs-solution/go.pkt2/packet/udp"
 import "github.com/scs-solution/go.pkt2/packet/tcp"
+import "github.com/scs-solution/go.pkt2/packet/trill"
 import "github.com/scs-solution/go.pkt2/packet/vlan"
+import "github.com/scs-solution/go.pkt2/packet/wol"
 
 var hwsrc_str = "4c:72:b9:54:e5:3d"
 var hwdst_str = "00:21:96:6e:f0:70"
@@ -577,6 +593,503 @@ func TestFindLayer(t *testing.T) {
 	}
 }
 
+var test_eth_ipv4_gre_ipv4_udp = []byte{
+	0x00, 0x21, 0x96, 0x6e, 0xf0, 0x70, 0x4c, 0x72, 0xb9, 0x54, 0xe5, 0x3d,
+	0x08, 0x00, 0x45, 0x00, 0x00, 0x38, 0x00, 0x01, 0x00, 0x00, 0x40, 0x2f,
+	0x27, 0x26, 0xc0, 0xa8, 0x01, 0x87, 0xc1, 0x1b, 0xd0, 0x25, 0x20, 0x00,
+	0x08, 0x00, 0x00, 0x00, 0x00, 0x2a, 0x45, 0x00, 0x00, 0x1c, 0x00, 0x01,
+	0x00, 0x00, 0x40, 0x11, 0x66, 0xce, 0x0a, 0x00, 0x00, 0x01, 0x0a, 0x00,
+	0x00, 0x02, 0xa2, 0x5a, 0x20, 0x92, 0x00, 0x08, 0x28, 0xef,
+}
+
+func TestPackEthIPv4GREIPv4UDP(t *testing.T) {
+	eth_pkt := eth.Make()
+	eth_pkt.SrcAddr, _ = net.ParseMAC(hwsrc_str)
+	eth_pkt.DstAddr, _ = net.ParseMAC(hwdst_str)
+
+	ip4_pkt := ipv4.Make()
+	ip4_pkt.SrcAddr = net.ParseIP(ipsrc_str)
+	ip4_pkt.DstAddr = net.ParseIP(ipdst_str)
+
+	gre_pkt := gre.Make()
+	gre_pkt.Flags = gre.KeyPresent
+	gre_pkt.Key = 42
+
+	inner_pkt := ipv4.Make()
+	inner_pkt.SrcAddr = net.ParseIP("10.0.0.1")
+	inner_pkt.DstAddr = net.ParseIP("10.0.0.2")
+
+	udp_pkt := udp.Make()
+	udp_pkt.SrcPort = 41562
+	udp_pkt.DstPort = 8338
+
+	buf, err := layers.Pack(eth_pkt, ip4_pkt, gre_pkt, inner_pkt, udp_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_eth_ipv4_gre_ipv4_udp, buf) {
+		t.Fatalf("Raw packet mismatch: %x", buf)
+	}
+}
+
+func TestUnpackAllEthIPv4GREIPv4UDP(t *testing.T) {
+	pkt, err := layers.UnpackAll(test_eth_ipv4_gre_ipv4_udp, packet.Eth)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	types := []packet.Type{
+		packet.Eth, packet.IPv4, packet.GRE, packet.IPv4, packet.UDP,
+	}
+
+	for _, typ := range types {
+		if pkt == nil || pkt.GetType() != typ {
+			t.Fatalf("Packet type mismatch, %s", pkt)
+		}
+
+		pkt = pkt.Payload()
+	}
+}
+
+func TestUnpackAllEthIPv4SCTP(t *testing.T) {
+	eth_pkt := eth.Make()
+	eth_pkt.SrcAddr, _ = net.ParseMAC(hwsrc_str)
+	eth_pkt.DstAddr, _ = net.ParseMAC(hwdst_str)
+
+	ip4_pkt := ipv4.Make()
+	ip4_pkt.SrcAddr = net.ParseIP(ipsrc_str)
+	ip4_pkt.DstAddr = net.ParseIP(ipdst_str)
+
+	sctp_pkt := sctp.Make()
+	sctp_pkt.SrcPort = 3868
+	sctp_pkt.DstPort = 3868
+	sctp_pkt.Chunks = sctp.Chunks{&sctp.CookieAckChunk{}}
+
+	buf, err := layers.Pack(eth_pkt, ip4_pkt, sctp_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	pkt, err := layers.UnpackAll(buf, packet.Eth)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	pkt = layers.FindLayer(pkt, packet.SCTP)
+	if pkt == nil || !pkt.Equals(sctp_pkt) {
+		t.Fatalf("Packet mismatch: %s", pkt)
+	}
+
+	if pkt.(*sctp.Packet).FindChunk(sctp.CookieAck) == nil {
+		t.Fatalf("Chunk mismatch: %s", pkt)
+	}
+}
+
+var test_eth_llc_snap_cdp = []byte{
+	0x01, 0x00, 0x0c, 0xcc, 0xcc, 0xcc, 0x4c, 0x72, 0xb9, 0x54, 0xe5, 0x3d,
+	0x00, 0x43, 0xaa, 0xaa, 0x03, 0x00, 0x00, 0x0c, 0x20, 0x00, 0x02, 0xb4,
+	0xa7, 0xcf, 0x00, 0x01, 0x00, 0x07, 0x73, 0x77, 0x31, 0x00, 0x02, 0x00,
+	0x11, 0x00, 0x00, 0x00, 0x01, 0x01, 0x01, 0xcc, 0x00, 0x04, 0x0a, 0x00,
+	0x00, 0x01, 0x00, 0x03, 0x00, 0x09, 0x47, 0x69, 0x30, 0x2f, 0x31, 0x00,
+	0x04, 0x00, 0x08, 0x00, 0x00, 0x00, 0x28, 0x00, 0x06, 0x00, 0x08, 0x57,
+	0x53, 0x2d, 0x43, 0x00, 0x0a, 0x00, 0x06, 0x00, 0x01,
+}
+
+func TestUnpackAllEthLLCSNAPCDP(t *testing.T) {
+	pkt, err := layers.UnpackAll(test_eth_llc_snap_cdp, packet.Eth)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	pkt = layers.FindLayer(pkt, packet.CDP)
+	if pkt == nil {
+		t.Fatalf("Packet type mismatch, %s", pkt)
+	}
+
+	if pkt.(*cdp.Packet).DeviceID != "sw1" {
+		t.Fatalf("Packet mismatch: %s", pkt)
+	}
+}
+
+func TestUnpackAllEthLLDP(t *testing.T) {
+	eth_pkt := eth.Make()
+	eth_pkt.SrcAddr, _ = net.ParseMAC(hwsrc_str)
+	eth_pkt.DstAddr = lldp.MulticastAddr
+
+	lldp_pkt := lldp.Make()
+	lldp_pkt.ChassisType = lldp.ChassisMACAddr
+	lldp_pkt.ChassisID = eth_pkt.SrcAddr
+	lldp_pkt.PortType = lldp.PortIfaceName
+	lldp_pkt.PortID = []byte("eth0")
+	lldp_pkt.SysName = "host"
+
+	buf, err := layers.Pack(eth_pkt, lldp_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	pkt, err := layers.UnpackAll(buf, packet.Eth)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	pkt = layers.FindLayer(pkt, packet.LLDP)
+	if pkt == nil || !pkt.Equals(lldp_pkt) {
+		t.Fatalf("Packet mismatch: %s", pkt)
+	}
+}
+
+var test_radiotap_wifi_llc_snap_ipv4_udp = []byte{
+	0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, 0x02, 0x00, 0x00,
+	0x4c, 0x72, 0xb9, 0x54, 0xe5, 0x3d, 0x00, 0x21, 0x96, 0x6e, 0xf0, 0x70,
+	0x00, 0x21, 0x96, 0x6e, 0xf0, 0x70, 0x10, 0x00, 0xaa, 0xaa, 0x03, 0x00,
+	0x00, 0x00, 0x08, 0x00, 0x45, 0x00, 0x00, 0x1c, 0x00, 0x01, 0x00, 0x00,
+	0x40, 0x11, 0x27, 0x60, 0xc0, 0xa8, 0x01, 0x87, 0xc1, 0x1b, 0xd0, 0x25,
+	0xa2, 0x5a, 0x20, 0x92, 0x00, 0x08, 0xe9, 0x80,
+}
+
+func TestUnpackAllRadioTapWiFiIPv4UDP(t *testing.T) {
+	pkt, err := layers.UnpackAll(test_radiotap_wifi_llc_snap_ipv4_udp,
+		packet.RadioTap)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	types := []packet.Type{
+		packet.RadioTap, packet.WiFi, packet.LLC, packet.SNAP,
+		packet.IPv4, packet.UDP,
+	}
+
+	for _, typ := range types {
+		if pkt == nil || pkt.GetType() != typ {
+			t.Fatalf("Packet type mismatch, %s", pkt)
+		}
+
+		pkt = pkt.Payload()
+	}
+}
+
+func TestUnpackAllEthIPv4UDPL2TPPPP(t *testing.T) {
+	eth_pkt := eth.Make()
+	eth_pkt.SrcAddr, _ = net.ParseMAC(hwsrc_str)
+	eth_pkt.DstAddr, _ = net.ParseMAC(hwdst_str)
+
+	ip4_pkt := ipv4.Make()
+	ip4_pkt.SrcAddr = net.ParseIP(ipsrc_str)
+	ip4_pkt.DstAddr = net.ParseIP(ipdst_str)
+
+	udp_pkt := udp.Make()
+	udp_pkt.SrcPort = 1701
+	udp_pkt.DstPort = 1701
+
+	l2tp_pkt := l2tp.Make()
+	l2tp_pkt.Flags = l2tp.LengthPresent
+	l2tp_pkt.TunnelID = 1
+	l2tp_pkt.SessionID = 2
+
+	ppp_pkt := ppp.Make()
+
+	inner_pkt := ipv4.Make()
+	inner_pkt.SrcAddr = net.ParseIP("10.0.0.1")
+	inner_pkt.DstAddr = net.ParseIP("10.0.0.2")
+
+	buf, err := layers.Pack(eth_pkt, ip4_pkt, udp_pkt, l2tp_pkt, ppp_pkt,
+		inner_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	pkt, err := layers.UnpackAll(buf, packet.Eth)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	types := []packet.Type{
+		packet.Eth, packet.IPv4, packet.UDP, packet.L2TP, packet.PPP,
+		packet.IPv4,
+	}
+
+	for _, typ := range types {
+		if pkt == nil || pkt.GetType() != typ {
+			t.Fatalf("Packet type mismatch, %s", pkt)
+		}
+
+		pkt = pkt.Payload()
+	}
+
+	if l2tp_pkt.Length != 32 {
+		t.Fatalf("Length mismatch: %d", l2tp_pkt.Length)
+	}
+}
+
+func TestUnpackEthIPv4L2TPv3Eth(t *testing.T) {
+	eth_pkt := eth.Make()
+	eth_pkt.SrcAddr, _ = net.ParseMAC(hwsrc_str)
+	eth_pkt.DstAddr, _ = net.ParseMAC(hwdst_str)
+
+	ip4_pkt := ipv4.Make()
+	ip4_pkt.SrcAddr = net.ParseIP(ipsrc_str)
+	ip4_pkt.DstAddr = net.ParseIP(ipdst_str)
+
+	l2tp_pkt := l2tp.MakeIP(0x1234, []byte{0xca, 0xfe, 0xca, 0xfe})
+
+	inner_pkt := eth.Make()
+	inner_pkt.SrcAddr, _ = net.ParseMAC(hwdst_str)
+	inner_pkt.DstAddr, _ = net.ParseMAC(hwsrc_str)
+
+	inner_ip4_pkt := ipv4.Make()
+	inner_ip4_pkt.SrcAddr = net.ParseIP("10.0.0.1")
+	inner_ip4_pkt.DstAddr = net.ParseIP("10.0.0.2")
+
+	buf, err := layers.Pack(eth_pkt, ip4_pkt, l2tp_pkt, inner_pkt,
+		inner_ip4_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	/* the cookie length is negotiated out-of-band */
+	pkt, err := layers.Unpack(buf, &eth.Packet{}, &ipv4.Packet{},
+		&l2tp.Packet{IP: true, CookieLen: 4}, &eth.Packet{},
+		&ipv4.Packet{})
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	pkt = layers.FindLayer(pkt, packet.L2TPv3)
+	if pkt == nil || !pkt.Equals(l2tp_pkt) {
+		t.Fatalf("Packet mismatch: %s", pkt)
+	}
+
+	if layers.FindLayer(pkt, packet.Eth) == nil {
+		t.Fatalf("Packet type mismatch, %s", pkt)
+	}
+}
+
+func TestUnpackAllEthLLCISIS(t *testing.T) {
+	eth_pkt := eth.Make()
+	eth_pkt.SrcAddr, _ = net.ParseMAC(hwsrc_str)
+	eth_pkt.DstAddr = isis.AllL2ISs
+
+	llc_pkt := llc.Make()
+
+	isis_pkt := isis.Make()
+	isis_pkt.Type = isis.L2LANHello
+	isis_pkt.Body = isis.MakeHello(isis.Level2, [6]byte{0, 0, 0, 0, 0, 1})
+	isis_pkt.TLVs = []isis.TLV{
+		isis.MakeAreaAddresses([]byte{0x49, 0x00, 0x01}),
+		isis.MakeProtocols(isis.NLPIDIPv4),
+	}
+
+	buf, err := layers.Pack(eth_pkt, llc_pkt, isis_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	pkt, err := layers.UnpackAll(buf, packet.Eth)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	types := []packet.Type{packet.Eth, packet.LLC, packet.ISIS}
+
+	for _, typ := range types {
+		if pkt == nil || pkt.GetType() != typ {
+			t.Fatalf("Packet type mismatch, %s", pkt)
+		}
+
+		if typ == packet.ISIS && !pkt.Equals(isis_pkt) {
+			t.Fatalf("Packet mismatch: %s", pkt)
+		}
+
+		pkt = pkt.Payload()
+	}
+}
+
+func TestPackEthQinQIPv4UDP(t *testing.T) {
+	eth_pkt := eth.Make()
+	eth_pkt.SrcAddr, _ = net.ParseMAC(hwsrc_str)
+	eth_pkt.DstAddr, _ = net.ParseMAC(hwdst_str)
+
+	outer_pkt := vlan.Make()
+	outer_pkt.VLAN = 100
+
+	inner_pkt := vlan.Make()
+	inner_pkt.VLAN = 200
+
+	ip4_pkt := ipv4.Make()
+	ip4_pkt.SrcAddr = net.ParseIP(ipsrc_str)
+	ip4_pkt.DstAddr = net.ParseIP(ipdst_str)
+
+	udp_pkt := udp.Make()
+	udp_pkt.SrcPort = 41562
+	udp_pkt.DstPort = 8338
+
+	buf, err := layers.Pack(eth_pkt, outer_pkt, inner_pkt, ip4_pkt, udp_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	tpids := []byte{0x88, 0xa8, 0x00, 0x64, 0x81, 0x00, 0x00, 0xc8, 0x08, 0x00}
+	if !bytes.Equal(tpids, buf[12:22]) {
+		t.Fatalf("Raw packet mismatch: %x", buf)
+	}
+
+	pkt, err := layers.UnpackAll(buf, packet.Eth)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if pkt.(*eth.Packet).Type != eth.QinQ {
+		t.Fatalf("Packet mismatch: %s", pkt)
+	}
+
+	types := []packet.Type{
+		packet.Eth, packet.VLAN, packet.VLAN, packet.IPv4, packet.UDP,
+	}
+
+	for _, typ := range types {
+		if pkt == nil || pkt.GetType() != typ {
+			t.Fatalf("Packet type mismatch, %s", pkt)
+		}
+
+		pkt = pkt.Payload()
+	}
+}
+
+func TestUnpackAllEthTRILLEth(t *testing.T) {
+	eth_pkt := eth.Make()
+	eth_pkt.SrcAddr, _ = net.ParseMAC(hwsrc_str)
+	eth_pkt.DstAddr, _ = net.ParseMAC(hwdst_str)
+
+	trill_pkt := trill.Make()
+	trill_pkt.Egress = 0x1234
+	trill_pkt.Ingress = 0x5678
+
+	inner_pkt := eth.Make()
+	inner_pkt.SrcAddr, _ = net.ParseMAC(hwdst_str)
+	inner_pkt.DstAddr, _ = net.ParseMAC(hwsrc_str)
+
+	vlan_pkt := vlan.Make()
+	vlan_pkt.VLAN = 100
+
+	ip4_pkt := ipv4.Make()
+	ip4_pkt.SrcAddr = net.ParseIP(ipsrc_str)
+	ip4_pkt.DstAddr = net.ParseIP(ipdst_str)
+
+	buf, err := layers.Pack(eth_pkt, trill_pkt, inner_pkt, vlan_pkt, ip4_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	pkt, err := layers.UnpackAll(buf, packet.Eth)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	types := []packet.Type{
+		packet.Eth, packet.TRILL, packet.Eth, packet.VLAN, packet.IPv4,
+	}
+
+	for _, typ := range types {
+		if pkt == nil || pkt.GetType() != typ {
+			t.Fatalf("Packet type mismatch, %s", pkt)
+		}
+
+		if typ == packet.TRILL && !pkt.Equals(trill_pkt) {
+			t.Fatalf("Packet mismatch: %s", pkt)
+		}
+
+		pkt = pkt.Payload()
+	}
+}
+
+func TestUnpackAllWoL(t *testing.T) {
+	eth_pkt := eth.Make()
+	eth_pkt.SrcAddr, _ = net.ParseMAC(hwsrc_str)
+	eth_pkt.DstAddr, _ = net.ParseMAC("ff:ff:ff:ff:ff:ff")
+
+	ip4_pkt := ipv4.Make()
+	ip4_pkt.SrcAddr = net.ParseIP(ipsrc_str)
+	ip4_pkt.DstAddr = net.ParseIP("255.255.255.255")
+
+	udp_pkt := udp.Make()
+	udp_pkt.SrcPort = 41562
+	udp_pkt.DstPort = 9
+
+	wol_pkt := wol.Make()
+	wol_pkt.Target, _ = net.ParseMAC(hwdst_str)
+
+	stacks := [][]packet.Packet{
+		{eth_pkt, wol_pkt},
+		{eth_pkt, ip4_pkt, udp_pkt, wol_pkt},
+	}
+
+	for _, pkts := range stacks {
+		buf, err := layers.Pack(pkts...)
+		if err != nil {
+			t.Fatalf("Error packing: %s", err)
+		}
+
+		pkt, err := layers.UnpackAll(buf, packet.Eth)
+		if err != nil {
+			t.Fatalf("Error unpacking: %s", err)
+		}
+
+		pkt = layers.FindLayer(pkt, packet.WoL)
+		if pkt == nil || !pkt.Equals(wol_pkt) {
+			t.Fatalf("Packet mismatch: %s", pkt)
+		}
+	}
+}
+
+func TestUnpackAllBluetoothL2CAPATT(t *testing.T) {
+	hci_pkt := bluetooth.Make()
+	hci_pkt.Body.(*bluetooth.ACL).Handle = 0x40
+	hci_pkt.Body.(*bluetooth.ACL).Boundary = bluetooth.FirstFlushable
+
+	data_pkt := btle.Make()
+	data_pkt.AccessAddr = 0x50654c2e
+	data_pkt.Body = &btle.DataPDU{}
+
+	stacks := []struct {
+		link_type packet.Type
+		link_pkt  packet.Packet
+	}{
+		{packet.Bluetooth, hci_pkt},
+		{packet.BTLE, data_pkt},
+	}
+
+	for _, stack := range stacks {
+		l2cap_pkt := l2cap.Make()
+		att_pkt := att.MakeNotification(0x2a, []byte{0x64})
+
+		buf, err := layers.Pack(stack.link_pkt, l2cap_pkt, att_pkt)
+		if err != nil {
+			t.Fatalf("Error packing: %s", err)
+		}
+
+		pkt, err := layers.UnpackAll(buf, stack.link_type)
+		if err != nil {
+			t.Fatalf("Error unpacking: %s", err)
+		}
+
+		types := []packet.Type{stack.link_type, packet.L2CAP, packet.ATT}
+
+		for _, typ := range types {
+			if pkt == nil || pkt.GetType() != typ {
+				t.Fatalf("Packet type mismatch, %s", pkt)
+			}
+
+			if typ == packet.ATT && !pkt.Equals(att_pkt) {
+				t.Fatalf("Packet mismatch: %s", pkt)
+			}
+
+			pkt = pkt.Payload()
+		}
+	}
+}
+
 func ExamplePack() {
 	// Create an Ethernet packet
 	eth_pkt := eth.Make()
@@ -611,3 +1124,141 @@ func ExampleUnpack() {
 
 	log.Println(pkt)
 }
+
+func TestUnpackAllIPv6ExtHdrs(t *testing.T) {
+	ip6_pkt := ipv6.Make()
+	ip6_pkt.SrcAddr = net.ParseIP("fe80::4e72:b9ff:fe54:e53d")
+	ip6_pkt.DstAddr = net.ParseIP("ff02::16")
+
+	hbh_pkt := ipv6.MakeHopByHop(ipv6.MakeRouterAlert(0))
+
+	dst_pkt := ipv6.MakeDestOpts(ipv6.Option{
+		Type: ipv6.TunnelEncapLimit,
+		Data: []byte{0x04},
+	})
+
+	udp_pkt := udp.Make()
+	udp_pkt.SrcPort = 41562
+	udp_pkt.DstPort = 8338
+
+	buf, err := layers.Pack(ip6_pkt, hbh_pkt, dst_pkt, udp_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	pkt, err := layers.UnpackAll(buf, packet.IPv6)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	types := []packet.Type{
+		packet.IPv6, packet.HopByHop, packet.DestOpts, packet.UDP,
+	}
+
+	for _, typ := range types {
+		if pkt == nil || pkt.GetType() != typ {
+			t.Fatalf("Packet type mismatch, %s", pkt)
+		}
+
+		pkt = pkt.Payload()
+	}
+}
+
+func TestUnpackAllDNS(t *testing.T) {
+	ip4_pkt := ipv4.Make()
+	ip4_pkt.SrcAddr = net.ParseIP(ipsrc_str)
+	ip4_pkt.DstAddr = net.ParseIP(ipdst_str)
+
+	udp_pkt := udp.Make()
+	udp_pkt.SrcPort = 41562
+	udp_pkt.DstPort = 53
+
+	tcp_pkt := tcp.Make()
+	tcp_pkt.SrcPort = 41562
+	tcp_pkt.DstPort = 53
+	tcp_pkt.Flags = tcp.PSH | tcp.Ack
+
+	for _, l4_pkt := range []packet.Packet{udp_pkt, tcp_pkt} {
+		dns_pkt := dns.MakeQuery(0x1234, "www.example.com", dns.TypeAAAA)
+		dns_pkt.TCP = l4_pkt.GetType() == packet.TCP
+
+		buf, err := layers.Pack(ip4_pkt, l4_pkt, dns_pkt)
+		if err != nil {
+			t.Fatalf("Error packing: %s", err)
+		}
+
+		pkt, err := layers.UnpackAll(buf, packet.IPv4)
+		if err != nil {
+			t.Fatalf("Error unpacking: %s", err)
+		}
+
+		rsp := layers.FindLayer(pkt, packet.DNS)
+		if rsp == nil || !rsp.Equals(dns_pkt) ||
+			rsp.(*dns.Packet).TCP != dns_pkt.TCP {
+			t.Fatalf("Packet mismatch: %s", pkt)
+		}
+	}
+}
+
+func TestUnpackAllInvalidDNS(t *testing.T) {
+	ip4_pkt := ipv4.Make()
+	ip4_pkt.SrcAddr = net.ParseIP(ipsrc_str)
+	ip4_pkt.DstAddr = net.ParseIP(ipdst_str)
+
+	dns_pkt := dns.MakeQuery(0x1234, "www.example.com", dns.TypeAAAA)
+	dns_pkt.TCP = true
+
+	msg, err := layers.Pack(dns_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	/* a DNS message split across two TCP segments */
+	for _, data := range [][]byte{msg[:10], msg[10:]} {
+		tcp_pkt := tcp.Make()
+		tcp_pkt.SrcPort = 41562
+		tcp_pkt.DstPort = 53
+		tcp_pkt.Flags = tcp.PSH | tcp.Ack
+
+		raw_pkt := raw.Make()
+		raw_pkt.Data = data
+
+		buf, err := layers.Pack(ip4_pkt, tcp_pkt, raw_pkt)
+		if err != nil {
+			t.Fatalf("Error packing: %s", err)
+		}
+
+		pkt, err := layers.UnpackAll(buf, packet.IPv4)
+		if err != nil {
+			t.Fatalf("Error unpacking: %s", err)
+		}
+
+		if !layers.FindLayer(pkt, packet.Raw).Equals(raw_pkt) {
+			t.Fatalf("Packet mismatch: %s", pkt)
+		}
+	}
+
+	/* malformed messages on the DNS and mDNS ports */
+	for _, port := range []uint16{53, 5353} {
+		udp_pkt := udp.Make()
+		udp_pkt.SrcPort = port
+		udp_pkt.DstPort = port
+
+		raw_pkt := raw.Make()
+		raw_pkt.Data = []byte("hello")
+
+		buf, err := layers.Pack(ip4_pkt, udp_pkt, raw_pkt)
+		if err != nil {
+			t.Fatalf("Error packing: %s", err)
+		}
+
+		pkt, err := layers.UnpackAll(buf, packet.IPv4)
+		if err != nil {
+			t.Fatalf("Error unpacking: %s", err)
+		}
+
+		if !layers.FindLayer(pkt, packet.Raw).Equals(raw_pkt) {
+			t.Fatalf("Packet mismatch: %s", pkt)
+		}
+	}
+}
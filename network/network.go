@@ -41,6 +41,7 @@ import "github.com/scs-solution/go.pkt2/capture"
 import "github.com/scs-solution/go.pkt2/packet"
 import "github.com/scs-solution/go.pkt2/packet/arp"
 import "github.com/scs-solution/go.pkt2/packet/eth"
+import "github.com/scs-solution/go.pkt2/packet/wol"
 import "github.com/scs-solution/go.pkt2/layers"
 import "github.com/scs-solution/go.pkt2/routing"
 
@@ -141,3 +142,18 @@ func NextHopMAC(c capture.Handle, t time.Duration, r *routing.Route, addr net.IP
 
 	return pkt.Payload().(*arp.Packet).HWSrcAddr, nil
 }
+
+// Send a Wake-on-LAN magic packet for the given target MAC address, using the
+// interface of the given route (see routing.RouteTo()). The password is the
+// optional SecureOn password of the target, and can be nil.
+func WakeOnLAN(c capture.Handle, r *routing.Route, target net.HardwareAddr, password []byte) error {
+	eth_pkt := eth.Make()
+	eth_pkt.SrcAddr = r.Iface.HardwareAddr
+	eth_pkt.DstAddr, _ = net.ParseMAC("ff:ff:ff:ff:ff:ff")
+
+	wol_pkt := wol.Make()
+	wol_pkt.Target = target
+	wol_pkt.Password = password
+
+	return Send(c, eth_pkt, wol_pkt)
+}
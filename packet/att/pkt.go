@@ -0,0 +1,290 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for Bluetooth Attribute Protocol (ATT)
+// packets.
+package att
+
+import "encoding/binary"
+import "fmt"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type Packet struct {
+	Opcode      Opcode
+	Params      []byte
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+type Opcode uint8
+
+const (
+	ErrorRsp           Opcode = 0x01
+	ExchangeMTUReq            = 0x02
+	ExchangeMTURsp            = 0x03
+	FindInfoReq               = 0x04
+	FindInfoRsp               = 0x05
+	FindByTypeValueReq        = 0x06
+	FindByTypeValueRsp        = 0x07
+	ReadByTypeReq             = 0x08
+	ReadByTypeRsp             = 0x09
+	ReadReq                   = 0x0a
+	ReadRsp                   = 0x0b
+	ReadBlobReq               = 0x0c
+	ReadBlobRsp               = 0x0d
+	ReadMultipleReq           = 0x0e
+	ReadMultipleRsp           = 0x0f
+	ReadByGroupTypeReq        = 0x10
+	ReadByGroupTypeRsp        = 0x11
+	WriteReq                  = 0x12
+	WriteRsp                  = 0x13
+	PrepareWriteReq           = 0x16
+	PrepareWriteRsp           = 0x17
+	ExecuteWriteReq           = 0x18
+	ExecuteWriteRsp           = 0x19
+	HandleValueNtf            = 0x1b
+	HandleValueInd            = 0x1d
+	HandleValueCfm            = 0x1e
+	WriteCmd                  = 0x52
+	SignedWriteCmd            = 0xd2
+)
+
+type ErrorCode uint8
+
+const (
+	InvalidHandle          ErrorCode = 0x01
+	ReadNotPermitted                 = 0x02
+	WriteNotPermitted                = 0x03
+	InvalidPDU                       = 0x04
+	InsufficientAuthn                = 0x05
+	RequestNotSupported              = 0x06
+	InvalidOffset                    = 0x07
+	InsufficientAuthz                = 0x08
+	PrepareQueueFull                 = 0x09
+	AttributeNotFound                = 0x0a
+	AttributeNotLong                 = 0x0b
+	InsufficientKeySize              = 0x0c
+	InvalidValueLength               = 0x0d
+	UnlikelyError                    = 0x0e
+	InsufficientEncryption           = 0x0f
+	UnsupportedGroupType             = 0x10
+	InsufficientResources            = 0x11
+)
+
+/* length of the authentication signature of signed write commands */
+const signature_len = 12
+
+func Make() *Packet {
+	return &Packet{}
+}
+
+// Create a new Read Request for the given attribute handle.
+func MakeReadReq(handle uint16) *Packet {
+	return &Packet{
+		Opcode: ReadReq,
+		Params: put_handle(handle, nil),
+	}
+}
+
+// Create a new Write Request setting the given attribute value.
+func MakeWriteReq(handle uint16, value []byte) *Packet {
+	return &Packet{
+		Opcode: WriteReq,
+		Params: put_handle(handle, value),
+	}
+}
+
+// Create a new Write Command setting the given attribute value.
+func MakeWriteCmd(handle uint16, value []byte) *Packet {
+	return &Packet{
+		Opcode: WriteCmd,
+		Params: put_handle(handle, value),
+	}
+}
+
+// Create a new Handle Value Notification for the given attribute value.
+func MakeNotification(handle uint16, value []byte) *Packet {
+	return &Packet{
+		Opcode: HandleValueNtf,
+		Params: put_handle(handle, value),
+	}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.ATT
+}
+
+func (p *Packet) GetLength() uint16 {
+	return 1 + uint16(len(p.Params))
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	if other == nil || other.GetType() != packet.ATT {
+		return false
+	}
+
+	req := other.(*Packet)
+
+	if p.Opcode == ErrorRsp {
+		return len(p.Params) >= 1 && Opcode(p.Params[0]) == req.Opcode
+	}
+
+	/* responses (and confirmations) use the opcode following the request */
+	if req.Opcode&1 == 0 || req.Opcode == HandleValueInd {
+		return p.Opcode == req.Opcode+1
+	}
+
+	return false
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	buf.WriteN(p.Opcode)
+	buf.Write(p.Params)
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	if buf.Len() < 1 {
+		return fmt.Errorf("Invalid packet length: %d", buf.Len())
+	}
+
+	buf.ReadN(&p.Opcode)
+	p.Params = buf.Next(buf.Len())
+
+	return nil
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	return packet.None
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+// Return the attribute handle the packet refers to.
+func (p *Packet) Handle() (uint16, bool) {
+	switch p.Opcode {
+	case ReadReq, ReadBlobReq, WriteReq, WriteCmd, SignedWriteCmd,
+		PrepareWriteReq, PrepareWriteRsp, HandleValueNtf,
+		HandleValueInd:
+		if len(p.Params) >= 2 {
+			return binary.LittleEndian.Uint16(p.Params), true
+		}
+
+	case ErrorRsp:
+		if len(p.Params) >= 3 {
+			return binary.LittleEndian.Uint16(p.Params[1:]), true
+		}
+	}
+
+	return 0, false
+}
+
+// Return the attribute value carried by the packet.
+func (p *Packet) Value() []byte {
+	switch p.Opcode {
+	case ReadRsp, ReadBlobRsp, ReadMultipleRsp:
+		return p.Params
+
+	case WriteReq, WriteCmd, HandleValueNtf, HandleValueInd:
+		if len(p.Params) >= 2 {
+			return p.Params[2:]
+		}
+
+	case SignedWriteCmd:
+		if len(p.Params) >= 2+signature_len {
+			return p.Params[2 : len(p.Params)-signature_len]
+		}
+
+	case PrepareWriteReq, PrepareWriteRsp:
+		if len(p.Params) >= 4 {
+			return p.Params[4:]
+		}
+	}
+
+	return nil
+}
+
+// Return the MTU carried by Exchange MTU requests and responses.
+func (p *Packet) MTU() uint16 {
+	if (p.Opcode != ExchangeMTUReq && p.Opcode != ExchangeMTURsp) ||
+		len(p.Params) < 2 {
+		return 0
+	}
+
+	return binary.LittleEndian.Uint16(p.Params)
+}
+
+// Return the error code carried by Error Responses.
+func (p *Packet) Error() ErrorCode {
+	if p.Opcode != ErrorRsp || len(p.Params) < 4 {
+		return 0
+	}
+
+	return ErrorCode(p.Params[3])
+}
+
+// Return whether the opcode is a command (i.e. doesn't expect a response).
+func (o Opcode) IsCommand() bool {
+	return o&0x40 != 0
+}
+
+// Return whether the opcode includes an authentication signature.
+func (o Opcode) IsSigned() bool {
+	return o&0x80 != 0
+}
+
+func put_handle(handle uint16, value []byte) []byte {
+	params := make([]byte, 2+len(value))
+
+	binary.LittleEndian.PutUint16(params, handle)
+	copy(params[2:], value)
+
+	return params
+}
@@ -0,0 +1,126 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package att_test
+
+import "bytes"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/att"
+
+var test_simple = []byte{
+	0x1b, 0x2a, 0x00, 0x64,
+}
+
+func MakeTestSimple() *att.Packet {
+	return att.MakeNotification(0x2a, []byte{0x64})
+}
+
+func TestPack(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_simple, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPack(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	var p att.Packet
+
+	cmp := MakeTestSimple()
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpack(bn *testing.B) {
+	var p att.Packet
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_simple)
+		p.Unpack(&b)
+	}
+}
+
+func TestAnswers(t *testing.T) {
+	req := att.MakeReadReq(0x2a)
+
+	rsp := &att.Packet{Opcode: att.ReadRsp, Params: []byte{0x64}}
+	if !rsp.Answers(req) {
+		t.Fatalf("Response does not answer request")
+	}
+
+	err_pkt := &att.Packet{
+		Opcode: att.ErrorRsp,
+		Params: []byte{0x0a, 0x2a, 0x00, 0x02},
+	}
+
+	if !err_pkt.Answers(req) || err_pkt.Error() != att.ReadNotPermitted {
+		t.Fatalf("Error mismatch: %s", err_pkt)
+	}
+
+	if handle, ok := err_pkt.Handle(); !ok || handle != 0x2a {
+		t.Fatalf("Handle mismatch: %d", handle)
+	}
+
+	if rsp.Answers(att.MakeWriteReq(0x2a, []byte{0x01})) {
+		t.Fatalf("Response answers unrelated request")
+	}
+}
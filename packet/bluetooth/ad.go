@@ -0,0 +1,111 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package bluetooth
+
+// Advertising data (AD) structure, as carried by LE advertising PDUs, by LE
+// Advertising Report events and by extended inquiry responses.
+type AD struct {
+	Type ADType
+	Data []byte
+}
+
+type ADType uint8
+
+const (
+	ADFlags             ADType = 0x01
+	ADIncompleteUUID16         = 0x02
+	ADCompleteUUID16           = 0x03
+	ADIncompleteUUID32         = 0x04
+	ADCompleteUUID32           = 0x05
+	ADIncompleteUUID128        = 0x06
+	ADCompleteUUID128          = 0x07
+	ADShortName                = 0x08
+	ADCompleteName             = 0x09
+	ADTxPower                  = 0x0a
+	ADServiceData16            = 0x16
+	ADAppearance               = 0x19
+	ADServiceData32            = 0x20
+	ADServiceData128           = 0x21
+	ADManufacturerData         = 0xff
+)
+
+// Decode the given advertising data into a list of AD structures. Decoding
+// stops at the first zero-length structure (i.e. at the padding).
+func ParseAD(data []byte) []AD {
+	var ads []AD
+
+	for len(data) >= 2 && data[0] > 0 {
+		length := int(data[0])
+		if len(data) < 1+length {
+			break
+		}
+
+		ads = append(ads, AD{ADType(data[1]), data[2 : 1+length]})
+
+		data = data[1+length:]
+	}
+
+	return ads
+}
+
+// Encode the given AD structures into advertising data.
+func PackAD(ads ...AD) []byte {
+	var data []byte
+
+	for _, ad := range ads {
+		data = append(data, uint8(len(ad.Data)+1), uint8(ad.Type))
+		data = append(data, ad.Data...)
+	}
+
+	return data
+}
+
+// Return the data of the first AD structure of the given type, or nil if not
+// present.
+func FindAD(ads []AD, typ ADType) []byte {
+	for _, ad := range ads {
+		if ad.Type == typ {
+			return ad.Data
+		}
+	}
+
+	return nil
+}
+
+// Return the complete (or, if not available, the shortened) local name
+// carried by the given AD structures.
+func LocalName(ads []AD) string {
+	if name := FindAD(ads, ADCompleteName); name != nil {
+		return string(name)
+	}
+
+	return string(FindAD(ads, ADShortName))
+}
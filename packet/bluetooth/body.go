@@ -0,0 +1,381 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package bluetooth
+
+import "encoding/binary"
+import "fmt"
+import "net"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+// HCI command packet.
+type Command struct {
+	Opcode Opcode
+	Params []byte
+}
+
+// HCI event packet.
+type Event struct {
+	Code   EventCode
+	Params []byte
+}
+
+// HCI ACL data packet. The data itself is stored as the packet payload.
+type ACL struct {
+	Handle    uint16
+	Boundary  Boundary `string:"pb"`
+	Broadcast uint8    `string:"bc"`
+	Length    uint16
+}
+
+// HCI synchronous (SCO) data packet.
+type SCO struct {
+	Handle uint16
+	Status uint8
+	Data   []byte
+}
+
+// Packet boundary flag of ACL packets.
+type Boundary uint8
+
+const (
+	FirstNonFlushable Boundary = 0x00
+	Continuing                 = 0x01
+	FirstFlushable             = 0x02
+	Complete                   = 0x03
+)
+
+// HCI command opcode, made up of the Opcode Group Field (OGF) in the upper 6
+// bits and of the Opcode Command Field (OCF) in the lower 10 bits.
+type Opcode uint16
+
+const (
+	Disconnect         Opcode = 0x0406
+	SetEventMask              = 0x0c01
+	Reset                     = 0x0c03
+	WriteLocalName            = 0x0c13
+	ReadLocalVersion          = 0x1001
+	ReadBufferSize            = 0x1005
+	ReadBDAddr                = 0x1009
+	LESetEventMask            = 0x2001
+	LEReadBufferSize          = 0x2002
+	LESetRandomAddr           = 0x2005
+	LESetAdvParams            = 0x2006
+	LESetAdvData              = 0x2008
+	LESetScanRspData          = 0x2009
+	LESetAdvEnable            = 0x200a
+	LESetScanParams           = 0x200b
+	LESetScanEnable           = 0x200c
+	LECreateConn              = 0x200d
+	LECreateConnCancel        = 0x200e
+	LEConnUpdate              = 0x2013
+	LEStartEncryption         = 0x2019
+)
+
+type EventCode uint8
+
+const (
+	InquiryComplete      EventCode = 0x01
+	ConnComplete                   = 0x03
+	ConnRequest                    = 0x04
+	DisconnComplete                = 0x05
+	EncryptionChange               = 0x08
+	ReadRemoteVersion              = 0x0c
+	CommandComplete                = 0x0e
+	CommandStatus                  = 0x0f
+	HardwareError                  = 0x10
+	NumCompletedPackets            = 0x13
+	EncryptionKeyRefresh           = 0x30
+	LEMeta                         = 0x3e
+)
+
+/* LE meta event subevent codes */
+const (
+	LEConnCompleteSubevent         uint8 = 0x01
+	LEAdvReportSubevent                  = 0x02
+	LEConnUpdateCompleteSubevent         = 0x03
+	LELongTermKeyRequestSubevent         = 0x05
+	LEEnhancedConnCompleteSubevent       = 0x0a
+)
+
+// Single report of an LE Advertising Report event.
+type AdvReport struct {
+	EventType uint8
+	AddrType  uint8
+	Addr      net.HardwareAddr
+	Data      []byte
+	RSSI      int8
+}
+
+// Create a new opcode from the given group and command fields.
+func MakeOpcode(ogf uint8, ocf uint16) Opcode {
+	return Opcode(uint16(ogf)<<10 | ocf&0x3ff)
+}
+
+// Return the Opcode Group Field of the opcode.
+func (o Opcode) OGF() uint8 {
+	return uint8(o >> 10)
+}
+
+// Return the Opcode Command Field of the opcode.
+func (o Opcode) OCF() uint16 {
+	return uint16(o) & 0x3ff
+}
+
+func (o Opcode) String() string {
+	return fmt.Sprintf("0x%02x|0x%04x", o.OGF(), o.OCF())
+}
+
+func (b *Command) length() uint16 {
+	return 3 + uint16(len(b.Params))
+}
+
+func (b *Command) pack(buf *packet.Buffer) {
+	buf.WriteL(b.Opcode)
+	buf.WriteN(uint8(len(b.Params)))
+	buf.Write(b.Params)
+}
+
+func (b *Command) unpack(buf *packet.Buffer) error {
+	if buf.Len() < 3 {
+		return fmt.Errorf("Invalid command length: %d", buf.Len())
+	}
+
+	var length uint8
+
+	buf.ReadL(&b.Opcode)
+	buf.ReadN(&length)
+
+	if int(length) > buf.Len() {
+		return fmt.Errorf("Invalid parameters length: %d", length)
+	}
+
+	b.Params = buf.Next(int(length))
+
+	return nil
+}
+
+func (b *Event) length() uint16 {
+	return 2 + uint16(len(b.Params))
+}
+
+func (b *Event) pack(buf *packet.Buffer) {
+	buf.WriteN(b.Code)
+	buf.WriteN(uint8(len(b.Params)))
+	buf.Write(b.Params)
+}
+
+func (b *Event) unpack(buf *packet.Buffer) error {
+	if buf.Len() < 2 {
+		return fmt.Errorf("Invalid event length: %d", buf.Len())
+	}
+
+	var length uint8
+
+	buf.ReadN(&b.Code)
+	buf.ReadN(&length)
+
+	if int(length) > buf.Len() {
+		return fmt.Errorf("Invalid parameters length: %d", length)
+	}
+
+	b.Params = buf.Next(int(length))
+
+	return nil
+}
+
+// Return the opcode of the command a Command Complete or Command Status event
+// refers to.
+func (b *Event) CommandOpcode() (Opcode, bool) {
+	switch {
+	case b.Code == CommandComplete && len(b.Params) >= 3:
+		return Opcode(binary.LittleEndian.Uint16(b.Params[1:])), true
+
+	case b.Code == CommandStatus && len(b.Params) >= 4:
+		return Opcode(binary.LittleEndian.Uint16(b.Params[2:])), true
+	}
+
+	return 0, false
+}
+
+// Return the return parameters of a Command Complete event.
+func (b *Event) ReturnParams() []byte {
+	if b.Code != CommandComplete || len(b.Params) < 3 {
+		return nil
+	}
+
+	return b.Params[3:]
+}
+
+// Return the status code of a Command Complete or Command Status event. For
+// Command Complete events this assumes that the first return parameter is the
+// status, which is the case for almost all commands.
+func (b *Event) Status() uint8 {
+	switch {
+	case b.Code == CommandComplete && len(b.Params) >= 4:
+		return b.Params[3]
+
+	case b.Code == CommandStatus && len(b.Params) >= 1:
+		return b.Params[0]
+
+	case b.Code == DisconnComplete && len(b.Params) >= 1:
+		return b.Params[0]
+	}
+
+	return 0
+}
+
+// Return the subevent code of an LE Meta event.
+func (b *Event) Subevent() uint8 {
+	if b.Code != LEMeta || len(b.Params) < 1 {
+		return 0
+	}
+
+	return b.Params[0]
+}
+
+// Return the reports carried by an LE Advertising Report event.
+func (b *Event) AdvReports() []AdvReport {
+	var reports []AdvReport
+
+	if b.Subevent() != LEAdvReportSubevent || len(b.Params) < 2 {
+		return nil
+	}
+
+	data := b.Params[2:]
+
+	for i := 0; i < int(b.Params[1]); i++ {
+		if len(data) < 9 || len(data) < 10+int(data[8]) {
+			break
+		}
+
+		var r AdvReport
+
+		r.EventType = data[0]
+		r.AddrType = data[1]
+		r.Addr = ParseAddr(data[2:8])
+		r.Data = data[9 : 9+int(data[8])]
+		r.RSSI = int8(data[9+int(data[8])])
+
+		reports = append(reports, r)
+
+		data = data[10+int(data[8]):]
+	}
+
+	return reports
+}
+
+func (b *ACL) length() uint16 {
+	return 4
+}
+
+func (b *ACL) pack(buf *packet.Buffer) {
+	handle := b.Handle&0x0fff |
+		uint16(b.Boundary&0x3)<<12 |
+		uint16(b.Broadcast&0x3)<<14
+
+	buf.WriteL(handle)
+	buf.WriteL(b.Length)
+}
+
+func (b *ACL) unpack(buf *packet.Buffer) error {
+	if buf.Len() < 4 {
+		return fmt.Errorf("Invalid ACL length: %d", buf.Len())
+	}
+
+	var handle uint16
+
+	buf.ReadL(&handle)
+	buf.ReadL(&b.Length)
+
+	b.Handle = handle & 0x0fff
+	b.Boundary = Boundary(handle >> 12 & 0x3)
+	b.Broadcast = uint8(handle >> 14)
+
+	if int(b.Length) > buf.Len() {
+		return fmt.Errorf("Invalid data length: %d", b.Length)
+	}
+
+	buf.Truncate(int(b.Length))
+
+	return nil
+}
+
+func (b *SCO) length() uint16 {
+	return 3 + uint16(len(b.Data))
+}
+
+func (b *SCO) pack(buf *packet.Buffer) {
+	buf.WriteL(b.Handle&0x0fff | uint16(b.Status&0x3)<<12)
+	buf.WriteN(uint8(len(b.Data)))
+	buf.Write(b.Data)
+}
+
+func (b *SCO) unpack(buf *packet.Buffer) error {
+	if buf.Len() < 3 {
+		return fmt.Errorf("Invalid SCO length: %d", buf.Len())
+	}
+
+	var handle uint16
+	var length uint8
+
+	buf.ReadL(&handle)
+	buf.ReadN(&length)
+
+	b.Handle = handle & 0x0fff
+	b.Status = uint8(handle >> 12 & 0x3)
+
+	if int(length) > buf.Len() {
+		return fmt.Errorf("Invalid data length: %d", length)
+	}
+
+	b.Data = buf.Next(int(length))
+
+	return nil
+}
+
+// Convert a Bluetooth device address from its on-air format (i.e. in little
+// endian byte order) to a MAC address.
+func ParseAddr(raw []byte) net.HardwareAddr {
+	addr := make(net.HardwareAddr, len(raw))
+
+	for i := range raw {
+		addr[len(raw)-1-i] = raw[i]
+	}
+
+	return addr
+}
+
+// Convert a MAC address to the on-air format of Bluetooth device addresses
+// (i.e. in little endian byte order).
+func AddrBytes(addr net.HardwareAddr) []byte {
+	return ParseAddr(addr)
+}
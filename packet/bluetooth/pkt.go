@@ -0,0 +1,231 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for Bluetooth HCI packets, as captured with
+// the H4 UART framing and the direction pseudo-header (PCAP link type 201).
+//
+// The type specific part of the packet is stored in the Body field (i.e. a
+// *Command, *Event, *ACL or *SCO). The L2CAP data carried by ACL packets is
+// decoded as the packet payload.
+package bluetooth
+
+import "fmt"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type Packet struct {
+	Direction   Direction `string:"dir"`
+	Type        PacketType
+	Body        Body          `cmp:"skip" string:"skip"`
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+type Direction uint32
+
+const (
+	Sent     Direction = 0
+	Received           = 1
+)
+
+type PacketType uint8
+
+const (
+	CommandPacket PacketType = 0x01
+	ACLPacket                = 0x02
+	SCOPacket                = 0x03
+	EventPacket              = 0x04
+	ISOPacket                = 0x05
+)
+
+// Body is the interface implemented by the type specific part of HCI packets
+// (i.e. *Command, *Event, *ACL and *SCO).
+type Body interface {
+	length() uint16
+	pack(buf *packet.Buffer)
+	unpack(buf *packet.Buffer) error
+}
+
+func Make() *Packet {
+	return &Packet{
+		Type: ACLPacket,
+		Body: &ACL{},
+	}
+}
+
+// Create a new HCI command packet with the given opcode and parameters.
+func MakeCommand(opcode Opcode, params []byte) *Packet {
+	return &Packet{
+		Direction: Sent,
+		Type:      CommandPacket,
+		Body:      &Command{Opcode: opcode, Params: params},
+	}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.Bluetooth
+}
+
+func (p *Packet) GetLength() uint16 {
+	length := uint16(5)
+
+	if p.Body != nil {
+		length += p.Body.length()
+	}
+
+	if p.pkt_payload != nil {
+		return p.pkt_payload.GetLength() + length
+	}
+
+	return length
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	if other == nil || other.GetType() != packet.Bluetooth {
+		return false
+	}
+
+	req, ok := other.(*Packet).Body.(*Command)
+	if !ok {
+		return false
+	}
+
+	evt, ok := p.Body.(*Event)
+	if !ok {
+		return false
+	}
+
+	opcode, ok := evt.CommandOpcode()
+
+	return ok && opcode == req.Opcode
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	if p.Body == nil {
+		return fmt.Errorf("Missing packet body")
+	}
+
+	buf.WriteN(p.Direction)
+	buf.WriteN(p.Type)
+
+	p.Body.pack(buf)
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	if buf.Len() < 5 {
+		return fmt.Errorf("Invalid packet length: %d", buf.Len())
+	}
+
+	buf.ReadN(&p.Direction)
+	buf.ReadN(&p.Type)
+
+	switch p.Type {
+	case CommandPacket:
+		p.Body = &Command{}
+	case ACLPacket:
+		p.Body = &ACL{}
+	case SCOPacket:
+		p.Body = &SCO{}
+	case EventPacket:
+		p.Body = &Event{}
+	default:
+		return fmt.Errorf("Unsupported packet type: %d", p.Type)
+	}
+
+	return p.Body.unpack(buf)
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	acl, ok := p.Body.(*ACL)
+	if !ok || acl.Length == 0 {
+		return packet.None
+	}
+
+	/* only the first fragment starts with the L2CAP header */
+	if acl.Boundary == Continuing {
+		return packet.Raw
+	}
+
+	return packet.L2CAP
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	if acl, ok := p.Body.(*ACL); ok {
+		acl.Length = pl.GetLength()
+	}
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+func (d Direction) String() string {
+	switch d {
+	case Sent:
+		return "sent"
+	case Received:
+		return "received"
+	}
+
+	return fmt.Sprintf("0x%x", uint32(d))
+}
+
+func (t PacketType) String() string {
+	switch t {
+	case CommandPacket:
+		return "command"
+	case ACLPacket:
+		return "acl"
+	case SCOPacket:
+		return "sco"
+	case EventPacket:
+		return "event"
+	case ISOPacket:
+		return "iso"
+	}
+
+	return fmt.Sprintf("0x%x", uint8(t))
+}
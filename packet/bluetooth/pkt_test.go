@@ -0,0 +1,162 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package bluetooth_test
+
+import "bytes"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/bluetooth"
+
+var test_simple = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x01, 0x03, 0x0c, 0x00,
+}
+
+func MakeTestSimple() *bluetooth.Packet {
+	return bluetooth.MakeCommand(bluetooth.Reset, nil)
+}
+
+func TestPack(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_simple, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPack(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	var p bluetooth.Packet
+
+	cmp := MakeTestSimple()
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpack(bn *testing.B) {
+	var p bluetooth.Packet
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_simple)
+		p.Unpack(&b)
+	}
+}
+
+var test_cmd_complete = []byte{
+	0x00, 0x00, 0x00, 0x01, 0x04, 0x0e, 0x04, 0x01, 0x03, 0x0c, 0x00,
+}
+
+func TestUnpackEvent(t *testing.T) {
+	var p bluetooth.Packet
+
+	var b packet.Buffer
+	b.Init(test_cmd_complete)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	evt, ok := p.Body.(*bluetooth.Event)
+	if !ok || evt.Code != bluetooth.CommandComplete || evt.Status() != 0 {
+		t.Fatalf("Event mismatch: %v", p.Body)
+	}
+
+	if p.Direction != bluetooth.Received {
+		t.Fatalf("Direction mismatch: %s", p.Direction)
+	}
+
+	if !p.Answers(MakeTestSimple()) {
+		t.Fatalf("Event does not answer command")
+	}
+}
+
+var test_adv_report = []byte{
+	0x00, 0x00, 0x00, 0x01, 0x04, 0x3e, 0x13, 0x02, 0x01, 0x00, 0x00, 0x66,
+	0x55, 0x44, 0x33, 0x22, 0x11, 0x07, 0x02, 0x01, 0x06, 0x03, 0x09, 0x68,
+	0x69, 0xc4,
+}
+
+func TestUnpackAdvReport(t *testing.T) {
+	var p bluetooth.Packet
+
+	var b packet.Buffer
+	b.Init(test_adv_report)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	reports := p.Body.(*bluetooth.Event).AdvReports()
+	if len(reports) != 1 {
+		t.Fatalf("Reports mismatch: %v", reports)
+	}
+
+	r := reports[0]
+
+	if r.Addr.String() != "11:22:33:44:55:66" || r.RSSI != -60 {
+		t.Fatalf("Report mismatch: %v", r)
+	}
+
+	if bluetooth.LocalName(bluetooth.ParseAD(r.Data)) != "hi" {
+		t.Fatalf("Name mismatch: %x", r.Data)
+	}
+}
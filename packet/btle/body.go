@@ -0,0 +1,321 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package btle
+
+import "encoding/binary"
+import "net"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/bluetooth"
+
+// Advertising channel PDU.
+type AdvPDU struct {
+	Type     PDUType
+	ChSel    bool
+	TxAdd    bool
+	RxAdd    bool
+	AdvAddr  net.HardwareAddr
+	PeerAddr net.HardwareAddr /* TargetA, ScanA or InitA */
+	Data     []byte           /* AdvData, ScanRspData or LLData */
+}
+
+type PDUType uint8
+
+const (
+	AdvInd        PDUType = 0x0
+	AdvDirectInd          = 0x1
+	AdvNonconnInd         = 0x2
+	ScanReq               = 0x3
+	ScanRsp               = 0x4
+	ConnectInd            = 0x5
+	AdvScanInd            = 0x6
+	AdvExtInd             = 0x7
+)
+
+// Data channel PDU. The L2CAP data is stored as the packet payload, while the
+// control PDUs are stored in the Opcode and CtrlData fields.
+type DataPDU struct {
+	LLID     LLID
+	NESN     bool
+	SN       bool
+	MD       bool
+	Length   uint8
+	Opcode   ControlOpcode
+	CtrlData []byte
+}
+
+// Logical link identifier of data channel PDUs.
+type LLID uint8
+
+const (
+	LLContinuation LLID = 0x1
+	LLStart             = 0x2
+	LLControl           = 0x3
+)
+
+type ControlOpcode uint8
+
+const (
+	LLConnUpdateInd     ControlOpcode = 0x00
+	LLChannelMapInd                   = 0x01
+	LLTerminateInd                    = 0x02
+	LLEncReq                          = 0x03
+	LLEncRsp                          = 0x04
+	LLStartEncReq                     = 0x05
+	LLStartEncRsp                     = 0x06
+	LLUnknownRsp                      = 0x07
+	LLFeatureReq                      = 0x08
+	LLFeatureRsp                      = 0x09
+	LLPauseEncReq                     = 0x0a
+	LLPauseEncRsp                     = 0x0b
+	LLVersionInd                      = 0x0c
+	LLRejectInd                       = 0x0d
+	LLPeripheralFeatReq               = 0x0e
+	LLConnParamReq                    = 0x0f
+	LLConnParamRsp                    = 0x10
+	LLRejectExtInd                    = 0x11
+	LLPingReq                         = 0x12
+	LLPingRsp                         = 0x13
+	LLLengthReq                       = 0x14
+	LLLengthRsp                       = 0x15
+	LLPhyReq                          = 0x16
+	LLPhyRsp                          = 0x17
+	LLPhyUpdateInd                    = 0x18
+)
+
+// Connection parameters carried by CONNECT_IND PDUs.
+type ConnParams struct {
+	AccessAddr uint32
+	CRCInit    uint32
+	WinSize    uint8
+	WinOffset  uint16
+	Interval   uint16
+	Latency    uint16
+	Timeout    uint16
+	ChannelMap [5]byte
+	Hop        uint8
+	SCA        uint8
+}
+
+// Create a new advertising channel PDU carrying the given AD structures.
+func MakeAdv(typ PDUType, addr net.HardwareAddr, ads ...bluetooth.AD) *AdvPDU {
+	return &AdvPDU{
+		Type:    typ,
+		AdvAddr: addr,
+		Data:    bluetooth.PackAD(ads...),
+	}
+}
+
+func (b *AdvPDU) length() uint8 {
+	length := len(b.Data)
+
+	switch b.Type {
+	case AdvInd, AdvNonconnInd, AdvScanInd, ScanRsp:
+		length += 6
+
+	case AdvDirectInd, ScanReq, ConnectInd:
+		length += 12
+	}
+
+	return uint8(length)
+}
+
+func (b *AdvPDU) pack(buf *packet.Buffer, pdu_len uint8) {
+	hdr := uint8(b.Type) & 0xf
+
+	if b.ChSel {
+		hdr |= 0x20
+	}
+
+	if b.TxAdd {
+		hdr |= 0x40
+	}
+
+	if b.RxAdd {
+		hdr |= 0x80
+	}
+
+	buf.WriteN(hdr)
+	buf.WriteN(pdu_len)
+
+	switch b.Type {
+	case AdvInd, AdvNonconnInd, AdvScanInd, ScanRsp:
+		write_addr(buf, b.AdvAddr)
+
+	case AdvDirectInd:
+		write_addr(buf, b.AdvAddr)
+		write_addr(buf, b.PeerAddr)
+
+	case ScanReq, ConnectInd:
+		write_addr(buf, b.PeerAddr)
+		write_addr(buf, b.AdvAddr)
+	}
+
+	buf.Write(b.Data)
+}
+
+func (b *AdvPDU) unpack(buf *packet.Buffer) error {
+	var hdr, length uint8
+
+	buf.ReadN(&hdr)
+	buf.ReadN(&length)
+
+	b.Type = PDUType(hdr & 0xf)
+	b.ChSel = hdr&0x20 != 0
+	b.TxAdd = hdr&0x40 != 0
+	b.RxAdd = hdr&0x80 != 0
+
+	b.AdvAddr = nil
+	b.PeerAddr = nil
+
+	switch b.Type {
+	case AdvInd, AdvNonconnInd, AdvScanInd, ScanRsp:
+		b.AdvAddr = read_addr(buf)
+
+	case AdvDirectInd:
+		b.AdvAddr = read_addr(buf)
+		b.PeerAddr = read_addr(buf)
+
+	case ScanReq, ConnectInd:
+		b.PeerAddr = read_addr(buf)
+		b.AdvAddr = read_addr(buf)
+	}
+
+	b.Data = buf.Next(buf.Len())
+
+	return nil
+}
+
+// Return the AD structures carried by the PDU.
+func (b *AdvPDU) ADs() []bluetooth.AD {
+	switch b.Type {
+	case AdvInd, AdvNonconnInd, AdvScanInd, ScanRsp:
+		return bluetooth.ParseAD(b.Data)
+	}
+
+	return nil
+}
+
+// Return the connection parameters carried by CONNECT_IND PDUs.
+func (b *AdvPDU) ConnParams() (ConnParams, bool) {
+	var c ConnParams
+
+	if b.Type != ConnectInd || len(b.Data) < 22 {
+		return c, false
+	}
+
+	c.AccessAddr = binary.LittleEndian.Uint32(b.Data[0:])
+	c.CRCInit = uint32(b.Data[4]) | uint32(b.Data[5])<<8 |
+		uint32(b.Data[6])<<16
+	c.WinSize = b.Data[7]
+	c.WinOffset = binary.LittleEndian.Uint16(b.Data[8:])
+	c.Interval = binary.LittleEndian.Uint16(b.Data[10:])
+	c.Latency = binary.LittleEndian.Uint16(b.Data[12:])
+	c.Timeout = binary.LittleEndian.Uint16(b.Data[14:])
+	copy(c.ChannelMap[:], b.Data[16:21])
+	c.Hop = b.Data[21] & 0x1f
+	c.SCA = b.Data[21] >> 5
+
+	return c, true
+}
+
+func (b *DataPDU) length() uint8 {
+	if b.LLID == LLControl {
+		return 1 + uint8(len(b.CtrlData))
+	}
+
+	return 0
+}
+
+func (b *DataPDU) pack(buf *packet.Buffer, pdu_len uint8) {
+	b.Length = pdu_len
+
+	hdr := uint8(b.LLID) & 0x3
+
+	if b.NESN {
+		hdr |= 0x04
+	}
+
+	if b.SN {
+		hdr |= 0x08
+	}
+
+	if b.MD {
+		hdr |= 0x10
+	}
+
+	buf.WriteN(hdr)
+	buf.WriteN(b.Length)
+
+	if b.LLID == LLControl {
+		buf.WriteN(b.Opcode)
+		buf.Write(b.CtrlData)
+	}
+}
+
+func (b *DataPDU) unpack(buf *packet.Buffer) error {
+	var hdr uint8
+
+	buf.ReadN(&hdr)
+	buf.ReadN(&b.Length)
+
+	b.LLID = LLID(hdr & 0x3)
+	b.NESN = hdr&0x04 != 0
+	b.SN = hdr&0x08 != 0
+	b.MD = hdr&0x10 != 0
+
+	b.CtrlData = nil
+
+	if b.LLID == LLControl && buf.Len() >= 1 {
+		buf.ReadN(&b.Opcode)
+		b.CtrlData = buf.Next(buf.Len())
+	}
+
+	return nil
+}
+
+func read_addr(buf *packet.Buffer) net.HardwareAddr {
+	if buf.Len() < 6 {
+		return nil
+	}
+
+	return bluetooth.ParseAddr(buf.Next(6))
+}
+
+func write_addr(buf *packet.Buffer, addr net.HardwareAddr) {
+	raw := make([]byte, 6)
+
+	if len(addr) == 6 {
+		copy(raw, bluetooth.AddrBytes(addr))
+	}
+
+	buf.Write(raw)
+}
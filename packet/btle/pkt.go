@@ -0,0 +1,292 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for Bluetooth Low Energy link layer packets,
+// with the pseudo-header used by PCAP link type 256 (LE LL with PHDR).
+//
+// The type specific part of the PDU is stored in the Body field (i.e. an
+// *AdvPDU for advertising channel PDUs, or a *DataPDU for data channel PDUs).
+// The L2CAP data carried by data channel PDUs is decoded as the packet
+// payload.
+package btle
+
+import "fmt"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type Packet struct {
+	Channel       uint8
+	Signal        int8
+	Noise         int8
+	AAOffenses    uint8  `string:"aa-offenses"`
+	RefAccessAddr uint32 `string:"ref-aa"`
+	Flags         Flags
+	AccessAddr    uint32        `string:"aa"`
+	Body          Body          `cmp:"skip" string:"skip"`
+	CRC           uint32        `cmp:"skip"`
+	pkt_payload   packet.Packet `cmp:"skip" string:"skip"`
+}
+
+// Flags of the pseudo-header.
+type Flags uint16
+
+const (
+	Dewhitened      Flags = 0x0001
+	SignalValid           = 0x0002
+	NoiseValid            = 0x0004
+	Decrypted             = 0x0008
+	RefAAValid            = 0x0010
+	AAOffensesValid       = 0x0020
+	ChannelAliased        = 0x0040
+	CRCChecked            = 0x0400
+	CRCValid              = 0x0800
+	MICChecked            = 0x1000
+	MICValid              = 0x2000
+)
+
+// Body is the interface implemented by the type specific part of link layer
+// PDUs (i.e. *AdvPDU and *DataPDU).
+type Body interface {
+	length() uint8
+	pack(buf *packet.Buffer, pdu_len uint8)
+	unpack(buf *packet.Buffer) error
+}
+
+// Access address used by all advertising channel PDUs.
+const AdvAccessAddr uint32 = 0x8e89bed6
+
+/* CRC initialization value of advertising channel PDUs */
+const adv_crc_init = 0x555555
+
+func Make() *Packet {
+	return &Packet{
+		AccessAddr: AdvAccessAddr,
+		Body:       &AdvPDU{},
+	}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.BTLE
+}
+
+func (p *Packet) GetLength() uint16 {
+	length := 10 + 4 + 2 + uint16(p.pdu_len()) + 3
+
+	if p.pkt_payload != nil {
+		return p.pkt_payload.GetLength() + length
+	}
+
+	return length
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	if other == nil || other.GetType() != packet.BTLE {
+		return false
+	}
+
+	rsp, ok := p.Body.(*AdvPDU)
+	if !ok {
+		if p.Payload() != nil {
+			return p.Payload().Answers(other.Payload())
+		}
+
+		return false
+	}
+
+	req, ok := other.(*Packet).Body.(*AdvPDU)
+	if !ok || req.Type != ScanReq || rsp.Type != ScanRsp {
+		return false
+	}
+
+	return req.AdvAddr.String() == rsp.AdvAddr.String()
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	if p.Body == nil {
+		return fmt.Errorf("Missing PDU body")
+	}
+
+	length := int(p.GetLength())
+
+	pl_len := 0
+	if p.pkt_payload != nil {
+		pl_len = int(p.pkt_payload.GetLength())
+	}
+
+	pdu_len := p.Body.length() + uint8(pl_len)
+
+	buf.WriteN(p.Channel)
+	buf.WriteN(p.Signal)
+	buf.WriteN(p.Noise)
+	buf.WriteN(p.AAOffenses)
+	buf.WriteL(p.RefAccessAddr)
+	buf.WriteL(p.Flags)
+	buf.WriteL(p.AccessAddr)
+
+	p.Body.pack(buf, pdu_len)
+
+	raw := buf.LayerBytes()
+
+	/*
+	 * The payload has already been packed at the end of the layer, so it
+	 * needs to be moved before the CRC trailer.
+	 */
+	off := buf.LayerLen()
+	copy(raw[off:off+pl_len], raw[length-pl_len:length])
+
+	if p.AccessAddr == AdvAccessAddr {
+		p.CRC = CalculateCRC(adv_crc_init, raw[14:off+pl_len])
+	}
+
+	raw[length-3] = uint8(p.CRC)
+	raw[length-2] = uint8(p.CRC >> 8)
+	raw[length-1] = uint8(p.CRC >> 16)
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	if buf.Len() < 10+4+2+3 {
+		return fmt.Errorf("Invalid packet length: %d", buf.Len())
+	}
+
+	buf.ReadN(&p.Channel)
+	buf.ReadN(&p.Signal)
+	buf.ReadN(&p.Noise)
+	buf.ReadN(&p.AAOffenses)
+	buf.ReadL(&p.RefAccessAddr)
+	buf.ReadL(&p.Flags)
+	buf.ReadL(&p.AccessAddr)
+
+	pdu_len := 2 + int(buf.Bytes()[1])
+	if buf.Len() < pdu_len+3 {
+		return fmt.Errorf("Invalid PDU length: %d", pdu_len)
+	}
+
+	crc := buf.Bytes()[pdu_len:]
+	p.CRC = uint32(crc[0]) | uint32(crc[1])<<8 | uint32(crc[2])<<16
+
+	/* don't decode the CRC as part of the payload */
+	buf.Truncate(pdu_len)
+
+	if p.AccessAddr == AdvAccessAddr {
+		p.Body = &AdvPDU{}
+	} else {
+		p.Body = &DataPDU{}
+	}
+
+	return p.Body.unpack(buf)
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	data, ok := p.Body.(*DataPDU)
+	if !ok || data.Length == 0 {
+		return packet.None
+	}
+
+	switch data.LLID {
+	case LLStart:
+		return packet.L2CAP
+
+	case LLContinuation:
+		return packet.Raw
+	}
+
+	return packet.None
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	if data, ok := p.Body.(*DataPDU); ok && pl.GetType() == packet.L2CAP {
+		data.LLID = LLStart
+	}
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+// Return whether the CRC of the packet was checked and found valid by the
+// capturing device.
+func (p *Packet) ValidCRC() bool {
+	return p.Flags&CRCChecked != 0 && p.Flags&CRCValid != 0
+}
+
+func (p *Packet) pdu_len() uint8 {
+	if p.Body == nil {
+		return 0
+	}
+
+	return p.Body.length()
+}
+
+// Calculate the link layer CRC of the given PDU (header included), using the
+// given CRC initialization value (0x555555 for advertising channel PDUs). The
+// CRC is returned in the byte order used on the air.
+func CalculateCRC(crc_init uint32, pdu []byte) uint32 {
+	/* the LFSR is fed and shifted out least significant bit first */
+	var state uint32
+
+	for i := uint(0); i < 24; i++ {
+		if crc_init&(1<<i) != 0 {
+			state |= 1 << (23 - i)
+		}
+	}
+
+	for _, b := range pdu {
+		for i := 0; i < 8; i++ {
+			bit := (state ^ uint32(b)) & 1
+
+			b >>= 1
+			state >>= 1
+
+			if bit != 0 {
+				state |= 1 << 23
+				state ^= 0x5a6000
+			}
+		}
+	}
+
+	return state
+}
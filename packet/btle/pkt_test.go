@@ -0,0 +1,149 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package btle_test
+
+import "bytes"
+import "net"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/bluetooth"
+import "github.com/scs-solution/go.pkt2/packet/btle"
+
+var test_simple = []byte{
+	0x25, 0xce, 0xa6, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0x0c, 0xd6, 0xbe,
+	0x89, 0x8e, 0x40, 0x0d, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x02, 0x01,
+	0x06, 0x03, 0x09, 0x68, 0x69, 0x39, 0xdb, 0x40,
+}
+
+func MakeTestSimple() *btle.Packet {
+	addr, _ := net.ParseMAC("11:22:33:44:55:66")
+
+	adv := btle.MakeAdv(btle.AdvInd, addr,
+		bluetooth.AD{Type: bluetooth.ADFlags, Data: []byte{0x06}},
+		bluetooth.AD{Type: bluetooth.ADCompleteName, Data: []byte("hi")},
+	)
+	adv.TxAdd = true
+
+	return &btle.Packet{
+		Channel:    37,
+		Signal:     -50,
+		Noise:      -90,
+		Flags:      btle.SignalValid | btle.CRCChecked | btle.CRCValid,
+		AccessAddr: btle.AdvAccessAddr,
+		Body:       adv,
+	}
+}
+
+func TestPack(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_simple, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPack(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	var p btle.Packet
+
+	cmp := MakeTestSimple()
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpack(bn *testing.B) {
+	var p btle.Packet
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_simple)
+		p.Unpack(&b)
+	}
+}
+
+func TestUnpackAdv(t *testing.T) {
+	var p btle.Packet
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	adv, ok := p.Body.(*btle.AdvPDU)
+	if !ok || adv.Type != btle.AdvInd || !adv.TxAdd {
+		t.Fatalf("PDU mismatch: %v", p.Body)
+	}
+
+	if adv.AdvAddr.String() != "11:22:33:44:55:66" {
+		t.Fatalf("Address mismatch: %s", adv.AdvAddr)
+	}
+
+	if bluetooth.LocalName(adv.ADs()) != "hi" {
+		t.Fatalf("Name mismatch: %x", adv.Data)
+	}
+
+	if !p.ValidCRC() ||
+		p.CRC != btle.CalculateCRC(0x555555, test_simple[14:29]) {
+		t.Fatalf("CRC mismatch: %x", p.CRC)
+	}
+}
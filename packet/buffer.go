@@ -127,12 +127,44 @@ func (p *Buffer) ReadL(data interface{}) error {
 }
 
 // Read aligned structured data from the buffer in little endian byte order.
+// The alignment width is relative to the start of the current layer.
 func (p *Buffer) ReadLAligned(data interface{}, width uintptr) error {
-    p.off = ((((p.off) + ((int(width)) - 1)) & (^((int(width)) - 1))) - p.off)
+    p.Align(int(width))
 
     return binary.Read(p, binary.LittleEndian, data)
 }
 
+// Append the value of data to the buffer in little endian byte order, after
+// zero-padding the buffer up to the given alignment width (relative to the
+// start of the current layer).
+func (b *Buffer) WriteLAligned(data interface{}, width uintptr) error {
+    off := b.off
+
+    b.Align(int(width))
+
+    for i := off; i < b.off && i < len(b.buf); i++ {
+        b.buf[i] = 0
+    }
+
+    return binary.Write(b, binary.LittleEndian, data)
+}
+
+// Advance the buffer offset up to the given alignment width, relative to the
+// start of the current layer.
+func (b *Buffer) Align(width int) {
+    if width <= 1 {
+        return
+    }
+
+    off := b.off - b.layer_off
+    off = (off + width - 1) &^ (width - 1)
+
+    b.off = b.layer_off + off
+    if b.off > len(b.buf) {
+        b.off = len(b.buf)
+    }
+}
+
 // Return a slice containing the next n bytes from the buffer, advancing the
 // buffer as if the bytes had been returned by Read
 func (b *Buffer) Next(n int) []byte {
@@ -144,3 +176,14 @@ func (b *Buffer) Next(n int) []byte {
     b.off += n
     return data
 }
+
+// Discard all but the first n unread bytes from the buffer. This is used by
+// layers that carry a trailer after their payload (e.g. a CRC), so that the
+// following layers don't decode it as part of their data.
+func (b *Buffer) Truncate(n int) {
+    if n < 0 || n > b.Len() {
+        return
+    }
+
+    b.buf = b.buf[:b.off + n]
+}
@@ -0,0 +1,456 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for CDP (Cisco Discovery Protocol) packets.
+package cdp
+
+import "fmt"
+import "net"
+import "strings"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+
+type Packet struct {
+	Version      uint8
+	TTL          uint8
+	Checksum     uint16       `string:"sum"`
+	DeviceID     string       `string:"device"`
+	PortID       string       `string:"port"`
+	Capabilities Capabilities `string:"cap"`
+	Software     string       `string:"skip"`
+	Platform     string
+	VTPDomain    string        `string:"vtp"`
+	NativeVLAN   uint16        `string:"vlan"`
+	Addresses    []net.IP      `string:"skip"`
+	MgmtAddrs    []net.IP      `string:"skip"`
+	TLVs         []TLV         `string:"skip"`
+	pkt_payload  packet.Packet `cmp:"skip" string:"skip"`
+}
+
+type TLVType uint16
+
+const (
+	DeviceIDTLV     TLVType = 0x0001
+	AddressesTLV            = 0x0002
+	PortIDTLV               = 0x0003
+	CapabilitiesTLV         = 0x0004
+	SoftwareTLV             = 0x0005
+	PlatformTLV             = 0x0006
+	VTPDomainTLV            = 0x0009
+	NativeVLANTLV           = 0x000a
+	DuplexTLV               = 0x000b
+	MgmtAddrsTLV            = 0x0016
+)
+
+// Generic TLV, used for TLV types that are not otherwise decoded.
+type TLV struct {
+	Type TLVType
+	Data []byte
+}
+
+type Capabilities uint32
+
+const (
+	Router            Capabilities = 0x001
+	TransparentBridge              = 0x002
+	SourceRouteBridge              = 0x004
+	Switch                         = 0x008
+	Host                           = 0x010
+	IGMP                           = 0x020
+	Repeater                       = 0x040
+	Phone                          = 0x080
+	RemotelyManaged                = 0x100
+	CVTA                           = 0x200
+	MACRelay                       = 0x400
+)
+
+// Multicast address CDP packets are sent to.
+var MulticastAddr = net.HardwareAddr{0x01, 0x00, 0x0c, 0xcc, 0xcc, 0xcc}
+
+/* address protocol identifiers */
+var nlpid_ipv4 = []byte{0xcc}
+var llc_ipv6 = []byte{0xaa, 0xaa, 0x03, 0x00, 0x00, 0x00, 0x86, 0xdd}
+
+func Make() *Packet {
+	return &Packet{
+		Version: 2,
+		TTL:     180,
+	}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.CDP
+}
+
+func (p *Packet) GetLength() uint16 {
+	length := uint16(4)
+
+	for _, s := range []string{p.DeviceID, p.PortID, p.Software,
+		p.Platform, p.VTPDomain} {
+		if s != "" {
+			length += 4 + uint16(len(s))
+		}
+	}
+
+	if p.Capabilities != 0 {
+		length += 4 + 4
+	}
+
+	if p.NativeVLAN != 0 {
+		length += 4 + 2
+	}
+
+	if len(p.Addresses) > 0 {
+		length += 4 + addrs_length(p.Addresses)
+	}
+
+	if len(p.MgmtAddrs) > 0 {
+		length += 4 + addrs_length(p.MgmtAddrs)
+	}
+
+	for _, t := range p.TLVs {
+		length += 4 + uint16(len(t.Data))
+	}
+
+	return length
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	return false
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	buf.WriteN(p.Version)
+	buf.WriteN(p.TTL)
+	buf.WriteN(uint16(0x00))
+
+	write_string(buf, DeviceIDTLV, p.DeviceID)
+
+	if len(p.Addresses) > 0 {
+		write_addrs(buf, AddressesTLV, p.Addresses)
+	}
+
+	write_string(buf, PortIDTLV, p.PortID)
+
+	if p.Capabilities != 0 {
+		write_tlv_hdr(buf, CapabilitiesTLV, 4)
+		buf.WriteN(p.Capabilities)
+	}
+
+	write_string(buf, SoftwareTLV, p.Software)
+	write_string(buf, PlatformTLV, p.Platform)
+	write_string(buf, VTPDomainTLV, p.VTPDomain)
+
+	if p.NativeVLAN != 0 {
+		write_tlv_hdr(buf, NativeVLANTLV, 2)
+		buf.WriteN(p.NativeVLAN)
+	}
+
+	for _, t := range p.TLVs {
+		write_tlv_hdr(buf, t.Type, len(t.Data))
+		buf.Write(t.Data)
+	}
+
+	if len(p.MgmtAddrs) > 0 {
+		write_addrs(buf, MgmtAddrsTLV, p.MgmtAddrs)
+	}
+
+	p.Checksum = CalculateChecksum(buf.LayerBytes()[:buf.LayerLen()])
+	buf.PutUint16N(2, p.Checksum)
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	p.Addresses = nil
+	p.MgmtAddrs = nil
+	p.TLVs = nil
+
+	buf.ReadN(&p.Version)
+	buf.ReadN(&p.TTL)
+	buf.ReadN(&p.Checksum)
+
+	for buf.Len() >= 4 {
+		var typ TLVType
+		buf.ReadN(&typ)
+
+		var length uint16
+		buf.ReadN(&length)
+
+		/* trailing Ethernet padding */
+		if length < 4 {
+			break
+		}
+
+		if int(length)-4 > buf.Len() {
+			return fmt.Errorf("Invalid TLV length: %d", length)
+		}
+
+		if int(length)-4 < tlv_min_len[typ] {
+			return fmt.Errorf("Invalid TLV length: %d", length)
+		}
+
+		var value packet.Buffer
+		value.Init(buf.Next(int(length) - 4))
+
+		switch typ {
+		case DeviceIDTLV:
+			p.DeviceID = string(value.Bytes())
+
+		case PortIDTLV:
+			p.PortID = string(value.Bytes())
+
+		case SoftwareTLV:
+			p.Software = string(value.Bytes())
+
+		case PlatformTLV:
+			p.Platform = string(value.Bytes())
+
+		case VTPDomainTLV:
+			p.VTPDomain = string(value.Bytes())
+
+		case CapabilitiesTLV:
+			value.ReadN(&p.Capabilities)
+
+		case NativeVLANTLV:
+			value.ReadN(&p.NativeVLAN)
+
+		case AddressesTLV:
+			addrs, err := read_addrs(&value)
+			if err != nil {
+				return err
+			}
+
+			p.Addresses = addrs
+
+		case MgmtAddrsTLV:
+			addrs, err := read_addrs(&value)
+			if err != nil {
+				return err
+			}
+
+			p.MgmtAddrs = addrs
+
+		default:
+			p.TLVs = append(p.TLVs, TLV{typ, value.Bytes()})
+		}
+	}
+
+	return nil
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	return packet.None
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+// Return the duplex advertised by the packet, and whether it was present at
+// all.
+func (p *Packet) FullDuplex() (bool, bool) {
+	for _, t := range p.TLVs {
+		if t.Type == DuplexTLV && len(t.Data) == 1 {
+			return t.Data[0] != 0, true
+		}
+	}
+
+	return false, false
+}
+
+// Calculate the CDP checksum of the given packet. This is the Internet
+// checksum, except that odd-length packets are padded the way Cisco devices
+// do it: the last byte is used as the low byte of the last word, with an
+// off-by-one compensation when its high bit is set.
+func CalculateChecksum(raw_bytes []byte) uint16 {
+	length := len(raw_bytes)
+
+	if length%2 == 0 {
+		return ipv4.CalculateChecksum(raw_bytes, 0)
+	}
+
+	last := raw_bytes[length-1]
+
+	word := uint32(last)
+	if last&0x80 != 0 {
+		word = 0xff00 | uint32(last-1)
+	}
+
+	return ipv4.CalculateChecksum(raw_bytes[:length-1], word)
+}
+
+/* Minimum value length of the TLVs starting with fixed-size fields */
+var tlv_min_len = map[TLVType]int{
+	CapabilitiesTLV: 4,
+	NativeVLANTLV:   2,
+	AddressesTLV:    4,
+	MgmtAddrsTLV:    4,
+}
+
+func write_tlv_hdr(buf *packet.Buffer, typ TLVType, length int) {
+	buf.WriteN(typ)
+	buf.WriteN(uint16(4 + length))
+}
+
+func write_string(buf *packet.Buffer, typ TLVType, s string) {
+	if s == "" {
+		return
+	}
+
+	write_tlv_hdr(buf, typ, len(s))
+	buf.Write([]byte(s))
+}
+
+func addr_proto(ip net.IP) []byte {
+	if ip.To4() != nil {
+		return nlpid_ipv4
+	}
+
+	return llc_ipv6
+}
+
+func addrs_length(addrs []net.IP) uint16 {
+	length := uint16(4)
+
+	for _, ip := range addrs {
+		proto := addr_proto(ip)
+
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+
+		length += 2 + uint16(len(proto)) + 2 + uint16(len(ip))
+	}
+
+	return length
+}
+
+func write_addrs(buf *packet.Buffer, typ TLVType, addrs []net.IP) {
+	write_tlv_hdr(buf, typ, int(addrs_length(addrs)))
+
+	buf.WriteN(uint32(len(addrs)))
+
+	for _, ip := range addrs {
+		proto := addr_proto(ip)
+
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+
+		if len(proto) == 1 {
+			buf.WriteN(uint8(1))
+		} else {
+			buf.WriteN(uint8(2))
+		}
+
+		buf.WriteN(uint8(len(proto)))
+		buf.Write(proto)
+
+		buf.WriteN(uint16(len(ip)))
+		buf.Write(ip)
+	}
+}
+
+func read_addrs(buf *packet.Buffer) ([]net.IP, error) {
+	var addrs []net.IP
+
+	var count uint32
+	buf.ReadN(&count)
+
+	for i := uint32(0); i < count && buf.Len() >= 2; i++ {
+		var proto_type, proto_len uint8
+		buf.ReadN(&proto_type)
+		buf.ReadN(&proto_len)
+
+		if int(proto_len)+2 > buf.Len() {
+			return nil, fmt.Errorf("Invalid protocol length: %d",
+				proto_len)
+		}
+
+		proto := buf.Next(int(proto_len))
+
+		var addr_len uint16
+		buf.ReadN(&addr_len)
+
+		if int(addr_len) > buf.Len() {
+			return nil, fmt.Errorf("Invalid address length: %d",
+				addr_len)
+		}
+
+		addr := buf.Next(int(addr_len))
+
+		switch {
+		case string(proto) == string(nlpid_ipv4) &&
+			len(addr) == net.IPv4len,
+			string(proto) == string(llc_ipv6) &&
+				len(addr) == net.IPv6len:
+			addrs = append(addrs, net.IP(addr))
+		}
+	}
+
+	return addrs, nil
+}
+
+func (c Capabilities) String() string {
+	var caps []string
+
+	names := []string{
+		"router", "trans-bridge", "source-route-bridge", "switch",
+		"host", "igmp", "repeater", "phone", "remotely-managed",
+		"cvta", "mac-relay",
+	}
+
+	for i, name := range names {
+		if c&(1<<uint(i)) != 0 {
+			caps = append(caps, name)
+		}
+	}
+
+	return strings.Join(caps, "|")
+}
@@ -0,0 +1,193 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package cdp_test
+
+import "bytes"
+import "net"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/cdp"
+
+var test_simple = []byte{
+	0x02, 0xb4, 0xa7, 0xcf, 0x00, 0x01, 0x00, 0x07, 0x73, 0x77, 0x31, 0x00,
+	0x02, 0x00, 0x11, 0x00, 0x00, 0x00, 0x01, 0x01, 0x01, 0xcc, 0x00, 0x04,
+	0x0a, 0x00, 0x00, 0x01, 0x00, 0x03, 0x00, 0x09, 0x47, 0x69, 0x30, 0x2f,
+	0x31, 0x00, 0x04, 0x00, 0x08, 0x00, 0x00, 0x00, 0x28, 0x00, 0x06, 0x00,
+	0x08, 0x57, 0x53, 0x2d, 0x43, 0x00, 0x0a, 0x00, 0x06, 0x00, 0x01,
+}
+
+func MakeTestSimple() *cdp.Packet {
+	return &cdp.Packet{
+		Version:      2,
+		TTL:          180,
+		Checksum:     0xa7cf,
+		DeviceID:     "sw1",
+		PortID:       "Gi0/1",
+		Capabilities: cdp.Switch | cdp.IGMP,
+		Platform:     "WS-C",
+		NativeVLAN:   1,
+		Addresses:    []net.IP{net.ParseIP("10.0.0.1")},
+	}
+}
+
+func TestPack(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_simple, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPack(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	var p cdp.Packet
+
+	cmp := MakeTestSimple()
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpack(bn *testing.B) {
+	var p cdp.Packet
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_simple)
+		p.Unpack(&b)
+	}
+}
+
+func TestUnpackAddresses(t *testing.T) {
+	var p cdp.Packet
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if len(p.Addresses) != 1 ||
+		!p.Addresses[0].Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("Addresses mismatch: %v", p.Addresses)
+	}
+}
+
+func TestAddressesIPv6(t *testing.T) {
+	p := cdp.Make()
+	p.DeviceID = "sw2"
+	p.MgmtAddrs = []net.IP{
+		net.ParseIP("192.168.0.1"), net.ParseIP("2001:db8::1"),
+	}
+
+	var b packet.Buffer
+	b.Init(make([]byte, p.GetLength()))
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if cdp.CalculateChecksum(b.Buffer()) != 0 {
+		t.Fatalf("Invalid checksum: %x", p.Checksum)
+	}
+
+	var p2 cdp.Packet
+
+	b.Init(b.Buffer())
+
+	err = p2.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p2.Equals(p) {
+		t.Fatalf("Management addresses mismatch: %v", p2.MgmtAddrs)
+	}
+}
+
+func TestUnpackTruncated(t *testing.T) {
+	tests := [][]byte{
+		/* capabilities with 1 byte of value */
+		{0x02, 0xb4, 0x00, 0x00, 0x00, 0x04, 0x00, 0x05, 0x01},
+		/* native VLAN with 1 byte of value */
+		{0x02, 0xb4, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x05, 0x01},
+		/* addresses with a truncated count */
+		{0x02, 0xb4, 0x00, 0x00, 0x00, 0x02, 0x00, 0x06, 0x00, 0x00},
+		/* address with a truncated protocol */
+		{
+			0x02, 0xb4, 0x00, 0x00, 0x00, 0x02, 0x00, 0x0a, 0x00, 0x00,
+			0x00, 0x01, 0x01, 0x05,
+		},
+	}
+
+	for _, raw := range tests {
+		var p cdp.Packet
+
+		var b packet.Buffer
+		b.Init(raw)
+
+		err := p.Unpack(&b)
+		if err == nil {
+			t.Fatalf("Truncated TLV not detected: %x", raw)
+		}
+	}
+}
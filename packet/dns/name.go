@@ -0,0 +1,138 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package dns
+
+import "fmt"
+import "strings"
+
+/* maximum number of compression pointers followed when decoding a name */
+const max_pointers = 64
+
+// Append the wire format of the given name to msg. If comp is not nil, the
+// name is compressed using the suffixes already present in the message, and
+// its own suffixes are added to comp.
+func pack_name(msg []byte, name string, comp map[string]int) ([]byte, error) {
+	name = strings.TrimSuffix(name, ".")
+
+	if len(name) > 253 {
+		return msg, fmt.Errorf("Invalid name length: %d", len(name))
+	}
+
+	if name == "" {
+		return append(msg, 0x00), nil
+	}
+
+	labels := strings.Split(name, ".")
+
+	for i, label := range labels {
+		if len(label) == 0 || len(label) > 63 {
+			return msg, fmt.Errorf("Invalid label length: %d", len(label))
+		}
+
+		if comp != nil {
+			suffix := strings.ToLower(strings.Join(labels[i:], "."))
+
+			if ptr, ok := comp[suffix]; ok {
+				return append(msg, byte(0xc0|ptr>>8), byte(ptr)), nil
+			}
+
+			/* pointers can only address the first 16KB */
+			if len(msg) < 0x4000 {
+				comp[suffix] = len(msg)
+			}
+		}
+
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+
+	return append(msg, 0x00), nil
+}
+
+// Decode the name starting at the given offset of msg, and return it along
+// with the offset following it.
+func unpack_name(msg []byte, off int) (string, int, error) {
+	var labels []string
+
+	next := -1
+	pointers := 0
+
+	for {
+		if off >= len(msg) {
+			return "", 0, fmt.Errorf("Invalid name offset: %d", off)
+		}
+
+		length := int(msg[off])
+
+		switch length & 0xc0 {
+		case 0x00:
+			if length == 0 {
+				if next < 0 {
+					next = off + 1
+				}
+
+				return strings.Join(labels, "."), next, nil
+			}
+
+			if off+1+length > len(msg) {
+				return "", 0, fmt.Errorf("Invalid label length: %d", length)
+			}
+
+			labels = append(labels, string(msg[off+1:off+1+length]))
+			off += 1 + length
+
+		case 0xc0:
+			if off+2 > len(msg) {
+				return "", 0, fmt.Errorf("Invalid name offset: %d", off)
+			}
+
+			pointers++
+			if pointers > max_pointers {
+				return "", 0, fmt.Errorf("Invalid name pointer: %d", off)
+			}
+
+			if next < 0 {
+				next = off + 2
+			}
+
+			off = (length&0x3f)<<8 | int(msg[off+1])
+
+		default:
+			return "", 0, fmt.Errorf("Invalid label type: %x", length)
+		}
+	}
+}
+
+/* compare two names case-insensitively, ignoring the trailing dot */
+func equal_names(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."),
+		strings.TrimSuffix(b, "."))
+}
@@ -0,0 +1,459 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for DNS messages.
+//
+// Domain names are represented without the trailing dot (the root domain is
+// the empty string) and are compressed when packing. Messages carried over
+// TCP are prefixed by their length; the TCP field selects this framing, and
+// it's set automatically when unpacking a TCP payload.
+package dns
+
+import "encoding/binary"
+import "fmt"
+import "strings"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type Packet struct {
+	Id          uint16
+	Flags       Flags
+	Opcode      Opcode
+	RCode       RCode
+	Question    []Question
+	Answer      []RR
+	Authority   []RR
+	Additional  []RR
+	TCP         bool          `cmp:"skip" string:"skip"`
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+type Flags uint16
+
+const (
+	CheckingDisabled   Flags = 0x0010
+	AuthenticData            = 0x0020
+	RecursionAvailable       = 0x0080
+	RecursionDesired         = 0x0100
+	Truncated                = 0x0200
+	Authoritative            = 0x0400
+	Response                 = 0x8000
+)
+
+type Opcode uint8
+
+const (
+	Query  Opcode = 0
+	IQuery        = 1
+	Status        = 2
+	Notify        = 4
+	Update        = 5
+)
+
+type RCode uint8
+
+const (
+	NoError  RCode = 0
+	FormErr        = 1
+	ServFail       = 2
+	NXDomain       = 3
+	NotImp         = 4
+	Refused        = 5
+)
+
+type Question struct {
+	Name  string
+	Type  RRType
+	Class Class
+}
+
+// Resource record. The type specific data is stored in the Data field (e.g. a
+// *A for TypeA records), records of unknown type use *Unknown.
+type RR struct {
+	Name  string
+	Type  RRType
+	Class Class
+	TTL   uint32
+	Data  RData
+}
+
+type RRType uint16
+
+const (
+	TypeA     RRType = 1
+	TypeNS           = 2
+	TypeCNAME        = 5
+	TypeSOA          = 6
+	TypePTR          = 12
+	TypeMX           = 15
+	TypeTXT          = 16
+	TypeAAAA         = 28
+	TypeSRV          = 33
+	TypeOPT          = 41
+	TypeSVCB         = 64
+	TypeHTTPS        = 65
+	TypeANY          = 255
+	TypeCAA          = 257
+)
+
+type Class uint16
+
+const (
+	ClassIN  Class = 1
+	ClassCH        = 3
+	ClassANY       = 255
+)
+
+/* length of the message header */
+const hdr_len = 12
+
+func Make() *Packet {
+	return &Packet{
+		Flags: RecursionDesired,
+	}
+}
+
+// Create a new recursive query for the given name and type.
+func MakeQuery(id uint16, name string, typ RRType) *Packet {
+	p := Make()
+	p.Id = id
+	p.Question = []Question{{Name: name, Type: typ, Class: ClassIN}}
+
+	return p
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.DNS
+}
+
+func (p *Packet) GetLength() uint16 {
+	msg, _ := p.encode()
+
+	if p.TCP {
+		return uint16(len(msg)) + 2
+	}
+
+	return uint16(len(msg))
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	if other == nil || other.GetType() != packet.DNS {
+		return false
+	}
+
+	req := other.(*Packet)
+
+	if p.Flags&Response == 0 || req.Flags&Response != 0 ||
+		p.Id != req.Id || p.Opcode != req.Opcode ||
+		len(p.Question) != len(req.Question) {
+		return false
+	}
+
+	for i, q := range p.Question {
+		if !equal_names(q.Name, req.Question[i].Name) ||
+			q.Type != req.Question[i].Type ||
+			q.Class != req.Question[i].Class {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	msg, err := p.encode()
+	if err != nil {
+		return err
+	}
+
+	if p.TCP {
+		buf.WriteN(uint16(len(msg)))
+	}
+
+	buf.Write(msg)
+
+	return nil
+}
+
+func (p *Packet) encode() ([]byte, error) {
+	var err error
+
+	msg := make([]byte, hdr_len, 512)
+	comp := make(map[string]int)
+
+	flags := uint16(p.Flags) | uint16(p.Opcode&0xf)<<11 | uint16(p.RCode&0xf)
+
+	binary.BigEndian.PutUint16(msg[0:], p.Id)
+	binary.BigEndian.PutUint16(msg[2:], flags)
+	binary.BigEndian.PutUint16(msg[4:], uint16(len(p.Question)))
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(p.Answer)))
+	binary.BigEndian.PutUint16(msg[8:], uint16(len(p.Authority)))
+	binary.BigEndian.PutUint16(msg[10:], uint16(len(p.Additional)))
+
+	for _, q := range p.Question {
+		msg, err = pack_name(msg, q.Name, comp)
+		if err != nil {
+			return msg, err
+		}
+
+		msg = append(msg, byte(q.Type>>8), byte(q.Type))
+		msg = append(msg, byte(q.Class>>8), byte(q.Class))
+	}
+
+	for _, section := range [][]RR{p.Answer, p.Authority, p.Additional} {
+		for _, rr := range section {
+			msg, err = rr.pack(msg, comp)
+			if err != nil {
+				return msg, err
+			}
+		}
+	}
+
+	if len(msg) > 65535 {
+		return msg, fmt.Errorf("Invalid message length: %d", len(msg))
+	}
+
+	return msg, nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	msg := buf.Bytes()
+
+	if p.TCP {
+		var length uint16
+
+		if buf.Len() < 2 {
+			return fmt.Errorf("Invalid message length: %d", buf.Len())
+		}
+
+		buf.ReadN(&length)
+
+		if int(length) > buf.Len() {
+			return fmt.Errorf("Invalid message length: %d", length)
+		}
+
+		msg = buf.Bytes()[:length]
+	}
+
+	if len(msg) < hdr_len {
+		return fmt.Errorf("Invalid message length: %d", len(msg))
+	}
+
+	flags := binary.BigEndian.Uint16(msg[2:])
+
+	p.Id = binary.BigEndian.Uint16(msg[0:])
+	p.Flags = Flags(flags &^ 0x780f)
+	p.Opcode = Opcode(flags>>11) & 0xf
+	p.RCode = RCode(flags & 0xf)
+
+	p.Question = nil
+	p.Answer = nil
+	p.Authority = nil
+	p.Additional = nil
+
+	off := hdr_len
+
+	for i := 0; i < int(binary.BigEndian.Uint16(msg[4:])); i++ {
+		var q Question
+		var err error
+
+		q.Name, off, err = unpack_name(msg, off)
+		if err != nil {
+			return err
+		}
+
+		if off+4 > len(msg) {
+			return fmt.Errorf("Invalid question length: %d", len(msg)-off)
+		}
+
+		q.Type = RRType(binary.BigEndian.Uint16(msg[off:]))
+		q.Class = Class(binary.BigEndian.Uint16(msg[off+2:]))
+		off += 4
+
+		p.Question = append(p.Question, q)
+	}
+
+	sections := []*[]RR{&p.Answer, &p.Authority, &p.Additional}
+
+	for i, section := range sections {
+		count := int(binary.BigEndian.Uint16(msg[6+i*2:]))
+
+		for j := 0; j < count; j++ {
+			var rr RR
+			var err error
+
+			off, err = rr.unpack(msg, off)
+			if err != nil {
+				return err
+			}
+
+			*section = append(*section, rr)
+		}
+	}
+
+	buf.Next(len(msg))
+
+	return nil
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	return packet.None
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+// Return whether the given data can be decoded as a DNS message. The data must
+// not include the length prefix used over TCP.
+func IsMessage(data []byte) bool {
+	var p Packet
+	var buf packet.Buffer
+
+	buf.Init(data)
+
+	return p.Unpack(&buf) == nil
+}
+
+// Return the OPT pseudo-record of the message (EDNS0), if present.
+func (p *Packet) OPT() *RR {
+	for i := range p.Additional {
+		if p.Additional[i].Type == TypeOPT {
+			return &p.Additional[i]
+		}
+	}
+
+	return nil
+}
+
+func (f Flags) String() string {
+	var flags []string
+
+	if f&Response != 0 {
+		flags = append(flags, "qr")
+	}
+
+	if f&Authoritative != 0 {
+		flags = append(flags, "aa")
+	}
+
+	if f&Truncated != 0 {
+		flags = append(flags, "tc")
+	}
+
+	if f&RecursionDesired != 0 {
+		flags = append(flags, "rd")
+	}
+
+	if f&RecursionAvailable != 0 {
+		flags = append(flags, "ra")
+	}
+
+	if f&AuthenticData != 0 {
+		flags = append(flags, "ad")
+	}
+
+	if f&CheckingDisabled != 0 {
+		flags = append(flags, "cd")
+	}
+
+	return strings.Join(flags, "|")
+}
+
+func (t RRType) String() string {
+	switch t {
+	case TypeA:
+		return "A"
+	case TypeNS:
+		return "NS"
+	case TypeCNAME:
+		return "CNAME"
+	case TypeSOA:
+		return "SOA"
+	case TypePTR:
+		return "PTR"
+	case TypeMX:
+		return "MX"
+	case TypeTXT:
+		return "TXT"
+	case TypeAAAA:
+		return "AAAA"
+	case TypeSRV:
+		return "SRV"
+	case TypeOPT:
+		return "OPT"
+	case TypeSVCB:
+		return "SVCB"
+	case TypeHTTPS:
+		return "HTTPS"
+	case TypeANY:
+		return "ANY"
+	case TypeCAA:
+		return "CAA"
+	default:
+		return fmt.Sprintf("TYPE%d", uint16(t))
+	}
+}
+
+func (r RCode) String() string {
+	switch r {
+	case NoError:
+		return ""
+	case FormErr:
+		return "formerr"
+	case ServFail:
+		return "servfail"
+	case NXDomain:
+		return "nxdomain"
+	case NotImp:
+		return "notimp"
+	case Refused:
+		return "refused"
+	default:
+		return fmt.Sprintf("%d", uint8(r))
+	}
+}
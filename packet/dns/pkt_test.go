@@ -0,0 +1,250 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package dns_test
+
+import "bytes"
+import "net"
+import "reflect"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/dns"
+
+var test_simple = []byte{
+	0x12, 0x34, 0x81, 0x80, 0x00, 0x01, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00,
+	0x03, 0x77, 0x77, 0x77, 0x07, 0x65, 0x78, 0x61, 0x6d, 0x70, 0x6c, 0x65,
+	0x03, 0x63, 0x6f, 0x6d, 0x00, 0x00, 0x01, 0x00, 0x01, 0xc0, 0x0c, 0x00,
+	0x05, 0x00, 0x01, 0x00, 0x00, 0x01, 0x2c, 0x00, 0x02, 0xc0, 0x10, 0xc0,
+	0x10, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x2c, 0x00, 0x04, 0x5d,
+	0xb8, 0xd8, 0x22,
+}
+
+func MakeTestSimple() *dns.Packet {
+	return &dns.Packet{
+		Id:    0x1234,
+		Flags: dns.Response | dns.RecursionDesired | dns.RecursionAvailable,
+		Question: []dns.Question{
+			{Name: "www.example.com", Type: dns.TypeA, Class: dns.ClassIN},
+		},
+		Answer: []dns.RR{
+			{
+				Name:  "www.example.com",
+				Type:  dns.TypeCNAME,
+				Class: dns.ClassIN,
+				TTL:   300,
+				Data:  &dns.CNAME{Target: "example.com"},
+			},
+			{
+				Name:  "example.com",
+				Type:  dns.TypeA,
+				Class: dns.ClassIN,
+				TTL:   300,
+				Data:  &dns.A{Addr: net.ParseIP("93.184.216.34")},
+			},
+		},
+	}
+}
+
+func TestPack(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_simple, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPack(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	var p dns.Packet
+
+	cmp := MakeTestSimple()
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpack(bn *testing.B) {
+	var p dns.Packet
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_simple)
+		p.Unpack(&b)
+	}
+}
+
+func TestAnswers(t *testing.T) {
+	req := dns.MakeQuery(0x1234, "WWW.example.com.", dns.TypeA)
+	rsp := MakeTestSimple()
+
+	if !rsp.Answers(req) {
+		t.Fatalf("Response mismatch:\n%s\n%s", rsp, req)
+	}
+
+	req.Id++
+
+	if rsp.Answers(req) {
+		t.Fatalf("Response answers query with different id")
+	}
+
+	req = dns.MakeQuery(0x1234, "www.example.com", dns.TypeAAAA)
+
+	if rsp.Answers(req) {
+		t.Fatalf("Response answers different question")
+	}
+}
+
+func TestRecords(t *testing.T) {
+	p := dns.MakeQuery(0xbeef, "example.com", dns.TypeANY)
+	p.Flags |= dns.Response | dns.Authoritative
+
+	p.Answer = []dns.RR{
+		{"example.com", dns.TypeAAAA, dns.ClassIN, 60,
+			&dns.AAAA{Addr: net.ParseIP("2001:db8::1")}},
+		{"example.com", dns.TypeMX, dns.ClassIN, 60,
+			&dns.MX{Preference: 10, Exchange: "mail.example.com"}},
+		{"example.com", dns.TypeNS, dns.ClassIN, 60,
+			&dns.NS{Host: "ns1.example.com"}},
+		{"example.com", dns.TypeTXT, dns.ClassIN, 60,
+			&dns.TXT{Strings: []string{"v=spf1 -all", "hello"}}},
+		{"_sip._udp.example.com", dns.TypeSRV, dns.ClassIN, 60,
+			&dns.SRV{Priority: 1, Weight: 5, Port: 5060,
+				Target: "sip.example.com"}},
+		{"example.com", dns.TypeCAA, dns.ClassIN, 60,
+			&dns.CAA{Tag: "issue", Value: []byte("letsencrypt.org")}},
+		{"example.com", dns.TypeHTTPS, dns.ClassIN, 60,
+			&dns.SVCB{Priority: 1, Params: []dns.SvcParam{
+				dns.MakeALPN("h2", "h3"),
+			}}},
+		{"34.216.184.93.in-addr.arpa", dns.TypePTR, dns.ClassIN, 60,
+			&dns.PTR{Target: "example.com"}},
+	}
+
+	p.Authority = []dns.RR{
+		{"example.com", dns.TypeSOA, dns.ClassIN, 3600,
+			&dns.SOA{MName: "ns1.example.com",
+				RName: "hostmaster.example.com", Serial: 2024010101,
+				Refresh: 7200, Retry: 900, Expire: 1209600,
+				Minimum: 300}},
+	}
+
+	p.Additional = []dns.RR{
+		dns.MakeOPT(1232, true, dns.EDNSOption{
+			Code: dns.Cookie,
+			Data: []byte{1, 2, 3, 4, 5, 6, 7, 8},
+		}),
+	}
+
+	var b packet.Buffer
+	b.Init(make([]byte, p.GetLength()))
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	var u dns.Packet
+	b.Init(b.Buffer())
+
+	err = u.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !u.Equals(p) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &u, p)
+	}
+
+	/* the record data is not compared by Equals */
+	for i, rr := range u.Answer {
+		if !reflect.DeepEqual(rr.Data, p.Answer[i].Data) {
+			t.Fatalf("Record mismatch: %v %v", rr.Data, p.Answer[i].Data)
+		}
+	}
+
+	if !reflect.DeepEqual(u.Authority[0].Data, p.Authority[0].Data) {
+		t.Fatalf("Record mismatch: %v", u.Authority[0].Data)
+	}
+
+	alpn := u.Answer[6].Data.(*dns.SVCB).ALPN()
+	if len(alpn) != 2 || alpn[0] != "h2" || alpn[1] != "h3" {
+		t.Fatalf("ALPN mismatch: %v", alpn)
+	}
+
+	opt := u.OPT()
+	if opt == nil || opt.Class != 1232 || opt.TTL != 0x8000 {
+		t.Fatalf("OPT mismatch: %v", opt)
+	}
+}
+
+func TestUnpackPointerLoop(t *testing.T) {
+	var p dns.Packet
+
+	raw := []byte{
+		0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xc0, 0x0c, 0x00, 0x01, 0x00, 0x01,
+	}
+
+	var b packet.Buffer
+	b.Init(raw)
+
+	err := p.Unpack(&b)
+	if err == nil {
+		t.Fatalf("Unpacked name with pointer loop: %s", &p)
+	}
+}
@@ -0,0 +1,629 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package dns
+
+import "encoding/binary"
+import "fmt"
+import "net"
+
+// RData is the interface implemented by the type specific data of resource
+// records (e.g. *A, *MX or *SVCB).
+type RData interface {
+	pack(msg []byte, comp map[string]int) ([]byte, error)
+	unpack(msg []byte, off, end int) error
+}
+
+type A struct {
+	Addr net.IP
+}
+
+type AAAA struct {
+	Addr net.IP
+}
+
+type NS struct {
+	Host string
+}
+
+type CNAME struct {
+	Target string
+}
+
+type PTR struct {
+	Target string
+}
+
+type MX struct {
+	Preference uint16
+	Exchange   string
+}
+
+type SOA struct {
+	MName   string
+	RName   string
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	Minimum uint32
+}
+
+type TXT struct {
+	Strings []string
+}
+
+type SRV struct {
+	Priority uint16
+	Weight   uint16
+	Port     uint16
+	Target   string
+}
+
+// Certification Authority Authorization record (RFC8659).
+type CAA struct {
+	Flags uint8
+	Tag   string
+	Value []byte
+}
+
+// Service Binding record (RFC9460), used by both SVCB and HTTPS records.
+type SVCB struct {
+	Priority uint16
+	Target   string
+	Params   []SvcParam
+}
+
+// Parameter of SVCB and HTTPS records. Parameters must be sorted by key.
+type SvcParam struct {
+	Key   SvcParamKey
+	Value []byte
+}
+
+type SvcParamKey uint16
+
+const (
+	Mandatory     SvcParamKey = 0
+	ALPN                      = 1
+	NoDefaultALPN             = 2
+	Port                      = 3
+	IPv4Hint                  = 4
+	ECH                       = 5
+	IPv6Hint                  = 6
+)
+
+// Data of the OPT pseudo-record (RFC6891). The requestor's UDP payload size
+// is stored in the Class field of the record, and the extended RCODE, version
+// and flags in its TTL field.
+type OPT struct {
+	Options []EDNSOption
+}
+
+type EDNSOption struct {
+	Code EDNSCode
+	Data []byte
+}
+
+type EDNSCode uint16
+
+const (
+	NSID         EDNSCode = 3
+	ClientSubnet          = 8
+	Cookie                = 10
+	Padding               = 12
+)
+
+// Data of records of unknown type.
+type Unknown struct {
+	Data []byte
+}
+
+/* DNSSEC OK bit of the OPT record TTL */
+const opt_do = 0x8000
+
+// Create a new OPT pseudo-record with the given UDP payload size.
+func MakeOPT(udp_size uint16, dnssec_ok bool, opts ...EDNSOption) RR {
+	rr := RR{
+		Type:  TypeOPT,
+		Class: Class(udp_size),
+		Data:  &OPT{Options: opts},
+	}
+
+	if dnssec_ok {
+		rr.TTL |= opt_do
+	}
+
+	return rr
+}
+
+func (rr RR) pack(msg []byte, comp map[string]int) ([]byte, error) {
+	msg, err := pack_name(msg, rr.Name, comp)
+	if err != nil {
+		return msg, err
+	}
+
+	msg = append(msg, byte(rr.Type>>8), byte(rr.Type))
+	msg = append(msg, byte(rr.Class>>8), byte(rr.Class))
+	msg = append(msg, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	binary.BigEndian.PutUint32(msg[len(msg)-6:], rr.TTL)
+
+	start := len(msg)
+
+	if rr.Data != nil {
+		msg, err = rr.Data.pack(msg, comp)
+		if err != nil {
+			return msg, err
+		}
+	}
+
+	rd_len := len(msg) - start
+	if rd_len > 65535 {
+		return msg, fmt.Errorf("Invalid data length: %d", rd_len)
+	}
+
+	binary.BigEndian.PutUint16(msg[start-2:], uint16(rd_len))
+
+	return msg, nil
+}
+
+func (rr *RR) unpack(msg []byte, off int) (int, error) {
+	var err error
+
+	rr.Name, off, err = unpack_name(msg, off)
+	if err != nil {
+		return 0, err
+	}
+
+	if off+10 > len(msg) {
+		return 0, fmt.Errorf("Invalid record length: %d", len(msg)-off)
+	}
+
+	rr.Type = RRType(binary.BigEndian.Uint16(msg[off:]))
+	rr.Class = Class(binary.BigEndian.Uint16(msg[off+2:]))
+	rr.TTL = binary.BigEndian.Uint32(msg[off+4:])
+
+	rd_len := int(binary.BigEndian.Uint16(msg[off+8:]))
+	off += 10
+
+	if off+rd_len > len(msg) {
+		return 0, fmt.Errorf("Invalid data length: %d", rd_len)
+	}
+
+	/* empty records are used by dynamic updates */
+	if rd_len == 0 && rr.Type != TypeOPT {
+		rr.Data = nil
+		return off, nil
+	}
+
+	switch rr.Type {
+	case TypeA:
+		rr.Data = &A{}
+	case TypeNS:
+		rr.Data = &NS{}
+	case TypeCNAME:
+		rr.Data = &CNAME{}
+	case TypeSOA:
+		rr.Data = &SOA{}
+	case TypePTR:
+		rr.Data = &PTR{}
+	case TypeMX:
+		rr.Data = &MX{}
+	case TypeTXT:
+		rr.Data = &TXT{}
+	case TypeAAAA:
+		rr.Data = &AAAA{}
+	case TypeSRV:
+		rr.Data = &SRV{}
+	case TypeOPT:
+		rr.Data = &OPT{}
+	case TypeSVCB, TypeHTTPS:
+		rr.Data = &SVCB{}
+	case TypeCAA:
+		rr.Data = &CAA{}
+	default:
+		rr.Data = &Unknown{}
+	}
+
+	err = rr.Data.unpack(msg, off, off+rd_len)
+	if err != nil {
+		return 0, err
+	}
+
+	return off + rd_len, nil
+}
+
+/* decode a name that must end exactly at the given offset */
+func unpack_name_at(msg []byte, off, end int) (string, error) {
+	name, next, err := unpack_name(msg[:end], off)
+	if err != nil {
+		return "", err
+	}
+
+	if next != end {
+		return "", fmt.Errorf("Invalid data length: %d", end-off)
+	}
+
+	return name, nil
+}
+
+func (d *A) pack(msg []byte, comp map[string]int) ([]byte, error) {
+	addr := d.Addr.To4()
+	if addr == nil {
+		return msg, fmt.Errorf("Invalid IPv4 address: %s", d.Addr)
+	}
+
+	return append(msg, addr...), nil
+}
+
+func (d *A) unpack(msg []byte, off, end int) error {
+	if end-off != 4 {
+		return fmt.Errorf("Invalid data length: %d", end-off)
+	}
+
+	d.Addr = net.IPv4(msg[off], msg[off+1], msg[off+2], msg[off+3])
+
+	return nil
+}
+
+func (d *AAAA) pack(msg []byte, comp map[string]int) ([]byte, error) {
+	addr := d.Addr.To16()
+	if addr == nil {
+		return msg, fmt.Errorf("Invalid IPv6 address: %s", d.Addr)
+	}
+
+	return append(msg, addr...), nil
+}
+
+func (d *AAAA) unpack(msg []byte, off, end int) error {
+	if end-off != 16 {
+		return fmt.Errorf("Invalid data length: %d", end-off)
+	}
+
+	d.Addr = append(net.IP(nil), msg[off:end]...)
+
+	return nil
+}
+
+func (d *NS) pack(msg []byte, comp map[string]int) ([]byte, error) {
+	return pack_name(msg, d.Host, comp)
+}
+
+func (d *NS) unpack(msg []byte, off, end int) (err error) {
+	d.Host, err = unpack_name_at(msg, off, end)
+	return
+}
+
+func (d *CNAME) pack(msg []byte, comp map[string]int) ([]byte, error) {
+	return pack_name(msg, d.Target, comp)
+}
+
+func (d *CNAME) unpack(msg []byte, off, end int) (err error) {
+	d.Target, err = unpack_name_at(msg, off, end)
+	return
+}
+
+func (d *PTR) pack(msg []byte, comp map[string]int) ([]byte, error) {
+	return pack_name(msg, d.Target, comp)
+}
+
+func (d *PTR) unpack(msg []byte, off, end int) (err error) {
+	d.Target, err = unpack_name_at(msg, off, end)
+	return
+}
+
+func (d *MX) pack(msg []byte, comp map[string]int) ([]byte, error) {
+	msg = append(msg, byte(d.Preference>>8), byte(d.Preference))
+
+	return pack_name(msg, d.Exchange, comp)
+}
+
+func (d *MX) unpack(msg []byte, off, end int) (err error) {
+	if end-off < 3 {
+		return fmt.Errorf("Invalid data length: %d", end-off)
+	}
+
+	d.Preference = binary.BigEndian.Uint16(msg[off:])
+	d.Exchange, err = unpack_name_at(msg, off+2, end)
+
+	return
+}
+
+func (d *SOA) pack(msg []byte, comp map[string]int) ([]byte, error) {
+	msg, err := pack_name(msg, d.MName, comp)
+	if err != nil {
+		return msg, err
+	}
+
+	msg, err = pack_name(msg, d.RName, comp)
+	if err != nil {
+		return msg, err
+	}
+
+	for _, val := range []uint32{
+		d.Serial, d.Refresh, d.Retry, d.Expire, d.Minimum,
+	} {
+		msg = append(msg, byte(val>>24), byte(val>>16), byte(val>>8),
+			byte(val))
+	}
+
+	return msg, nil
+}
+
+func (d *SOA) unpack(msg []byte, off, end int) error {
+	var err error
+
+	d.MName, off, err = unpack_name(msg[:end], off)
+	if err != nil {
+		return err
+	}
+
+	d.RName, off, err = unpack_name(msg[:end], off)
+	if err != nil {
+		return err
+	}
+
+	if end-off != 20 {
+		return fmt.Errorf("Invalid data length: %d", end-off)
+	}
+
+	d.Serial = binary.BigEndian.Uint32(msg[off:])
+	d.Refresh = binary.BigEndian.Uint32(msg[off+4:])
+	d.Retry = binary.BigEndian.Uint32(msg[off+8:])
+	d.Expire = binary.BigEndian.Uint32(msg[off+12:])
+	d.Minimum = binary.BigEndian.Uint32(msg[off+16:])
+
+	return nil
+}
+
+func (d *TXT) pack(msg []byte, comp map[string]int) ([]byte, error) {
+	for _, s := range d.Strings {
+		if len(s) > 255 {
+			return msg, fmt.Errorf("Invalid string length: %d", len(s))
+		}
+
+		msg = append(msg, byte(len(s)))
+		msg = append(msg, s...)
+	}
+
+	return msg, nil
+}
+
+func (d *TXT) unpack(msg []byte, off, end int) error {
+	d.Strings = nil
+
+	for off < end {
+		length := int(msg[off])
+		if off+1+length > end {
+			return fmt.Errorf("Invalid string length: %d", length)
+		}
+
+		d.Strings = append(d.Strings, string(msg[off+1:off+1+length]))
+		off += 1 + length
+	}
+
+	return nil
+}
+
+func (d *SRV) pack(msg []byte, comp map[string]int) ([]byte, error) {
+	msg = append(msg, byte(d.Priority>>8), byte(d.Priority))
+	msg = append(msg, byte(d.Weight>>8), byte(d.Weight))
+	msg = append(msg, byte(d.Port>>8), byte(d.Port))
+
+	/* the target must not be compressed (RFC2782) */
+	return pack_name(msg, d.Target, nil)
+}
+
+func (d *SRV) unpack(msg []byte, off, end int) (err error) {
+	if end-off < 7 {
+		return fmt.Errorf("Invalid data length: %d", end-off)
+	}
+
+	d.Priority = binary.BigEndian.Uint16(msg[off:])
+	d.Weight = binary.BigEndian.Uint16(msg[off+2:])
+	d.Port = binary.BigEndian.Uint16(msg[off+4:])
+	d.Target, err = unpack_name_at(msg, off+6, end)
+
+	return
+}
+
+func (d *CAA) pack(msg []byte, comp map[string]int) ([]byte, error) {
+	if len(d.Tag) == 0 || len(d.Tag) > 255 {
+		return msg, fmt.Errorf("Invalid tag length: %d", len(d.Tag))
+	}
+
+	msg = append(msg, d.Flags, byte(len(d.Tag)))
+	msg = append(msg, d.Tag...)
+
+	return append(msg, d.Value...), nil
+}
+
+func (d *CAA) unpack(msg []byte, off, end int) error {
+	if end-off < 2 || off+2+int(msg[off+1]) > end {
+		return fmt.Errorf("Invalid data length: %d", end-off)
+	}
+
+	tag_len := int(msg[off+1])
+
+	d.Flags = msg[off]
+	d.Tag = string(msg[off+2 : off+2+tag_len])
+	d.Value = msg[off+2+tag_len : end]
+
+	return nil
+}
+
+func (d *SVCB) pack(msg []byte, comp map[string]int) ([]byte, error) {
+	msg = append(msg, byte(d.Priority>>8), byte(d.Priority))
+
+	/* the target must not be compressed (RFC9460) */
+	msg, err := pack_name(msg, d.Target, nil)
+	if err != nil {
+		return msg, err
+	}
+
+	for _, param := range d.Params {
+		if len(param.Value) > 65535 {
+			return msg, fmt.Errorf("Invalid param length: %d",
+				len(param.Value))
+		}
+
+		msg = append(msg, byte(param.Key>>8), byte(param.Key))
+		msg = append(msg, byte(len(param.Value)>>8), byte(len(param.Value)))
+		msg = append(msg, param.Value...)
+	}
+
+	return msg, nil
+}
+
+func (d *SVCB) unpack(msg []byte, off, end int) error {
+	var err error
+
+	if end-off < 3 {
+		return fmt.Errorf("Invalid data length: %d", end-off)
+	}
+
+	d.Priority = binary.BigEndian.Uint16(msg[off:])
+
+	d.Target, off, err = unpack_name(msg[:end], off+2)
+	if err != nil {
+		return err
+	}
+
+	d.Params = nil
+
+	for off < end {
+		if off+4 > end {
+			return fmt.Errorf("Invalid param length: %d", end-off)
+		}
+
+		key := SvcParamKey(binary.BigEndian.Uint16(msg[off:]))
+		length := int(binary.BigEndian.Uint16(msg[off+2:]))
+
+		if off+4+length > end {
+			return fmt.Errorf("Invalid param length: %d", length)
+		}
+
+		d.Params = append(d.Params, SvcParam{
+			Key:   key,
+			Value: msg[off+4 : off+4+length],
+		})
+
+		off += 4 + length
+	}
+
+	return nil
+}
+
+// Return the value of the given parameter.
+func (d *SVCB) Param(key SvcParamKey) ([]byte, bool) {
+	for _, param := range d.Params {
+		if param.Key == key {
+			return param.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// Return the protocol identifiers of the ALPN parameter.
+func (d *SVCB) ALPN() []string {
+	var ids []string
+
+	value, _ := d.Param(ALPN)
+
+	for len(value) > 0 && len(value) > int(value[0]) {
+		ids = append(ids, string(value[1:1+value[0]]))
+		value = value[1+value[0]:]
+	}
+
+	return ids
+}
+
+// Create a new ALPN parameter for the given protocol identifiers.
+func MakeALPN(ids ...string) SvcParam {
+	var value []byte
+
+	for _, id := range ids {
+		value = append(value, byte(len(id)))
+		value = append(value, id...)
+	}
+
+	return SvcParam{Key: ALPN, Value: value}
+}
+
+func (d *OPT) pack(msg []byte, comp map[string]int) ([]byte, error) {
+	for _, opt := range d.Options {
+		if len(opt.Data) > 65535 {
+			return msg, fmt.Errorf("Invalid option length: %d",
+				len(opt.Data))
+		}
+
+		msg = append(msg, byte(opt.Code>>8), byte(opt.Code))
+		msg = append(msg, byte(len(opt.Data)>>8), byte(len(opt.Data)))
+		msg = append(msg, opt.Data...)
+	}
+
+	return msg, nil
+}
+
+func (d *OPT) unpack(msg []byte, off, end int) error {
+	d.Options = nil
+
+	for off < end {
+		if off+4 > end {
+			return fmt.Errorf("Invalid option length: %d", end-off)
+		}
+
+		code := EDNSCode(binary.BigEndian.Uint16(msg[off:]))
+		length := int(binary.BigEndian.Uint16(msg[off+2:]))
+
+		if off+4+length > end {
+			return fmt.Errorf("Invalid option length: %d", length)
+		}
+
+		d.Options = append(d.Options, EDNSOption{
+			Code: code,
+			Data: msg[off+4 : off+4+length],
+		})
+
+		off += 4 + length
+	}
+
+	return nil
+}
+
+func (d *Unknown) pack(msg []byte, comp map[string]int) ([]byte, error) {
+	return append(msg, d.Data...), nil
+}
+
+func (d *Unknown) unpack(msg []byte, off, end int) error {
+	d.Data = msg[off:end]
+
+	return nil
+}
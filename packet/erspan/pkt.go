@@ -0,0 +1,205 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for ERSPAN (Encapsulated Remote SPAN) type II
+// and type III packets.
+package erspan
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type Packet struct {
+	Version     uint8 `string:"ver"`
+	VLAN        uint16
+	COS         uint8
+	Encap       uint8  `string:"en"`
+	Truncated   bool   `string:"trunc"`
+	SessionID   uint16 `string:"session"`
+	Index       uint32
+	Timestamp   uint32 `string:"ts"`
+	SGT         uint16
+	PDUFrame    bool  `string:"pdu"`
+	FrameType   uint8 `string:"ft"`
+	HwID        uint8 `string:"hwid"`
+	Egress      bool
+	Granularity uint8         `string:"gra"`
+	Platform    []byte        `string:"skip"`
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+const (
+	TypeII  uint8 = 1
+	TypeIII       = 2
+)
+
+func Make() *Packet {
+	return &Packet{
+		Version: TypeII,
+	}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.ERSPAN
+}
+
+func (p *Packet) GetLength() uint16 {
+	if p.pkt_payload != nil {
+		return p.pkt_payload.GetLength() + p.header_len()
+	}
+
+	return p.header_len()
+}
+
+func (p *Packet) header_len() uint16 {
+	if p.Version == TypeIII {
+		return 12 + uint16(len(p.Platform))
+	}
+
+	return 8
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	return false
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	buf.WriteN(uint16(p.Version)<<12 | p.VLAN&0x0fff)
+
+	sess := uint16(p.COS&0x07)<<13 | uint16(p.Encap&0x03)<<11 |
+		p.SessionID&0x03ff
+	if p.Truncated {
+		sess |= 1 << 10
+	}
+
+	buf.WriteN(sess)
+
+	if p.Version != TypeIII {
+		buf.WriteN(p.Index & 0x000fffff)
+		return nil
+	}
+
+	buf.WriteN(p.Timestamp)
+	buf.WriteN(p.SGT)
+
+	var flags uint16
+
+	if p.PDUFrame {
+		flags |= 1 << 15
+	}
+
+	flags |= uint16(p.FrameType&0x1f) << 10
+	flags |= uint16(p.HwID&0x3f) << 4
+
+	if p.Egress {
+		flags |= 1 << 3
+	}
+
+	flags |= uint16(p.Granularity&0x03) << 1
+
+	if len(p.Platform) > 0 {
+		flags |= 1
+	}
+
+	buf.WriteN(flags)
+	buf.Write(p.Platform)
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	var ver_vlan uint16
+	buf.ReadN(&ver_vlan)
+
+	p.Version = uint8(ver_vlan >> 12)
+	p.VLAN = ver_vlan & 0x0fff
+
+	var sess uint16
+	buf.ReadN(&sess)
+
+	p.COS = uint8(sess >> 13)
+	p.Encap = uint8(sess>>11) & 0x03
+	p.Truncated = sess&(1<<10) != 0
+	p.SessionID = sess & 0x03ff
+
+	if p.Version != TypeIII {
+		buf.ReadN(&p.Index)
+		p.Index &= 0x000fffff
+
+		return nil
+	}
+
+	buf.ReadN(&p.Timestamp)
+	buf.ReadN(&p.SGT)
+
+	var flags uint16
+	buf.ReadN(&flags)
+
+	p.PDUFrame = flags&(1<<15) != 0
+	p.FrameType = uint8(flags>>10) & 0x1f
+	p.HwID = uint8(flags>>4) & 0x3f
+	p.Egress = flags&(1<<3) != 0
+	p.Granularity = uint8(flags>>1) & 0x03
+
+	/* optional platform specific subheader */
+	if flags&1 != 0 {
+		p.Platform = buf.Next(8)
+	}
+
+	return nil
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	/* type III can also carry IP packets, but their version is unknown */
+	if p.Version == TypeIII && p.FrameType != 0 {
+		return packet.Raw
+	}
+
+	return packet.Eth
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
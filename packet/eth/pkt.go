@@ -48,16 +48,19 @@ type Packet struct {
 type EtherType uint16
 
 const (
-	None  EtherType = 0x0000
-	ARP             = 0x0806
-	IPv4            = 0x0800
-	IPv6            = 0x86dd
-	LLC             = 0x0001 /* pseudo ethertype */
-	LLDP            = 0x088cc
-	QinQ            = 0x88a8
-	TRILL           = 0x22f3
-	VLAN            = 0x8100
-	WoL             = 0x0842
+	None    EtherType = 0x0000
+	ARP               = 0x0806
+	ERSPAN            = 0x88be
+	ERSPAN3           = 0x22eb
+	IPv4              = 0x0800
+	IPv6              = 0x86dd
+	LLC               = 0x0001 /* pseudo ethertype */
+	LLDP              = 0x088cc
+	QinQ              = 0x88a8
+	TEB               = 0x6558
+	TRILL             = 0x22f3
+	VLAN              = 0x8100
+	WoL               = 0x0842
 )
 
 func Make() *Packet {
@@ -137,10 +140,10 @@ func (p *Packet) GuessPayloadType() packet.Type {
 
 func (p *Packet) SetPayload(pl packet.Packet) error {
 	p.pkt_payload = pl
-	p.Type = TypeToEtherType(pl.GetType())
+	p.Type = PayloadToEtherType(p.Type, pl)
 
 	if p.Type < 0x0600 {
-		p.Length = p.GetLength()
+		p.Length = pl.GetLength()
 	}
 
 	return nil
@@ -154,16 +157,19 @@ func (p *Packet) String() string {
 }
 
 var ethertype_to_type_map = map[EtherType]packet.Type{
-	None:  packet.None,
-	ARP:   packet.ARP,
-	IPv4:  packet.IPv4,
-	IPv6:  packet.IPv6,
-	LLC:   packet.LLC,
-	LLDP:  packet.LLDP,
-	VLAN:  packet.VLAN,
-	QinQ:  packet.VLAN,
-	TRILL: packet.TRILL,
-	WoL:   packet.WoL,
+	None:    packet.None,
+	ARP:     packet.ARP,
+	ERSPAN:  packet.ERSPAN,
+	ERSPAN3: packet.ERSPAN,
+	IPv4:    packet.IPv4,
+	IPv6:    packet.IPv6,
+	LLC:     packet.LLC,
+	LLDP:    packet.LLDP,
+	VLAN:    packet.VLAN,
+	QinQ:    packet.VLAN,
+	TEB:     packet.Eth,
+	TRILL:   packet.TRILL,
+	WoL:     packet.WoL,
 }
 
 // Create a new Type from the given EtherType.
@@ -184,6 +190,10 @@ func TypeToEtherType(pkttype packet.Type) EtherType {
 		return VLAN
 	}
 
+	if pkttype == packet.ERSPAN {
+		return ERSPAN
+	}
+
 	for e, t := range ethertype_to_type_map {
 		if t == pkttype {
 			return e
@@ -193,10 +203,35 @@ func TypeToEtherType(pkttype packet.Type) EtherType {
 	return None
 }
 
+// Return the EtherType to be used by a header carrying the given payload.
+// Unlike TypeToEtherType() this also selects the right TPID for VLAN tags: the
+// outer tags of a stack are S-tags (QinQ) and the innermost one is a C-tag,
+// unless the current EtherType already marks it as an S-tag.
+func PayloadToEtherType(cur EtherType, pl packet.Packet) EtherType {
+	if pl.GetType() != packet.VLAN {
+		return TypeToEtherType(pl.GetType())
+	}
+
+	inner := pl.Payload()
+	if inner != nil && inner.GetType() == packet.VLAN {
+		return QinQ
+	}
+
+	if cur == QinQ {
+		return QinQ
+	}
+
+	return VLAN
+}
+
 func (t EtherType) String() string {
 	switch t {
 	case ARP:
 		return "ARP"
+	case ERSPAN:
+		return "ERSPAN"
+	case ERSPAN3:
+		return "ERSPAN3"
 	case IPv4:
 		return "IPv4"
 	case IPv6:
@@ -209,6 +244,8 @@ func (t EtherType) String() string {
 		return "None"
 	case QinQ:
 		return "QinQ"
+	case TEB:
+		return "TEB"
 	case TRILL:
 		return "TRILL"
 	case VLAN:
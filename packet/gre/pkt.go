@@ -0,0 +1,331 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for GRE packets, including the enhanced GRE
+// header (version 1) used by PPTP.
+package gre
+
+import "fmt"
+import "strings"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/erspan"
+import "github.com/scs-solution/go.pkt2/packet/eth"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+
+type Packet struct {
+	Flags       Flags
+	Recursion   uint8         `string:"recur"`
+	Version     uint8         `string:"ver"`
+	Protocol    eth.EtherType `string:"proto"`
+	Checksum    uint16        `string:"sum"`
+	Offset      uint16        `string:"off"`
+	Key         uint32
+	PayloadLen  uint16 `cmp:"skip" string:"paylen"`
+	CallID      uint16 `string:"call"`
+	Seq         uint32
+	Ack         uint32
+	Routing     []SRE
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+type Flags uint8
+
+// A Source Route Entry (RFC1701), present when the RoutingPresent flag is set.
+type SRE struct {
+	Family uint16 `string:"af"`
+	Offset uint8  `string:"off"`
+	Info   []byte
+}
+
+const (
+	ChecksumPresent Flags = 1 << 7
+	RoutingPresent        = 1 << 6
+	KeyPresent            = 1 << 5
+	SeqPresent            = 1 << 4
+	StrictRoute           = 1 << 3
+	AckPresent            = 1 << 0
+)
+
+const (
+	PPP eth.EtherType = 0x880b
+)
+
+func Make() *Packet {
+	return &Packet{}
+}
+
+// Create a new enhanced GRE packet (version 1), as used by PPTP.
+func MakePPTP(call_id uint16) *Packet {
+	return &Packet{
+		Flags:    KeyPresent,
+		Version:  1,
+		Protocol: PPP,
+		CallID:   call_id,
+	}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.GRE
+}
+
+func (p *Packet) GetLength() uint16 {
+	if p.pkt_payload != nil {
+		return p.pkt_payload.GetLength() + p.header_len()
+	}
+
+	return p.header_len()
+}
+
+func (p *Packet) header_len() uint16 {
+	length := uint16(4)
+
+	if p.Flags&(ChecksumPresent|RoutingPresent) != 0 {
+		length += 4
+	}
+
+	if p.Flags&KeyPresent != 0 {
+		length += 4
+	}
+
+	if p.Flags&SeqPresent != 0 {
+		length += 4
+	}
+
+	if p.Version == 1 && p.Flags&AckPresent != 0 {
+		length += 4
+	}
+
+	if p.Flags&RoutingPresent != 0 {
+		for _, sre := range p.Routing {
+			length += 4 + uint16(len(sre.Info))
+		}
+
+		/* terminating null SRE */
+		length += 4
+	}
+
+	return length
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	if other == nil || other.GetType() != packet.GRE {
+		return false
+	}
+
+	/* a PPTP acknowledgment for one of the packets of the same call */
+	if p.Version == 1 && other.(*Packet).Version == 1 {
+		return p.Flags&AckPresent != 0 &&
+			other.(*Packet).Flags&SeqPresent != 0 &&
+			p.Ack == other.(*Packet).Seq
+	}
+
+	return false
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	flags := uint16(p.Flags&0xf8) << 8
+	flags |= uint16(p.Recursion&0x07) << 8
+	flags |= uint16(p.Flags&AckPresent) << 7
+	flags |= uint16(p.Version & 0x07)
+
+	buf.WriteN(flags)
+	buf.WriteN(p.Protocol)
+
+	if p.Flags&(ChecksumPresent|RoutingPresent) != 0 {
+		buf.WriteN(uint16(0x0000))
+		buf.WriteN(p.Offset)
+	}
+
+	if p.Flags&KeyPresent != 0 {
+		if p.Version == 1 {
+			buf.WriteN(p.PayloadLen)
+			buf.WriteN(p.CallID)
+		} else {
+			buf.WriteN(p.Key)
+		}
+	}
+
+	if p.Flags&SeqPresent != 0 {
+		buf.WriteN(p.Seq)
+	}
+
+	if p.Version == 1 && p.Flags&AckPresent != 0 {
+		buf.WriteN(p.Ack)
+	}
+
+	if p.Flags&RoutingPresent != 0 {
+		for _, sre := range p.Routing {
+			buf.WriteN(sre.Family)
+			buf.WriteN(sre.Offset)
+			buf.WriteN(uint8(len(sre.Info)))
+			buf.Write(sre.Info)
+		}
+
+		buf.WriteN(uint32(0x00000000))
+	}
+
+	if p.Flags&ChecksumPresent != 0 {
+		p.Checksum = ipv4.CalculateChecksum(buf.LayerBytes(), 0)
+		buf.PutUint16N(4, p.Checksum)
+	}
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	var flags uint16
+	buf.ReadN(&flags)
+
+	p.Flags = Flags(flags>>8) & 0xf8
+	p.Flags |= Flags(flags>>7) & AckPresent
+	p.Recursion = uint8(flags>>8) & 0x07
+	p.Version = uint8(flags) & 0x07
+
+	buf.ReadN(&p.Protocol)
+
+	if p.Flags&(ChecksumPresent|RoutingPresent) != 0 {
+		buf.ReadN(&p.Checksum)
+		buf.ReadN(&p.Offset)
+	}
+
+	if p.Flags&KeyPresent != 0 {
+		if p.Version == 1 {
+			buf.ReadN(&p.PayloadLen)
+			buf.ReadN(&p.CallID)
+		} else {
+			buf.ReadN(&p.Key)
+		}
+	}
+
+	if p.Flags&SeqPresent != 0 {
+		buf.ReadN(&p.Seq)
+	}
+
+	if p.Version == 1 && p.Flags&AckPresent != 0 {
+		buf.ReadN(&p.Ack)
+	}
+
+	if p.Flags&RoutingPresent != 0 {
+		p.Routing = nil
+
+		for {
+			var sre SRE
+			var sre_len uint8
+
+			if buf.Len() < 4 {
+				return fmt.Errorf("Missing null SRE")
+			}
+
+			buf.ReadN(&sre.Family)
+			buf.ReadN(&sre.Offset)
+			buf.ReadN(&sre_len)
+
+			if sre.Family == 0 && sre_len == 0 {
+				break
+			}
+
+			if int(sre_len) > buf.Len() {
+				return fmt.Errorf("Invalid SRE length: %d", sre_len)
+			}
+
+			sre.Info = append([]byte(nil), buf.Next(int(sre_len))...)
+
+			p.Routing = append(p.Routing, sre)
+		}
+	}
+
+	return nil
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	return eth.EtherTypeToType(p.Protocol)
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+	p.Protocol = eth.PayloadToEtherType(p.Protocol, pl)
+
+	/* ERSPAN type III uses a different protocol type */
+	if e, ok := pl.(*erspan.Packet); ok && e.Version == erspan.TypeIII {
+		p.Protocol = eth.ERSPAN3
+	}
+
+	if p.Version == 1 {
+		p.PayloadLen = pl.GetLength()
+	}
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+func (f Flags) String() string {
+	var flags []string
+
+	if f&ChecksumPresent != 0 {
+		flags = append(flags, "checksum")
+	}
+
+	if f&RoutingPresent != 0 {
+		flags = append(flags, "routing")
+	}
+
+	if f&KeyPresent != 0 {
+		flags = append(flags, "key")
+	}
+
+	if f&SeqPresent != 0 {
+		flags = append(flags, "seq")
+	}
+
+	if f&StrictRoute != 0 {
+		flags = append(flags, "strict")
+	}
+
+	if f&AckPresent != 0 {
+		flags = append(flags, "ack")
+	}
+
+	return strings.Join(flags, "|")
+}
@@ -0,0 +1,248 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package gre_test
+
+import "bytes"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/eth"
+import "github.com/scs-solution/go.pkt2/packet/gre"
+
+var test_simple = []byte{
+	0xb0, 0x00, 0x08, 0x00, 0x47, 0xd4, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2a,
+	0x00, 0x00, 0x00, 0x01,
+}
+
+func MakeTestSimple() *gre.Packet {
+	return &gre.Packet{
+		Flags:    gre.ChecksumPresent | gre.KeyPresent | gre.SeqPresent,
+		Protocol: eth.IPv4,
+		Checksum: 0x47d4,
+		Key:      42,
+		Seq:      1,
+	}
+}
+
+func TestPack(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_simple, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPack(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	var p gre.Packet
+
+	cmp := MakeTestSimple()
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpack(bn *testing.B) {
+	var p gre.Packet
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_simple)
+		p.Unpack(&b)
+	}
+}
+
+var test_pptp = []byte{
+	0x30, 0x81, 0x88, 0x0b, 0x00, 0x00, 0x12, 0x34, 0x00, 0x00, 0x00, 0x05,
+	0x00, 0x00, 0x00, 0x04,
+}
+
+func MakeTestPPTP() *gre.Packet {
+	p := gre.MakePPTP(0x1234)
+	p.Flags |= gre.SeqPresent | gre.AckPresent
+	p.Seq = 5
+	p.Ack = 4
+
+	return p
+}
+
+func TestPackPPTP(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_pptp)))
+
+	p := MakeTestPPTP()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_pptp, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func TestUnpackPPTP(t *testing.T) {
+	var p gre.Packet
+
+	cmp := MakeTestPPTP()
+
+	var b packet.Buffer
+	b.Init(test_pptp)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+
+	if p.GetLength() != uint16(len(test_pptp)) {
+		t.Fatalf("Length mismatch: %d", p.GetLength())
+	}
+}
+
+func TestAnswersPPTP(t *testing.T) {
+	req := gre.MakePPTP(0x1234)
+	req.Flags |= gre.SeqPresent
+	req.Seq = 4
+
+	if !MakeTestPPTP().Answers(req) {
+		t.Fatalf("PPTP acknowledgment does not answer")
+	}
+
+	req.Seq = 5
+
+	if MakeTestPPTP().Answers(req) {
+		t.Fatalf("PPTP acknowledgment answers wrong sequence")
+	}
+}
+
+var test_routing = []byte{
+	0x40, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, 0x00, 0x00, 0x08,
+	0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8, 0x01, 0x02, 0x00, 0x00, 0x00, 0x00,
+}
+
+func MakeTestRouting() *gre.Packet {
+	return &gre.Packet{
+		Flags:    gre.RoutingPresent,
+		Protocol: eth.IPv4,
+		Routing: []gre.SRE{
+			{
+				Family: 0x0800,
+				Info: []byte{
+					0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8, 0x01, 0x02,
+				},
+			},
+		},
+	}
+}
+
+func TestPackRouting(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_routing)))
+
+	p := MakeTestRouting()
+
+	if p.GetLength() != uint16(len(test_routing)) {
+		t.Fatalf("Length mismatch: %d", p.GetLength())
+	}
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_routing, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func TestUnpackRouting(t *testing.T) {
+	var p gre.Packet
+
+	cmp := MakeTestRouting()
+
+	/* the payload must start right after the null SRE */
+	raw := append(append([]byte{}, test_routing...), 0x45, 0x00)
+
+	var b packet.Buffer
+	b.Init(raw)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+
+	if b.Len() != 2 || b.Bytes()[0] != 0x45 {
+		t.Fatalf("Payload offset mismatch: %x", b.Bytes())
+	}
+
+	b.Init(test_routing[:len(test_routing)-4])
+
+	err = p.Unpack(&b)
+	if err == nil {
+		t.Fatalf("Missing null SRE not detected")
+	}
+}
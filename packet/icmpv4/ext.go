@@ -0,0 +1,161 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package icmpv4
+
+import "encoding/binary"
+import "fmt"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+
+// Object of an ICMP multi-part message extension (RFC4884).
+type Extension struct {
+	Class ExtClass
+	CType uint8
+	Data  []byte
+}
+
+type ExtClass uint8
+
+const (
+	MPLSStackClass     ExtClass = 1 /* RFC4950 */
+	InterfaceInfoClass          = 2 /* RFC5837 */
+	InterfaceIdClass            = 3 /* RFC8335 */
+)
+
+// Entry of the MPLS label stack reported by an MPLS Label Stack object.
+type MPLSLabel struct {
+	Label uint32
+	TC    uint8
+	S     bool
+	TTL   uint8
+}
+
+/* version of the extension structure */
+const ext_version = 2
+
+// Create a new MPLS Label Stack extension object for the given labels.
+func MakeMPLSExtension(labels ...MPLSLabel) Extension {
+	data := make([]byte, len(labels)*4)
+
+	for i, label := range labels {
+		entry := label.Label<<12 | uint32(label.TC&0x7)<<9 | uint32(label.TTL)
+		if label.S {
+			entry |= 0x100
+		}
+
+		binary.BigEndian.PutUint32(data[i*4:], entry)
+	}
+
+	return Extension{Class: MPLSStackClass, CType: 1, Data: data}
+}
+
+// Return the entries of an MPLS Label Stack extension object.
+func (e Extension) MPLSLabels() []MPLSLabel {
+	var labels []MPLSLabel
+
+	if e.Class != MPLSStackClass || e.CType != 1 {
+		return nil
+	}
+
+	for i := 0; i+4 <= len(e.Data); i += 4 {
+		entry := binary.BigEndian.Uint32(e.Data[i:])
+
+		labels = append(labels, MPLSLabel{
+			Label: entry >> 12,
+			TC:    uint8(entry>>9) & 0x7,
+			S:     entry&0x100 != 0,
+			TTL:   uint8(entry),
+		})
+	}
+
+	return labels
+}
+
+// Return the MPLS label stack reported by the message, if any.
+func (p *Packet) MPLSLabels() []MPLSLabel {
+	for _, ext := range p.Extensions {
+		if labels := ext.MPLSLabels(); labels != nil {
+			return labels
+		}
+	}
+
+	return nil
+}
+
+func ext_len(exts []Extension) uint16 {
+	length := uint16(4)
+
+	for _, ext := range exts {
+		length += 4 + uint16(len(ext.Data))
+	}
+
+	return length
+}
+
+func pack_ext(buf *packet.Buffer, exts []Extension) {
+	off := buf.LayerLen()
+
+	buf.WriteN(uint8(ext_version << 4))
+	buf.WriteN(uint8(0x00))
+	buf.WriteN(uint16(0x0000))
+
+	for _, ext := range exts {
+		buf.WriteN(uint16(4 + len(ext.Data)))
+		buf.WriteN(ext.Class)
+		buf.WriteN(ext.CType)
+		buf.Write(ext.Data)
+	}
+
+	raw_ext := buf.LayerBytes()[off:buf.LayerLen()]
+	buf.PutUint16N(off+2, ipv4.CalculateChecksum(raw_ext, 0))
+}
+
+func unpack_ext(raw_ext []byte) ([]Extension, error) {
+	var exts []Extension
+
+	for off := 4; off+4 <= len(raw_ext); {
+		obj_len := int(binary.BigEndian.Uint16(raw_ext[off:]))
+		if obj_len < 4 || off+obj_len > len(raw_ext) {
+			return nil, fmt.Errorf("Invalid object length: %d", obj_len)
+		}
+
+		exts = append(exts, Extension{
+			Class: ExtClass(raw_ext[off+2]),
+			CType: raw_ext[off+3],
+			Data:  raw_ext[off+4 : off+obj_len],
+		})
+
+		off += obj_len
+	}
+
+	return exts, nil
+}
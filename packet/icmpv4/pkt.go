@@ -31,7 +31,9 @@
 // Provides encoding and decoding for ICMPv4 packets.
 package icmpv4
 
+import "encoding/binary"
 import "fmt"
+import "net"
 
 import "github.com/scs-solution/go.pkt2/packet"
 import "github.com/scs-solution/go.pkt2/packet/ipv4"
@@ -42,6 +44,8 @@ type Packet struct {
 	Checksum    uint16 `string:"sum"`
 	Id          uint16
 	Seq         uint16
+	Data        []byte /* message data of non-error messages */
+	Extensions  []Extension
 	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
 }
 
@@ -81,11 +85,51 @@ func (p *Packet) GetType() packet.Type {
 }
 
 func (p *Packet) GetLength() uint16 {
+	length := 8 + uint16(len(p.Data))
+
+	if p.pkt_payload != nil {
+		length += p.pkt_payload.GetLength()
+	}
+
+	if len(p.Extensions) > 0 {
+		length = 8 + p.orig_len() + ext_len(p.Extensions)
+	}
+
+	return length
+}
+
+/* length of the original datagram field, padded as required by RFC4884 */
+func (p *Packet) orig_len() uint16 {
+	var length uint16
+
 	if p.pkt_payload != nil {
-		return p.pkt_payload.GetLength() + 8
+		length = p.pkt_payload.GetLength()
+	}
+
+	if length < 128 {
+		return 128
+	}
+
+	return (length + 3) &^ 3
+}
+
+func (p *Packet) is_error() bool {
+	switch p.Type {
+	case DstUnreachable, SrcQuench, RedirectMsg, TimeExceeded, ParamProblem:
+		return true
+	}
+
+	return false
+}
+
+/* whether the message carries the length field defined by RFC4884 */
+func (p *Packet) has_ext() bool {
+	switch p.Type {
+	case DstUnreachable, TimeExceeded, ParamProblem:
+		return true
 	}
 
-	return 8
+	return false
 }
 
 func (p *Packet) Equals(other packet.Packet) bool {
@@ -109,11 +153,43 @@ func (p *Packet) Answers(other packet.Packet) bool {
 }
 
 func (p *Packet) Pack(buf *packet.Buffer) error {
+	if len(p.Extensions) > 0 {
+		if !p.has_ext() {
+			return fmt.Errorf("Invalid type for extensions: %d", p.Type)
+		}
+
+		p.Id = p.Id&0xff00 | p.orig_len()/4
+	}
+
 	buf.WriteN(byte(p.Type))
 	buf.WriteN(byte(p.Code))
 	buf.WriteN(uint16(0x0000))
 	buf.WriteN(p.Id)
 	buf.WriteN(p.Seq)
+	buf.Write(p.Data)
+
+	if len(p.Extensions) > 0 {
+		var pl_len int
+
+		if p.pkt_payload != nil {
+			pl_len = int(p.pkt_payload.GetLength())
+		}
+
+		raw := buf.LayerBytes()
+		length := int(p.GetLength())
+
+		/*
+		 * The payload has already been packed at the end of the layer,
+		 * so it needs to be moved before the extension structure.
+		 */
+		off := buf.LayerLen()
+		copy(raw[off:off+pl_len], raw[length-pl_len:length])
+
+		buf.Next(pl_len)
+		buf.Write(make([]byte, int(p.orig_len())-pl_len))
+
+		pack_ext(buf, p.Extensions)
+	}
 
 	p.Checksum = ipv4.CalculateChecksum(buf.LayerBytes(), 0)
 	buf.PutUint16N(2, p.Checksum)
@@ -128,7 +204,32 @@ func (p *Packet) Unpack(buf *packet.Buffer) error {
 	buf.ReadN(&p.Id)
 	buf.ReadN(&p.Seq)
 
-	/* TODO: data */
+	p.Data = nil
+	p.Extensions = nil
+
+	if !p.is_error() {
+		if buf.Len() > 0 {
+			p.Data = buf.Next(buf.Len())
+		}
+
+		return nil
+	}
+
+	orig_len := int(p.Id&0xff) * 4
+
+	/* messages not compliant with RFC4884 have no extension structure */
+	if p.has_ext() && orig_len > 0 && buf.Len() >= orig_len+4 &&
+		buf.Bytes()[orig_len]>>4 == ext_version {
+		exts, err := unpack_ext(buf.Bytes()[orig_len:])
+		if err != nil {
+			return err
+		}
+
+		p.Extensions = exts
+
+		/* the payload is followed by the extension structure */
+		buf.Truncate(orig_len)
+	}
 
 	return nil
 }
@@ -138,8 +239,7 @@ func (p *Packet) Payload() packet.Packet {
 }
 
 func (p *Packet) GuessPayloadType() packet.Type {
-	switch p.Type {
-	case DstUnreachable, SrcQuench, RedirectMsg, TimeExceeded, ParamProblem:
+	if p.is_error() {
 		return packet.IPv4
 	}
 
@@ -147,8 +247,7 @@ func (p *Packet) GuessPayloadType() packet.Type {
 }
 
 func (p *Packet) SetPayload(pl packet.Packet) error {
-	switch p.Type {
-	case DstUnreachable, SrcQuench, RedirectMsg, TimeExceeded, ParamProblem:
+	if p.is_error() {
 		p.pkt_payload = pl
 	}
 
@@ -162,6 +261,95 @@ func (p *Packet) String() string {
 	return packet.Stringify(p)
 }
 
+// Return the gateway address of a Redirect message.
+func (p *Packet) Gateway() net.IP {
+	if p.Type != RedirectMsg {
+		return nil
+	}
+
+	return net.IPv4(byte(p.Id>>8), byte(p.Id), byte(p.Seq>>8), byte(p.Seq))
+}
+
+// Set the gateway address of a Redirect message.
+func (p *Packet) SetGateway(addr net.IP) {
+	addr = addr.To4()
+	if addr == nil {
+		return
+	}
+
+	p.Id = binary.BigEndian.Uint16(addr)
+	p.Seq = binary.BigEndian.Uint16(addr[2:])
+}
+
+// Return the pointer to the offending octet of a Parameter Problem message.
+func (p *Packet) Pointer() uint8 {
+	if p.Type != ParamProblem {
+		return 0
+	}
+
+	return uint8(p.Id >> 8)
+}
+
+// Set the pointer to the offending octet of a Parameter Problem message.
+func (p *Packet) SetPointer(ptr uint8) {
+	p.Id = uint16(ptr)<<8 | p.Id&0xff
+}
+
+// Return the next-hop MTU of a "fragmentation needed" Destination Unreachable
+// message (RFC1191).
+func (p *Packet) NextHopMTU() uint16 {
+	if p.Type != DstUnreachable {
+		return 0
+	}
+
+	return p.Seq
+}
+
+// Set the next-hop MTU of a "fragmentation needed" Destination Unreachable
+// message.
+func (p *Packet) SetNextHopMTU(mtu uint16) {
+	p.Seq = mtu
+}
+
+// Return the originate, receive and transmit timestamps (in milliseconds
+// since midnight UT) of a Timestamp or Timestamp Reply message.
+func (p *Packet) Timestamps() (uint32, uint32, uint32) {
+	if (p.Type != Timestamp && p.Type != TimestampReply) ||
+		len(p.Data) < 12 {
+		return 0, 0, 0
+	}
+
+	return binary.BigEndian.Uint32(p.Data),
+		binary.BigEndian.Uint32(p.Data[4:]),
+		binary.BigEndian.Uint32(p.Data[8:])
+}
+
+// Set the originate, receive and transmit timestamps of a Timestamp or
+// Timestamp Reply message.
+func (p *Packet) SetTimestamps(orig, recv, xmit uint32) {
+	p.Data = make([]byte, 12)
+
+	binary.BigEndian.PutUint32(p.Data, orig)
+	binary.BigEndian.PutUint32(p.Data[4:], recv)
+	binary.BigEndian.PutUint32(p.Data[8:], xmit)
+}
+
+// Return the address mask of an Address Mask Request or Reply message.
+func (p *Packet) AddrMask() net.IPMask {
+	if (p.Type != AddrMaskRequest && p.Type != AddrMaskReply) ||
+		len(p.Data) < 4 {
+		return nil
+	}
+
+	return net.IPMask(p.Data[:4])
+}
+
+// Set the address mask of an Address Mask Request or Reply message.
+func (p *Packet) SetAddrMask(mask net.IPMask) {
+	p.Data = make([]byte, 4)
+	copy(p.Data, mask)
+}
+
 func (t Type) String() string {
 	switch t {
 	case EchoReply:
@@ -31,10 +31,14 @@
 package icmpv4_test
 
 import "bytes"
+import "net"
 import "testing"
 
+import "github.com/scs-solution/go.pkt2/layers"
 import "github.com/scs-solution/go.pkt2/packet"
 import "github.com/scs-solution/go.pkt2/packet/icmpv4"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+import "github.com/scs-solution/go.pkt2/packet/udp"
 
 var test_simple = []byte{
 	0x08, 0x00, 0xf7, 0xd2, 0x00, 0x0f, 0x00, 0x1e,
@@ -104,3 +108,130 @@ func BenchmarkUnpack(bn *testing.B) {
 		p.Unpack(&b)
 	}
 }
+
+var test_timestamp = []byte{
+	0x0e, 0x00, 0x52, 0x03, 0x00, 0x0f, 0x00, 0x1e, 0x03, 0x93, 0x87, 0x00,
+	0x03, 0x93, 0x87, 0x0a, 0x03, 0x93, 0x87, 0x0b,
+}
+
+func TestPackTimestamp(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_timestamp)))
+
+	p := MakeTestSimple()
+	p.Type = icmpv4.TimestampReply
+	p.SetTimestamps(60000000, 60000010, 60000011)
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_timestamp, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func TestUnpackTimestamp(t *testing.T) {
+	var p icmpv4.Packet
+
+	var b packet.Buffer
+	b.Init(test_timestamp)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	orig, recv, xmit := p.Timestamps()
+	if orig != 60000000 || recv != 60000010 || xmit != 60000011 {
+		t.Fatalf("Timestamps mismatch: %d %d %d", orig, recv, xmit)
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	p := icmpv4.Make()
+	p.Type = icmpv4.RedirectMsg
+	p.SetGateway(net.ParseIP("192.168.1.254"))
+
+	if p.Id != 0xc0a8 || p.Seq != 0x01fe ||
+		!p.Gateway().Equal(net.ParseIP("192.168.1.254")) {
+		t.Fatalf("Gateway mismatch: %s", p.Gateway())
+	}
+
+	p.Type = icmpv4.ParamProblem
+	p.SetPointer(12)
+
+	if p.Pointer() != 12 {
+		t.Fatalf("Pointer mismatch: %d", p.Pointer())
+	}
+
+	p.Type = icmpv4.AddrMaskReply
+	p.SetAddrMask(net.CIDRMask(24, 32))
+
+	if p.AddrMask().String() != "ffffff00" {
+		t.Fatalf("Address mask mismatch: %s", p.AddrMask())
+	}
+}
+
+func TestPackMPLSExtension(t *testing.T) {
+	ip4_pkt := ipv4.Make()
+	ip4_pkt.SrcAddr = net.ParseIP("10.0.0.1")
+	ip4_pkt.DstAddr = net.ParseIP("192.168.1.135")
+
+	icmp_pkt := icmpv4.Make()
+	icmp_pkt.Type = icmpv4.TimeExceeded
+
+	labels := []icmpv4.MPLSLabel{
+		{Label: 16004, TC: 0, S: false, TTL: 1},
+		{Label: 24001, TC: 5, S: true, TTL: 1},
+	}
+
+	icmp_pkt.Extensions = []icmpv4.Extension{
+		icmpv4.MakeMPLSExtension(labels...),
+	}
+
+	orig_pkt := ipv4.Make()
+	orig_pkt.SrcAddr = net.ParseIP("192.168.1.135")
+	orig_pkt.DstAddr = net.ParseIP("8.8.8.8")
+	orig_pkt.TTL = 1
+
+	udp_pkt := udp.Make()
+	udp_pkt.SrcPort = 49152
+	udp_pkt.DstPort = 33434
+
+	buf, err := layers.Pack(ip4_pkt, icmp_pkt, orig_pkt, udp_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	/* header, padded original datagram and extension structure */
+	if len(buf) != 20+8+128+4+12 {
+		t.Fatalf("Packet length mismatch: %d", len(buf))
+	}
+
+	if ipv4.CalculateChecksum(buf[20:], 0) != 0 ||
+		ipv4.CalculateChecksum(buf[20+8+128:], 0) != 0 {
+		t.Fatalf("Checksum mismatch: %x", buf)
+	}
+
+	pkt, err := layers.UnpackAll(buf, packet.IPv4)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	icmp_pkt = layers.FindLayer(pkt, packet.ICMPv4).(*icmpv4.Packet)
+	if icmp_pkt.Id != 32 {
+		t.Fatalf("Length mismatch: %d", icmp_pkt.Id)
+	}
+
+	rsp := icmp_pkt.MPLSLabels()
+	if len(rsp) != len(labels) || rsp[0] != labels[0] || rsp[1] != labels[1] {
+		t.Fatalf("Labels mismatch: %v", rsp)
+	}
+
+	udp_rsp := layers.FindLayer(pkt, packet.UDP)
+	if udp_rsp == nil || udp_rsp.(*udp.Packet).DstPort != 33434 {
+		t.Fatalf("Packet mismatch: %s", pkt)
+	}
+}
@@ -0,0 +1,215 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package icmpv6
+
+import "encoding/binary"
+import "net"
+
+// Multicast address record of MLDv2 reports (RFC3810).
+type MLDRecord struct {
+	Type    RecordType
+	Addr    net.IP
+	Sources []net.IP
+	AuxData []byte
+}
+
+type RecordType uint8
+
+const (
+	ModeIsInclude   RecordType = 1
+	ModeIsExclude              = 2
+	ChangeToInclude            = 3
+	ChangeToExclude            = 4
+	AllowNewSources            = 5
+	BlockOldSources            = 6
+)
+
+// Create a new MLDv1 query for the given multicast address. An unspecified
+// address makes a general query.
+func MakeMLDQuery(max_delay uint16, group net.IP) *Packet {
+	return &Packet{
+		Type: MLDQuery,
+		Body: uint32(max_delay) << 16,
+		Data: mld_addr(group),
+	}
+}
+
+// Create a new MLDv2 query for the given multicast address and sources, with
+// the given querier's robustness variable and query interval code.
+func MakeMLDv2Query(max_resp uint16, group net.IP, qrv, qqic uint8, sources ...net.IP) *Packet {
+	data := mld_addr(group)
+
+	data = append(data, qrv&0x7, qqic, 0x00, 0x00)
+	binary.BigEndian.PutUint16(data[18:], uint16(len(sources)))
+
+	for _, addr := range sources {
+		data = append(data, addr.To16()...)
+	}
+
+	return &Packet{
+		Type: MLDQuery,
+		Body: uint32(max_resp) << 16,
+		Data: data,
+	}
+}
+
+// Create a new MLDv1 report for the given multicast address.
+func MakeMLDReport(group net.IP) *Packet {
+	return &Packet{Type: MLDReport, Data: mld_addr(group)}
+}
+
+// Create a new MLDv1 done message for the given multicast address.
+func MakeMLDDone(group net.IP) *Packet {
+	return &Packet{Type: MLDDone, Data: mld_addr(group)}
+}
+
+// Create a new MLDv2 report with the given multicast address records.
+func MakeMLDv2Report(records ...MLDRecord) *Packet {
+	var data []byte
+
+	for _, rec := range records {
+		aux_len := (len(rec.AuxData) + 3) &^ 3
+
+		data = append(data, uint8(rec.Type), uint8(aux_len/4), 0x00, 0x00)
+		binary.BigEndian.PutUint16(data[len(data)-2:], uint16(len(rec.Sources)))
+
+		data = append(data, rec.Addr.To16()...)
+
+		for _, addr := range rec.Sources {
+			data = append(data, addr.To16()...)
+		}
+
+		data = append(data, rec.AuxData...)
+		data = append(data, make([]byte, aux_len-len(rec.AuxData))...)
+	}
+
+	return &Packet{
+		Type: MLDv2Report,
+		Body: uint32(len(records)),
+		Data: data,
+	}
+}
+
+// Return the maximum response delay (MLDv1) or code (MLDv2) of a query.
+func (p *Packet) MaxRespDelay() uint16 {
+	if p.Type != MLDQuery {
+		return 0
+	}
+
+	return uint16(p.Body >> 16)
+}
+
+// Return the multicast address of an MLDv1 message or MLDv2 query.
+func (p *Packet) MulticastAddr() net.IP {
+	switch p.Type {
+	case MLDQuery, MLDReport, MLDDone:
+		if len(p.Data) >= 16 {
+			return net.IP(p.Data[:16])
+		}
+	}
+
+	return nil
+}
+
+// Return whether the message is an MLDv2 query.
+func (p *Packet) IsMLDv2Query() bool {
+	return p.Type == MLDQuery && len(p.Data) >= 20
+}
+
+// Return the querier's robustness variable, the query interval code and the
+// sources of an MLDv2 query.
+func (p *Packet) QueryParams() (uint8, uint8, []net.IP) {
+	var sources []net.IP
+
+	if !p.IsMLDv2Query() {
+		return 0, 0, nil
+	}
+
+	count := int(binary.BigEndian.Uint16(p.Data[18:]))
+
+	for i := 20; i+16 <= len(p.Data) && len(sources) < count; i += 16 {
+		sources = append(sources, net.IP(p.Data[i:i+16]))
+	}
+
+	return p.Data[16] & 0x7, p.Data[17], sources
+}
+
+// Return the multicast address records of an MLDv2 report.
+func (p *Packet) Records() []MLDRecord {
+	var records []MLDRecord
+
+	if p.Type != MLDv2Report {
+		return nil
+	}
+
+	off := 0
+
+	for i := 0; i < int(p.Body&0xffff); i++ {
+		if off+20 > len(p.Data) {
+			break
+		}
+
+		rec := MLDRecord{
+			Type: RecordType(p.Data[off]),
+			Addr: net.IP(p.Data[off+4 : off+20]),
+		}
+
+		aux_len := int(p.Data[off+1]) * 4
+		count := int(binary.BigEndian.Uint16(p.Data[off+2:]))
+
+		off += 20
+
+		if off+count*16+aux_len > len(p.Data) {
+			break
+		}
+
+		for j := 0; j < count; j++ {
+			rec.Sources = append(rec.Sources, net.IP(p.Data[off:off+16]))
+			off += 16
+		}
+
+		if aux_len > 0 {
+			rec.AuxData = p.Data[off : off+aux_len]
+			off += aux_len
+		}
+
+		records = append(records, rec)
+	}
+
+	return records
+}
+
+func mld_addr(group net.IP) []byte {
+	data := make([]byte, 16)
+	copy(data, group.To16())
+
+	return data
+}
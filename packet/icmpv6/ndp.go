@@ -0,0 +1,380 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package icmpv6
+
+import "encoding/binary"
+import "fmt"
+import "net"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+// Neighbor Discovery option (RFC4861). The Data field holds the option data
+// following the type and length fields; it is zero-padded to a multiple of 8
+// bytes when packed.
+type Option struct {
+	Type OptType
+	Data []byte
+}
+
+type OptType uint8
+
+const (
+	SrcLLAddr     OptType = 1
+	TgtLLAddr             = 2
+	PrefixInfo            = 3
+	RedirectedHdr         = 4
+	MTU                   = 5
+	RDNSS                 = 25 /* RFC8106 */
+	DNSSL                 = 31 /* RFC8106 */
+)
+
+// Flags of Router Advertisement messages.
+type RAFlags uint8
+
+const (
+	Managed   RAFlags = 0x80
+	Other             = 0x40
+	HomeAgent         = 0x20
+)
+
+// Flags of Neighbor Advertisement messages.
+type NAFlags uint8
+
+const (
+	Router    NAFlags = 0x80
+	Solicited         = 0x40
+	Override          = 0x20
+)
+
+// Flags of the Prefix Information option.
+type PrefixFlags uint8
+
+const (
+	OnLink     PrefixFlags = 0x80
+	Autonomous             = 0x40
+)
+
+// Content of the Prefix Information option.
+type Prefix struct {
+	Length            uint8
+	Flags             PrefixFlags
+	ValidLifetime     uint32
+	PreferredLifetime uint32
+	Prefix            net.IP
+}
+
+// Create a new Router Solicitation message.
+func MakeRouterSol(opts ...Option) *Packet {
+	return &Packet{Type: RouterSol, Options: opts}
+}
+
+// Create a new Router Advertisement message. The router lifetime is in
+// seconds, the reachable time and retransmission timer in milliseconds.
+func MakeRouterAdv(hop_limit uint8, flags RAFlags, lifetime uint16, reachable, retrans uint32, opts ...Option) *Packet {
+	data := make([]byte, 8)
+
+	binary.BigEndian.PutUint32(data, reachable)
+	binary.BigEndian.PutUint32(data[4:], retrans)
+
+	return &Packet{
+		Type:    RouterAdv,
+		Body:    uint32(hop_limit)<<24 | uint32(flags)<<16 | uint32(lifetime),
+		Data:    data,
+		Options: opts,
+	}
+}
+
+// Create a new Neighbor Solicitation message for the given target address.
+func MakeNeighborSol(target net.IP, opts ...Option) *Packet {
+	return &Packet{
+		Type:    NeighborSol,
+		Data:    append([]byte(nil), target.To16()...),
+		Options: opts,
+	}
+}
+
+// Create a new Neighbor Advertisement message for the given target address.
+func MakeNeighborAdv(flags NAFlags, target net.IP, opts ...Option) *Packet {
+	return &Packet{
+		Type:    NeighborAdv,
+		Body:    uint32(flags) << 24,
+		Data:    append([]byte(nil), target.To16()...),
+		Options: opts,
+	}
+}
+
+// Create a new Redirect message, telling that the given target is a better
+// first hop for the given destination.
+func MakeRedirect(target, dst net.IP, opts ...Option) *Packet {
+	data := append([]byte(nil), target.To16()...)
+
+	return &Packet{
+		Type:    RedirectMsg,
+		Data:    append(data, dst.To16()...),
+		Options: opts,
+	}
+}
+
+// Return the current hop limit advertised by a Router Advertisement message.
+func (p *Packet) HopLimit() uint8 {
+	if p.Type != RouterAdv {
+		return 0
+	}
+
+	return uint8(p.Body >> 24)
+}
+
+// Return the flags of a Router Advertisement message.
+func (p *Packet) RAFlags() RAFlags {
+	if p.Type != RouterAdv {
+		return 0
+	}
+
+	return RAFlags(p.Body >> 16)
+}
+
+// Return the router lifetime (in seconds) of a Router Advertisement message.
+func (p *Packet) RouterLifetime() uint16 {
+	if p.Type != RouterAdv {
+		return 0
+	}
+
+	return uint16(p.Body)
+}
+
+// Return the reachable time and the retransmission timer (in milliseconds) of
+// a Router Advertisement message.
+func (p *Packet) RouterTimers() (uint32, uint32) {
+	if p.Type != RouterAdv || len(p.Data) < 8 {
+		return 0, 0
+	}
+
+	return binary.BigEndian.Uint32(p.Data), binary.BigEndian.Uint32(p.Data[4:])
+}
+
+// Return the flags of a Neighbor Advertisement message.
+func (p *Packet) NAFlags() NAFlags {
+	if p.Type != NeighborAdv {
+		return 0
+	}
+
+	return NAFlags(p.Body >> 24)
+}
+
+// Return the target address of a Neighbor Solicitation, Neighbor Advertisement
+// or Redirect message.
+func (p *Packet) Target() net.IP {
+	switch p.Type {
+	case NeighborSol, NeighborAdv, RedirectMsg:
+		if len(p.Data) >= 16 {
+			return net.IP(p.Data[:16])
+		}
+	}
+
+	return nil
+}
+
+// Return the destination address of a Redirect message.
+func (p *Packet) Destination() net.IP {
+	if p.Type != RedirectMsg || len(p.Data) < 32 {
+		return nil
+	}
+
+	return net.IP(p.Data[16:32])
+}
+
+// Return the first option of the given type.
+func (p *Packet) FindOption(typ OptType) (Option, bool) {
+	for _, opt := range p.Options {
+		if opt.Type == typ {
+			return opt, true
+		}
+	}
+
+	return Option{}, false
+}
+
+// Create a new Source Link-Layer Address option.
+func MakeSrcLLAddr(addr net.HardwareAddr) Option {
+	return Option{Type: SrcLLAddr, Data: append([]byte(nil), addr...)}
+}
+
+// Create a new Target Link-Layer Address option.
+func MakeTgtLLAddr(addr net.HardwareAddr) Option {
+	return Option{Type: TgtLLAddr, Data: append([]byte(nil), addr...)}
+}
+
+// Create a new Prefix Information option.
+func MakePrefixInfo(prefix Prefix) Option {
+	data := make([]byte, 30)
+
+	data[0] = prefix.Length
+	data[1] = uint8(prefix.Flags)
+	binary.BigEndian.PutUint32(data[2:], prefix.ValidLifetime)
+	binary.BigEndian.PutUint32(data[6:], prefix.PreferredLifetime)
+	copy(data[14:], prefix.Prefix.To16())
+
+	return Option{Type: PrefixInfo, Data: data}
+}
+
+// Create a new MTU option.
+func MakeMTU(mtu uint32) Option {
+	data := make([]byte, 6)
+	binary.BigEndian.PutUint32(data[2:], mtu)
+
+	return Option{Type: MTU, Data: data}
+}
+
+// Create a new Recursive DNS Server option with the given lifetime (in
+// seconds).
+func MakeRDNSS(lifetime uint32, servers ...net.IP) Option {
+	data := make([]byte, 6+16*len(servers))
+
+	binary.BigEndian.PutUint32(data[2:], lifetime)
+
+	for i, addr := range servers {
+		copy(data[6+i*16:], addr.To16())
+	}
+
+	return Option{Type: RDNSS, Data: data}
+}
+
+// Return the link-layer address of a Source or Target Link-Layer Address
+// option.
+func (o Option) LLAddr() net.HardwareAddr {
+	if o.Type != SrcLLAddr && o.Type != TgtLLAddr {
+		return nil
+	}
+
+	return net.HardwareAddr(o.Data)
+}
+
+// Return the content of a Prefix Information option.
+func (o Option) Prefix() Prefix {
+	if o.Type != PrefixInfo || len(o.Data) < 30 {
+		return Prefix{}
+	}
+
+	return Prefix{
+		Length:            o.Data[0],
+		Flags:             PrefixFlags(o.Data[1]),
+		ValidLifetime:     binary.BigEndian.Uint32(o.Data[2:]),
+		PreferredLifetime: binary.BigEndian.Uint32(o.Data[6:]),
+		Prefix:            net.IP(o.Data[14:30]),
+	}
+}
+
+// Return the value of an MTU option.
+func (o Option) MTU() uint32 {
+	if o.Type != MTU || len(o.Data) < 6 {
+		return 0
+	}
+
+	return binary.BigEndian.Uint32(o.Data[2:])
+}
+
+// Return the lifetime (in seconds) and the addresses of a Recursive DNS Server
+// option.
+func (o Option) RDNSS() (uint32, []net.IP) {
+	var servers []net.IP
+
+	if o.Type != RDNSS || len(o.Data) < 6 {
+		return 0, nil
+	}
+
+	for i := 6; i+16 <= len(o.Data); i += 16 {
+		servers = append(servers, net.IP(o.Data[i:i+16]))
+	}
+
+	return binary.BigEndian.Uint32(o.Data[2:]), servers
+}
+
+/* length of the fixed message data preceding the options */
+func ndp_data_len(typ Type) int {
+	switch typ {
+	case RouterAdv:
+		return 8
+
+	case NeighborSol, NeighborAdv:
+		return 16
+
+	case RedirectMsg:
+		return 32
+	}
+
+	return 0
+}
+
+func opts_len(opts []Option) uint16 {
+	var length uint16
+
+	for _, opt := range opts {
+		length += (2 + uint16(len(opt.Data)) + 7) &^ 7
+	}
+
+	return length
+}
+
+func pack_opts(buf *packet.Buffer, opts []Option) {
+	for _, opt := range opts {
+		opt_len := (2 + len(opt.Data) + 7) &^ 7
+
+		buf.WriteN(opt.Type)
+		buf.WriteN(uint8(opt_len / 8))
+		buf.Write(opt.Data)
+
+		/* add padding */
+		buf.Write(make([]byte, opt_len-2-len(opt.Data)))
+	}
+}
+
+func unpack_opts(buf *packet.Buffer) ([]Option, error) {
+	var opts []Option
+
+	for buf.Len() >= 2 {
+		var opt_type OptType
+		var opt_len uint8
+
+		buf.ReadN(&opt_type)
+		buf.ReadN(&opt_len)
+
+		if opt_len == 0 || int(opt_len)*8-2 > buf.Len() {
+			return nil, fmt.Errorf("Invalid option length: %d", opt_len)
+		}
+
+		opts = append(opts, Option{
+			Type: opt_type,
+			Data: buf.Next(int(opt_len)*8 - 2),
+		})
+	}
+
+	return opts, nil
+}
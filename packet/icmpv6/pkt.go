@@ -42,6 +42,8 @@ type Packet struct {
 	Checksum    uint16        `string:"sum"`
 	csum_seed   uint32        `cmp:"skip" string:"skip"`
 	Body        uint32        `cmp:"skip" string:"skip"`
+	Data        []byte        /* message data following the body */
+	Options     []Option      /* NDP options */
 	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
 }
 
@@ -58,7 +60,15 @@ const (
 	Reserved1           = 127
 	EchoRequest         = 128
 	EchoReply           = 129
-	/* TODO: more types */
+	MLDQuery            = 130
+	MLDReport           = 131
+	MLDDone             = 132
+	RouterSol           = 133
+	RouterAdv           = 134
+	NeighborSol         = 135
+	NeighborAdv         = 136
+	RedirectMsg         = 137
+	MLDv2Report         = 143
 )
 
 func Make() *Packet {
@@ -72,11 +82,13 @@ func (p *Packet) GetType() packet.Type {
 }
 
 func (p *Packet) GetLength() uint16 {
+	length := 8 + uint16(len(p.Data)) + opts_len(p.Options)
+
 	if p.pkt_payload != nil {
-		return p.pkt_payload.GetLength() + 8
+		return p.pkt_payload.GetLength() + length
 	}
 
-	return 8
+	return length
 }
 
 func (p *Packet) Equals(other packet.Packet) bool {
@@ -92,6 +104,14 @@ func (p *Packet) Answers(other packet.Packet) bool {
 		return true
 	}
 
+	if other.(*Packet).Type == RouterSol && p.Type == RouterAdv {
+		return true
+	}
+
+	if other.(*Packet).Type == NeighborSol && p.Type == NeighborAdv {
+		return p.Target().Equal(other.(*Packet).Target())
+	}
+
 	return false
 }
 
@@ -100,6 +120,9 @@ func (p *Packet) Pack(buf *packet.Buffer) error {
 	buf.WriteN(byte(p.Code))
 	buf.WriteN(uint16(0x00))
 	buf.WriteN(p.Body)
+	buf.Write(p.Data)
+
+	pack_opts(buf, p.Options)
 
 	if p.csum_seed != 0 {
 		p.Checksum = ipv4.CalculateChecksum(buf.LayerBytes(), p.csum_seed)
@@ -113,10 +136,38 @@ func (p *Packet) Unpack(buf *packet.Buffer) error {
 	buf.ReadN(&p.Type)
 	buf.ReadN(&p.Code)
 	buf.ReadN(&p.Checksum)
-
-	/* TODO: data */
 	buf.ReadN(&p.Body)
 
+	p.Data = nil
+	p.Options = nil
+
+	switch p.Type {
+	case DstUnreachable, PacketTooBig, TimeExceeded, ParamProblem:
+		return nil
+
+	case RouterSol, RouterAdv, NeighborSol, NeighborAdv, RedirectMsg:
+		data_len := ndp_data_len(p.Type)
+		if buf.Len() < data_len {
+			return fmt.Errorf("Invalid message length: %d", buf.Len())
+		}
+
+		if data_len > 0 {
+			p.Data = buf.Next(data_len)
+		}
+
+		opts, err := unpack_opts(buf)
+		if err != nil {
+			return err
+		}
+
+		p.Options = opts
+
+	default:
+		if buf.Len() > 0 {
+			p.Data = buf.Next(buf.Len())
+		}
+	}
+
 	return nil
 }
 
@@ -164,6 +215,24 @@ func (t Type) String() string {
 		return "echo-request"
 	case EchoReply:
 		return "echo-reply"
+	case MLDQuery:
+		return "mld-query"
+	case MLDReport:
+		return "mld-report"
+	case MLDDone:
+		return "mld-done"
+	case RouterSol:
+		return "router-sol"
+	case RouterAdv:
+		return "router-adv"
+	case NeighborSol:
+		return "neighbor-sol"
+	case NeighborAdv:
+		return "neighbor-adv"
+	case RedirectMsg:
+		return "redirect"
+	case MLDv2Report:
+		return "mldv2-report"
 	default:
 		return "unknown"
 	}
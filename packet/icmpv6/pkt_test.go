@@ -34,6 +34,7 @@ import "bytes"
 import "net"
 import "testing"
 
+import "github.com/scs-solution/go.pkt2/layers"
 import "github.com/scs-solution/go.pkt2/packet"
 import "github.com/scs-solution/go.pkt2/packet/icmpv6"
 import "github.com/scs-solution/go.pkt2/packet/ipv6"
@@ -150,3 +151,194 @@ func TestUnpackWithIPv6(t *testing.T) {
 		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
 	}
 }
+
+var test_neighbor_sol = []byte{
+	0x87, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xfe, 0x80, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x02, 0x00, 0x5e, 0xff, 0xfe, 0x00, 0x53, 0x01,
+	0x01, 0x01, 0x4c, 0x72, 0xb9, 0x54, 0xe5, 0x3d,
+}
+
+func MakeTestNeighborSol() *icmpv6.Packet {
+	hwaddr, _ := net.ParseMAC("4c:72:b9:54:e5:3d")
+
+	return icmpv6.MakeNeighborSol(net.ParseIP("fe80::200:5eff:fe00:5301"),
+		icmpv6.MakeSrcLLAddr(hwaddr))
+}
+
+func TestPackNeighborSol(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_neighbor_sol)))
+
+	p := MakeTestNeighborSol()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_neighbor_sol, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func TestUnpackNeighborSol(t *testing.T) {
+	var p icmpv6.Packet
+
+	cmp := MakeTestNeighborSol()
+
+	var b packet.Buffer
+	b.Init(test_neighbor_sol)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+
+	opt, ok := p.FindOption(icmpv6.SrcLLAddr)
+	if !ok || opt.LLAddr().String() != "4c:72:b9:54:e5:3d" {
+		t.Fatalf("Option mismatch: %v", p.Options)
+	}
+
+	adv := icmpv6.MakeNeighborAdv(icmpv6.Solicited, p.Target())
+	if !adv.Answers(&p) || adv.NAFlags() != icmpv6.Solicited {
+		t.Fatalf("Packet mismatch: %s", adv)
+	}
+}
+
+func TestRouterAdv(t *testing.T) {
+	ip6 := ipv6.Make()
+	ip6.SrcAddr = net.ParseIP(ipsrc_str)
+	ip6.DstAddr = net.ParseIP("ff02::1")
+	ip6.HopLimit = 255
+
+	ra := icmpv6.MakeRouterAdv(64, icmpv6.Other, 1800, 0, 0,
+		icmpv6.MakePrefixInfo(icmpv6.Prefix{
+			Length:            64,
+			Flags:             icmpv6.OnLink | icmpv6.Autonomous,
+			ValidLifetime:     86400,
+			PreferredLifetime: 14400,
+			Prefix:            net.ParseIP("2001:db8:1::"),
+		}),
+		icmpv6.MakeMTU(1500),
+		icmpv6.MakeRDNSS(600, net.ParseIP("2001:db8:1::53")),
+	)
+
+	buf, err := layers.Pack(ip6, ra)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	pkt, err := layers.UnpackAll(buf, packet.IPv6)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	rsp := layers.FindLayer(pkt, packet.ICMPv6).(*icmpv6.Packet)
+	if !rsp.Equals(ra) || rsp.Checksum != ra.Checksum {
+		t.Fatalf("Packet mismatch:\n%s\n%s", rsp, ra)
+	}
+
+	if rsp.HopLimit() != 64 || rsp.RAFlags() != icmpv6.Other ||
+		rsp.RouterLifetime() != 1800 {
+		t.Fatalf("Packet mismatch: %s", rsp)
+	}
+
+	if len(rsp.Options) != 3 {
+		t.Fatalf("Options number mismatch: %d", len(rsp.Options))
+	}
+
+	prefix := rsp.Options[0].Prefix()
+	if prefix.Length != 64 || prefix.ValidLifetime != 86400 ||
+		!prefix.Prefix.Equal(net.ParseIP("2001:db8:1::")) {
+		t.Fatalf("Prefix mismatch: %v", prefix)
+	}
+
+	if rsp.Options[1].MTU() != 1500 {
+		t.Fatalf("MTU mismatch: %d", rsp.Options[1].MTU())
+	}
+
+	lifetime, servers := rsp.Options[2].RDNSS()
+	if lifetime != 600 || len(servers) != 1 ||
+		!servers[0].Equal(net.ParseIP("2001:db8:1::53")) {
+		t.Fatalf("RDNSS mismatch: %d %v", lifetime, servers)
+	}
+}
+
+func TestMLDv2Report(t *testing.T) {
+	var sums []uint16
+
+	records := []icmpv6.MLDRecord{
+		{
+			Type:    icmpv6.ChangeToInclude,
+			Addr:    net.ParseIP("ff15::1234"),
+			Sources: []net.IP{net.ParseIP("2001:db8::1")},
+		},
+		{
+			Type: icmpv6.ModeIsExclude,
+			Addr: net.ParseIP("ff02::fb"),
+		},
+	}
+
+	for _, hbh := range []bool{false, true} {
+		ip6 := ipv6.Make()
+		ip6.SrcAddr = net.ParseIP(ipsrc_str)
+		ip6.DstAddr = net.ParseIP("ff02::16")
+		ip6.HopLimit = 1
+
+		pkts := []packet.Packet{ip6}
+
+		if hbh {
+			pkts = append(pkts, ipv6.MakeHopByHop(ipv6.MakeRouterAlert(0)))
+		}
+
+		pkts = append(pkts, icmpv6.MakeMLDv2Report(records...))
+
+		buf, err := layers.Pack(pkts...)
+		if err != nil {
+			t.Fatalf("Error packing: %s", err)
+		}
+
+		pkt, err := layers.UnpackAll(buf, packet.IPv6)
+		if err != nil {
+			t.Fatalf("Error unpacking: %s", err)
+		}
+
+		rsp := layers.FindLayer(pkt, packet.ICMPv6).(*icmpv6.Packet)
+
+		recs := rsp.Records()
+		if len(recs) != 2 || recs[0].Type != icmpv6.ChangeToInclude ||
+			len(recs[0].Sources) != 1 || !recs[1].Addr.Equal(records[1].Addr) {
+			t.Fatalf("Records mismatch: %v", recs)
+		}
+
+		sums = append(sums, rsp.Checksum)
+	}
+
+	/* the pseudo-header skips the extension headers */
+	if sums[0] != sums[1] {
+		t.Fatalf("Checksum mismatch: %x %x", sums[0], sums[1])
+	}
+}
+
+func TestMLDv2Query(t *testing.T) {
+	p := icmpv6.MakeMLDv2Query(10000, net.ParseIP("ff15::1234"), 2, 125,
+		net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"))
+
+	if p.GetLength() != 8+20+32 || !p.IsMLDv2Query() {
+		t.Fatalf("Packet length mismatch: %d", p.GetLength())
+	}
+
+	qrv, qqic, sources := p.QueryParams()
+	if qrv != 2 || qqic != 125 || len(sources) != 2 ||
+		!p.MulticastAddr().Equal(net.ParseIP("ff15::1234")) {
+		t.Fatalf("Query mismatch: %d %d %v", qrv, qqic, sources)
+	}
+
+	if icmpv6.MakeMLDQuery(1000, net.IPv6unspecified).IsMLDv2Query() {
+		t.Fatalf("Query version mismatch")
+	}
+}
@@ -0,0 +1,392 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for IGMP (version 1, 2 and 3) packets.
+//
+// Note that IGMPv2 and IGMPv3 messages should be sent with a TTL of 1 and with
+// the IPv4 Router Alert option (see ipv4.MakeRouterAlert()).
+package igmp
+
+import "fmt"
+import "net"
+import "time"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+
+type Packet struct {
+	Type        Type
+	MaxResp     uint8  `string:"maxresp"`
+	Checksum    uint16 `string:"sum"`
+	Group       net.IP
+	Version     uint8 `string:"ver"`
+	Suppress    bool  `string:"s"`
+	QRV         uint8
+	QQIC        uint8
+	Sources     []net.IP      `string:"skip"`
+	Records     []GroupRecord `string:"skip"`
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+type Type uint8
+
+const (
+	MembershipQuery    Type = 0x11
+	V1MembershipReport      = 0x12
+	V2MembershipReport      = 0x16
+	LeaveGroup              = 0x17
+	V3MembershipReport      = 0x22
+)
+
+type GroupRecord struct {
+	Type    RecordType
+	Group   net.IP
+	Sources []net.IP
+	Aux     []byte
+}
+
+type RecordType uint8
+
+const (
+	ModeIsInclude   RecordType = 1
+	ModeIsExclude              = 2
+	ChangeToInclude            = 3
+	ChangeToExclude            = 4
+	AllowNewSources            = 5
+	BlockOldSources            = 6
+)
+
+func Make() *Packet {
+	return &Packet{
+		Type:    MembershipQuery,
+		Version: 3,
+		MaxResp: 100,
+		Group:   net.IPv4zero,
+		QRV:     2,
+		QQIC:    125,
+	}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.IGMP
+}
+
+func (p *Packet) GetLength() uint16 {
+	switch {
+	case p.Type == MembershipQuery && p.Version == 3:
+		return 12 + 4*uint16(len(p.Sources))
+
+	case p.Type == V3MembershipReport:
+		length := uint16(8)
+
+		for _, r := range p.Records {
+			length += 8 + 4*uint16(len(r.Sources))
+			length += uint16(len(r.Aux)+3) &^ 3
+		}
+
+		return length
+	}
+
+	return 8
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	if other == nil || other.GetType() != packet.IGMP {
+		return false
+	}
+
+	query := other.(*Packet)
+
+	if query.Type != MembershipQuery {
+		return false
+	}
+
+	switch p.Type {
+	case V1MembershipReport, V2MembershipReport:
+		/* general queries are answered by reports for any group */
+		return query.Group.IsUnspecified() || query.Group.Equal(p.Group)
+
+	case V3MembershipReport:
+		if query.Group.IsUnspecified() {
+			return true
+		}
+
+		for _, r := range p.Records {
+			if r.Group.Equal(query.Group) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	buf.WriteN(p.Type)
+
+	if p.Type == V3MembershipReport {
+		buf.WriteN(uint8(0x00))
+		buf.WriteN(uint16(0x0000))
+		buf.WriteN(uint16(0x0000))
+		buf.WriteN(uint16(len(p.Records)))
+
+		for _, r := range p.Records {
+			aux_len := (len(r.Aux) + 3) / 4
+
+			buf.WriteN(r.Type)
+			buf.WriteN(uint8(aux_len))
+			buf.WriteN(uint16(len(r.Sources)))
+			write_addr(buf, r.Group)
+
+			for _, src := range r.Sources {
+				write_addr(buf, src)
+			}
+
+			buf.Write(r.Aux)
+
+			for i := len(r.Aux); i < aux_len*4; i++ {
+				buf.WriteN(uint8(0x00))
+			}
+		}
+	} else {
+		buf.WriteN(p.MaxResp)
+		buf.WriteN(uint16(0x0000))
+		write_addr(buf, p.Group)
+
+		if p.Type == MembershipQuery && p.Version == 3 {
+			flags := p.QRV & 0x07
+			if p.Suppress {
+				flags |= 0x08
+			}
+
+			buf.WriteN(flags)
+			buf.WriteN(p.QQIC)
+			buf.WriteN(uint16(len(p.Sources)))
+
+			for _, src := range p.Sources {
+				write_addr(buf, src)
+			}
+		}
+	}
+
+	p.Checksum = ipv4.CalculateChecksum(buf.LayerBytes()[:buf.LayerLen()], 0)
+	buf.PutUint16N(2, p.Checksum)
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	buf.ReadN(&p.Type)
+
+	p.Sources = nil
+	p.Records = nil
+
+	if p.Type == V3MembershipReport {
+		p.Version = 3
+
+		buf.Next(1)
+		buf.ReadN(&p.Checksum)
+		buf.Next(2)
+
+		var records uint16
+		buf.ReadN(&records)
+
+		for i := 0; i < int(records); i++ {
+			var r GroupRecord
+			buf.ReadN(&r.Type)
+
+			var aux_len uint8
+			buf.ReadN(&aux_len)
+
+			var sources uint16
+			buf.ReadN(&sources)
+
+			if buf.Len() < 4+4*int(sources)+4*int(aux_len) {
+				return fmt.Errorf("Invalid group record length: %d",
+					buf.Len())
+			}
+
+			r.Group = net.IP(buf.Next(4))
+
+			for j := 0; j < int(sources); j++ {
+				r.Sources = append(r.Sources, net.IP(buf.Next(4)))
+			}
+
+			if aux_len > 0 {
+				r.Aux = buf.Next(int(aux_len) * 4)
+			}
+
+			p.Records = append(p.Records, r)
+		}
+
+		return nil
+	}
+
+	buf.ReadN(&p.MaxResp)
+	buf.ReadN(&p.Checksum)
+	p.Group = net.IP(buf.Next(4))
+
+	switch p.Type {
+	case MembershipQuery:
+		/*
+		 * IGMPv3 queries are at least 12 bytes long, while IGMPv1
+		 * queries have no maximum response time.
+		 */
+		if buf.Len() >= 4 {
+			p.Version = 3
+		} else if p.MaxResp != 0 {
+			p.Version = 2
+		} else {
+			p.Version = 1
+		}
+
+	case V1MembershipReport:
+		p.Version = 1
+
+	default:
+		p.Version = 2
+	}
+
+	if p.Version != 3 {
+		return nil
+	}
+
+	var flags uint8
+	buf.ReadN(&flags)
+
+	p.Suppress = flags&0x08 != 0
+	p.QRV = flags & 0x07
+
+	buf.ReadN(&p.QQIC)
+
+	var sources uint16
+	buf.ReadN(&sources)
+
+	if buf.Len() < 4*int(sources) {
+		return fmt.Errorf("Invalid number of sources: %d", sources)
+	}
+
+	for i := 0; i < int(sources); i++ {
+		p.Sources = append(p.Sources, net.IP(buf.Next(4)))
+	}
+
+	return nil
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	return packet.None
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+// Return the maximum response time of a membership query, decoding the
+// floating-point representation used by IGMPv3 if needed.
+func (p *Packet) MaxRespTime() time.Duration {
+	return decode_time(p.MaxResp, p.Version) * time.Second / 10
+}
+
+// Return the querier's query interval of an IGMPv3 query.
+func (p *Packet) QueryInterval() time.Duration {
+	return decode_time(p.QQIC, p.Version) * time.Second
+}
+
+func decode_time(code uint8, version uint8) time.Duration {
+	if version < 3 || code < 128 {
+		return time.Duration(code)
+	}
+
+	mant := uint(code & 0x0f)
+	exp := uint(code>>4) & 0x07
+
+	return time.Duration((mant | 0x10) << (exp + 3))
+}
+
+func write_addr(buf *packet.Buffer, addr net.IP) {
+	if addr.To4() == nil {
+		addr = net.IPv4zero
+	}
+
+	buf.Write(addr.To4())
+}
+
+func (t Type) String() string {
+	switch t {
+	case MembershipQuery:
+		return "membership-query"
+	case V1MembershipReport:
+		return "v1-membership-report"
+	case V2MembershipReport:
+		return "v2-membership-report"
+	case LeaveGroup:
+		return "leave-group"
+	case V3MembershipReport:
+		return "v3-membership-report"
+	default:
+		return "unknown"
+	}
+}
+
+func (t RecordType) String() string {
+	switch t {
+	case ModeIsInclude:
+		return "mode-is-include"
+	case ModeIsExclude:
+		return "mode-is-exclude"
+	case ChangeToInclude:
+		return "change-to-include"
+	case ChangeToExclude:
+		return "change-to-exclude"
+	case AllowNewSources:
+		return "allow-new-sources"
+	case BlockOldSources:
+		return "block-old-sources"
+	default:
+		return "unknown"
+	}
+}
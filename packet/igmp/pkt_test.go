@@ -0,0 +1,310 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package igmp_test
+
+import "bytes"
+import "net"
+import "testing"
+import "time"
+
+import "github.com/scs-solution/go.pkt2/layers"
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/eth"
+import "github.com/scs-solution/go.pkt2/packet/igmp"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+
+var test_simple = []byte{
+	0x16, 0x00, 0xf8, 0xfa, 0xef, 0x01, 0x02, 0x03,
+}
+
+func MakeTestSimple() *igmp.Packet {
+	return &igmp.Packet{
+		Type:     igmp.V2MembershipReport,
+		Version:  2,
+		Checksum: 0xf8fa,
+		Group:    net.ParseIP("239.1.2.3"),
+	}
+}
+
+func TestPack(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_simple, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPack(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	var p igmp.Packet
+
+	cmp := MakeTestSimple()
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpack(bn *testing.B) {
+	var p igmp.Packet
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_simple)
+		p.Unpack(&b)
+	}
+}
+
+var test_query = []byte{
+	0x11, 0x64, 0x60, 0xc8, 0x00, 0x00, 0x00, 0x00, 0x0a, 0x7d, 0x00, 0x02,
+	0xc0, 0xa8, 0x01, 0x01, 0xc0, 0xa8, 0x01, 0x02,
+}
+
+func MakeTestQuery() *igmp.Packet {
+	p := igmp.Make()
+	p.Checksum = 0x60c8
+	p.Suppress = true
+	p.Sources = []net.IP{
+		net.ParseIP("192.168.1.1"), net.ParseIP("192.168.1.2"),
+	}
+
+	return p
+}
+
+func TestPackQuery(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_query)))
+
+	p := MakeTestQuery()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_query, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func TestUnpackQuery(t *testing.T) {
+	var p igmp.Packet
+
+	cmp := MakeTestQuery()
+
+	var b packet.Buffer
+	b.Init(test_query)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+
+	if p.MaxRespTime() != 10*time.Second {
+		t.Fatalf("Max response time mismatch: %s", p.MaxRespTime())
+	}
+
+	if p.QueryInterval() != 125*time.Second {
+		t.Fatalf("Query interval mismatch: %s", p.QueryInterval())
+	}
+}
+
+var test_report = []byte{
+	0x22, 0x00, 0x76, 0x33, 0x00, 0x00, 0x00, 0x02, 0x04, 0x00, 0x00, 0x00,
+	0xef, 0x01, 0x02, 0x03, 0x01, 0x01, 0x00, 0x01, 0xef, 0x01, 0x02, 0x04,
+	0x0a, 0x00, 0x00, 0x01, 0xaa, 0xbb, 0xcc, 0x00,
+}
+
+func MakeTestReport() *igmp.Packet {
+	return &igmp.Packet{
+		Type:     igmp.V3MembershipReport,
+		Version:  3,
+		Checksum: 0x7633,
+		Records: []igmp.GroupRecord{
+			{
+				Type:  igmp.ChangeToExclude,
+				Group: net.ParseIP("239.1.2.3").To4(),
+			},
+			{
+				Type:    igmp.ModeIsInclude,
+				Group:   net.ParseIP("239.1.2.4").To4(),
+				Sources: []net.IP{net.ParseIP("10.0.0.1").To4()},
+				Aux:     []byte{0xaa, 0xbb, 0xcc, 0x00},
+			},
+		},
+	}
+}
+
+func TestPackReport(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_report)))
+
+	p := MakeTestReport()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_report, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func TestUnpackReport(t *testing.T) {
+	var p igmp.Packet
+
+	cmp := MakeTestReport()
+
+	var b packet.Buffer
+	b.Init(test_report)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+
+	cmp.Records[1].Sources[0] = net.ParseIP("10.0.0.2")
+
+	if p.Equals(cmp) {
+		t.Fatalf("Record mismatch not detected")
+	}
+
+	if !p.Answers(MakeTestQuery()) {
+		t.Fatalf("Report does not answer general query")
+	}
+}
+
+func TestPackWithIPv4(t *testing.T) {
+	ip4 := ipv4.Make()
+	ip4.TTL = 1
+	ip4.SrcAddr = net.ParseIP("192.168.1.135")
+	ip4.DstAddr = net.ParseIP("224.0.0.22")
+	ip4.Options = []ipv4.Option{ipv4.MakeRouterAlert(0)}
+
+	ip4.SetPayload(MakeTestReport())
+
+	var b packet.Buffer
+	b.Init(make([]byte, ip4.GetLength()))
+
+	err := ip4.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if ip4.IHL != 6 || ip4.Length != 24+uint16(len(test_report)) {
+		t.Fatalf("Header length mismatch: %d %d", ip4.IHL, ip4.Length)
+	}
+
+	if !bytes.Equal(b.Buffer()[20:24], []byte{0x94, 0x04, 0x00, 0x00}) {
+		t.Fatalf("Router alert mismatch: %x", b.Buffer()[20:24])
+	}
+
+	var p ipv4.Packet
+	b.Init(b.Buffer())
+
+	err = p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if len(p.Options) != 1 || p.Options[0].Type != ipv4.RouterAlert ||
+		b.LayerLen() != 24 {
+		t.Fatalf("Options mismatch: %v", p.Options)
+	}
+}
+
+func TestUnpackPadded(t *testing.T) {
+	eth_pkt := eth.Make()
+	eth_pkt.SrcAddr, _ = net.ParseMAC("4c:72:b9:54:e5:3d")
+	eth_pkt.DstAddr, _ = net.ParseMAC("01:00:5e:00:00:01")
+
+	ip4_pkt := ipv4.Make()
+	ip4_pkt.TTL = 1
+	ip4_pkt.SrcAddr = net.ParseIP("192.168.1.1")
+	ip4_pkt.DstAddr = net.ParseIP("224.0.0.1")
+
+	query := igmp.Make()
+	query.Version = 2
+
+	buf, err := layers.Pack(eth_pkt, ip4_pkt, query)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	/* pad to the minimum Ethernet frame length */
+	buf = append(buf, make([]byte, 60-len(buf))...)
+
+	pkt, err := layers.UnpackAll(buf, packet.Eth)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	p := layers.FindLayer(pkt, packet.IGMP).(*igmp.Packet)
+
+	if p.Version != 2 || p.Sources != nil {
+		t.Fatalf("Padded query mismatch:\n%s", p)
+	}
+}
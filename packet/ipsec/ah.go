@@ -0,0 +1,165 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for IPsec AH (Authentication Header) and ESP
+// (Encapsulating Security Payload) packets, as well as decryption of ESP
+// payloads.
+package ipsec
+
+import "fmt"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+
+type AH struct {
+	NextHdr     ipv4.Protocol `string:"next"`
+	PayloadLen  uint8         `cmp:"skip" string:"len"`
+	SPI         uint32        `string:"spi"`
+	Seq         uint32
+	ICV         []byte        `string:"skip"`
+	IPv6        bool          `cmp:"skip" string:"skip"`
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+// Create a new AH packet with a zeroed ICV of the given length (e.g. 12 bytes
+// for HMAC-SHA1-96 or 16 bytes for HMAC-SHA256-128). The IPv6 field must be set
+// if the packet is carried over IPv6, so that the ICV is padded accordingly.
+func MakeAH(icv_len int) *AH {
+	p := &AH{ICV: make([]byte, icv_len)}
+	p.PayloadLen = uint8(p.header_len()/4 - 2)
+
+	return p
+}
+
+func (p *AH) GetType() packet.Type {
+	return packet.IPSecAH
+}
+
+func (p *AH) GetLength() uint16 {
+	if p.pkt_payload != nil {
+		return p.pkt_payload.GetLength() + p.header_len()
+	}
+
+	return p.header_len()
+}
+
+/* The header must be a multiple of 4 octets over IPv4 and 8 over IPv6 */
+func (p *AH) header_len() uint16 {
+	if p.IPv6 {
+		return uint16(12+len(p.ICV)+7) &^ 7
+	}
+
+	return uint16(12+len(p.ICV)+3) &^ 3
+}
+
+func (p *AH) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *AH) Answers(other packet.Packet) bool {
+	if other == nil || other.GetType() != packet.IPSecAH {
+		return false
+	}
+
+	if p.Payload() != nil {
+		return p.Payload().Answers(other.Payload())
+	}
+
+	return true
+}
+
+func (p *AH) Pack(buf *packet.Buffer) error {
+	p.PayloadLen = uint8(p.header_len()/4 - 2)
+
+	buf.WriteN(p.NextHdr)
+	buf.WriteN(p.PayloadLen)
+	buf.WriteN(uint16(0x0000))
+	buf.WriteN(p.SPI)
+	buf.WriteN(p.Seq)
+	buf.Write(p.ICV)
+
+	/* add padding */
+	for buf.LayerLen() < int(p.header_len()) {
+		buf.WriteN(uint8(0x00))
+	}
+
+	return nil
+}
+
+func (p *AH) Unpack(buf *packet.Buffer) error {
+	buf.ReadN(&p.NextHdr)
+	buf.ReadN(&p.PayloadLen)
+	buf.Next(2)
+	buf.ReadN(&p.SPI)
+	buf.ReadN(&p.Seq)
+
+	icv_len := (int(p.PayloadLen)+2)*4 - 12
+	if icv_len < 0 || icv_len > buf.Len() {
+		return fmt.Errorf("Invalid payload length: %d", p.PayloadLen)
+	}
+
+	p.ICV = buf.Next(icv_len)
+
+	return nil
+}
+
+func (p *AH) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *AH) GuessPayloadType() packet.Type {
+	return ipv4.ProtocolToType(p.NextHdr)
+}
+
+func (p *AH) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+	p.NextHdr = ipv4.TypeToProtocol(pl.GetType())
+
+	return nil
+}
+
+func (p *AH) InitChecksum(csum uint32) {
+	if p.pkt_payload == nil {
+		return
+	}
+
+	/*
+	 * The pseudo-header of the payload must refer to the upper-layer
+	 * protocol and length, not to the AH ones.
+	 */
+	csum -= uint32(ipv4.IPSecAH) + uint32(p.header_len())
+	csum += uint32(p.NextHdr)
+
+	p.pkt_payload.InitChecksum(csum)
+}
+
+func (p *AH) String() string {
+	return packet.Stringify(p)
+}
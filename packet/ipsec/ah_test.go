@@ -0,0 +1,191 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ipsec_test
+
+import "bytes"
+import "net"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/layers"
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/ipsec"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+import "github.com/scs-solution/go.pkt2/packet/ipv6"
+import "github.com/scs-solution/go.pkt2/packet/tcp"
+
+var test_ah = []byte{
+	0x06, 0x04, 0x00, 0x00, 0x00, 0x00, 0x10, 0x01, 0x00, 0x00, 0x00, 0x02,
+	0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c,
+}
+
+func MakeTestAH() *ipsec.AH {
+	return &ipsec.AH{
+		NextHdr: ipv4.TCP,
+		SPI:     0x1001,
+		Seq:     2,
+		ICV: []byte{
+			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a,
+			0x0b, 0x0c,
+		},
+	}
+}
+
+func TestPackAH(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_ah)))
+
+	p := MakeTestAH()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_ah, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPackAH(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_ah)))
+
+	p := MakeTestAH()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpackAH(t *testing.T) {
+	var p ipsec.AH
+
+	cmp := MakeTestAH()
+
+	var b packet.Buffer
+	b.Init(test_ah)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpackAH(bn *testing.B) {
+	var p ipsec.AH
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_ah)
+		p.Unpack(&b)
+	}
+}
+
+func TestMakeAH(t *testing.T) {
+	p := ipsec.MakeAH(16)
+
+	if p.GetLength() != 28 || p.PayloadLen != 5 {
+		t.Fatalf("Length mismatch: %d %d", p.GetLength(), p.PayloadLen)
+	}
+}
+
+func TestChecksumWithAH(t *testing.T) {
+	ip4_pkt := ipv4.Make()
+	ip4_pkt.SrcAddr = net.ParseIP("192.168.1.135")
+	ip4_pkt.DstAddr = net.ParseIP("193.27.208.37")
+
+	tcp_pkt := tcp.Make()
+	tcp_pkt.SrcPort = 41562
+	tcp_pkt.DstPort = 8338
+
+	_, err := layers.Pack(ip4_pkt, tcp_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	csum := tcp_pkt.Checksum
+
+	_, err = layers.Pack(ip4_pkt, ipsec.MakeAH(12), tcp_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if tcp_pkt.Checksum != csum {
+		t.Fatalf("Checksum mismatch: 0x%x 0x%x", tcp_pkt.Checksum, csum)
+	}
+}
+
+func TestPackUnpackAHIPv6(t *testing.T) {
+	ip6_pkt := ipv6.Make()
+	ip6_pkt.SrcAddr = net.ParseIP("fe80::4e72:b9ff:fe54:e53d")
+	ip6_pkt.DstAddr = net.ParseIP("fe80::221:96ff:fe6e:f070")
+
+	ah_pkt := ipsec.MakeAH(16)
+	ah_pkt.IPv6 = true
+	ah_pkt.SPI = 0x1001
+	copy(ah_pkt.ICV, []byte{0xde, 0xad, 0xbe, 0xef})
+
+	tcp_pkt := tcp.Make()
+	tcp_pkt.SrcPort = 41562
+	tcp_pkt.DstPort = 8338
+
+	buf, err := layers.Pack(ip6_pkt, ah_pkt, tcp_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	/* 12 bytes of header plus 16 of ICV, padded to 8 octets */
+	if ah_pkt.GetLength()-tcp_pkt.GetLength() != 32 ||
+		ah_pkt.PayloadLen != 6 {
+		t.Fatalf("Length mismatch: %d %d",
+			ah_pkt.GetLength()-tcp_pkt.GetLength(), ah_pkt.PayloadLen)
+	}
+
+	pkt, err := layers.UnpackAll(buf, packet.IPv6)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	ah := layers.FindLayer(pkt, packet.IPSecAH).(*ipsec.AH)
+
+	if !ah.IPv6 || ah.SPI != 0x1001 || len(ah.ICV) != 20 ||
+		!bytes.Equal(ah.ICV[:16], ah_pkt.ICV) {
+		t.Fatalf("AH mismatch:\n%s\n%s", ah, ah_pkt)
+	}
+
+	if !tcp_pkt.Equals(layers.FindLayer(pkt, packet.TCP)) {
+		t.Fatalf("Payload mismatch:\n%s", pkt)
+	}
+}
@@ -0,0 +1,323 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ipsec
+
+import "crypto/aes"
+import "crypto/cipher"
+import "crypto/rand"
+import "encoding/binary"
+import "fmt"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+
+type ESP struct {
+	SPI         uint32 `string:"spi"`
+	Seq         uint32
+	Data        []byte        `string:"skip"`
+	NextHdr     ipv4.Protocol `cmp:"skip" string:"next"`
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+// Transform identifies the encryption algorithm used by an ESP security
+// association.
+type Transform uint8
+
+const (
+	Null   Transform = iota /* RFC2410 */
+	AESCBC                  /* RFC3602 */
+	AESGCM                  /* RFC4106 */
+)
+
+// SA holds the parameters of an ESP security association.
+type SA struct {
+	/* SPI of the association, or 0 to match any packet */
+	SPI uint32
+
+	Transform Transform
+
+	/* Encryption key. For AES-GCM the last 4 bytes are the salt. */
+	Key []byte
+
+	/* Length of the ICV appended to the payload (16 if 0 for AES-GCM) */
+	ICVLen int
+}
+
+func MakeESP() *ESP {
+	return &ESP{}
+}
+
+func (p *ESP) GetType() packet.Type {
+	return packet.IPSecESP
+}
+
+func (p *ESP) GetLength() uint16 {
+	return 8 + uint16(len(p.Data))
+}
+
+func (p *ESP) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *ESP) Answers(other packet.Packet) bool {
+	return false
+}
+
+func (p *ESP) Pack(buf *packet.Buffer) error {
+	buf.WriteN(p.SPI)
+	buf.WriteN(p.Seq)
+	buf.Write(p.Data)
+
+	return nil
+}
+
+func (p *ESP) Unpack(buf *packet.Buffer) error {
+	buf.ReadN(&p.SPI)
+	buf.ReadN(&p.Seq)
+
+	p.Data = buf.Next(buf.Len())
+
+	return nil
+}
+
+func (p *ESP) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *ESP) GuessPayloadType() packet.Type {
+	return packet.None
+}
+
+/* The payload is carried encrypted in Data, so it can't be stacked as a layer
+ * but must be packed and passed to Encrypt() instead */
+func (p *ESP) SetPayload(pl packet.Packet) error {
+	return fmt.Errorf("ESP payload must be set with Encrypt()")
+}
+
+func (p *ESP) InitChecksum(csum uint32) {
+}
+
+func (p *ESP) String() string {
+	return packet.Stringify(p)
+}
+
+// Decrypt the ESP payload using the given security association. The returned
+// slice contains the decrypted payload without padding and trailer, and can be
+// decoded with layers.UnpackAll() using ipv4.ProtocolToType(p.NextHdr).
+//
+// Note that the ICV is only verified for AES-GCM.
+func (p *ESP) Decrypt(sa *SA) ([]byte, error) {
+	if sa.SPI != 0 && sa.SPI != p.SPI {
+		return nil, fmt.Errorf("SPI mismatch: 0x%x", p.SPI)
+	}
+
+	var plain []byte
+
+	switch sa.Transform {
+	case Null:
+		if len(p.Data) < sa.ICVLen {
+			return nil, fmt.Errorf("Invalid payload length: %d", len(p.Data))
+		}
+
+		plain = p.Data[:len(p.Data)-sa.ICVLen]
+
+	case AESCBC:
+		block, err := aes.NewCipher(sa.Key)
+		if err != nil {
+			return nil, fmt.Errorf("Could not create cipher: %s", err)
+		}
+
+		length := len(p.Data) - aes.BlockSize - sa.ICVLen
+		if length < 0 || length%aes.BlockSize != 0 {
+			return nil, fmt.Errorf("Invalid payload length: %d", len(p.Data))
+		}
+
+		iv := p.Data[:aes.BlockSize]
+		plain = make([]byte, length)
+
+		cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain,
+			p.Data[aes.BlockSize:aes.BlockSize+length])
+
+	case AESGCM:
+		aead, err := sa.gcm()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(p.Data) < 8+aead.Overhead() {
+			return nil, fmt.Errorf("Invalid payload length: %d", len(p.Data))
+		}
+
+		plain, err = aead.Open(nil, sa.nonce(p.Data[:8]), p.Data[8:],
+			p.aad())
+		if err != nil {
+			return nil, fmt.Errorf("Could not decrypt: %s", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("Unsupported transform: %d", sa.Transform)
+	}
+
+	if len(plain) < 2 || int(plain[len(plain)-2])+2 > len(plain) {
+		return nil, fmt.Errorf("Invalid padding")
+	}
+
+	pad_len := int(plain[len(plain)-2])
+
+	p.NextHdr = ipv4.Protocol(plain[len(plain)-1])
+
+	return plain[:len(plain)-2-pad_len], nil
+}
+
+// Encrypt the given payload using the given security association, and set the
+// ESP data accordingly.
+//
+// Note that the ICV is only calculated for AES-GCM, and is zeroed otherwise.
+func (p *ESP) Encrypt(sa *SA, next ipv4.Protocol, payload []byte) error {
+	if sa.SPI != 0 {
+		p.SPI = sa.SPI
+	}
+
+	block_size := 4
+	if sa.Transform == AESCBC {
+		block_size = aes.BlockSize
+	}
+
+	pad_len := (block_size - (len(payload)+2)%block_size) % block_size
+
+	plain := make([]byte, len(payload), len(payload)+pad_len+2)
+	copy(plain, payload)
+
+	for i := 1; i <= pad_len; i++ {
+		plain = append(plain, uint8(i))
+	}
+
+	plain = append(plain, uint8(pad_len), uint8(next))
+
+	switch sa.Transform {
+	case Null:
+		p.Data = append(plain, make([]byte, sa.ICVLen)...)
+
+	case AESCBC:
+		block, err := aes.NewCipher(sa.Key)
+		if err != nil {
+			return fmt.Errorf("Could not create cipher: %s", err)
+		}
+
+		p.Data = make([]byte, aes.BlockSize+len(plain)+sa.ICVLen)
+
+		iv := p.Data[:aes.BlockSize]
+
+		_, err = rand.Read(iv)
+		if err != nil {
+			return fmt.Errorf("Could not generate IV: %s", err)
+		}
+
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(
+			p.Data[aes.BlockSize:aes.BlockSize+len(plain)], plain)
+
+	case AESGCM:
+		aead, err := sa.gcm()
+		if err != nil {
+			return err
+		}
+
+		iv := make([]byte, 8)
+
+		_, err = rand.Read(iv)
+		if err != nil {
+			return fmt.Errorf("Could not generate IV: %s", err)
+		}
+
+		p.Data = aead.Seal(iv, sa.nonce(iv), plain, p.aad())
+
+	default:
+		return fmt.Errorf("Unsupported transform: %d", sa.Transform)
+	}
+
+	p.NextHdr = next
+
+	return nil
+}
+
+func (p *ESP) aad() []byte {
+	aad := make([]byte, 8)
+
+	binary.BigEndian.PutUint32(aad[0:], p.SPI)
+	binary.BigEndian.PutUint32(aad[4:], p.Seq)
+
+	return aad
+}
+
+func (sa *SA) gcm() (cipher.AEAD, error) {
+	if len(sa.Key) < 4 {
+		return nil, fmt.Errorf("Invalid key length: %d", len(sa.Key))
+	}
+
+	block, err := aes.NewCipher(sa.Key[:len(sa.Key)-4])
+	if err != nil {
+		return nil, fmt.Errorf("Could not create cipher: %s", err)
+	}
+
+	icv_len := sa.ICVLen
+	if icv_len == 0 {
+		icv_len = 16
+	}
+
+	aead, err := cipher.NewGCMWithTagSize(block, icv_len)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create cipher: %s", err)
+	}
+
+	return aead, nil
+}
+
+func (sa *SA) nonce(iv []byte) []byte {
+	nonce := make([]byte, 0, 12)
+
+	nonce = append(nonce, sa.Key[len(sa.Key)-4:]...)
+	nonce = append(nonce, iv...)
+
+	return nonce
+}
+
+func (t Transform) String() string {
+	switch t {
+	case Null:
+		return "null"
+	case AESCBC:
+		return "aes-cbc"
+	case AESGCM:
+		return "aes-gcm"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,249 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ipsec_test
+
+import "bytes"
+import "net"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/layers"
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/ipsec"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+import "github.com/scs-solution/go.pkt2/packet/udp"
+
+var test_esp = []byte{
+	0x00, 0x00, 0x10, 0x01, 0x00, 0x00, 0x00, 0x02, 0xde, 0xad, 0xbe, 0xef,
+}
+
+func MakeTestESP() *ipsec.ESP {
+	return &ipsec.ESP{
+		SPI:  0x1001,
+		Seq:  2,
+		Data: []byte{0xde, 0xad, 0xbe, 0xef},
+	}
+}
+
+func TestPackESP(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_esp)))
+
+	p := MakeTestESP()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_esp, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPackESP(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_esp)))
+
+	p := MakeTestESP()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpackESP(t *testing.T) {
+	var p ipsec.ESP
+
+	cmp := MakeTestESP()
+
+	var b packet.Buffer
+	b.Init(test_esp)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpackESP(bn *testing.B) {
+	var p ipsec.ESP
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_esp)
+		p.Unpack(&b)
+	}
+}
+
+var test_null = []byte{
+	0x00, 0x00, 0x10, 0x01, 0x00, 0x00, 0x00, 0x02, 0x61, 0x73, 0x64, 0x01,
+	0x01, 0x11, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestDecryptNull(t *testing.T) {
+	var p ipsec.ESP
+
+	var b packet.Buffer
+	b.Init(test_null)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	plain, err := p.Decrypt(&ipsec.SA{Transform: ipsec.Null, ICVLen: 4})
+	if err != nil {
+		t.Fatalf("Error decrypting: %s", err)
+	}
+
+	if !bytes.Equal(plain, []byte("asd")) || p.NextHdr != ipv4.UDP {
+		t.Fatalf("Payload mismatch: %x %s", plain, p.NextHdr)
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	sas := []*ipsec.SA{
+		{
+			SPI:       0x1001,
+			Transform: ipsec.Null,
+			ICVLen:    12,
+		},
+		{
+			SPI:       0x1001,
+			Transform: ipsec.AESCBC,
+			Key:       bytes.Repeat([]byte{0x42}, 16),
+			ICVLen:    12,
+		},
+		{
+			SPI:       0x1001,
+			Transform: ipsec.AESGCM,
+			Key:       bytes.Repeat([]byte{0x42}, 20),
+		},
+	}
+
+	payload := []byte("fdg agfh ldfhgk hfdkgh kfjdhsg kshfdgk")
+
+	for _, sa := range sas {
+		p := ipsec.MakeESP()
+		p.Seq = 7
+
+		err := p.Encrypt(sa, ipv4.TCP, payload)
+		if err != nil {
+			t.Fatalf("Error encrypting with %s: %s", sa.Transform, err)
+		}
+
+		var b packet.Buffer
+		b.Init(make([]byte, p.GetLength()))
+
+		err = p.Pack(&b)
+		if err != nil {
+			t.Fatalf("Error packing: %s", err)
+		}
+
+		var q ipsec.ESP
+		b.Init(b.Buffer())
+
+		err = q.Unpack(&b)
+		if err != nil {
+			t.Fatalf("Error unpacking: %s", err)
+		}
+
+		plain, err := q.Decrypt(sa)
+		if err != nil {
+			t.Fatalf("Error decrypting with %s: %s", sa.Transform, err)
+		}
+
+		if !bytes.Equal(plain, payload) || q.NextHdr != ipv4.TCP {
+			t.Fatalf("Payload mismatch with %s: %x", sa.Transform, plain)
+		}
+	}
+}
+
+func TestDecryptGCMTampered(t *testing.T) {
+	sa := &ipsec.SA{
+		Transform: ipsec.AESGCM,
+		Key:       bytes.Repeat([]byte{0x42}, 36),
+	}
+
+	p := ipsec.MakeESP()
+
+	err := p.Encrypt(sa, ipv4.UDP, []byte("asd"))
+	if err != nil {
+		t.Fatalf("Error encrypting: %s", err)
+	}
+
+	p.Seq++
+
+	_, err = p.Decrypt(sa)
+	if err == nil {
+		t.Fatalf("Tampered packet decrypted")
+	}
+}
+
+func TestPackESPPayload(t *testing.T) {
+	ip4_pkt := ipv4.Make()
+	ip4_pkt.SrcAddr = net.ParseIP("192.168.1.135")
+	ip4_pkt.DstAddr = net.ParseIP("193.27.208.37")
+
+	udp_pkt := udp.Make()
+	udp_pkt.SrcPort = 41562
+	udp_pkt.DstPort = 8338
+
+	_, err := layers.Pack(ip4_pkt, ipsec.MakeESP(), udp_pkt)
+	if err == nil {
+		t.Fatalf("Unencrypted ESP payload accepted")
+	}
+
+	payload, err := layers.Pack(udp_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	esp_pkt := ipsec.MakeESP()
+
+	err = esp_pkt.Encrypt(&ipsec.SA{SPI: 0x1001}, ipv4.UDP, payload)
+	if err != nil {
+		t.Fatalf("Error encrypting: %s", err)
+	}
+
+	buf, err := layers.Pack(ip4_pkt, esp_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if len(buf) != 20+8+12 {
+		t.Fatalf("Length mismatch: %d", len(buf))
+	}
+}
@@ -0,0 +1,259 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ipv4
+
+import "encoding/binary"
+import "net"
+
+type Option struct {
+	Type OptType
+	Len  uint8
+	Data []byte
+}
+
+type OptType uint8
+
+const (
+	End         OptType = 0x00
+	Nop                 = 0x01
+	RecordRoute         = 0x07
+	Timestamp           = 0x44
+	Security            = 0x82
+	LSRR                = 0x83
+	StreamID            = 0x88
+	SSRR                = 0x89
+	RouterAlert         = 0x94
+)
+
+// Flag of the Timestamp option, describing the format of its entries.
+type TSFlag uint8
+
+const (
+	TSOnly    TSFlag = 0x0
+	TSAndAddr        = 0x1
+	TSPrespec        = 0x3
+)
+
+// Single entry of the Timestamp option. Addr is nil for TSOnly timestamps.
+type TSEntry struct {
+	Addr net.IP
+	Time uint32
+}
+
+// Classification level of the Security option (RFC1108).
+type SecurityLevel uint8
+
+const (
+	TopSecret    SecurityLevel = 0x3d
+	Secret                     = 0x5a
+	Confidential               = 0x96
+	Unclassified               = 0xab
+)
+
+/* maximum length of the options area */
+const max_opts_len = 40
+
+// Create a new Record Route option with room for the given number of
+// addresses (at most 9).
+func MakeRecordRoute(slots int) Option {
+	return make_route(RecordRoute, make([]net.IP, slots))
+}
+
+// Create a new Loose (or Strict) Source and Record Route option for the given
+// list of addresses. The last address is the final destination of the packet.
+func MakeSourceRoute(strict bool, addrs ...net.IP) Option {
+	if strict {
+		return make_route(SSRR, addrs)
+	}
+
+	return make_route(LSRR, addrs)
+}
+
+// Create a new Timestamp option with room for the given number of entries. For
+// the TSPrespec flag, the addresses of the entries are taken from addrs.
+func MakeTimestamp(flag TSFlag, slots int, addrs ...net.IP) Option {
+	entry_len := 8
+	if flag == TSOnly {
+		entry_len = 4
+	}
+
+	data := make([]byte, 2+slots*entry_len)
+	data[0] = 5
+	data[1] = uint8(flag) & 0x0f
+
+	for i, addr := range addrs {
+		if i >= slots || entry_len == 4 {
+			break
+		}
+
+		copy(data[2+i*entry_len:], addr.To4())
+	}
+
+	return Option{Type: Timestamp, Len: uint8(2 + len(data)), Data: data}
+}
+
+// Create a new Router Alert option (RFC2113) with the given value. A value of
+// zero means that routers should examine the packet.
+func MakeRouterAlert(value uint16) Option {
+	return Option{
+		Type: RouterAlert,
+		Len:  4,
+		Data: []byte{byte(value >> 8), byte(value)},
+	}
+}
+
+// Create a new Basic Security option (RFC1108) with the given classification
+// level and protection authority flags.
+func MakeSecurity(level SecurityLevel, authority []byte) Option {
+	data := append([]byte{uint8(level)}, authority...)
+
+	return Option{Type: Security, Len: uint8(2 + len(data)), Data: data}
+}
+
+func make_route(typ OptType, addrs []net.IP) Option {
+	data := make([]byte, 1+len(addrs)*4)
+	data[0] = 4
+
+	for i, addr := range addrs {
+		if addr != nil {
+			copy(data[1+i*4:], addr.To4())
+		}
+	}
+
+	return Option{Type: typ, Len: uint8(2 + len(data)), Data: data}
+}
+
+// Return the first option of the given type.
+func (p *Packet) FindOption(typ OptType) (Option, bool) {
+	for _, opt := range p.Options {
+		if opt.Type == typ {
+			return opt, true
+		}
+	}
+
+	return Option{}, false
+}
+
+// Return the addresses recorded so far by the Record Route option, if
+// present.
+func (p *Packet) RecordedRoute() []net.IP {
+	opt, ok := p.FindOption(RecordRoute)
+	if !ok {
+		return nil
+	}
+
+	ptr, addrs := opt.Route()
+	if ptr < 4 {
+		return nil
+	}
+
+	recorded := int(ptr-4) / 4
+	if recorded > len(addrs) {
+		recorded = len(addrs)
+	}
+
+	return addrs[:recorded]
+}
+
+// Return the pointer and the list of addresses of a Record Route or Source
+// Route option.
+func (o Option) Route() (uint8, []net.IP) {
+	var addrs []net.IP
+
+	if (o.Type != RecordRoute && o.Type != LSRR && o.Type != SSRR) ||
+		len(o.Data) < 1 {
+		return 0, nil
+	}
+
+	for i := 1; i+4 <= len(o.Data); i += 4 {
+		addrs = append(addrs, net.IP(o.Data[i:i+4]))
+	}
+
+	return o.Data[0], addrs
+}
+
+// Return the flag, the overflow counter and the recorded entries of a
+// Timestamp option.
+func (o Option) Timestamps() (TSFlag, uint8, []TSEntry) {
+	var entries []TSEntry
+
+	if o.Type != Timestamp || len(o.Data) < 2 {
+		return 0, 0, nil
+	}
+
+	ptr := int(o.Data[0])
+	flag := TSFlag(o.Data[1] & 0x0f)
+	overflow := o.Data[1] >> 4
+
+	entry_len := 8
+	if flag == TSOnly {
+		entry_len = 4
+	}
+
+	/* the pointer is relative to the option start and 1-based */
+	for i := 2; i+entry_len <= len(o.Data) && i+3+entry_len <= ptr; i += entry_len {
+		var entry TSEntry
+
+		if entry_len == 8 {
+			entry.Addr = net.IP(o.Data[i : i+4])
+		}
+
+		entry.Time = binary.BigEndian.Uint32(o.Data[i+entry_len-4:])
+
+		entries = append(entries, entry)
+	}
+
+	return flag, overflow, entries
+}
+
+// Return the value of a Router Alert option.
+func (o Option) RouterAlertValue() uint16 {
+	if o.Type != RouterAlert || len(o.Data) < 2 {
+		return 0
+	}
+
+	return binary.BigEndian.Uint16(o.Data)
+}
+
+// Return the classification level and the protection authority flags of a
+// Basic Security option.
+func (o Option) Security() (SecurityLevel, []byte) {
+	if o.Type != Security || len(o.Data) < 1 {
+		return 0, nil
+	}
+
+	return SecurityLevel(o.Data[0]), o.Data[1:]
+}
+
+// Return whether the option is copied into all fragments.
+func (t OptType) Copied() bool {
+	return t&0x80 != 0
+}
@@ -50,6 +50,7 @@ type Packet struct {
 	Checksum    uint16        `cmp:"skip" string:"sum"`
 	SrcAddr     net.IP        `string:"src"`
 	DstAddr     net.IP        `string:"dst"`
+	Options     []Option      `cmp:"skip" string:"skip"`
 	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
 }
 
@@ -64,21 +65,26 @@ const (
 type Protocol uint8
 
 const (
-	None     Protocol = 0x00
-	GRE               = 0x2F
-	ICMPv4            = 0x01
-	ICMPv6            = 0x3A
-	IGMP              = 0x02
-	IPSecAH           = 0x33
-	IPSecESP          = 0x32
-	IPv6              = 0x29
-	ISIS              = 0x7C
-	L2TP              = 0x73
-	OSPF              = 0x59
-	SCTP              = 0x84
-	TCP               = 0x06
-	UDP               = 0x11
-	UDPLite           = 0x88
+	None      Protocol = 0x00
+	GRE                = 0x2F
+	ICMPv4             = 0x01
+	ICMPv6             = 0x3A
+	IGMP               = 0x02
+	IPSecAH            = 0x33
+	IPSecESP           = 0x32
+	IPv6               = 0x29
+	IPv6Frag           = 0x2C
+	IPv6NoNxt          = 0x3B
+	IPv6Opts           = 0x3C
+	IPv6Route          = 0x2B
+	ISIS               = 0x7C
+	L2TP               = 0x73
+	Mobility           = 0x87
+	OSPF               = 0x59
+	SCTP               = 0x84
+	TCP                = 0x06
+	UDP                = 0x11
+	UDPLite            = 0x88
 )
 
 func Make() *Packet {
@@ -97,10 +103,26 @@ func (p *Packet) GetType() packet.Type {
 
 func (p *Packet) GetLength() uint16 {
 	if p.pkt_payload != nil {
-		return p.pkt_payload.GetLength() + 20
+		return p.pkt_payload.GetLength() + p.header_len()
 	}
 
-	return 20
+	return p.header_len()
+}
+
+func (p *Packet) header_len() uint16 {
+	length := uint16(20)
+
+	for _, opt := range p.Options {
+		switch opt.Type {
+		case End, Nop:
+			length += 1
+
+		default:
+			length += 2 + uint16(len(opt.Data))
+		}
+	}
+
+	return (length + 3) &^ 3
 }
 
 func (p *Packet) Equals(other packet.Packet) bool {
@@ -131,6 +153,13 @@ func (p *Packet) Answers(other packet.Packet) bool {
 }
 
 func (p *Packet) Pack(buf *packet.Buffer) error {
+	hdr_len := int(p.header_len())
+	if hdr_len > 20+max_opts_len {
+		return fmt.Errorf("Invalid options length: %d", hdr_len-20)
+	}
+
+	p.IHL = uint8(hdr_len / 4)
+
 	buf.WriteN((p.Version << 4) | p.IHL)
 	buf.WriteN(p.TOS)
 	buf.WriteN(p.Length)
@@ -142,7 +171,23 @@ func (p *Packet) Pack(buf *packet.Buffer) error {
 	buf.Write(p.SrcAddr.To4())
 	buf.Write(p.DstAddr.To4())
 
-	p.checksum(buf.LayerBytes()[:20])
+	for _, opt := range p.Options {
+		buf.WriteN(opt.Type)
+
+		if opt.Type == End || opt.Type == Nop {
+			continue
+		}
+
+		buf.WriteN(uint8(2 + len(opt.Data)))
+		buf.Write(opt.Data)
+	}
+
+	/* add padding */
+	for buf.LayerLen() < hdr_len {
+		buf.WriteN(uint8(End))
+	}
+
+	p.checksum(buf.LayerBytes()[:hdr_len])
 	buf.PutUint16N(10, p.Checksum)
 
 	return nil
@@ -164,14 +209,33 @@ func (p *Packet) pseudo_checksum() uint32 {
 
 	csum += (uint32(p.SrcAddr.To4()[0]) + uint32(p.SrcAddr.To4()[2])) << 8
 	csum += uint32(p.SrcAddr.To4()[1]) + uint32(p.SrcAddr.To4()[3])
-	csum += (uint32(p.DstAddr.To4()[0]) + uint32(p.DstAddr.To4()[2])) << 8
-	csum += uint32(p.DstAddr.To4()[1]) + uint32(p.DstAddr.To4()[3])
+	dst := p.final_dst().To4()
+
+	csum += (uint32(dst[0]) + uint32(dst[2])) << 8
+	csum += uint32(dst[1]) + uint32(dst[3])
 	csum += uint32(p.Protocol)
 	csum += uint32(p.pkt_payload.GetLength())
 
 	return csum
 }
 
+// Return the final destination of the packet, which differs from DstAddr for
+// source routed packets that haven't yet reached the end of the route.
+func (p *Packet) final_dst() net.IP {
+	for _, opt := range p.Options {
+		if opt.Type != LSRR && opt.Type != SSRR {
+			continue
+		}
+
+		ptr, addrs := opt.Route()
+		if len(addrs) > 0 && int(ptr) <= 4*len(addrs) {
+			return addrs[len(addrs)-1]
+		}
+	}
+
+	return p.DstAddr
+}
+
 func (p *Packet) Unpack(buf *packet.Buffer) error {
 	var versihl uint8
 	buf.ReadN(&versihl)
@@ -179,6 +243,10 @@ func (p *Packet) Unpack(buf *packet.Buffer) error {
 	p.Version = versihl >> 4
 	p.IHL = versihl & 0x0F
 
+	if p.IHL < 5 || buf.Len()+1 < int(p.IHL)*4 {
+		return fmt.Errorf("Invalid header length: %d", p.IHL)
+	}
+
 	buf.ReadN(&p.TOS)
 	buf.ReadN(&p.Length)
 	buf.ReadN(&p.Id)
@@ -197,7 +265,43 @@ func (p *Packet) Unpack(buf *packet.Buffer) error {
 	p.SrcAddr = net.IP(buf.Next(4))
 	p.DstAddr = net.IP(buf.Next(4))
 
-	/* TODO: Options */
+	p.Options = nil
+
+options:
+	for buf.LayerLen() < int(p.IHL)*4 {
+		var opt_type OptType
+		buf.ReadN(&opt_type)
+
+		switch opt_type {
+		case End: /* end of options */
+			break options
+
+		case Nop: /* padding */
+			p.Options = append(p.Options, Option{Type: Nop})
+
+		default:
+			opt := Option{Type: opt_type}
+
+			buf.ReadN(&opt.Len)
+
+			if opt.Len < 2 ||
+				buf.LayerLen()+int(opt.Len)-2 > int(p.IHL)*4 {
+				return fmt.Errorf("Invalid option length: %d", opt.Len)
+			}
+
+			opt.Data = buf.Next(int(opt.Len) - 2)
+
+			p.Options = append(p.Options, opt)
+		}
+	}
+
+	/* remove padding */
+	if buf.LayerLen() < int(p.IHL)*4 {
+		buf.Next(int(p.IHL)*4 - buf.LayerLen())
+	}
+
+	/* ignore link layer padding past the end of the datagram */
+	buf.Truncate(int(p.Length) - int(p.IHL)*4)
 
 	return nil
 }
@@ -259,21 +363,25 @@ func CalculateChecksum(raw_bytes []byte, csum uint32) uint16 {
 }
 
 var ipv4proto_to_type_map = map[Protocol]packet.Type{
-	None:     packet.None,
-	GRE:      packet.GRE,
-	ICMPv4:   packet.ICMPv4,
-	ICMPv6:   packet.ICMPv6,
-	IGMP:     packet.IGMP,
-	IPSecAH:  packet.IPSec,
-	IPSecESP: packet.IPSec,
-	IPv6:     packet.IPv6,
-	UDP:      packet.UDP,
-	ISIS:     packet.ISIS,
-	L2TP:     packet.L2TP,
-	OSPF:     packet.OSPF,
-	SCTP:     packet.SCTP,
-	UDPLite:  packet.UDPLite,
-	TCP:      packet.TCP,
+	None:      packet.None,
+	GRE:       packet.GRE,
+	ICMPv4:    packet.ICMPv4,
+	ICMPv6:    packet.ICMPv6,
+	IGMP:      packet.IGMP,
+	IPSecAH:   packet.IPSecAH,
+	IPSecESP:  packet.IPSecESP,
+	IPv6:      packet.IPv6,
+	IPv6Frag:  packet.Fragment,
+	IPv6Opts:  packet.DestOpts,
+	IPv6Route: packet.Routing,
+	UDP:       packet.UDP,
+	ISIS:      packet.ISIS,
+	L2TP:      packet.L2TPv3,
+	Mobility:  packet.Mobility,
+	OSPF:      packet.OSPF,
+	SCTP:      packet.SCTP,
+	UDPLite:   packet.UDPLite,
+	TCP:       packet.TCP,
 }
 
 // Create a new Type from the given IP protocol ID.
@@ -314,12 +422,22 @@ func (p Protocol) String() string {
 		return "IPSecESP"
 	case IPv6:
 		return "IPv6"
+	case IPv6Frag:
+		return "IPv6Frag"
+	case IPv6NoNxt:
+		return "IPv6NoNxt"
+	case IPv6Opts:
+		return "IPv6Opts"
+	case IPv6Route:
+		return "IPv6Route"
 	case UDP:
 		return "UDP"
 	case ISIS:
 		return "ISIS"
 	case L2TP:
 		return "L2TP"
+	case Mobility:
+		return "Mobility"
 	case OSPF:
 		return "OSPF"
 	case SCTP:
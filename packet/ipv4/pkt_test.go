@@ -34,8 +34,11 @@ import "bytes"
 import "net"
 import "testing"
 
+import "github.com/scs-solution/go.pkt2/layers"
 import "github.com/scs-solution/go.pkt2/packet"
 import "github.com/scs-solution/go.pkt2/packet/ipv4"
+import "github.com/scs-solution/go.pkt2/packet/raw"
+import "github.com/scs-solution/go.pkt2/packet/udp"
 
 var test_simple = []byte{
 	0x45, 0x03, 0x00, 0x14, 0x00, 0x0f, 0x40, 0x00, 0x64, 0x06, 0x48, 0x97,
@@ -115,3 +118,137 @@ func BenchmarkUnpack(bn *testing.B) {
 		p.Unpack(&b)
 	}
 }
+
+var test_options = []byte{
+	0x48, 0x00, 0x00, 0x20, 0x00, 0x01, 0x00, 0x00, 0x40, 0x11, 0x93, 0x81,
+	0xc0, 0xa8, 0x01, 0x87, 0x08, 0x08, 0x04, 0x04, 0x01, 0x07, 0x0b, 0x08,
+	0x0a, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
+}
+
+func MakeTestOptions() *ipv4.Packet {
+	p := ipv4.Make()
+	p.Length = 32
+	p.Protocol = ipv4.UDP
+	p.SrcAddr = net.ParseIP(ipsrc_str)
+	p.DstAddr = net.ParseIP(ipdst_str)
+
+	rr := ipv4.MakeRecordRoute(2)
+	rr.Data[0] = 8
+	copy(rr.Data[1:], net.ParseIP("10.0.0.1").To4())
+
+	p.Options = []ipv4.Option{{Type: ipv4.Nop}, rr}
+
+	return p
+}
+
+func TestPackOptions(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_options)))
+
+	p := MakeTestOptions()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_options, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+
+	if p.IHL != 8 {
+		t.Fatalf("Header length mismatch: %d", p.IHL)
+	}
+}
+
+func TestUnpackOptions(t *testing.T) {
+	var p ipv4.Packet
+
+	var b packet.Buffer
+	b.Init(test_options)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if len(p.Options) != 2 || p.Options[1].Type != ipv4.RecordRoute {
+		t.Fatalf("Options mismatch: %v", p.Options)
+	}
+
+	route := p.RecordedRoute()
+	if len(route) != 1 || !route[0].Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("Route mismatch: %v", route)
+	}
+
+	if b.LayerLen() != 32 {
+		t.Fatalf("Payload offset mismatch: %d", b.LayerLen())
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	opt := ipv4.MakeTimestamp(ipv4.TSAndAddr, 2)
+	if opt.Len != 20 {
+		t.Fatalf("Option length mismatch: %d", opt.Len)
+	}
+
+	opt.Data[0] = 13
+	copy(opt.Data[2:], net.ParseIP("10.0.0.1").To4())
+	opt.Data[9] = 0x64
+
+	flag, overflow, entries := opt.Timestamps()
+	if flag != ipv4.TSAndAddr || overflow != 0 || len(entries) != 1 {
+		t.Fatalf("Timestamps mismatch: %v", entries)
+	}
+
+	if !entries[0].Addr.Equal(net.ParseIP("10.0.0.1")) ||
+		entries[0].Time != 100 {
+		t.Fatalf("Timestamp mismatch: %v", entries[0])
+	}
+}
+
+func TestPackSourceRoute(t *testing.T) {
+	var sums []uint16
+
+	for _, routed := range []bool{false, true} {
+		ip4_pkt := ipv4.Make()
+		ip4_pkt.SrcAddr = net.ParseIP(ipsrc_str)
+		ip4_pkt.DstAddr = net.ParseIP(ipdst_str)
+
+		if routed {
+			ip4_pkt.DstAddr = net.ParseIP("10.0.0.254")
+			ip4_pkt.Options = []ipv4.Option{
+				ipv4.MakeSourceRoute(false, net.ParseIP(ipdst_str)),
+			}
+		}
+
+		udp_pkt := udp.Make()
+		udp_pkt.SrcPort = 41562
+		udp_pkt.DstPort = 8338
+
+		raw_pkt := raw.Make()
+		raw_pkt.Data = []byte("hello")
+
+		buf, err := layers.Pack(ip4_pkt, udp_pkt, raw_pkt)
+		if err != nil {
+			t.Fatalf("Error packing: %s", err)
+		}
+
+		pkt, err := layers.UnpackAll(buf, packet.IPv4)
+		if err != nil {
+			t.Fatalf("Error unpacking: %s", err)
+		}
+
+		udp_pkt = layers.FindLayer(pkt, packet.UDP).(*udp.Packet)
+		if udp_pkt.SrcPort != 41562 || udp_pkt.DstPort != 8338 {
+			t.Fatalf("Packet mismatch: %s", udp_pkt)
+		}
+
+		sums = append(sums, udp_pkt.Checksum)
+	}
+
+	/* the pseudo-header uses the final destination */
+	if sums[0] != sums[1] {
+		t.Fatalf("Checksum mismatch: %x %x", sums[0], sums[1])
+	}
+}
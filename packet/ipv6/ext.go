@@ -0,0 +1,428 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ipv6
+
+import "fmt"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+
+// Hop-by-Hop Options extension header.
+type HopByHop struct {
+	NextHdr     ipv4.Protocol `string:"next"`
+	Options     []Option
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+// Destination Options extension header.
+type DestOpts struct {
+	NextHdr     ipv4.Protocol `string:"next"`
+	Options     []Option
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+// Fragment extension header.
+type Fragment struct {
+	NextHdr     ipv4.Protocol `string:"next"`
+	Offset      uint16        `string:"off"`
+	More        bool
+	Id          uint32
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+// Option of the Hop-by-Hop and Destination Options headers. Padding options
+// are added automatically when packing, and are skipped when unpacking.
+type Option struct {
+	Type OptType
+	Data []byte
+}
+
+type OptType uint8
+
+const (
+	Pad1             OptType = 0x00
+	PadN                     = 0x01
+	TunnelEncapLimit         = 0x04
+	RouterAlert              = 0x05
+	JumboPayload             = 0xc2
+	HomeAddress              = 0xc9
+)
+
+// Create a new Router Alert option (RFC2711) with the given value (e.g. zero
+// for MLD messages).
+func MakeRouterAlert(value uint16) Option {
+	return Option{
+		Type: RouterAlert,
+		Data: []byte{byte(value >> 8), byte(value)},
+	}
+}
+
+// Create a new Hop-by-Hop Options header with the given options.
+func MakeHopByHop(opts ...Option) *HopByHop {
+	return &HopByHop{
+		NextHdr: ipv4.IPv6NoNxt,
+		Options: opts,
+	}
+}
+
+// Create a new Destination Options header with the given options.
+func MakeDestOpts(opts ...Option) *DestOpts {
+	return &DestOpts{
+		NextHdr: ipv4.IPv6NoNxt,
+		Options: opts,
+	}
+}
+
+func (p *HopByHop) GetType() packet.Type {
+	return packet.HopByHop
+}
+
+func (p *HopByHop) GetLength() uint16 {
+	if p.pkt_payload != nil {
+		return p.pkt_payload.GetLength() + opts_hdr_len(p.Options)
+	}
+
+	return opts_hdr_len(p.Options)
+}
+
+func (p *HopByHop) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *HopByHop) Answers(other packet.Packet) bool {
+	return ext_answers(p, other)
+}
+
+func (p *HopByHop) Pack(buf *packet.Buffer) error {
+	return pack_opts_hdr(buf, p.NextHdr, p.Options)
+}
+
+func (p *HopByHop) Unpack(buf *packet.Buffer) error {
+	var err error
+
+	p.NextHdr, p.Options, err = unpack_opts_hdr(buf)
+
+	return err
+}
+
+func (p *HopByHop) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *HopByHop) GuessPayloadType() packet.Type {
+	return NextHdrToType(p.NextHdr)
+}
+
+func (p *HopByHop) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+	p.NextHdr = TypeToNextHdr(pl.GetType())
+
+	return nil
+}
+
+func (p *HopByHop) InitChecksum(csum uint32) {
+	init_payload_checksum(p.pkt_payload, csum, HopByHopHdr, p.NextHdr,
+		opts_hdr_len(p.Options))
+}
+
+func (p *HopByHop) String() string {
+	return packet.Stringify(p)
+}
+
+func (p *DestOpts) GetType() packet.Type {
+	return packet.DestOpts
+}
+
+func (p *DestOpts) GetLength() uint16 {
+	if p.pkt_payload != nil {
+		return p.pkt_payload.GetLength() + opts_hdr_len(p.Options)
+	}
+
+	return opts_hdr_len(p.Options)
+}
+
+func (p *DestOpts) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *DestOpts) Answers(other packet.Packet) bool {
+	return ext_answers(p, other)
+}
+
+func (p *DestOpts) Pack(buf *packet.Buffer) error {
+	return pack_opts_hdr(buf, p.NextHdr, p.Options)
+}
+
+func (p *DestOpts) Unpack(buf *packet.Buffer) error {
+	var err error
+
+	p.NextHdr, p.Options, err = unpack_opts_hdr(buf)
+
+	return err
+}
+
+func (p *DestOpts) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *DestOpts) GuessPayloadType() packet.Type {
+	return NextHdrToType(p.NextHdr)
+}
+
+func (p *DestOpts) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+	p.NextHdr = TypeToNextHdr(pl.GetType())
+
+	return nil
+}
+
+func (p *DestOpts) InitChecksum(csum uint32) {
+	init_payload_checksum(p.pkt_payload, csum, ipv4.IPv6Opts, p.NextHdr,
+		opts_hdr_len(p.Options))
+}
+
+func (p *DestOpts) String() string {
+	return packet.Stringify(p)
+}
+
+// Create a new Fragment header.
+func MakeFragment(id uint32, offset uint16, more bool) *Fragment {
+	return &Fragment{
+		NextHdr: ipv4.IPv6NoNxt,
+		Offset:  offset,
+		More:    more,
+		Id:      id,
+	}
+}
+
+func (p *Fragment) GetType() packet.Type {
+	return packet.Fragment
+}
+
+func (p *Fragment) GetLength() uint16 {
+	if p.pkt_payload != nil {
+		return p.pkt_payload.GetLength() + 8
+	}
+
+	return 8
+}
+
+func (p *Fragment) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Fragment) Answers(other packet.Packet) bool {
+	return ext_answers(p, other)
+}
+
+func (p *Fragment) Pack(buf *packet.Buffer) error {
+	off := p.Offset << 3
+	if p.More {
+		off |= 0x1
+	}
+
+	buf.WriteN(p.NextHdr)
+	buf.WriteN(uint8(0x00))
+	buf.WriteN(off)
+	buf.WriteN(p.Id)
+
+	return nil
+}
+
+func (p *Fragment) Unpack(buf *packet.Buffer) error {
+	if buf.Len() < 8 {
+		return fmt.Errorf("Invalid header length: %d", buf.Len())
+	}
+
+	var off uint16
+
+	buf.ReadN(&p.NextHdr)
+	buf.Next(1)
+	buf.ReadN(&off)
+	buf.ReadN(&p.Id)
+
+	p.Offset = off >> 3
+	p.More = off&0x1 != 0
+
+	return nil
+}
+
+func (p *Fragment) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Fragment) GuessPayloadType() packet.Type {
+	/* only the first fragment starts with the upper-layer header */
+	if p.Offset != 0 {
+		return packet.Raw
+	}
+
+	return NextHdrToType(p.NextHdr)
+}
+
+func (p *Fragment) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	if pl.GetType() != packet.Raw {
+		p.NextHdr = TypeToNextHdr(pl.GetType())
+	}
+
+	return nil
+}
+
+func (p *Fragment) InitChecksum(csum uint32) {
+	init_payload_checksum(p.pkt_payload, csum, ipv4.IPv6Frag, p.NextHdr, 8)
+}
+
+func (p *Fragment) String() string {
+	return packet.Stringify(p)
+}
+
+func opts_hdr_len(opts []Option) uint16 {
+	length := uint16(2)
+
+	for _, opt := range opts {
+		if opt.Type == Pad1 {
+			length += 1
+		} else {
+			length += 2 + uint16(len(opt.Data))
+		}
+	}
+
+	return (length + 7) &^ 7
+}
+
+func pack_opts_hdr(buf *packet.Buffer, next ipv4.Protocol, opts []Option) error {
+	hdr_len := int(opts_hdr_len(opts))
+	if hdr_len > 2048 {
+		return fmt.Errorf("Invalid header length: %d", hdr_len)
+	}
+
+	buf.WriteN(next)
+	buf.WriteN(uint8(hdr_len/8 - 1))
+
+	for _, opt := range opts {
+		buf.WriteN(opt.Type)
+
+		if opt.Type == Pad1 {
+			continue
+		}
+
+		if len(opt.Data) > 255 {
+			return fmt.Errorf("Invalid option length: %d", len(opt.Data))
+		}
+
+		buf.WriteN(uint8(len(opt.Data)))
+		buf.Write(opt.Data)
+	}
+
+	/* add padding */
+	switch pad := hdr_len - buf.LayerLen(); {
+	case pad == 1:
+		buf.WriteN(uint8(Pad1))
+
+	case pad > 1:
+		buf.WriteN(uint8(PadN))
+		buf.WriteN(uint8(pad - 2))
+		buf.Write(make([]byte, pad-2))
+	}
+
+	return nil
+}
+
+func unpack_opts_hdr(buf *packet.Buffer) (ipv4.Protocol, []Option, error) {
+	var next ipv4.Protocol
+	var ext_len uint8
+	var opts []Option
+
+	if buf.Len() < 8 {
+		return next, nil, fmt.Errorf("Invalid header length: %d", buf.Len())
+	}
+
+	buf.ReadN(&next)
+	buf.ReadN(&ext_len)
+
+	hdr_len := (int(ext_len) + 1) * 8
+	if hdr_len > buf.Len()+2 {
+		return next, nil, fmt.Errorf("Invalid header length: %d", hdr_len)
+	}
+
+	for buf.LayerLen() < hdr_len {
+		var opt Option
+		buf.ReadN(&opt.Type)
+
+		if opt.Type == Pad1 {
+			continue
+		}
+
+		var length uint8
+		buf.ReadN(&length)
+
+		if buf.LayerLen()+int(length) > hdr_len {
+			return next, nil, fmt.Errorf("Invalid option length: %d", length)
+		}
+
+		opt.Data = buf.Next(int(length))
+
+		if opt.Type != PadN {
+			opts = append(opts, opt)
+		}
+	}
+
+	return next, opts, nil
+}
+
+/*
+ * The pseudo-header of the upper-layer payload must refer to the upper-layer
+ * protocol and length, not to the extension header ones.
+ */
+func init_payload_checksum(pl packet.Packet, csum uint32, proto, next ipv4.Protocol, hdr_len uint16) {
+	if pl == nil {
+		return
+	}
+
+	csum -= uint32(proto) + uint32(hdr_len)
+	csum += uint32(next)
+
+	pl.InitChecksum(csum)
+}
+
+func ext_answers(p, other packet.Packet) bool {
+	if other == nil || other.GetType() != p.GetType() {
+		return false
+	}
+
+	if p.Payload() != nil {
+		return p.Payload().Answers(other.Payload())
+	}
+
+	return true
+}
@@ -0,0 +1,165 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ipv6
+
+import "fmt"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+
+// Mobility extension header (RFC6275). The Data field holds the type specific
+// message data, including any mobility options.
+type Mobility struct {
+	NextHdr     ipv4.Protocol `string:"next"`
+	Type        MHType
+	Checksum    uint16 `string:"sum"`
+	Data        []byte
+	csum_seed   uint32        `cmp:"skip" string:"skip"`
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+type MHType uint8
+
+const (
+	BindingRefreshRequest MHType = iota
+	HomeTestInit
+	CareOfTestInit
+	HomeTest
+	CareOfTest
+	BindingUpdate
+	BindingAck
+	BindingError
+)
+
+func MakeMobility(mh_type MHType, data []byte) *Mobility {
+	return &Mobility{
+		NextHdr: ipv4.IPv6NoNxt,
+		Type:    mh_type,
+		Data:    data,
+	}
+}
+
+func (p *Mobility) GetType() packet.Type {
+	return packet.Mobility
+}
+
+func (p *Mobility) GetLength() uint16 {
+	if p.pkt_payload != nil {
+		return p.pkt_payload.GetLength() + p.header_len()
+	}
+
+	return p.header_len()
+}
+
+func (p *Mobility) header_len() uint16 {
+	return (6 + uint16(len(p.Data)) + 7) &^ 7
+}
+
+func (p *Mobility) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Mobility) Answers(other packet.Packet) bool {
+	return ext_answers(p, other)
+}
+
+func (p *Mobility) Pack(buf *packet.Buffer) error {
+	hdr_len := int(p.header_len())
+	if hdr_len > 2048 {
+		return fmt.Errorf("Invalid header length: %d", hdr_len)
+	}
+
+	buf.WriteN(p.NextHdr)
+	buf.WriteN(uint8(hdr_len/8 - 1))
+	buf.WriteN(p.Type)
+	buf.WriteN(uint8(0x00))
+	buf.WriteN(uint16(0x0000))
+	buf.Write(p.Data)
+
+	/* add padding */
+	buf.Write(make([]byte, hdr_len-buf.LayerLen()))
+
+	if p.csum_seed != 0 {
+		p.Checksum = ipv4.CalculateChecksum(buf.LayerBytes(), p.csum_seed)
+		buf.PutUint16N(4, p.Checksum)
+	}
+
+	return nil
+}
+
+func (p *Mobility) Unpack(buf *packet.Buffer) error {
+	var ext_len uint8
+
+	if buf.Len() < 8 {
+		return fmt.Errorf("Invalid header length: %d", buf.Len())
+	}
+
+	buf.ReadN(&p.NextHdr)
+	buf.ReadN(&ext_len)
+	buf.ReadN(&p.Type)
+	buf.Next(1)
+	buf.ReadN(&p.Checksum)
+
+	hdr_len := (int(ext_len) + 1) * 8
+	if hdr_len > buf.Len()+6 {
+		return fmt.Errorf("Invalid header length: %d", hdr_len)
+	}
+
+	p.Data = buf.Next(hdr_len - 6)
+
+	return nil
+}
+
+func (p *Mobility) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Mobility) GuessPayloadType() packet.Type {
+	return NextHdrToType(p.NextHdr)
+}
+
+func (p *Mobility) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+	p.NextHdr = TypeToNextHdr(pl.GetType())
+
+	return nil
+}
+
+func (p *Mobility) InitChecksum(csum uint32) {
+	p.csum_seed = csum
+
+	init_payload_checksum(p.pkt_payload, csum, ipv4.Mobility, p.NextHdr,
+		p.header_len())
+}
+
+func (p *Mobility) String() string {
+	return packet.Stringify(p)
+}
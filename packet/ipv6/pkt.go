@@ -29,6 +29,10 @@
  */
 
 // Provides encoding and decoding for IPv6 packets.
+//
+// Extension headers (i.e. Hop-by-Hop Options, Routing, Fragment, Destination
+// Options and Mobility) are each decoded as a separate layer, chained through
+// their NextHdr fields.
 package ipv6
 
 import "encoding/binary"
@@ -51,6 +55,10 @@ type Packet struct {
 
 type Flags uint8
 
+// Next header value of the Hop-by-Hop Options header, which has no IPv4
+// protocol equivalent.
+const HopByHopHdr ipv4.Protocol = 0x00
+
 func Make() *Packet {
 	return &Packet{
 		Version:  6,
@@ -111,11 +119,13 @@ func (p *Packet) Pack(buf *packet.Buffer) error {
 func (p *Packet) pseudo_checksum() uint32 {
 	var csum uint32
 
+	dst := p.final_dst().To16()
+
 	for i := 0; i < 16; i += 2 {
 		csum += uint32(p.SrcAddr.To16()[i]) << 8
 		csum += uint32(p.SrcAddr.To16()[i+1])
-		csum += uint32(p.DstAddr.To16()[i]) << 8
-		csum += uint32(p.DstAddr.To16()[i+1])
+		csum += uint32(dst[i]) << 8
+		csum += uint32(dst[i+1])
 	}
 
 	csum += uint32(p.Length)
@@ -124,6 +134,20 @@ func (p *Packet) pseudo_checksum() uint32 {
 	return csum
 }
 
+// Return the final destination of the packet, which differs from DstAddr for
+// packets carrying a Routing header with segments left.
+func (p *Packet) final_dst() net.IP {
+	for pl := p.pkt_payload; pl != nil && is_ext_hdr(pl); pl = pl.Payload() {
+		if r, ok := pl.(*Routing); ok {
+			if dst := r.FinalDst(); dst != nil {
+				return dst
+			}
+		}
+	}
+
+	return p.DstAddr
+}
+
 func (p *Packet) Unpack(buf *packet.Buffer) error {
 	var versclass uint8
 	buf.ReadN(&versclass)
@@ -145,8 +169,6 @@ func (p *Packet) Unpack(buf *packet.Buffer) error {
 	p.SrcAddr = net.IP(buf.Next(16))
 	p.DstAddr = net.IP(buf.Next(16))
 
-	/* TODO: Options */
-
 	return nil
 }
 
@@ -155,12 +177,12 @@ func (p *Packet) Payload() packet.Packet {
 }
 
 func (p *Packet) GuessPayloadType() packet.Type {
-	return ipv4.ProtocolToType(p.NextHdr)
+	return NextHdrToType(p.NextHdr)
 }
 
 func (p *Packet) SetPayload(pl packet.Packet) error {
 	p.pkt_payload = pl
-	p.NextHdr = ipv4.TypeToProtocol(pl.GetType())
+	p.NextHdr = TypeToNextHdr(pl.GetType())
 	p.Length = pl.GetLength()
 
 	pl.InitChecksum(p.pseudo_checksum())
@@ -174,3 +196,39 @@ func (p *Packet) InitChecksum(csum uint32) {
 func (p *Packet) String() string {
 	return packet.Stringify(p)
 }
+
+// Create a new Type from the given IPv6 next header value.
+func NextHdrToType(next ipv4.Protocol) packet.Type {
+	switch next {
+	case HopByHopHdr:
+		return packet.HopByHop
+
+	case ipv4.IPv6NoNxt:
+		return packet.None
+	}
+
+	return ipv4.ProtocolToType(next)
+}
+
+// Convert the Type to the corresponding IPv6 next header value.
+func TypeToNextHdr(pkttype packet.Type) ipv4.Protocol {
+	switch pkttype {
+	case packet.HopByHop:
+		return HopByHopHdr
+
+	case packet.None:
+		return ipv4.IPv6NoNxt
+	}
+
+	return ipv4.TypeToProtocol(pkttype)
+}
+
+func is_ext_hdr(p packet.Packet) bool {
+	switch p.GetType() {
+	case packet.HopByHop, packet.Routing, packet.Fragment,
+		packet.DestOpts, packet.IPSecAH:
+		return true
+	}
+
+	return false
+}
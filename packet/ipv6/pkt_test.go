@@ -34,9 +34,12 @@ import "bytes"
 import "net"
 import "testing"
 
+import "github.com/scs-solution/go.pkt2/layers"
 import "github.com/scs-solution/go.pkt2/packet"
 import "github.com/scs-solution/go.pkt2/packet/ipv4"
 import "github.com/scs-solution/go.pkt2/packet/ipv6"
+import "github.com/scs-solution/go.pkt2/packet/raw"
+import "github.com/scs-solution/go.pkt2/packet/udp"
 
 var test_simple = []byte{
 	0x63, 0x0d, 0x5b, 0x0a, 0x00, 0x08, 0x11, 0x40, 0xfe, 0x80, 0x00, 0x00,
@@ -115,3 +118,142 @@ func BenchmarkUnpack(bn *testing.B) {
 		p.Unpack(&b)
 	}
 }
+
+var test_hbh = []byte{
+	0x11, 0x00, 0x05, 0x02, 0x00, 0x00, 0x01, 0x00,
+}
+
+func TestPackHopByHop(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_hbh)))
+
+	p := ipv6.MakeHopByHop(ipv6.MakeRouterAlert(0))
+	p.NextHdr = ipv4.UDP
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_hbh, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func TestUnpackHopByHop(t *testing.T) {
+	var p ipv6.HopByHop
+
+	cmp := ipv6.MakeHopByHop(ipv6.MakeRouterAlert(0))
+	cmp.NextHdr = ipv4.UDP
+
+	var b packet.Buffer
+	b.Init(test_hbh)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+var test_frag = []byte{
+	0x11, 0x00, 0x05, 0xc9, 0x12, 0x34, 0x56, 0x78,
+}
+
+func TestPackFragment(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_frag)))
+
+	p := ipv6.MakeFragment(0x12345678, 185, true)
+	p.NextHdr = ipv4.UDP
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_frag, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func TestUnpackFragment(t *testing.T) {
+	var p ipv6.Fragment
+
+	cmp := ipv6.MakeFragment(0x12345678, 185, true)
+	cmp.NextHdr = ipv4.UDP
+
+	var b packet.Buffer
+	b.Init(test_frag)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func TestPackSRH(t *testing.T) {
+	var sums []uint16
+
+	final := net.ParseIP(ipdst_str)
+	first := net.ParseIP("fc00::1")
+
+	for _, routed := range []bool{false, true} {
+		ip6_pkt := ipv6.Make()
+		ip6_pkt.SrcAddr = net.ParseIP(ipsrc_str)
+		ip6_pkt.DstAddr = final
+
+		pkts := []packet.Packet{ip6_pkt}
+
+		if routed {
+			ip6_pkt.DstAddr = first
+			pkts = append(pkts, ipv6.MakeSRH(first, final))
+		}
+
+		udp_pkt := udp.Make()
+		udp_pkt.SrcPort = 41562
+		udp_pkt.DstPort = 8338
+
+		raw_pkt := raw.Make()
+		raw_pkt.Data = []byte("hello")
+
+		pkts = append(pkts, udp_pkt, raw_pkt)
+
+		buf, err := layers.Pack(pkts...)
+		if err != nil {
+			t.Fatalf("Error packing: %s", err)
+		}
+
+		pkt, err := layers.UnpackAll(buf, packet.IPv6)
+		if err != nil {
+			t.Fatalf("Error unpacking: %s", err)
+		}
+
+		if routed {
+			srh := layers.FindLayer(pkt, packet.Routing).(*ipv6.Routing)
+			if srh.SegmentsLeft != 1 || srh.LastEntry != 1 ||
+				!srh.FinalDst().Equal(final) {
+				t.Fatalf("Packet mismatch: %s", srh)
+			}
+		}
+
+		udp_pkt = layers.FindLayer(pkt, packet.UDP).(*udp.Packet)
+		if udp_pkt.SrcPort != 41562 || udp_pkt.DstPort != 8338 {
+			t.Fatalf("Packet mismatch: %s", udp_pkt)
+		}
+
+		sums = append(sums, udp_pkt.Checksum)
+	}
+
+	/* the pseudo-header uses the final destination */
+	if sums[0] != sums[1] {
+		t.Fatalf("Checksum mismatch: %x %x", sums[0], sums[1])
+	}
+}
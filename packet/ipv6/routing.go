@@ -0,0 +1,252 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ipv6
+
+import "fmt"
+import "net"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+
+// Routing extension header. The Addrs field holds the addresses of type 0 and
+// type 2 headers, or the segment list of Segment Routing headers (RFC8754).
+type Routing struct {
+	NextHdr      ipv4.Protocol `string:"next"`
+	Type         RoutingType
+	SegmentsLeft uint8  `string:"left"`
+	LastEntry    uint8  `string:"last"` /* SRH only */
+	Flags        uint8  /* SRH only */
+	Tag          uint16 /* SRH only */
+	Addrs        []net.IP
+	Data         []byte        /* SRH TLVs or type specific data */
+	pkt_payload  packet.Packet `cmp:"skip" string:"skip"`
+}
+
+type RoutingType uint8
+
+const (
+	SourceRoute RoutingType = 0 /* deprecated by RFC5095 */
+	HomeAddr                = 2
+	RPL                     = 3
+	SRH                     = 4
+)
+
+// Create a new Segment Routing header for the given path of segments, in the
+// order in which they are to be visited. The destination address of the IPv6
+// packet should be set to the first segment.
+func MakeSRH(path ...net.IP) *Routing {
+	segments := make([]net.IP, len(path))
+
+	/* the segment list is encoded in reverse order */
+	for i, addr := range path {
+		segments[len(path)-1-i] = addr
+	}
+
+	left := 0
+	if len(path) > 0 {
+		left = len(path) - 1
+	}
+
+	return &Routing{
+		NextHdr:      ipv4.IPv6NoNxt,
+		Type:         SRH,
+		SegmentsLeft: uint8(left),
+		Addrs:        segments,
+	}
+}
+
+func (p *Routing) GetType() packet.Type {
+	return packet.Routing
+}
+
+func (p *Routing) GetLength() uint16 {
+	if p.pkt_payload != nil {
+		return p.pkt_payload.GetLength() + p.header_len()
+	}
+
+	return p.header_len()
+}
+
+func (p *Routing) header_len() uint16 {
+	length := uint16(8 + len(p.Data))
+
+	if p.has_addrs() {
+		length += 16 * uint16(len(p.Addrs))
+	} else {
+		length -= 4
+	}
+
+	return (length + 7) &^ 7
+}
+
+func (p *Routing) has_addrs() bool {
+	switch p.Type {
+	case SourceRoute, HomeAddr, SRH:
+		return true
+	}
+
+	return false
+}
+
+func (p *Routing) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Routing) Answers(other packet.Packet) bool {
+	return ext_answers(p, other)
+}
+
+func (p *Routing) Pack(buf *packet.Buffer) error {
+	hdr_len := int(p.header_len())
+	if hdr_len > 2048 {
+		return fmt.Errorf("Invalid header length: %d", hdr_len)
+	}
+
+	buf.WriteN(p.NextHdr)
+	buf.WriteN(uint8(hdr_len/8 - 1))
+	buf.WriteN(p.Type)
+	buf.WriteN(p.SegmentsLeft)
+
+	switch p.Type {
+	case SourceRoute, HomeAddr:
+		buf.WriteN(uint32(0x00))
+
+	case SRH:
+		if len(p.Addrs) > 0 {
+			p.LastEntry = uint8(len(p.Addrs) - 1)
+		}
+
+		buf.WriteN(p.LastEntry)
+		buf.WriteN(p.Flags)
+		buf.WriteN(p.Tag)
+	}
+
+	if p.has_addrs() {
+		for _, addr := range p.Addrs {
+			buf.Write(addr.To16())
+		}
+	}
+
+	buf.Write(p.Data)
+
+	/* add padding */
+	buf.Write(make([]byte, hdr_len-buf.LayerLen()))
+
+	return nil
+}
+
+func (p *Routing) Unpack(buf *packet.Buffer) error {
+	var ext_len uint8
+
+	if buf.Len() < 8 {
+		return fmt.Errorf("Invalid header length: %d", buf.Len())
+	}
+
+	buf.ReadN(&p.NextHdr)
+	buf.ReadN(&ext_len)
+	buf.ReadN(&p.Type)
+	buf.ReadN(&p.SegmentsLeft)
+
+	hdr_len := (int(ext_len) + 1) * 8
+	if hdr_len > buf.Len()+4 {
+		return fmt.Errorf("Invalid header length: %d", hdr_len)
+	}
+
+	p.Addrs = nil
+	p.Data = nil
+
+	switch p.Type {
+	case SourceRoute, HomeAddr:
+		buf.Next(4)
+
+		for buf.LayerLen()+16 <= hdr_len {
+			p.Addrs = append(p.Addrs, net.IP(buf.Next(16)))
+		}
+
+	case SRH:
+		buf.ReadN(&p.LastEntry)
+		buf.ReadN(&p.Flags)
+		buf.ReadN(&p.Tag)
+
+		if 8+(int(p.LastEntry)+1)*16 > hdr_len {
+			return fmt.Errorf("Invalid last entry: %d", p.LastEntry)
+		}
+
+		for i := 0; i <= int(p.LastEntry); i++ {
+			p.Addrs = append(p.Addrs, net.IP(buf.Next(16)))
+		}
+	}
+
+	if buf.LayerLen() < hdr_len {
+		p.Data = buf.Next(hdr_len - buf.LayerLen())
+	}
+
+	return nil
+}
+
+func (p *Routing) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Routing) GuessPayloadType() packet.Type {
+	return NextHdrToType(p.NextHdr)
+}
+
+func (p *Routing) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+	p.NextHdr = TypeToNextHdr(pl.GetType())
+
+	return nil
+}
+
+func (p *Routing) InitChecksum(csum uint32) {
+	init_payload_checksum(p.pkt_payload, csum, ipv4.IPv6Route, p.NextHdr,
+		p.header_len())
+}
+
+func (p *Routing) String() string {
+	return packet.Stringify(p)
+}
+
+// Return the final destination of the packet, or nil if the packet has no
+// segments left (i.e. its destination address is already the final one).
+func (p *Routing) FinalDst() net.IP {
+	if p.SegmentsLeft == 0 || len(p.Addrs) == 0 || !p.has_addrs() {
+		return nil
+	}
+
+	/* the segment list is encoded in reverse order */
+	if p.Type == SRH {
+		return p.Addrs[0]
+	}
+
+	return p.Addrs[len(p.Addrs)-1]
+}
@@ -0,0 +1,209 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package isis
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+// IS-IS Hello PDU, used for both LAN and point-to-point IIHs.
+type Hello struct {
+	CircuitType    CircuitType
+	SourceID       [6]byte
+	HoldTime       uint16
+	PDULength      uint16
+	Priority       uint8   /* LAN only */
+	LANID          [7]byte /* LAN only */
+	LocalCircuitID uint8   /* point-to-point only */
+}
+
+type CircuitType uint8
+
+const (
+	Level1   CircuitType = 1
+	Level2               = 2
+	Level1_2             = 3
+)
+
+// Link State PDU.
+type LSP struct {
+	PDULength uint16
+	Lifetime  uint16
+	LSPID     [8]byte
+	Seq       uint32
+	Checksum  uint16
+	Flags     LSPFlags
+}
+
+type LSPFlags uint8
+
+const (
+	Partition  LSPFlags = 0x80
+	ATTError            = 0x40
+	ATTExpense          = 0x20
+	ATTDelay            = 0x10
+	ATTDefault          = 0x08
+	Overload            = 0x04
+	ISType1             = 0x01
+	ISType2             = 0x03
+)
+
+// Complete Sequence Numbers PDU.
+type CSNP struct {
+	PDULength uint16
+	SourceID  [7]byte
+	Start     [8]byte
+	End       [8]byte
+}
+
+// Partial Sequence Numbers PDU.
+type PSNP struct {
+	PDULength uint16
+	SourceID  [7]byte
+}
+
+// Create a new Hello body with the default hold time.
+func MakeHello(circuit CircuitType, source_id [6]byte) *Hello {
+	return &Hello{
+		CircuitType: circuit,
+		SourceID:    source_id,
+		HoldTime:    30,
+		Priority:    64,
+	}
+}
+
+func (b *Hello) length(typ PDUType) uint16 {
+	if typ == P2PHello {
+		return 12
+	}
+
+	return 19
+}
+
+func (b *Hello) pack(buf *packet.Buffer, typ PDUType, pdu_len uint16) {
+	b.PDULength = pdu_len
+
+	buf.WriteN(b.CircuitType)
+	buf.WriteN(b.SourceID)
+	buf.WriteN(b.HoldTime)
+	buf.WriteN(b.PDULength)
+
+	if typ == P2PHello {
+		buf.WriteN(b.LocalCircuitID)
+	} else {
+		buf.WriteN(b.Priority & 0x7f)
+		buf.WriteN(b.LANID)
+	}
+}
+
+func (b *Hello) unpack(buf *packet.Buffer, typ PDUType) error {
+	buf.ReadN(&b.CircuitType)
+	buf.ReadN(&b.SourceID)
+	buf.ReadN(&b.HoldTime)
+	buf.ReadN(&b.PDULength)
+
+	if typ == P2PHello {
+		buf.ReadN(&b.LocalCircuitID)
+	} else {
+		buf.ReadN(&b.Priority)
+		buf.ReadN(&b.LANID)
+
+		b.Priority &= 0x7f
+	}
+
+	b.CircuitType &= 0x03
+
+	return nil
+}
+
+func (b *LSP) length(typ PDUType) uint16 {
+	return 19
+}
+
+func (b *LSP) pack(buf *packet.Buffer, typ PDUType, pdu_len uint16) {
+	b.PDULength = pdu_len
+
+	buf.WriteN(b.PDULength)
+	buf.WriteN(b.Lifetime)
+	buf.WriteN(b.LSPID)
+	buf.WriteN(b.Seq)
+	buf.WriteN(uint16(0x00))
+	buf.WriteN(b.Flags)
+}
+
+func (b *LSP) unpack(buf *packet.Buffer, typ PDUType) error {
+	buf.ReadN(&b.PDULength)
+	buf.ReadN(&b.Lifetime)
+	buf.ReadN(&b.LSPID)
+	buf.ReadN(&b.Seq)
+	buf.ReadN(&b.Checksum)
+	buf.ReadN(&b.Flags)
+
+	return nil
+}
+
+func (b *CSNP) length(typ PDUType) uint16 {
+	return 25
+}
+
+func (b *CSNP) pack(buf *packet.Buffer, typ PDUType, pdu_len uint16) {
+	b.PDULength = pdu_len
+
+	buf.WriteN(b.PDULength)
+	buf.WriteN(b.SourceID)
+	buf.WriteN(b.Start)
+	buf.WriteN(b.End)
+}
+
+func (b *CSNP) unpack(buf *packet.Buffer, typ PDUType) error {
+	buf.ReadN(&b.PDULength)
+	buf.ReadN(&b.SourceID)
+	buf.ReadN(&b.Start)
+	buf.ReadN(&b.End)
+
+	return nil
+}
+
+func (b *PSNP) length(typ PDUType) uint16 {
+	return 9
+}
+
+func (b *PSNP) pack(buf *packet.Buffer, typ PDUType, pdu_len uint16) {
+	b.PDULength = pdu_len
+
+	buf.WriteN(b.PDULength)
+	buf.WriteN(b.SourceID)
+}
+
+func (b *PSNP) unpack(buf *packet.Buffer, typ PDUType) error {
+	buf.ReadN(&b.PDULength)
+	buf.ReadN(&b.SourceID)
+
+	return nil
+}
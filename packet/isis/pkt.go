@@ -0,0 +1,345 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for IS-IS packets.
+//
+// The type specific part of the PDU is stored in the Body field (e.g. a *Hello
+// for IIH PDUs), while the TLVs carried by the PDU are stored in the TLVs
+// field.
+package isis
+
+import "fmt"
+import "net"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type Packet struct {
+	Type         PDUType
+	IDLength     uint8         `string:"id-len"`
+	MaxAreaAddrs uint8         `string:"max-areas"`
+	Body         Body          `cmp:"skip" string:"skip"`
+	TLVs         []TLV         `string:"skip"`
+	pkt_payload  packet.Packet `cmp:"skip" string:"skip"`
+}
+
+type PDUType uint8
+
+const (
+	L1LANHello PDUType = 15
+	L2LANHello         = 16
+	P2PHello           = 17
+	L1LSP              = 18
+	L2LSP              = 20
+	L1CSNP             = 24
+	L2CSNP             = 25
+	L1PSNP             = 26
+	L2PSNP             = 27
+)
+
+// Body is the interface implemented by the type specific part of IS-IS PDUs
+// (i.e. *Hello, *LSP, *CSNP and *PSNP).
+type Body interface {
+	length(typ PDUType) uint16
+	pack(buf *packet.Buffer, typ PDUType, pdu_len uint16)
+	unpack(buf *packet.Buffer, typ PDUType) error
+}
+
+var (
+	AllL1ISs = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x14}
+	AllL2ISs = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x15}
+	AllISs   = net.HardwareAddr{0x09, 0x00, 0x2b, 0x00, 0x00, 0x05}
+)
+
+/* intradomain routeing protocol discriminator */
+const irpd = 0x83
+
+func Make() *Packet {
+	return &Packet{
+		Type: P2PHello,
+		Body: &Hello{},
+	}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.ISIS
+}
+
+func (p *Packet) GetLength() uint16 {
+	length := p.header_len()
+
+	for _, tlv := range p.TLVs {
+		length += 2 + uint16(len(tlv.Value))
+	}
+
+	return length
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	req, ok := other.(*Packet)
+	if !ok {
+		return false
+	}
+
+	lsp, ok := req.Body.(*LSP)
+	if !ok {
+		return false
+	}
+
+	/* PSNPs acknowledge LSPs on point-to-point circuits */
+	if p.Type != L1PSNP && p.Type != L2PSNP {
+		return false
+	}
+
+	for _, entry := range p.LSPEntries() {
+		if entry.LSPID == lsp.LSPID && entry.Seq == lsp.Seq {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	if p.Body == nil {
+		return fmt.Errorf("Missing PDU body")
+	}
+
+	pdu_len := p.GetLength()
+
+	buf.WriteN(uint8(irpd))
+	buf.WriteN(uint8(p.header_len()))
+	buf.WriteN(uint8(1))
+	buf.WriteN(p.IDLength)
+	buf.WriteN(uint8(p.Type) & 0x1f)
+	buf.WriteN(uint8(1))
+	buf.WriteN(uint8(0))
+	buf.WriteN(p.MaxAreaAddrs)
+
+	p.Body.pack(buf, p.Type, pdu_len)
+
+	for _, tlv := range p.TLVs {
+		if len(tlv.Value) > 255 {
+			return fmt.Errorf("Invalid TLV length: %d", len(tlv.Value))
+		}
+
+		buf.WriteN(tlv.Type)
+		buf.WriteN(uint8(len(tlv.Value)))
+		buf.Write(tlv.Value)
+	}
+
+	if lsp, ok := p.Body.(*LSP); ok {
+		/* checksum covers the PDU from the LSP ID field */
+		lsp.Checksum = CalculateLSPChecksum(buf.LayerBytes()[12:pdu_len])
+		buf.PutUint16N(24, lsp.Checksum)
+	}
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	p.TLVs = nil
+
+	if buf.Len() < 8 {
+		return fmt.Errorf("Invalid PDU length: %d", buf.Len())
+	}
+
+	var disc, hdr_len, version, typ, version2, reserved uint8
+
+	buf.ReadN(&disc)
+	if disc != irpd {
+		return fmt.Errorf("Invalid protocol discriminator: 0x%x", disc)
+	}
+
+	buf.ReadN(&hdr_len)
+	buf.ReadN(&version)
+	buf.ReadN(&p.IDLength)
+	buf.ReadN(&typ)
+	buf.ReadN(&version2)
+	buf.ReadN(&reserved)
+	buf.ReadN(&p.MaxAreaAddrs)
+
+	if p.IDLength != 0 && p.IDLength != 6 {
+		return fmt.Errorf("Unsupported ID length: %d", p.IDLength)
+	}
+
+	p.Type = PDUType(typ & 0x1f)
+
+	switch p.Type {
+	case L1LANHello, L2LANHello, P2PHello:
+		p.Body = &Hello{}
+	case L1LSP, L2LSP:
+		p.Body = &LSP{}
+	case L1CSNP, L2CSNP:
+		p.Body = &CSNP{}
+	case L1PSNP, L2PSNP:
+		p.Body = &PSNP{}
+	default:
+		return fmt.Errorf("Unknown PDU type: %d", p.Type)
+	}
+
+	if buf.Len() < int(hdr_len)-8 {
+		return fmt.Errorf("Invalid header length: %d", hdr_len)
+	}
+
+	err := p.Body.unpack(buf, p.Type)
+	if err != nil {
+		return err
+	}
+
+	/* skip unknown header fields */
+	if buf.LayerLen() < int(hdr_len) {
+		buf.Next(int(hdr_len) - buf.LayerLen())
+	}
+
+	end := int(p.pdu_len()) - buf.LayerLen()
+	if end < 0 || end > buf.Len() {
+		return fmt.Errorf("Invalid PDU length: %d", p.pdu_len())
+	}
+
+	var tlvs packet.Buffer
+	tlvs.Init(buf.Next(end))
+
+	for tlvs.Len() >= 2 {
+		var tlv TLV
+		tlvs.ReadN(&tlv.Type)
+
+		var length uint8
+		tlvs.ReadN(&length)
+
+		if int(length) > tlvs.Len() {
+			return fmt.Errorf("Invalid TLV length: %d", length)
+		}
+
+		tlv.Value = tlvs.Next(int(length))
+
+		p.TLVs = append(p.TLVs, tlv)
+	}
+
+	return nil
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	return packet.None
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+func (p *Packet) header_len() uint16 {
+	if p.Body == nil {
+		return 8
+	}
+
+	return 8 + p.Body.length(p.Type)
+}
+
+func (p *Packet) pdu_len() uint16 {
+	switch body := p.Body.(type) {
+	case *Hello:
+		return body.PDULength
+	case *LSP:
+		return body.PDULength
+	case *CSNP:
+		return body.PDULength
+	case *PSNP:
+		return body.PDULength
+	}
+
+	return 0
+}
+
+// Calculate the Fletcher checksum of the given LSP, starting from the LSP ID
+// field. The checksum field of the LSP must be set to zero.
+func CalculateLSPChecksum(raw_bytes []byte) uint16 {
+	var c0, c1 int
+
+	for _, b := range raw_bytes {
+		c0 = (c0 + int(b)) % 255
+		c1 = (c1 + c0) % 255
+	}
+
+	/* offset of the checksum field, relative to the LSP ID field */
+	off := 12
+
+	x := ((len(raw_bytes)-off-1)*c0 - c1) % 255
+	if x <= 0 {
+		x += 255
+	}
+
+	y := 510 - c0 - x
+	if y > 255 {
+		y -= 255
+	}
+
+	return uint16(x)<<8 | uint16(y)
+}
+
+func (t PDUType) String() string {
+	switch t {
+	case L1LANHello:
+		return "l1-lan-iih"
+	case L2LANHello:
+		return "l2-lan-iih"
+	case P2PHello:
+		return "p2p-iih"
+	case L1LSP:
+		return "l1-lsp"
+	case L2LSP:
+		return "l2-lsp"
+	case L1CSNP:
+		return "l1-csnp"
+	case L2CSNP:
+		return "l2-csnp"
+	case L1PSNP:
+		return "l1-psnp"
+	case L2PSNP:
+		return "l2-psnp"
+	}
+
+	return fmt.Sprintf("0x%x", uint8(t))
+}
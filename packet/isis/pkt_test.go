@@ -0,0 +1,241 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package isis_test
+
+import "bytes"
+import "net"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/isis"
+
+var test_simple = []byte{
+	0x83, 0x14, 0x01, 0x00, 0x11, 0x01, 0x00, 0x03,
+	0x03, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00,
+	0x1e, 0x00, 0x26, 0x01, 0x81, 0x01, 0xcc, 0x01,
+	0x04, 0x03, 0x49, 0x00, 0x01, 0x84, 0x04, 0x0a,
+	0x00, 0x00, 0x01, 0xf0, 0x01, 0x00,
+}
+
+func MakeTestSimple() *isis.Packet {
+	return &isis.Packet{
+		Type:         isis.P2PHello,
+		MaxAreaAddrs: 3,
+		Body: &isis.Hello{
+			CircuitType:    isis.Level1_2,
+			SourceID:       [6]byte{0, 0, 0, 0, 0, 1},
+			HoldTime:       30,
+			LocalCircuitID: 1,
+		},
+		TLVs: []isis.TLV{
+			isis.MakeProtocols(isis.NLPIDIPv4),
+			isis.MakeAreaAddresses([]byte{0x49, 0x00, 0x01}),
+			isis.MakeIPIfAddrs(net.ParseIP("10.0.0.1")),
+			{isis.P2PAdjStateTLV, []byte{0x00}},
+		},
+	}
+}
+
+func TestPack(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_simple, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPack(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	var p isis.Packet
+
+	cmp := MakeTestSimple()
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpack(bn *testing.B) {
+	var p isis.Packet
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_simple)
+		p.Unpack(&b)
+	}
+}
+
+func TestUnpackHello(t *testing.T) {
+	var p isis.Packet
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	hello, ok := p.Body.(*isis.Hello)
+	if !ok || hello.PDULength != 38 || hello.HoldTime != 30 {
+		t.Fatalf("Hello mismatch: %v", p.Body)
+	}
+
+	areas := p.AreaAddresses()
+	if len(areas) != 1 || !bytes.Equal(areas[0], []byte{0x49, 0x00, 0x01}) {
+		t.Fatalf("Area addresses mismatch: %x", areas)
+	}
+
+	addrs := p.IPIfAddrs()
+	if len(addrs) != 1 || !addrs[0].Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("Interface addresses mismatch: %v", addrs)
+	}
+}
+
+func MakeTestLSP() *isis.Packet {
+	_, prefix, _ := net.ParseCIDR("10.1.0.0/16")
+
+	return &isis.Packet{
+		Type: isis.L2LSP,
+		Body: &isis.LSP{
+			Lifetime: 1199,
+			LSPID:    [8]byte{0, 0, 0, 0, 0, 1, 0, 0},
+			Seq:      4,
+			Flags:    isis.ISType2,
+		},
+		TLVs: []isis.TLV{
+			isis.MakeAreaAddresses([]byte{0x49, 0x00, 0x01}),
+			isis.MakeHostname("r1"),
+			isis.MakeExtISReach(isis.ISReach{
+				Neighbor: [7]byte{0, 0, 0, 0, 0, 2, 0},
+				Metric:   10,
+			}),
+			isis.MakeExtIPReach(isis.IPReach{
+				Prefix: *prefix,
+				Metric: 20,
+			}),
+		},
+	}
+}
+
+func TestPackLSP(t *testing.T) {
+	p := MakeTestLSP()
+
+	var b packet.Buffer
+	b.Init(make([]byte, p.GetLength()))
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	/* a valid Fletcher checksum sums to zero */
+	var c0, c1 int
+	for _, c := range b.Buffer()[12:] {
+		c0 = (c0 + int(c)) % 255
+		c1 = (c1 + c0) % 255
+	}
+
+	if c0 != 0 || c1 != 0 {
+		t.Fatalf("Invalid checksum: %x", b.Buffer())
+	}
+
+	var q isis.Packet
+
+	b.Init(b.Buffer())
+
+	err = q.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !q.Equals(p) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &q, p)
+	}
+
+	if q.Hostname() != "r1" {
+		t.Fatalf("Hostname mismatch: %s", q.Hostname())
+	}
+
+	is_reach := q.ISReach()
+	if len(is_reach) != 1 || is_reach[0].Metric != 10 ||
+		is_reach[0].Neighbor[5] != 2 {
+		t.Fatalf("IS reachability mismatch: %v", is_reach)
+	}
+
+	ip_reach := q.IPReach()
+	if len(ip_reach) != 1 || ip_reach[0].Metric != 20 ||
+		ip_reach[0].Prefix.String() != "10.1.0.0/16" {
+		t.Fatalf("IP reachability mismatch: %v", ip_reach)
+	}
+
+	psnp := &isis.Packet{
+		Type: isis.L2PSNP,
+		Body: &isis.PSNP{},
+		TLVs: []isis.TLV{
+			isis.MakeLSPEntries(isis.LSPEntry{
+				Lifetime: 1199,
+				LSPID:    [8]byte{0, 0, 0, 0, 0, 1, 0, 0},
+				Seq:      4,
+			}),
+		},
+	}
+
+	if !psnp.Answers(&q) {
+		t.Fatalf("PSNP does not answer LSP")
+	}
+}
@@ -0,0 +1,404 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package isis
+
+import "encoding/binary"
+import "net"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type TLV struct {
+	Type  TLVType
+	Value []byte
+}
+
+type TLVType uint8
+
+const (
+	AreaAddressesTLV TLVType = 1
+	ISReachTLV               = 2
+	ISNeighborsTLV           = 6
+	PaddingTLV               = 8
+	LSPEntriesTLV            = 9
+	AuthTLV                  = 10
+	ExtISReachTLV            = 22
+	IPIntReachTLV            = 128
+	ProtocolsTLV             = 129
+	IPExtReachTLV            = 130
+	IPIfAddrTLV              = 132
+	TERouterIDTLV            = 134
+	ExtIPReachTLV            = 135
+	HostnameTLV              = 137
+	IPv6IfAddrTLV            = 232
+	IPv6ReachTLV             = 236
+	P2PAdjStateTLV           = 240
+)
+
+/* NLPIDs used in the protocols supported TLV */
+const (
+	NLPIDIPv4 uint8 = 0xcc
+	NLPIDIPv6       = 0x8e
+)
+
+// IS reachability entry, decoded from both the narrow (2) and extended (22)
+// IS reachability TLVs.
+type ISReach struct {
+	Neighbor [7]byte
+	Metric   uint32
+	SubTLVs  []byte
+}
+
+// IP reachability entry, decoded from both the narrow (128 and 130) and
+// extended (135) IP reachability TLVs.
+type IPReach struct {
+	Prefix  net.IPNet
+	Metric  uint32
+	Down    bool
+	SubTLVs []byte
+}
+
+type LSPEntry struct {
+	Lifetime uint16
+	LSPID    [8]byte
+	Seq      uint32
+	Checksum uint16
+}
+
+// Return the area addresses carried by the PDU.
+func (p *Packet) AreaAddresses() [][]byte {
+	var areas [][]byte
+
+	for _, tlv := range p.find_tlvs(AreaAddressesTLV) {
+		for len(tlv) >= 1 && len(tlv) >= 1+int(tlv[0]) {
+			areas = append(areas, tlv[1:1+int(tlv[0])])
+			tlv = tlv[1+int(tlv[0]):]
+		}
+	}
+
+	return areas
+}
+
+// Return the MAC addresses of the neighbors seen on a LAN circuit.
+func (p *Packet) ISNeighbors() []net.HardwareAddr {
+	var addrs []net.HardwareAddr
+
+	for _, tlv := range p.find_tlvs(ISNeighborsTLV) {
+		for ; len(tlv) >= 6; tlv = tlv[6:] {
+			addrs = append(addrs, net.HardwareAddr(tlv[:6]))
+		}
+	}
+
+	return addrs
+}
+
+// Return the NLPIDs of the protocols supported by the IS.
+func (p *Packet) Protocols() []uint8 {
+	var nlpids []uint8
+
+	for _, tlv := range p.find_tlvs(ProtocolsTLV) {
+		nlpids = append(nlpids, tlv...)
+	}
+
+	return nlpids
+}
+
+// Return the IPv4 interface addresses of the IS.
+func (p *Packet) IPIfAddrs() []net.IP {
+	var addrs []net.IP
+
+	for _, tlv := range p.find_tlvs(IPIfAddrTLV) {
+		for ; len(tlv) >= 4; tlv = tlv[4:] {
+			addrs = append(addrs, net.IP(tlv[:4]))
+		}
+	}
+
+	return addrs
+}
+
+// Return the dynamic hostname of the IS, or "" if not present.
+func (p *Packet) Hostname() string {
+	for _, tlv := range p.find_tlvs(HostnameTLV) {
+		return string(tlv)
+	}
+
+	return ""
+}
+
+// Return the IS reachability entries carried by the PDU.
+func (p *Packet) ISReach() []ISReach {
+	var reach []ISReach
+
+	for _, tlv := range p.find_tlvs(ISReachTLV) {
+		/* skip virtual flag */
+		if len(tlv) < 1 {
+			continue
+		}
+
+		for tlv = tlv[1:]; len(tlv) >= 11; tlv = tlv[11:] {
+			var r ISReach
+
+			r.Metric = uint32(tlv[0] & 0x3f)
+			copy(r.Neighbor[:], tlv[4:11])
+
+			reach = append(reach, r)
+		}
+	}
+
+	for _, tlv := range p.find_tlvs(ExtISReachTLV) {
+		for len(tlv) >= 11 {
+			var r ISReach
+
+			copy(r.Neighbor[:], tlv[0:7])
+			r.Metric = uint32(tlv[7])<<16 | uint32(tlv[8])<<8 |
+				uint32(tlv[9])
+
+			sub_len := int(tlv[10])
+			if len(tlv) < 11+sub_len {
+				break
+			}
+
+			r.SubTLVs = tlv[11 : 11+sub_len]
+
+			reach = append(reach, r)
+
+			tlv = tlv[11+sub_len:]
+		}
+	}
+
+	return reach
+}
+
+// Return the IPv4 reachability entries carried by the PDU.
+func (p *Packet) IPReach() []IPReach {
+	var reach []IPReach
+
+	for _, typ := range []TLVType{IPIntReachTLV, IPExtReachTLV} {
+		for _, tlv := range p.find_tlvs(typ) {
+			for ; len(tlv) >= 12; tlv = tlv[12:] {
+				var r IPReach
+
+				r.Metric = uint32(tlv[0] & 0x3f)
+				r.Down = tlv[0]&0x80 != 0
+
+				r.Prefix.IP = net.IP(tlv[4:8])
+				r.Prefix.Mask = net.IPMask(tlv[8:12])
+
+				reach = append(reach, r)
+			}
+		}
+	}
+
+	for _, tlv := range p.find_tlvs(ExtIPReachTLV) {
+		for len(tlv) >= 5 {
+			var r IPReach
+
+			r.Metric = binary.BigEndian.Uint32(tlv)
+			r.Down = tlv[4]&0x80 != 0
+
+			has_sub := tlv[4]&0x40 != 0
+
+			prefix_len := int(tlv[4] & 0x3f)
+			if prefix_len > 32 {
+				break
+			}
+
+			n := (prefix_len + 7) / 8
+			if len(tlv) < 5+n {
+				break
+			}
+
+			ip := make(net.IP, net.IPv4len)
+			copy(ip, tlv[5:5+n])
+
+			r.Prefix.IP = ip
+			r.Prefix.Mask = net.CIDRMask(prefix_len, 32)
+
+			tlv = tlv[5+n:]
+
+			if has_sub {
+				if len(tlv) < 1 || len(tlv) < 1+int(tlv[0]) {
+					break
+				}
+
+				r.SubTLVs = tlv[1 : 1+int(tlv[0])]
+				tlv = tlv[1+int(tlv[0]):]
+			}
+
+			reach = append(reach, r)
+		}
+	}
+
+	return reach
+}
+
+// Return the LSP entries carried by a sequence numbers PDU.
+func (p *Packet) LSPEntries() []LSPEntry {
+	var entries []LSPEntry
+
+	for _, tlv := range p.find_tlvs(LSPEntriesTLV) {
+		var buf packet.Buffer
+		buf.Init(tlv)
+
+		for buf.Len() >= 16 {
+			var e LSPEntry
+
+			buf.ReadN(&e.Lifetime)
+			buf.ReadN(&e.LSPID)
+			buf.ReadN(&e.Seq)
+			buf.ReadN(&e.Checksum)
+
+			entries = append(entries, e)
+		}
+	}
+
+	return entries
+}
+
+func (p *Packet) find_tlvs(typ TLVType) [][]byte {
+	var values [][]byte
+
+	for _, tlv := range p.TLVs {
+		if tlv.Type == typ {
+			values = append(values, tlv.Value)
+		}
+	}
+
+	return values
+}
+
+// Create a new area addresses TLV.
+func MakeAreaAddresses(areas ...[]byte) TLV {
+	var value []byte
+
+	for _, area := range areas {
+		value = append(value, uint8(len(area)))
+		value = append(value, area...)
+	}
+
+	return TLV{AreaAddressesTLV, value}
+}
+
+// Create a new IS neighbors TLV.
+func MakeISNeighbors(addrs ...net.HardwareAddr) TLV {
+	var value []byte
+
+	for _, addr := range addrs {
+		value = append(value, addr...)
+	}
+
+	return TLV{ISNeighborsTLV, value}
+}
+
+// Create a new protocols supported TLV.
+func MakeProtocols(nlpids ...uint8) TLV {
+	return TLV{ProtocolsTLV, nlpids}
+}
+
+// Create a new IP interface addresses TLV.
+func MakeIPIfAddrs(addrs ...net.IP) TLV {
+	var value []byte
+
+	for _, addr := range addrs {
+		value = append(value, addr.To4()...)
+	}
+
+	return TLV{IPIfAddrTLV, value}
+}
+
+// Create a new dynamic hostname TLV.
+func MakeHostname(name string) TLV {
+	return TLV{HostnameTLV, []byte(name)}
+}
+
+// Create a new extended IS reachability TLV.
+func MakeExtISReach(reach ...ISReach) TLV {
+	var value []byte
+
+	for _, r := range reach {
+		value = append(value, r.Neighbor[:]...)
+		value = append(value, uint8(r.Metric>>16), uint8(r.Metric>>8),
+			uint8(r.Metric), uint8(len(r.SubTLVs)))
+		value = append(value, r.SubTLVs...)
+	}
+
+	return TLV{ExtISReachTLV, value}
+}
+
+// Create a new extended IP reachability TLV.
+func MakeExtIPReach(reach ...IPReach) TLV {
+	var value []byte
+
+	for _, r := range reach {
+		prefix_len, _ := r.Prefix.Mask.Size()
+
+		ctrl := uint8(prefix_len)
+
+		if r.Down {
+			ctrl |= 0x80
+		}
+
+		if len(r.SubTLVs) > 0 {
+			ctrl |= 0x40
+		}
+
+		metric := make([]byte, 4)
+		binary.BigEndian.PutUint32(metric, r.Metric)
+
+		value = append(value, metric...)
+		value = append(value, ctrl)
+		value = append(value, r.Prefix.IP.To4()[:(prefix_len+7)/8]...)
+
+		if len(r.SubTLVs) > 0 {
+			value = append(value, uint8(len(r.SubTLVs)))
+			value = append(value, r.SubTLVs...)
+		}
+	}
+
+	return TLV{ExtIPReachTLV, value}
+}
+
+// Create a new LSP entries TLV.
+func MakeLSPEntries(entries ...LSPEntry) TLV {
+	value := make([]byte, 0, 16*len(entries))
+
+	for _, e := range entries {
+		entry := make([]byte, 16)
+
+		binary.BigEndian.PutUint16(entry[0:], e.Lifetime)
+		copy(entry[2:], e.LSPID[:])
+		binary.BigEndian.PutUint32(entry[10:], e.Seq)
+		binary.BigEndian.PutUint16(entry[14:], e.Checksum)
+
+		value = append(value, entry...)
+	}
+
+	return TLV{LSPEntriesTLV, value}
+}
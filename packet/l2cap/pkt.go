@@ -0,0 +1,145 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for Bluetooth L2CAP packets (basic mode).
+package l2cap
+
+import "fmt"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type Packet struct {
+	Length      uint16
+	CID         CID
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+// L2CAP channel identifier.
+type CID uint16
+
+const (
+	Signaling      CID = 0x0001
+	Connectionless     = 0x0002
+	ATT                = 0x0004
+	LESignaling        = 0x0005
+	SMP                = 0x0006
+	BREDRSMP           = 0x0007
+)
+
+func Make() *Packet {
+	return &Packet{}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.L2CAP
+}
+
+func (p *Packet) GetLength() uint16 {
+	if p.pkt_payload != nil {
+		return p.pkt_payload.GetLength() + 4
+	}
+
+	return 4
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	if other == nil || other.GetType() != packet.L2CAP {
+		return false
+	}
+
+	if p.CID != other.(*Packet).CID {
+		return false
+	}
+
+	if p.Payload() != nil {
+		return p.Payload().Answers(other.Payload())
+	}
+
+	return true
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	buf.WriteL(p.Length)
+	buf.WriteL(p.CID)
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	if buf.Len() < 4 {
+		return fmt.Errorf("Invalid packet length: %d", buf.Len())
+	}
+
+	buf.ReadL(&p.Length)
+	buf.ReadL(&p.CID)
+
+	/* the data may be split across multiple fragments */
+	buf.Truncate(int(p.Length))
+
+	return nil
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	if p.Length == 0 {
+		return packet.None
+	}
+
+	if p.CID == ATT {
+		return packet.ATT
+	}
+
+	return packet.Raw
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+	p.Length = pl.GetLength()
+
+	if pl.GetType() == packet.ATT {
+		p.CID = ATT
+	}
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
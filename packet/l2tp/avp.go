@@ -0,0 +1,217 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package l2tp
+
+import "encoding/binary"
+import "fmt"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+// Attribute-value pair carried by control messages.
+type AVP struct {
+	Flags  AVPFlags
+	Vendor uint16
+	Type   AVPType
+	Value  []byte
+}
+
+type AVPFlags uint16
+
+const (
+	Mandatory AVPFlags = 0x8000
+	Hidden             = 0x4000
+)
+
+type AVPType uint16
+
+const (
+	MessageTypeAVP       AVPType = 0
+	ResultCodeAVP                = 1
+	ProtocolVersionAVP           = 2
+	FramingCapsAVP               = 3
+	BearerCapsAVP                = 4
+	TieBreakerAVP                = 5
+	FirmwareRevisionAVP          = 6
+	HostNameAVP                  = 7
+	VendorNameAVP                = 8
+	AssignedTunnelIDAVP          = 9
+	RecvWindowSizeAVP            = 10
+	ChallengeAVP                 = 11
+	ChallengeResponseAVP         = 13
+	AssignedSessionIDAVP         = 14
+	CallSerialNumberAVP          = 15
+	FramingTypeAVP               = 19
+	TxConnectSpeedAVP            = 24
+	RxConnectSpeedAVP            = 38
+	RouterIDAVP                  = 60
+	AssignedConnIDAVP            = 61
+	PseudowireCapsAVP            = 62
+	LocalSessionIDAVP            = 63
+	RemoteSessionIDAVP           = 64
+	AssignedCookieAVP            = 65
+	RemoteEndIDAVP               = 66
+	PseudowireTypeAVP            = 68
+	L2SublayerAVP                = 69
+)
+
+type MessageType uint16
+
+const (
+	SCCRQ   MessageType = 1
+	SCCRP               = 2
+	SCCCN               = 3
+	StopCCN             = 4
+	Hello               = 6
+	OCRQ                = 7
+	OCRP                = 8
+	OCCN                = 9
+	ICRQ                = 10
+	ICRP                = 11
+	ICCN                = 12
+	CDN                 = 14
+	WEN                 = 15
+	SLI                 = 16
+)
+
+// Create a new mandatory IETF AVP.
+func MakeAVP(typ AVPType, value []byte) AVP {
+	return AVP{
+		Flags: Mandatory,
+		Type:  typ,
+		Value: value,
+	}
+}
+
+// Create a new Message Type AVP.
+func MakeMessageType(typ MessageType) AVP {
+	return MakeUint16AVP(MessageTypeAVP, uint16(typ))
+}
+
+// Create a new mandatory IETF AVP with a 16 bit value.
+func MakeUint16AVP(typ AVPType, value uint16) AVP {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, value)
+
+	return MakeAVP(typ, data)
+}
+
+// Create a new mandatory IETF AVP with a 32 bit value.
+func MakeUint32AVP(typ AVPType, value uint32) AVP {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, value)
+
+	return MakeAVP(typ, data)
+}
+
+// Return the value of the AVP as 16 bit integer.
+func (a *AVP) Uint16() uint16 {
+	if len(a.Value) < 2 {
+		return 0
+	}
+
+	return binary.BigEndian.Uint16(a.Value)
+}
+
+// Return the value of the AVP as 32 bit integer.
+func (a *AVP) Uint32() uint32 {
+	if len(a.Value) < 4 {
+		return 0
+	}
+
+	return binary.BigEndian.Uint32(a.Value)
+}
+
+func (a *AVP) length() uint16 {
+	return 6 + uint16(len(a.Value))
+}
+
+func (a *AVP) pack(buf *packet.Buffer) {
+	buf.WriteN(uint16(a.Flags)&0xfc00 | a.length()&0x03ff)
+	buf.WriteN(a.Vendor)
+	buf.WriteN(a.Type)
+	buf.Write(a.Value)
+}
+
+func (a *AVP) unpack(buf *packet.Buffer) error {
+	var hdr uint16
+	buf.ReadN(&hdr)
+
+	a.Flags = AVPFlags(hdr & 0xfc00)
+
+	length := int(hdr & 0x03ff)
+	if length < 6 || length-2 > buf.Len() {
+		return fmt.Errorf("Invalid AVP length: %d", length)
+	}
+
+	buf.ReadN(&a.Vendor)
+	buf.ReadN(&a.Type)
+
+	a.Value = buf.Next(length - 6)
+
+	return nil
+}
+
+func (t MessageType) String() string {
+	switch t {
+	case 0:
+		return "zlb"
+	case SCCRQ:
+		return "sccrq"
+	case SCCRP:
+		return "sccrp"
+	case SCCCN:
+		return "scccn"
+	case StopCCN:
+		return "stopccn"
+	case Hello:
+		return "hello"
+	case OCRQ:
+		return "ocrq"
+	case OCRP:
+		return "ocrp"
+	case OCCN:
+		return "occn"
+	case ICRQ:
+		return "icrq"
+	case ICRP:
+		return "icrp"
+	case ICCN:
+		return "iccn"
+	case CDN:
+		return "cdn"
+	case WEN:
+		return "wen"
+	case SLI:
+		return "sli"
+	}
+
+	return fmt.Sprintf("0x%x", uint16(t))
+}
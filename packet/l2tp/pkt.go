@@ -0,0 +1,421 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for L2TP packets.
+//
+// L2TPv2 and L2TPv3 over UDP (port 1701) are decoded as packet.L2TP, while
+// L2TPv3 over IP (protocol 115) is decoded as packet.L2TPv3, with the IP field
+// set. The cookie carried by L2TPv3 data messages is negotiated out-of-band,
+// so its length needs to be set in the CookieLen field of the packet to decode
+// (e.g. with layers.Unpack()), otherwise no cookie is decoded.
+package l2tp
+
+import "fmt"
+import "strings"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type Packet struct {
+	Flags       Flags
+	Version     uint8
+	Length      uint16
+	TunnelID    uint16 `string:"tunnel"`
+	SessionID   uint32 `string:"session"`
+	ConnID      uint32 `string:"conn"`
+	Ns          uint16
+	Nr          uint16
+	Offset      uint16
+	Cookie      []byte        `string:"skip"`
+	AVPs        []AVP         `string:"skip"`
+	IP          bool          `cmp:"skip" string:"skip"`
+	CookieLen   int           `cmp:"skip" string:"skip"`
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+type Flags uint16
+
+const (
+	Control       Flags = 0x8000
+	LengthPresent       = 0x4000
+	SeqPresent          = 0x0800
+	OffsetPresent       = 0x0200
+	Priority            = 0x0100
+)
+
+func Make() *Packet {
+	return &Packet{
+		Version: 2,
+	}
+}
+
+// Create a new L2TPv2 control message.
+func MakeControl(tunnel_id uint16, avps ...AVP) *Packet {
+	return &Packet{
+		Flags:    Control | LengthPresent | SeqPresent,
+		Version:  2,
+		TunnelID: tunnel_id,
+		AVPs:     avps,
+	}
+}
+
+// Create a new L2TPv3 over IP data message.
+func MakeIP(session_id uint32, cookie []byte) *Packet {
+	return &Packet{
+		Version:   3,
+		SessionID: session_id,
+		Cookie:    cookie,
+		IP:        true,
+		CookieLen: len(cookie),
+	}
+}
+
+func (p *Packet) GetType() packet.Type {
+	if p.IP {
+		return packet.L2TPv3
+	}
+
+	return packet.L2TP
+}
+
+func (p *Packet) GetLength() uint16 {
+	length := p.header_len()
+
+	for _, avp := range p.AVPs {
+		length += avp.length()
+	}
+
+	if p.pkt_payload != nil {
+		length += p.pkt_payload.GetLength()
+	}
+
+	return length
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	req, ok := other.(*Packet)
+	if !ok || p.Flags&Control == 0 || req.Flags&Control == 0 {
+		return false
+	}
+
+	return p.Version == req.Version && p.Nr == req.Ns+1
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	if p.Flags&LengthPresent != 0 {
+		p.Length = p.GetLength()
+
+		if p.IP {
+			p.Length -= 4
+		}
+	}
+
+	if p.IP {
+		if p.Flags&Control == 0 {
+			buf.WriteN(p.SessionID)
+			buf.Write(p.Cookie)
+
+			return nil
+		}
+
+		buf.WriteN(uint32(0))
+	}
+
+	buf.WriteN(uint16(p.Flags) | uint16(p.Version&0x0f))
+
+	switch {
+	case p.Version == 3 && p.Flags&Control != 0:
+		buf.WriteN(p.Length)
+		buf.WriteN(p.ConnID)
+		buf.WriteN(p.Ns)
+		buf.WriteN(p.Nr)
+
+	case p.Version == 3:
+		buf.WriteN(uint16(0))
+		buf.WriteN(p.SessionID)
+		buf.Write(p.Cookie)
+
+	default:
+		if p.Flags&LengthPresent != 0 {
+			buf.WriteN(p.Length)
+		}
+
+		buf.WriteN(p.TunnelID)
+		buf.WriteN(uint16(p.SessionID))
+
+		if p.Flags&SeqPresent != 0 {
+			buf.WriteN(p.Ns)
+			buf.WriteN(p.Nr)
+		}
+
+		if p.Flags&OffsetPresent != 0 {
+			buf.WriteN(p.Offset)
+			buf.Write(make([]byte, p.Offset))
+		}
+	}
+
+	for _, avp := range p.AVPs {
+		avp.pack(buf)
+	}
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	p.AVPs = nil
+	p.Cookie = nil
+
+	if p.IP {
+		if buf.Len() < 4 {
+			return fmt.Errorf("Invalid packet length: %d", buf.Len())
+		}
+
+		buf.ReadN(&p.SessionID)
+
+		if p.SessionID != 0 {
+			p.Flags = 0
+			p.Version = 3
+			return p.unpack_cookie(buf)
+		}
+	}
+
+	if buf.Len() < 6 {
+		return fmt.Errorf("Invalid packet length: %d", buf.Len())
+	}
+
+	var flags uint16
+	buf.ReadN(&flags)
+
+	p.Flags = Flags(flags & 0xfff0)
+	p.Version = uint8(flags & 0x000f)
+
+	switch {
+	case p.Version == 3 && p.Flags&Control != 0:
+		buf.ReadN(&p.Length)
+		buf.ReadN(&p.ConnID)
+		buf.ReadN(&p.Ns)
+		buf.ReadN(&p.Nr)
+
+	case p.Version == 3:
+		var reserved uint16
+		buf.ReadN(&reserved)
+
+		buf.ReadN(&p.SessionID)
+		return p.unpack_cookie(buf)
+
+	case p.Version == 2:
+		if p.Flags&LengthPresent != 0 {
+			buf.ReadN(&p.Length)
+		}
+
+		buf.ReadN(&p.TunnelID)
+
+		var session_id uint16
+		buf.ReadN(&session_id)
+
+		p.SessionID = uint32(session_id)
+
+		if p.Flags&SeqPresent != 0 {
+			buf.ReadN(&p.Ns)
+			buf.ReadN(&p.Nr)
+		}
+
+		if p.Flags&OffsetPresent != 0 {
+			buf.ReadN(&p.Offset)
+			buf.Next(int(p.Offset))
+		}
+
+	default:
+		return fmt.Errorf("Unsupported version: %d", p.Version)
+	}
+
+	if p.Flags&Control == 0 {
+		return nil
+	}
+
+	end := buf.Len()
+	if p.Flags&LengthPresent != 0 {
+		end = int(p.Length) - buf.LayerLen()
+
+		if p.IP {
+			end += 4
+		}
+
+		if end < 0 || end > buf.Len() {
+			return fmt.Errorf("Invalid length: %d", p.Length)
+		}
+	}
+
+	var avps packet.Buffer
+	avps.Init(buf.Next(end))
+
+	for avps.Len() >= 6 {
+		var avp AVP
+
+		err := avp.unpack(&avps)
+		if err != nil {
+			return err
+		}
+
+		p.AVPs = append(p.AVPs, avp)
+	}
+
+	return nil
+}
+
+func (p *Packet) unpack_cookie(buf *packet.Buffer) error {
+	if p.CookieLen > buf.Len() {
+		return fmt.Errorf("Invalid cookie length: %d", p.CookieLen)
+	}
+
+	if p.CookieLen > 0 {
+		p.Cookie = buf.Next(p.CookieLen)
+	}
+
+	return nil
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	switch {
+	case p.Flags&Control != 0:
+		return packet.None
+
+	case p.Version == 2:
+		return packet.PPP
+
+	default:
+		return packet.Eth
+	}
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+// Return the message type of a control message, or 0 for a ZLB (zero-length
+// body) acknowledgement.
+func (p *Packet) MessageType() MessageType {
+	avp := p.FindAVP(MessageTypeAVP)
+	if avp == nil || avp.Vendor != 0 {
+		return 0
+	}
+
+	return MessageType(avp.Uint16())
+}
+
+// Return the first IETF AVP of the given type, or nil if not present.
+func (p *Packet) FindAVP(typ AVPType) *AVP {
+	for i := range p.AVPs {
+		if p.AVPs[i].Vendor == 0 && p.AVPs[i].Type == typ {
+			return &p.AVPs[i]
+		}
+	}
+
+	return nil
+}
+
+func (p *Packet) header_len() uint16 {
+	switch {
+	case p.IP && p.Flags&Control == 0:
+		return 4 + uint16(len(p.Cookie))
+
+	case p.Version == 3 && p.Flags&Control != 0:
+		length := uint16(12)
+
+		if p.IP {
+			length += 4
+		}
+
+		return length
+
+	case p.Version == 3:
+		return 8 + uint16(len(p.Cookie))
+	}
+
+	length := uint16(6)
+
+	if p.Flags&LengthPresent != 0 {
+		length += 2
+	}
+
+	if p.Flags&SeqPresent != 0 {
+		length += 4
+	}
+
+	if p.Flags&OffsetPresent != 0 {
+		length += 2 + p.Offset
+	}
+
+	return length
+}
+
+func (f Flags) String() string {
+	var flags []string
+
+	if f&Control != 0 {
+		flags = append(flags, "control")
+	} else {
+		flags = append(flags, "data")
+	}
+
+	if f&LengthPresent != 0 {
+		flags = append(flags, "len")
+	}
+
+	if f&SeqPresent != 0 {
+		flags = append(flags, "seq")
+	}
+
+	if f&OffsetPresent != 0 {
+		flags = append(flags, "offset")
+	}
+
+	if f&Priority != 0 {
+		flags = append(flags, "prio")
+	}
+
+	return strings.Join(flags, "|")
+}
@@ -0,0 +1,186 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package l2tp_test
+
+import "bytes"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/l2tp"
+
+var test_simple = []byte{
+	0xc8, 0x02, 0x00, 0x2d, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x80, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x80, 0x08, 0x00, 0x00,
+	0x00, 0x02, 0x01, 0x00, 0x80, 0x09, 0x00, 0x00, 0x00, 0x07, 0x6c, 0x61,
+	0x63, 0x80, 0x08, 0x00, 0x00, 0x00, 0x09, 0x00, 0x01,
+}
+
+func MakeTestSimple() *l2tp.Packet {
+	return &l2tp.Packet{
+		Flags:   l2tp.Control | l2tp.LengthPresent | l2tp.SeqPresent,
+		Version: 2,
+		Length:  45,
+		AVPs: []l2tp.AVP{
+			l2tp.MakeMessageType(l2tp.SCCRQ),
+			l2tp.MakeUint16AVP(l2tp.ProtocolVersionAVP, 0x0100),
+			l2tp.MakeAVP(l2tp.HostNameAVP, []byte("lac")),
+			l2tp.MakeUint16AVP(l2tp.AssignedTunnelIDAVP, 1),
+		},
+	}
+}
+
+func TestPack(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_simple, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPack(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	var p l2tp.Packet
+
+	cmp := MakeTestSimple()
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpack(bn *testing.B) {
+	var p l2tp.Packet
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_simple)
+		p.Unpack(&b)
+	}
+}
+
+func TestUnpackAVPs(t *testing.T) {
+	var p l2tp.Packet
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if p.MessageType() != l2tp.SCCRQ {
+		t.Fatalf("Message type mismatch: %s", p.MessageType())
+	}
+
+	if p.GuessPayloadType() != packet.None {
+		t.Fatalf("Payload type mismatch: %s", p.GuessPayloadType())
+	}
+}
+
+func TestAnswers(t *testing.T) {
+	req := MakeTestSimple()
+
+	rsp := l2tp.MakeControl(1, l2tp.MakeMessageType(l2tp.SCCRP))
+	rsp.Nr = 1
+
+	if !rsp.Answers(req) {
+		t.Fatalf("SCCRP doesn't answer SCCRQ")
+	}
+}
+
+var test_ip_control = []byte{
+	0x00, 0x00, 0x00, 0x00, 0xc8, 0x03, 0x00, 0x14, 0x00, 0x00, 0x00, 0x2a,
+	0x00, 0x01, 0x00, 0x02, 0x80, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x06,
+}
+
+func TestIPControl(t *testing.T) {
+	p := &l2tp.Packet{
+		Flags:   l2tp.Control | l2tp.LengthPresent | l2tp.SeqPresent,
+		Version: 3,
+		ConnID:  42,
+		Ns:      1,
+		Nr:      2,
+		AVPs:    []l2tp.AVP{l2tp.MakeMessageType(l2tp.Hello)},
+		IP:      true,
+	}
+
+	var b packet.Buffer
+	b.Init(make([]byte, p.GetLength()))
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_ip_control, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+
+	p2 := &l2tp.Packet{IP: true}
+
+	b.Init(test_ip_control)
+
+	err = p2.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p2.Equals(p) || p2.MessageType() != l2tp.Hello {
+		t.Fatalf("Packet mismatch:\n%s\n%s", p2, p)
+	}
+}
@@ -50,11 +50,18 @@ func (p *Packet) GetType() packet.Type {
 }
 
 func (p *Packet) GetLength() uint16 {
+	length := uint16(3)
+
+	/* I and S format frames have a 2 bytes control field */
+	if p.Control&0x1 == 0 || p.Control&0x3 == 0x1 {
+		length += 1
+	}
+
 	if p.pkt_payload != nil {
-		return p.pkt_payload.GetLength() + 2
+		length += p.pkt_payload.GetLength()
 	}
 
-	return 2
+	return length
 }
 
 func (p *Packet) Equals(other packet.Packet) bool {
@@ -99,8 +106,12 @@ func (p *Packet) Payload() packet.Packet {
 }
 
 func (p *Packet) GuessPayloadType() packet.Type {
-	if p.DSAP == 0xaa && p.SSAP == 0xaa {
+	switch {
+	case p.DSAP == 0xaa && p.SSAP == 0xaa:
 		return packet.SNAP
+
+	case p.DSAP == 0xfe && p.SSAP == 0xfe:
+		return packet.ISIS
 	}
 
 	return packet.None
@@ -109,6 +120,18 @@ func (p *Packet) GuessPayloadType() packet.Type {
 func (p *Packet) SetPayload(pl packet.Packet) error {
 	p.pkt_payload = pl
 
+	switch pl.GetType() {
+	case packet.SNAP:
+		p.DSAP = 0xaa
+		p.SSAP = 0xaa
+		p.Control = 0x03
+
+	case packet.ISIS:
+		p.DSAP = 0xfe
+		p.SSAP = 0xfe
+		p.Control = 0x03
+	}
+
 	return nil
 }
 
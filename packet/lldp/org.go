@@ -0,0 +1,280 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package lldp
+
+import "encoding/binary"
+
+// Organizationally specific TLV.
+type OrgTLV struct {
+	OUI     [3]byte
+	Subtype uint8
+	Data    []byte
+}
+
+var (
+	OUI8021 = [3]byte{0x00, 0x80, 0xc2}
+	OUI8023 = [3]byte{0x00, 0x12, 0x0f}
+	OUIMED  = [3]byte{0x00, 0x12, 0xbb}
+)
+
+/* IEEE 802.1 subtypes */
+const (
+	Dot1PortVLANID  uint8 = 1
+	Dot1ProtoVLANID       = 2
+	Dot1VLANName          = 3
+	Dot1LinkAgg           = 7
+)
+
+/* IEEE 802.3 subtypes */
+const (
+	Dot3MACPHY       uint8 = 1
+	Dot3Power              = 2
+	Dot3LinkAgg            = 3
+	Dot3MaxFrameSize       = 4
+)
+
+/* LLDP-MED (ANSI/TIA-1057) subtypes */
+const (
+	MEDCaps          uint8 = 1
+	MEDNetworkPolicy       = 2
+	MEDLocation            = 3
+	MEDExtPower            = 4
+	MEDHardwareRev         = 5
+	MEDFirmwareRev         = 6
+	MEDSoftwareRev         = 7
+	MEDSerialNumber        = 8
+	MEDManufacturer        = 9
+	MEDModel               = 10
+	MEDAssetID             = 11
+)
+
+type VLANName struct {
+	VLAN uint16
+	Name string
+}
+
+type MACPHYStatus struct {
+	AutonegSupported bool
+	AutonegEnabled   bool
+	Advertised       uint16
+	MAUType          uint16
+}
+
+type MEDCapabilities struct {
+	Capabilities uint16
+	DeviceClass  uint8
+}
+
+type NetworkPolicy struct {
+	App      uint8
+	Unknown  bool
+	Tagged   bool
+	VLAN     uint16
+	Priority uint8
+	DSCP     uint8
+}
+
+// Return the first organizationally specific TLV with the given OUI and
+// subtype, or nil if not present.
+func (p *Packet) FindOrgTLV(oui [3]byte, subtype uint8) *OrgTLV {
+	for i := range p.OrgTLVs {
+		if p.OrgTLVs[i].OUI == oui && p.OrgTLVs[i].Subtype == subtype {
+			return &p.OrgTLVs[i]
+		}
+	}
+
+	return nil
+}
+
+// Return the port VLAN ID (802.1), or 0 if not present.
+func (p *Packet) PortVLANID() uint16 {
+	o := p.FindOrgTLV(OUI8021, Dot1PortVLANID)
+	if o == nil || len(o.Data) < 2 {
+		return 0
+	}
+
+	return binary.BigEndian.Uint16(o.Data)
+}
+
+// Return the VLAN names (802.1) advertised by the packet.
+func (p *Packet) VLANNames() []VLANName {
+	var names []VLANName
+
+	for _, o := range p.OrgTLVs {
+		if o.OUI != OUI8021 || o.Subtype != Dot1VLANName ||
+			len(o.Data) < 3 || len(o.Data) < 3+int(o.Data[2]) {
+			continue
+		}
+
+		names = append(names, VLANName{
+			VLAN: binary.BigEndian.Uint16(o.Data),
+			Name: string(o.Data[3 : 3+int(o.Data[2])]),
+		})
+	}
+
+	return names
+}
+
+// Return the maximum frame size (802.3), or 0 if not present.
+func (p *Packet) MaxFrameSize() uint16 {
+	o := p.FindOrgTLV(OUI8023, Dot3MaxFrameSize)
+	if o == nil || len(o.Data) < 2 {
+		return 0
+	}
+
+	return binary.BigEndian.Uint16(o.Data)
+}
+
+// Return the MAC/PHY configuration and status (802.3), or nil if not present.
+func (p *Packet) MACPHY() *MACPHYStatus {
+	o := p.FindOrgTLV(OUI8023, Dot3MACPHY)
+	if o == nil || len(o.Data) < 5 {
+		return nil
+	}
+
+	return &MACPHYStatus{
+		AutonegSupported: o.Data[0]&0x01 != 0,
+		AutonegEnabled:   o.Data[0]&0x02 != 0,
+		Advertised:       binary.BigEndian.Uint16(o.Data[1:]),
+		MAUType:          binary.BigEndian.Uint16(o.Data[3:]),
+	}
+}
+
+// Return the LLDP-MED capabilities, or nil if not present.
+func (p *Packet) MEDCapabilities() *MEDCapabilities {
+	o := p.FindOrgTLV(OUIMED, MEDCaps)
+	if o == nil || len(o.Data) < 3 {
+		return nil
+	}
+
+	return &MEDCapabilities{
+		Capabilities: binary.BigEndian.Uint16(o.Data),
+		DeviceClass:  o.Data[2],
+	}
+}
+
+// Return the LLDP-MED network policies advertised by the packet.
+func (p *Packet) NetworkPolicies() []NetworkPolicy {
+	var policies []NetworkPolicy
+
+	for _, o := range p.OrgTLVs {
+		if o.OUI != OUIMED || o.Subtype != MEDNetworkPolicy ||
+			len(o.Data) < 4 {
+			continue
+		}
+
+		val := uint32(o.Data[1])<<16 | uint32(o.Data[2])<<8 |
+			uint32(o.Data[3])
+
+		policies = append(policies, NetworkPolicy{
+			App:      o.Data[0],
+			Unknown:  val&(1<<23) != 0,
+			Tagged:   val&(1<<22) != 0,
+			VLAN:     uint16(val>>9) & 0x0fff,
+			Priority: uint8(val>>6) & 0x07,
+			DSCP:     uint8(val) & 0x3f,
+		})
+	}
+
+	return policies
+}
+
+// Return the LLDP-MED inventory string of the given subtype (e.g.
+// MEDSoftwareRev or MEDModel), or "" if not present.
+func (p *Packet) MEDInventory(subtype uint8) string {
+	o := p.FindOrgTLV(OUIMED, subtype)
+	if o == nil {
+		return ""
+	}
+
+	return string(o.Data)
+}
+
+// Create a new port VLAN ID TLV (802.1).
+func MakePortVLANID(vlan uint16) OrgTLV {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, vlan)
+
+	return OrgTLV{OUI8021, Dot1PortVLANID, data}
+}
+
+// Create a new VLAN name TLV (802.1).
+func MakeVLANName(vlan uint16, name string) OrgTLV {
+	data := make([]byte, 3, 3+len(name))
+	binary.BigEndian.PutUint16(data, vlan)
+	data[2] = uint8(len(name))
+
+	return OrgTLV{OUI8021, Dot1VLANName, append(data, name...)}
+}
+
+// Create a new maximum frame size TLV (802.3).
+func MakeMaxFrameSize(size uint16) OrgTLV {
+	data := make([]byte, 2)
+	binary.BigEndian.PutUint16(data, size)
+
+	return OrgTLV{OUI8023, Dot3MaxFrameSize, data}
+}
+
+// Create a new MAC/PHY configuration and status TLV (802.3).
+func MakeMACPHY(status MACPHYStatus) OrgTLV {
+	data := make([]byte, 5)
+
+	if status.AutonegSupported {
+		data[0] |= 0x01
+	}
+
+	if status.AutonegEnabled {
+		data[0] |= 0x02
+	}
+
+	binary.BigEndian.PutUint16(data[1:], status.Advertised)
+	binary.BigEndian.PutUint16(data[3:], status.MAUType)
+
+	return OrgTLV{OUI8023, Dot3MACPHY, data}
+}
+
+// Create a new LLDP-MED network policy TLV.
+func MakeNetworkPolicy(policy NetworkPolicy) OrgTLV {
+	val := uint32(policy.VLAN&0x0fff)<<9 |
+		uint32(policy.Priority&0x07)<<6 | uint32(policy.DSCP&0x3f)
+
+	if policy.Unknown {
+		val |= 1 << 23
+	}
+
+	if policy.Tagged {
+		val |= 1 << 22
+	}
+
+	data := []byte{policy.App, uint8(val >> 16), uint8(val >> 8), uint8(val)}
+
+	return OrgTLV{OUIMED, MEDNetworkPolicy, data}
+}
@@ -0,0 +1,460 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for LLDP (802.1AB Link Layer Discovery
+// Protocol) packets.
+package lldp
+
+import "fmt"
+import "net"
+import "strings"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type Packet struct {
+	ChassisType  ChassisIDType `string:"skip"`
+	ChassisID    []byte        `string:"skip"`
+	PortType     PortIDType    `string:"skip"`
+	PortID       []byte        `string:"skip"`
+	TTL          uint16
+	PortDesc     string       `string:"port-desc"`
+	SysName      string       `string:"name"`
+	SysDesc      string       `string:"desc"`
+	Capabilities Capabilities `string:"cap"`
+	Enabled      Capabilities
+	MgmtAddrs    []MgmtAddr    `string:"skip"`
+	OrgTLVs      []OrgTLV      `string:"skip"`
+	TLVs         []TLV         `string:"skip"`
+	pkt_payload  packet.Packet `cmp:"skip" string:"skip"`
+}
+
+type TLVType uint8
+
+const (
+	EndTLV         TLVType = 0
+	ChassisIDTLV           = 1
+	PortIDTLV              = 2
+	TTLTLV                 = 3
+	PortDescTLV            = 4
+	SysNameTLV             = 5
+	SysDescTLV             = 6
+	SysCapTLV              = 7
+	MgmtAddrTLV            = 8
+	OrgSpecificTLV         = 127
+)
+
+// Generic TLV, used for TLV types that are not otherwise decoded.
+type TLV struct {
+	Type TLVType
+	Data []byte
+}
+
+type ChassisIDType uint8
+
+const (
+	ChassisComponent     ChassisIDType = 1
+	ChassisIfaceAlias                  = 2
+	ChassisPortComponent               = 3
+	ChassisMACAddr                     = 4
+	ChassisNetworkAddr                 = 5
+	ChassisIfaceName                   = 6
+	ChassisLocal                       = 7
+)
+
+type PortIDType uint8
+
+const (
+	PortIfaceAlias   PortIDType = 1
+	PortComponent               = 2
+	PortMACAddr                 = 3
+	PortNetworkAddr             = 4
+	PortIfaceName               = 5
+	PortAgentCircuit            = 6
+	PortLocal                   = 7
+)
+
+type Capabilities uint16
+
+const (
+	Other     Capabilities = 1 << 0
+	Repeater               = 1 << 1
+	Bridge                 = 1 << 2
+	WLANAP                 = 1 << 3
+	Router                 = 1 << 4
+	Telephone              = 1 << 5
+	DOCSIS                 = 1 << 6
+	Station                = 1 << 7
+	CVLAN                  = 1 << 8
+	SVLAN                  = 1 << 9
+	TPMR                   = 1 << 10
+)
+
+// Address family numbers (as assigned by IANA) used by management addresses.
+type AddrFamily uint8
+
+const (
+	FamilyIPv4 AddrFamily = 1
+	FamilyIPv6            = 2
+	Family802             = 6
+)
+
+type MgmtAddr struct {
+	Family      AddrFamily
+	Addr        []byte
+	IfaceType   uint8
+	IfaceNumber uint32
+	OID         []byte
+}
+
+// Nearest bridge multicast address, LLDP packets are sent to.
+var MulticastAddr = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e}
+
+func Make() *Packet {
+	return &Packet{
+		TTL: 120,
+	}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.LLDP
+}
+
+func (p *Packet) GetLength() uint16 {
+	/* chassis ID, port ID, TTL and end TLVs */
+	length := uint16(2+1+len(p.ChassisID)) + uint16(2+1+len(p.PortID)) +
+		4 + 2
+
+	if p.PortDesc != "" {
+		length += 2 + uint16(len(p.PortDesc))
+	}
+
+	if p.SysName != "" {
+		length += 2 + uint16(len(p.SysName))
+	}
+
+	if p.SysDesc != "" {
+		length += 2 + uint16(len(p.SysDesc))
+	}
+
+	if p.Capabilities != 0 {
+		length += 2 + 4
+	}
+
+	for _, m := range p.MgmtAddrs {
+		length += 2 + m.length()
+	}
+
+	for _, o := range p.OrgTLVs {
+		length += 2 + 4 + uint16(len(o.Data))
+	}
+
+	for _, t := range p.TLVs {
+		length += 2 + uint16(len(t.Data))
+	}
+
+	return length
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	return false
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	write_tlv_hdr(buf, ChassisIDTLV, 1+len(p.ChassisID))
+	buf.WriteN(p.ChassisType)
+	buf.Write(p.ChassisID)
+
+	write_tlv_hdr(buf, PortIDTLV, 1+len(p.PortID))
+	buf.WriteN(p.PortType)
+	buf.Write(p.PortID)
+
+	write_tlv_hdr(buf, TTLTLV, 2)
+	buf.WriteN(p.TTL)
+
+	if p.PortDesc != "" {
+		write_tlv_hdr(buf, PortDescTLV, len(p.PortDesc))
+		buf.Write([]byte(p.PortDesc))
+	}
+
+	if p.SysName != "" {
+		write_tlv_hdr(buf, SysNameTLV, len(p.SysName))
+		buf.Write([]byte(p.SysName))
+	}
+
+	if p.SysDesc != "" {
+		write_tlv_hdr(buf, SysDescTLV, len(p.SysDesc))
+		buf.Write([]byte(p.SysDesc))
+	}
+
+	if p.Capabilities != 0 {
+		write_tlv_hdr(buf, SysCapTLV, 4)
+		buf.WriteN(p.Capabilities)
+		buf.WriteN(p.Enabled)
+	}
+
+	for _, m := range p.MgmtAddrs {
+		write_tlv_hdr(buf, MgmtAddrTLV, int(m.length()))
+
+		buf.WriteN(uint8(1 + len(m.Addr)))
+		buf.WriteN(m.Family)
+		buf.Write(m.Addr)
+		buf.WriteN(m.IfaceType)
+		buf.WriteN(m.IfaceNumber)
+		buf.WriteN(uint8(len(m.OID)))
+		buf.Write(m.OID)
+	}
+
+	for _, o := range p.OrgTLVs {
+		write_tlv_hdr(buf, OrgSpecificTLV, 4+len(o.Data))
+		buf.WriteN(o.OUI)
+		buf.WriteN(o.Subtype)
+		buf.Write(o.Data)
+	}
+
+	for _, t := range p.TLVs {
+		write_tlv_hdr(buf, t.Type, len(t.Data))
+		buf.Write(t.Data)
+	}
+
+	write_tlv_hdr(buf, EndTLV, 0)
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	p.MgmtAddrs = nil
+	p.OrgTLVs = nil
+	p.TLVs = nil
+
+	for buf.Len() >= 2 {
+		var hdr uint16
+		buf.ReadN(&hdr)
+
+		typ := TLVType(hdr >> 9)
+		length := int(hdr & 0x01ff)
+
+		if typ == EndTLV {
+			break
+		}
+
+		if length > buf.Len() {
+			return fmt.Errorf("Invalid TLV length: %d", length)
+		}
+
+		if length < tlv_min_len[typ] {
+			return fmt.Errorf("Invalid TLV length: %d", length)
+		}
+
+		var value packet.Buffer
+		value.Init(buf.Next(length))
+
+		switch typ {
+		case ChassisIDTLV:
+			value.ReadN(&p.ChassisType)
+			p.ChassisID = value.Next(value.Len())
+
+		case PortIDTLV:
+			value.ReadN(&p.PortType)
+			p.PortID = value.Next(value.Len())
+
+		case TTLTLV:
+			value.ReadN(&p.TTL)
+
+		case PortDescTLV:
+			p.PortDesc = string(value.Bytes())
+
+		case SysNameTLV:
+			p.SysName = string(value.Bytes())
+
+		case SysDescTLV:
+			p.SysDesc = string(value.Bytes())
+
+		case SysCapTLV:
+			value.ReadN(&p.Capabilities)
+			value.ReadN(&p.Enabled)
+
+		case MgmtAddrTLV:
+			var m MgmtAddr
+
+			var addr_len uint8
+			value.ReadN(&addr_len)
+
+			if addr_len < 1 || int(addr_len)+7 > length {
+				return fmt.Errorf("Invalid address length: %d",
+					addr_len)
+			}
+
+			value.ReadN(&m.Family)
+			m.Addr = value.Next(int(addr_len) - 1)
+
+			value.ReadN(&m.IfaceType)
+			value.ReadN(&m.IfaceNumber)
+
+			var oid_len uint8
+			value.ReadN(&oid_len)
+
+			if oid_len > 0 {
+				m.OID = value.Next(int(oid_len))
+			}
+
+			p.MgmtAddrs = append(p.MgmtAddrs, m)
+
+		case OrgSpecificTLV:
+			var o OrgTLV
+			value.ReadN(&o.OUI)
+			value.ReadN(&o.Subtype)
+
+			o.Data = value.Next(value.Len())
+
+			p.OrgTLVs = append(p.OrgTLVs, o)
+
+		default:
+			p.TLVs = append(p.TLVs, TLV{typ, value.Bytes()})
+		}
+	}
+
+	return nil
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	return packet.None
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+// Return a human-readable representation of the chassis ID, depending on its
+// subtype.
+func (p *Packet) ChassisIDString() string {
+	switch p.ChassisType {
+	case ChassisMACAddr:
+		return net.HardwareAddr(p.ChassisID).String()
+
+	case ChassisNetworkAddr:
+		return addr_string(p.ChassisID)
+
+	default:
+		return string(p.ChassisID)
+	}
+}
+
+// Return a human-readable representation of the port ID, depending on its
+// subtype.
+func (p *Packet) PortIDString() string {
+	switch p.PortType {
+	case PortMACAddr:
+		return net.HardwareAddr(p.PortID).String()
+
+	case PortNetworkAddr:
+		return addr_string(p.PortID)
+
+	default:
+		return string(p.PortID)
+	}
+}
+
+// Return the management address as IP address, or nil if the address is not
+// an IPv4 or IPv6 address.
+func (m *MgmtAddr) IP() net.IP {
+	switch {
+	case m.Family == FamilyIPv4 && len(m.Addr) == net.IPv4len,
+		m.Family == FamilyIPv6 && len(m.Addr) == net.IPv6len:
+		return net.IP(m.Addr)
+	}
+
+	return nil
+}
+
+func (m *MgmtAddr) length() uint16 {
+	return uint16(1+1+len(m.Addr)) + 1 + 4 + uint16(1+len(m.OID))
+}
+
+/* Minimum value length of the TLVs starting with fixed-size fields */
+var tlv_min_len = map[TLVType]int{
+	ChassisIDTLV:   1,
+	PortIDTLV:      1,
+	TTLTLV:         2,
+	SysCapTLV:      4,
+	MgmtAddrTLV:    8,
+	OrgSpecificTLV: 4,
+}
+
+func write_tlv_hdr(buf *packet.Buffer, typ TLVType, length int) {
+	buf.WriteN(uint16(typ)<<9 | uint16(length)&0x01ff)
+}
+
+func addr_string(addr []byte) string {
+	if len(addr) < 1 {
+		return ""
+	}
+
+	m := MgmtAddr{Family: AddrFamily(addr[0]), Addr: addr[1:]}
+
+	if ip := m.IP(); ip != nil {
+		return ip.String()
+	}
+
+	return fmt.Sprintf("%x", addr)
+}
+
+func (c Capabilities) String() string {
+	var caps []string
+
+	names := []string{
+		"other", "repeater", "bridge", "wlan-ap", "router", "telephone",
+		"docsis", "station", "c-vlan", "s-vlan", "tpmr",
+	}
+
+	for i, name := range names {
+		if c&(1<<uint(i)) != 0 {
+			caps = append(caps, name)
+		}
+	}
+
+	return strings.Join(caps, "|")
+}
@@ -0,0 +1,254 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package lldp_test
+
+import "bytes"
+import "net"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/lldp"
+
+var test_simple = []byte{
+	0x02, 0x07, 0x04, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x04, 0x06, 0x05,
+	0x47, 0x69, 0x30, 0x2f, 0x31, 0x06, 0x02, 0x00, 0x78, 0x0a, 0x03, 0x73,
+	0x77, 0x31, 0x0e, 0x04, 0x00, 0x14, 0x00, 0x10, 0x10, 0x0c, 0x05, 0x01,
+	0xc0, 0xa8, 0x01, 0x01, 0x02, 0x00, 0x00, 0x00, 0x01, 0x00, 0xfe, 0x06,
+	0x00, 0x80, 0xc2, 0x01, 0x00, 0x0a, 0x00, 0x00,
+}
+
+func MakeTestSimple() *lldp.Packet {
+	return &lldp.Packet{
+		ChassisType:  lldp.ChassisMACAddr,
+		ChassisID:    []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55},
+		PortType:     lldp.PortIfaceName,
+		PortID:       []byte("Gi0/1"),
+		TTL:          120,
+		SysName:      "sw1",
+		Capabilities: lldp.Bridge | lldp.Router,
+		Enabled:      lldp.Router,
+		MgmtAddrs: []lldp.MgmtAddr{
+			{
+				Family:      lldp.FamilyIPv4,
+				Addr:        []byte{192, 168, 1, 1},
+				IfaceType:   2,
+				IfaceNumber: 1,
+			},
+		},
+		OrgTLVs: []lldp.OrgTLV{lldp.MakePortVLANID(10)},
+	}
+}
+
+func TestPack(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_simple, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPack(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	var p lldp.Packet
+
+	cmp := MakeTestSimple()
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpack(bn *testing.B) {
+	var p lldp.Packet
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_simple)
+		p.Unpack(&b)
+	}
+}
+
+func TestUnpackTLVs(t *testing.T) {
+	var p lldp.Packet
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if p.ChassisIDString() != "00:11:22:33:44:55" {
+		t.Fatalf("Chassis ID mismatch: %s", p.ChassisIDString())
+	}
+
+	if p.PortIDString() != "Gi0/1" {
+		t.Fatalf("Port ID mismatch: %s", p.PortIDString())
+	}
+
+	if len(p.MgmtAddrs) != 1 ||
+		!p.MgmtAddrs[0].IP().Equal(net.ParseIP("192.168.1.1")) {
+		t.Fatalf("Management address mismatch: %v", p.MgmtAddrs)
+	}
+
+	if p.PortVLANID() != 10 {
+		t.Fatalf("Port VLAN ID mismatch: %d", p.PortVLANID())
+	}
+}
+
+func TestOrgTLVs(t *testing.T) {
+	p := lldp.Make()
+	p.ChassisType = lldp.ChassisLocal
+	p.ChassisID = []byte("sw2")
+	p.PortType = lldp.PortLocal
+	p.PortID = []byte("1")
+
+	policy := lldp.NetworkPolicy{
+		App:      1,
+		Tagged:   true,
+		VLAN:     100,
+		Priority: 5,
+		DSCP:     46,
+	}
+
+	phy := lldp.MACPHYStatus{
+		AutonegSupported: true,
+		AutonegEnabled:   true,
+		Advertised:       0x6c00,
+		MAUType:          30,
+	}
+
+	p.OrgTLVs = []lldp.OrgTLV{
+		lldp.MakeVLANName(10, "users"),
+		lldp.MakeVLANName(20, "voice"),
+		lldp.MakeMaxFrameSize(1522),
+		lldp.MakeMACPHY(phy),
+		lldp.MakeNetworkPolicy(policy),
+	}
+
+	var b packet.Buffer
+	b.Init(make([]byte, p.GetLength()))
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	var p2 lldp.Packet
+
+	b.Init(b.Buffer())
+
+	err = p2.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p2.Equals(p) {
+		t.Fatalf("Organizationally specific TLVs mismatch: %v", p2.OrgTLVs)
+	}
+
+	names := p2.VLANNames()
+	if len(names) != 2 || names[1].VLAN != 20 || names[1].Name != "voice" {
+		t.Fatalf("VLAN names mismatch: %v", names)
+	}
+
+	if p2.MaxFrameSize() != 1522 {
+		t.Fatalf("Max frame size mismatch: %d", p2.MaxFrameSize())
+	}
+
+	if s := p2.MACPHY(); s == nil || *s != phy {
+		t.Fatalf("MAC/PHY status mismatch: %v", s)
+	}
+
+	policies := p2.NetworkPolicies()
+	if len(policies) != 1 || policies[0] != policy {
+		t.Fatalf("Network policy mismatch: %v", policies)
+	}
+}
+
+func TestUnpackTruncated(t *testing.T) {
+	tests := [][]byte{
+		/* TTL with 1 byte of value */
+		{0x06, 0x01, 0x00, 0x00, 0x00},
+		/* empty chassis ID */
+		{0x02, 0x00, 0x00, 0x00},
+		/* capabilities with 2 bytes of value */
+		{0x0e, 0x02, 0x00, 0x01, 0x00, 0x00},
+		/* management address with 1 byte of value */
+		{0x10, 0x01, 0x05, 0x00, 0x00},
+		/* management address longer than the TLV */
+		{
+			0x10, 0x08, 0x05, 0x01, 0xc0, 0xa8, 0x01, 0x01, 0x00,
+			0x00, 0x00, 0x00,
+		},
+		/* organizationally specific TLV without subtype */
+		{0xfe, 0x03, 0x00, 0x12, 0x0f, 0x00, 0x00},
+	}
+
+	for _, raw := range tests {
+		var p lldp.Packet
+
+		var b packet.Buffer
+		b.Init(raw)
+
+		err := p.Unpack(&b)
+		if err == nil {
+			t.Fatalf("Truncated TLV not detected: %x", raw)
+		}
+	}
+}
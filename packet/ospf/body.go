@@ -0,0 +1,354 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ospf
+
+import "fmt"
+import "net"
+import "strings"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type Hello struct {
+	NetworkMask   net.IP /* OSPFv2 */
+	InterfaceID   uint32 /* OSPFv3 */
+	HelloInterval uint16
+	Options       Options
+	Priority      uint8
+	DeadInterval  uint32
+	DR            net.IP
+	BDR           net.IP
+	Neighbors     []net.IP
+}
+
+type DBDesc struct {
+	MTU     uint16
+	Options Options
+	Flags   DBDescFlags
+	Seq     uint32
+	LSAs    []LSAHeader
+}
+
+type DBDescFlags uint8
+
+const (
+	MasterSlave DBDescFlags = 1 << 0
+	More                    = 1 << 1
+	Initial                 = 1 << 2
+)
+
+type LSRequest struct {
+	Requests []LSRequestEntry
+}
+
+type LSRequestEntry struct {
+	Type      LSType
+	ID        net.IP
+	AdvRouter net.IP
+}
+
+type LSUpdate struct {
+	LSAs []LSA
+}
+
+type LSAck struct {
+	LSAs []LSAHeader
+}
+
+// Create a new Hello body with the default RFC2328 timers.
+func MakeHello() *Hello {
+	return &Hello{
+		NetworkMask:   net.IPv4zero,
+		HelloInterval: 10,
+		Options:       E,
+		Priority:      1,
+		DeadInterval:  40,
+		DR:            net.IPv4zero,
+		BDR:           net.IPv4zero,
+	}
+}
+
+func (b *Hello) Type() Type {
+	return HelloType
+}
+
+func (b *Hello) length(version uint8) uint16 {
+	return 20 + 4*uint16(len(b.Neighbors))
+}
+
+func (b *Hello) pack(buf *packet.Buffer, version uint8) {
+	if version == 3 {
+		buf.WriteN(b.InterfaceID)
+		buf.WriteN(uint32(b.Priority)<<24 | uint32(b.Options&0xffffff))
+		buf.WriteN(b.HelloInterval)
+		buf.WriteN(uint16(b.DeadInterval))
+	} else {
+		write_addr(buf, b.NetworkMask)
+		buf.WriteN(b.HelloInterval)
+		buf.WriteN(uint8(b.Options))
+		buf.WriteN(b.Priority)
+		buf.WriteN(b.DeadInterval)
+	}
+
+	write_addr(buf, b.DR)
+	write_addr(buf, b.BDR)
+
+	for _, n := range b.Neighbors {
+		write_addr(buf, n)
+	}
+}
+
+func (b *Hello) unpack(buf *packet.Buffer, version uint8) error {
+	if buf.Len() < 20 {
+		return fmt.Errorf("Invalid hello length: %d", buf.Len())
+	}
+
+	if version == 3 {
+		buf.ReadN(&b.InterfaceID)
+
+		var prio_opts uint32
+		buf.ReadN(&prio_opts)
+
+		b.Priority = uint8(prio_opts >> 24)
+		b.Options = Options(prio_opts & 0xffffff)
+
+		buf.ReadN(&b.HelloInterval)
+
+		var dead uint16
+		buf.ReadN(&dead)
+
+		b.DeadInterval = uint32(dead)
+	} else {
+		b.NetworkMask = net.IP(buf.Next(4))
+
+		buf.ReadN(&b.HelloInterval)
+
+		var opts uint8
+		buf.ReadN(&opts)
+
+		b.Options = Options(opts)
+
+		buf.ReadN(&b.Priority)
+		buf.ReadN(&b.DeadInterval)
+	}
+
+	b.DR = net.IP(buf.Next(4))
+	b.BDR = net.IP(buf.Next(4))
+
+	b.Neighbors = nil
+
+	for buf.Len() >= 4 {
+		b.Neighbors = append(b.Neighbors, net.IP(buf.Next(4)))
+	}
+
+	return nil
+}
+
+func (b *DBDesc) Type() Type {
+	return DBDescType
+}
+
+func (b *DBDesc) length(version uint8) uint16 {
+	length := uint16(8)
+	if version == 3 {
+		length = 12
+	}
+
+	return length + 20*uint16(len(b.LSAs))
+}
+
+func (b *DBDesc) pack(buf *packet.Buffer, version uint8) {
+	if version == 3 {
+		buf.WriteN(uint32(b.Options & 0xffffff))
+		buf.WriteN(b.MTU)
+		buf.WriteN(uint8(0x00))
+	} else {
+		buf.WriteN(b.MTU)
+		buf.WriteN(uint8(b.Options))
+	}
+
+	buf.WriteN(b.Flags)
+	buf.WriteN(b.Seq)
+
+	for _, h := range b.LSAs {
+		h.pack(buf, version)
+	}
+}
+
+func (b *DBDesc) unpack(buf *packet.Buffer, version uint8) error {
+	if version == 3 {
+		var opts uint32
+		buf.ReadN(&opts)
+
+		b.Options = Options(opts & 0xffffff)
+
+		buf.ReadN(&b.MTU)
+		buf.Next(1)
+	} else {
+		buf.ReadN(&b.MTU)
+
+		var opts uint8
+		buf.ReadN(&opts)
+
+		b.Options = Options(opts)
+	}
+
+	buf.ReadN(&b.Flags)
+	buf.ReadN(&b.Seq)
+
+	var err error
+
+	b.LSAs, err = unpack_lsa_headers(buf, version)
+
+	return err
+}
+
+func (b *LSRequest) Type() Type {
+	return LSRequestType
+}
+
+func (b *LSRequest) length(version uint8) uint16 {
+	return 12 * uint16(len(b.Requests))
+}
+
+func (b *LSRequest) pack(buf *packet.Buffer, version uint8) {
+	for _, r := range b.Requests {
+		if version == 3 {
+			buf.WriteN(uint16(0x0000))
+			buf.WriteN(r.Type)
+		} else {
+			buf.WriteN(uint32(r.Type))
+		}
+
+		write_addr(buf, r.ID)
+		write_addr(buf, r.AdvRouter)
+	}
+}
+
+func (b *LSRequest) unpack(buf *packet.Buffer, version uint8) error {
+	b.Requests = nil
+
+	for buf.Len() >= 12 {
+		var r LSRequestEntry
+
+		var typ uint32
+		buf.ReadN(&typ)
+
+		r.Type = LSType(typ)
+		r.ID = net.IP(buf.Next(4))
+		r.AdvRouter = net.IP(buf.Next(4))
+
+		b.Requests = append(b.Requests, r)
+	}
+
+	return nil
+}
+
+func (b *LSUpdate) Type() Type {
+	return LSUpdateType
+}
+
+func (b *LSUpdate) length(version uint8) uint16 {
+	length := uint16(4)
+
+	for i := range b.LSAs {
+		length += b.LSAs[i].length(version)
+	}
+
+	return length
+}
+
+func (b *LSUpdate) pack(buf *packet.Buffer, version uint8) {
+	buf.WriteN(uint32(len(b.LSAs)))
+
+	for i := range b.LSAs {
+		b.LSAs[i].pack(buf, version)
+	}
+}
+
+func (b *LSUpdate) unpack(buf *packet.Buffer, version uint8) error {
+	var count uint32
+	buf.ReadN(&count)
+
+	b.LSAs = nil
+
+	for i := 0; i < int(count); i++ {
+		var lsa LSA
+
+		err := lsa.unpack(buf, version)
+		if err != nil {
+			return err
+		}
+
+		b.LSAs = append(b.LSAs, lsa)
+	}
+
+	return nil
+}
+
+func (b *LSAck) Type() Type {
+	return LSAckType
+}
+
+func (b *LSAck) length(version uint8) uint16 {
+	return 20 * uint16(len(b.LSAs))
+}
+
+func (b *LSAck) pack(buf *packet.Buffer, version uint8) {
+	for _, h := range b.LSAs {
+		h.pack(buf, version)
+	}
+}
+
+func (b *LSAck) unpack(buf *packet.Buffer, version uint8) error {
+	var err error
+
+	b.LSAs, err = unpack_lsa_headers(buf, version)
+
+	return err
+}
+
+func (f DBDescFlags) String() string {
+	var flags []string
+
+	if f&Initial != 0 {
+		flags = append(flags, "init")
+	}
+
+	if f&More != 0 {
+		flags = append(flags, "more")
+	}
+
+	if f&MasterSlave != 0 {
+		flags = append(flags, "master")
+	}
+
+	return strings.Join(flags, "|")
+}
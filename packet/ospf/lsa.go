@@ -0,0 +1,688 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ospf
+
+import "fmt"
+import "net"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type LSType uint16
+
+const (
+	Router      LSType = 1
+	Network            = 2
+	Summary            = 3
+	ASBRSummary        = 4
+	External           = 5
+	NSSA               = 7
+
+	RouterV3        = 0x2001
+	NetworkV3       = 0x2002
+	InterAreaPrefix = 0x2003
+	InterAreaRouter = 0x2004
+	ExternalV3      = 0x4005
+	NSSAV3          = 0x2007
+	Link            = 0x0008
+	IntraAreaPrefix = 0x2009
+)
+
+type LSAHeader struct {
+	Age       uint16
+	Options   Options /* OSPFv2 */
+	Type      LSType
+	ID        net.IP
+	AdvRouter net.IP
+	Seq       uint32
+	Checksum  uint16
+	Length    uint16
+}
+
+type LSA struct {
+	LSAHeader
+
+	/* Router, network, summary and external LSAs are fully decoded, any
+	 * other type is returned as *RawLSA. */
+	Body LSABody
+}
+
+// LSABody is the interface implemented by the type specific part of LSAs.
+type LSABody interface {
+	length(version uint8, typ LSType) uint16
+	pack(buf *packet.Buffer, version uint8, typ LSType)
+	unpack(buf *packet.Buffer, version uint8, typ LSType) error
+}
+
+type RouterFlags uint8
+
+const (
+	AreaBorder     RouterFlags = 1 << 0
+	ASBoundary                 = 1 << 1
+	VirtualLink                = 1 << 2
+	WildcardMember             = 1 << 3 /* OSPFv3 */
+)
+
+type RouterLSA struct {
+	Flags   RouterFlags
+	Options Options /* OSPFv3 */
+	Links   []RouterLink
+}
+
+type LinkType uint8
+
+const (
+	PointToPoint LinkType = 1
+	TransitNet            = 2
+	StubNet               = 3
+	Virtual               = 4
+)
+
+// Link described by a router LSA. Note that the TOS metrics of OSPFv2 links
+// are skipped when unpacking.
+type RouterLink struct {
+	Type           LinkType
+	Metric         uint16
+	ID             net.IP /* OSPFv2 */
+	Data           net.IP /* OSPFv2 */
+	InterfaceID    uint32 /* OSPFv3 */
+	NbrInterfaceID uint32 /* OSPFv3 */
+	NbrRouterID    net.IP /* OSPFv3 */
+}
+
+type NetworkLSA struct {
+	Mask    net.IP  /* OSPFv2 */
+	Options Options /* OSPFv3 */
+	Routers []net.IP
+}
+
+// Summary LSA (OSPFv2 types 3 and 4), and inter-area-prefix and
+// inter-area-router LSAs (OSPFv3).
+type SummaryLSA struct {
+	Mask          net.IP /* OSPFv2 */
+	Metric        uint32
+	Prefix        *net.IPNet /* OSPFv3 inter-area-prefix */
+	PrefixOptions uint8      /* OSPFv3 inter-area-prefix */
+	Options       Options    /* OSPFv3 inter-area-router */
+	Router        net.IP     /* OSPFv3 inter-area-router */
+}
+
+// AS external and NSSA LSA.
+type ExternalLSA struct {
+	Mask          net.IP /* OSPFv2 */
+	External      bool   /* type 2 external metric */
+	Metric        uint32
+	Forward       net.IP
+	Tag           uint32
+	Prefix        *net.IPNet /* OSPFv3 */
+	PrefixOptions uint8      /* OSPFv3 */
+	RefLSType     LSType     /* OSPFv3 */
+	RefLSID       uint32     /* OSPFv3 */
+}
+
+type RawLSA struct {
+	Data []byte
+}
+
+func (h *LSAHeader) pack(buf *packet.Buffer, version uint8) {
+	buf.WriteN(h.Age)
+
+	if version == 3 {
+		buf.WriteN(h.Type)
+	} else {
+		buf.WriteN(uint8(h.Options))
+		buf.WriteN(uint8(h.Type))
+	}
+
+	write_addr(buf, h.ID)
+	write_addr(buf, h.AdvRouter)
+	buf.WriteN(h.Seq)
+	buf.WriteN(h.Checksum)
+	buf.WriteN(h.Length)
+}
+
+func (h *LSAHeader) unpack(buf *packet.Buffer, version uint8) error {
+	if buf.Len() < 20 {
+		return fmt.Errorf("Invalid LSA header length: %d", buf.Len())
+	}
+
+	buf.ReadN(&h.Age)
+
+	if version == 3 {
+		buf.ReadN(&h.Type)
+	} else {
+		var opts, typ uint8
+		buf.ReadN(&opts)
+		buf.ReadN(&typ)
+
+		h.Options = Options(opts)
+		h.Type = LSType(typ)
+	}
+
+	h.ID = net.IP(buf.Next(4))
+	h.AdvRouter = net.IP(buf.Next(4))
+
+	buf.ReadN(&h.Seq)
+	buf.ReadN(&h.Checksum)
+	buf.ReadN(&h.Length)
+
+	return nil
+}
+
+func unpack_lsa_headers(buf *packet.Buffer, version uint8) ([]LSAHeader, error) {
+	var hdrs []LSAHeader
+
+	for buf.Len() > 0 {
+		var h LSAHeader
+
+		err := h.unpack(buf, version)
+		if err != nil {
+			return nil, err
+		}
+
+		hdrs = append(hdrs, h)
+	}
+
+	return hdrs, nil
+}
+
+func (l *LSA) length(version uint8) uint16 {
+	if l.Body == nil {
+		return 20
+	}
+
+	return 20 + l.Body.length(version, l.Type)
+}
+
+func (l *LSA) pack(buf *packet.Buffer, version uint8) {
+	start := len(buf.Buffer()) - buf.Len()
+
+	l.Length = l.length(version)
+	l.Checksum = 0
+
+	l.LSAHeader.pack(buf, version)
+
+	if l.Body != nil {
+		l.Body.pack(buf, version, l.Type)
+	}
+
+	raw_bytes := buf.Buffer()[start : start+int(l.Length)]
+
+	/* the age is excluded from the checksum */
+	l.Checksum = CalculateLSAChecksum(raw_bytes[2:])
+
+	raw_bytes[16] = uint8(l.Checksum >> 8)
+	raw_bytes[17] = uint8(l.Checksum)
+}
+
+func (l *LSA) unpack(buf *packet.Buffer, version uint8) error {
+	err := l.LSAHeader.unpack(buf, version)
+	if err != nil {
+		return err
+	}
+
+	if l.Length < 20 || int(l.Length)-20 > buf.Len() {
+		return fmt.Errorf("Invalid LSA length: %d", l.Length)
+	}
+
+	switch l.Type {
+	case Router, RouterV3:
+		l.Body = &RouterLSA{}
+	case Network, NetworkV3:
+		l.Body = &NetworkLSA{}
+	case Summary, ASBRSummary, InterAreaPrefix, InterAreaRouter:
+		l.Body = &SummaryLSA{}
+	case External, NSSA, ExternalV3, NSSAV3:
+		l.Body = &ExternalLSA{}
+	default:
+		l.Body = &RawLSA{}
+	}
+
+	var body packet.Buffer
+	body.Init(buf.Next(int(l.Length) - 20))
+
+	return l.Body.unpack(&body, version, l.Type)
+}
+
+func (b *RouterLSA) length(version uint8, typ LSType) uint16 {
+	if version == 3 {
+		return 4 + 16*uint16(len(b.Links))
+	}
+
+	return 4 + 12*uint16(len(b.Links))
+}
+
+func (b *RouterLSA) pack(buf *packet.Buffer, version uint8, typ LSType) {
+	if version == 3 {
+		buf.WriteN(uint32(b.Flags)<<24 | uint32(b.Options&0xffffff))
+
+		for _, l := range b.Links {
+			buf.WriteN(l.Type)
+			buf.WriteN(uint8(0x00))
+			buf.WriteN(l.Metric)
+			buf.WriteN(l.InterfaceID)
+			buf.WriteN(l.NbrInterfaceID)
+			write_addr(buf, l.NbrRouterID)
+		}
+
+		return
+	}
+
+	buf.WriteN(b.Flags)
+	buf.WriteN(uint8(0x00))
+	buf.WriteN(uint16(len(b.Links)))
+
+	for _, l := range b.Links {
+		write_addr(buf, l.ID)
+		write_addr(buf, l.Data)
+		buf.WriteN(l.Type)
+		buf.WriteN(uint8(0x00))
+		buf.WriteN(l.Metric)
+	}
+}
+
+func (b *RouterLSA) unpack(buf *packet.Buffer, version uint8, typ LSType) error {
+	b.Links = nil
+
+	if version == 3 {
+		var flags_opts uint32
+		buf.ReadN(&flags_opts)
+
+		b.Flags = RouterFlags(flags_opts >> 24)
+		b.Options = Options(flags_opts & 0xffffff)
+
+		for buf.Len() >= 16 {
+			var l RouterLink
+
+			buf.ReadN(&l.Type)
+			buf.Next(1)
+			buf.ReadN(&l.Metric)
+			buf.ReadN(&l.InterfaceID)
+			buf.ReadN(&l.NbrInterfaceID)
+
+			l.NbrRouterID = net.IP(buf.Next(4))
+
+			b.Links = append(b.Links, l)
+		}
+
+		return nil
+	}
+
+	buf.ReadN(&b.Flags)
+	buf.Next(1)
+
+	var count uint16
+	buf.ReadN(&count)
+
+	for i := 0; i < int(count); i++ {
+		if buf.Len() < 12 {
+			return fmt.Errorf("Invalid router LSA length")
+		}
+
+		var l RouterLink
+
+		l.ID = net.IP(buf.Next(4))
+		l.Data = net.IP(buf.Next(4))
+
+		buf.ReadN(&l.Type)
+
+		var tos uint8
+		buf.ReadN(&tos)
+
+		buf.ReadN(&l.Metric)
+		buf.Next(4 * int(tos))
+
+		b.Links = append(b.Links, l)
+	}
+
+	return nil
+}
+
+func (b *NetworkLSA) length(version uint8, typ LSType) uint16 {
+	return 4 + 4*uint16(len(b.Routers))
+}
+
+func (b *NetworkLSA) pack(buf *packet.Buffer, version uint8, typ LSType) {
+	if version == 3 {
+		buf.WriteN(uint32(b.Options & 0xffffff))
+	} else {
+		write_addr(buf, b.Mask)
+	}
+
+	for _, r := range b.Routers {
+		write_addr(buf, r)
+	}
+}
+
+func (b *NetworkLSA) unpack(buf *packet.Buffer, version uint8, typ LSType) error {
+	if version == 3 {
+		var opts uint32
+		buf.ReadN(&opts)
+
+		b.Options = Options(opts & 0xffffff)
+	} else {
+		b.Mask = net.IP(buf.Next(4))
+	}
+
+	b.Routers = nil
+
+	for buf.Len() >= 4 {
+		b.Routers = append(b.Routers, net.IP(buf.Next(4)))
+	}
+
+	return nil
+}
+
+func (b *SummaryLSA) length(version uint8, typ LSType) uint16 {
+	switch {
+	case version != 3:
+		return 8
+
+	case typ == InterAreaRouter:
+		return 12
+
+	default:
+		return 8 + prefix_len(b.Prefix)
+	}
+}
+
+func (b *SummaryLSA) pack(buf *packet.Buffer, version uint8, typ LSType) {
+	switch {
+	case version != 3:
+		write_addr(buf, b.Mask)
+		buf.WriteN(b.Metric & 0xffffff)
+
+	case typ == InterAreaRouter:
+		buf.WriteN(uint32(b.Options & 0xffffff))
+		buf.WriteN(b.Metric & 0xffffff)
+		write_addr(buf, b.Router)
+
+	default:
+		buf.WriteN(b.Metric & 0xffffff)
+		pack_prefix(buf, b.Prefix, b.PrefixOptions, 0)
+	}
+}
+
+func (b *SummaryLSA) unpack(buf *packet.Buffer, version uint8, typ LSType) error {
+	switch {
+	case version != 3:
+		b.Mask = net.IP(buf.Next(4))
+		buf.ReadN(&b.Metric)
+
+	case typ == InterAreaRouter:
+		var opts uint32
+		buf.ReadN(&opts)
+
+		b.Options = Options(opts & 0xffffff)
+
+		buf.ReadN(&b.Metric)
+		b.Router = net.IP(buf.Next(4))
+
+	default:
+		buf.ReadN(&b.Metric)
+
+		var err error
+
+		b.Prefix, b.PrefixOptions, _, err = unpack_prefix(buf)
+		if err != nil {
+			return err
+		}
+	}
+
+	b.Metric &= 0xffffff
+
+	return nil
+}
+
+func (b *ExternalLSA) length(version uint8, typ LSType) uint16 {
+	if version != 3 {
+		return 16
+	}
+
+	length := 8 + prefix_len(b.Prefix)
+
+	if b.Forward != nil {
+		length += 16
+	}
+
+	if b.Tag != 0 {
+		length += 4
+	}
+
+	if b.RefLSType != 0 {
+		length += 4
+	}
+
+	return length
+}
+
+func (b *ExternalLSA) pack(buf *packet.Buffer, version uint8, typ LSType) {
+	if version != 3 {
+		write_addr(buf, b.Mask)
+
+		metric := b.Metric & 0xffffff
+		if b.External {
+			metric |= 0x80000000
+		}
+
+		buf.WriteN(metric)
+		write_addr(buf, b.Forward)
+		buf.WriteN(b.Tag)
+
+		return
+	}
+
+	flags_metric := b.Metric & 0xffffff
+
+	if b.External {
+		flags_metric |= 0x04000000
+	}
+
+	if b.Forward != nil {
+		flags_metric |= 0x02000000
+	}
+
+	if b.Tag != 0 {
+		flags_metric |= 0x01000000
+	}
+
+	buf.WriteN(flags_metric)
+	pack_prefix(buf, b.Prefix, b.PrefixOptions, uint16(b.RefLSType))
+
+	if b.Forward != nil {
+		buf.Write(b.Forward.To16())
+	}
+
+	if b.Tag != 0 {
+		buf.WriteN(b.Tag)
+	}
+
+	if b.RefLSType != 0 {
+		buf.WriteN(b.RefLSID)
+	}
+}
+
+func (b *ExternalLSA) unpack(buf *packet.Buffer, version uint8, typ LSType) error {
+	if version != 3 {
+		b.Mask = net.IP(buf.Next(4))
+
+		var metric uint32
+		buf.ReadN(&metric)
+
+		b.External = metric&0x80000000 != 0
+		b.Metric = metric & 0xffffff
+
+		b.Forward = net.IP(buf.Next(4))
+		buf.ReadN(&b.Tag)
+
+		return nil
+	}
+
+	var flags_metric uint32
+	buf.ReadN(&flags_metric)
+
+	b.External = flags_metric&0x04000000 != 0
+	b.Metric = flags_metric & 0xffffff
+
+	prefix, opts, ref, err := unpack_prefix(buf)
+	if err != nil {
+		return err
+	}
+
+	b.Prefix = prefix
+	b.PrefixOptions = opts
+	b.RefLSType = LSType(ref)
+
+	if flags_metric&0x02000000 != 0 {
+		b.Forward = net.IP(buf.Next(16))
+	}
+
+	if flags_metric&0x01000000 != 0 {
+		buf.ReadN(&b.Tag)
+	}
+
+	if b.RefLSType != 0 {
+		buf.ReadN(&b.RefLSID)
+	}
+
+	return nil
+}
+
+func (b *RawLSA) length(version uint8, typ LSType) uint16 {
+	return uint16(len(b.Data))
+}
+
+func (b *RawLSA) pack(buf *packet.Buffer, version uint8, typ LSType) {
+	buf.Write(b.Data)
+}
+
+func (b *RawLSA) unpack(buf *packet.Buffer, version uint8, typ LSType) error {
+	b.Data = buf.Next(buf.Len())
+
+	return nil
+}
+
+func prefix_len(prefix *net.IPNet) uint16 {
+	if prefix == nil {
+		return 4
+	}
+
+	ones, _ := prefix.Mask.Size()
+
+	return 4 + uint16((ones+31)/32*4)
+}
+
+func pack_prefix(buf *packet.Buffer, prefix *net.IPNet, opts uint8, extra uint16) {
+	if prefix == nil {
+		buf.WriteN(uint8(0x00))
+		buf.WriteN(opts)
+		buf.WriteN(extra)
+		return
+	}
+
+	ones, _ := prefix.Mask.Size()
+
+	buf.WriteN(uint8(ones))
+	buf.WriteN(opts)
+	buf.WriteN(extra)
+	buf.Write(prefix.IP.To16()[:(ones+31)/32*4])
+}
+
+func unpack_prefix(buf *packet.Buffer) (*net.IPNet, uint8, uint16, error) {
+	var ones, opts uint8
+	buf.ReadN(&ones)
+	buf.ReadN(&opts)
+
+	var extra uint16
+	buf.ReadN(&extra)
+
+	if ones > 128 || buf.Len() < (int(ones)+31)/32*4 {
+		return nil, 0, 0, fmt.Errorf("Invalid prefix length: %d", ones)
+	}
+
+	prefix := &net.IPNet{
+		IP:   make(net.IP, net.IPv6len),
+		Mask: net.CIDRMask(int(ones), 128),
+	}
+
+	copy(prefix.IP, buf.Next((int(ones)+31)/32*4))
+
+	return prefix, opts, extra, nil
+}
+
+// Calculate the Fletcher checksum of the given LSA, excluding the LS age
+// field (i.e. starting from the options field). The checksum field of the
+// LSA must be set to zero.
+func CalculateLSAChecksum(raw_bytes []byte) uint16 {
+	var c0, c1 int
+
+	for _, b := range raw_bytes {
+		c0 = (c0 + int(b)) % 255
+		c1 = (c1 + c0) % 255
+	}
+
+	/* offset of the checksum field, relative to the options field */
+	off := 14
+
+	x := ((len(raw_bytes)-off-1)*c0 - c1) % 255
+	if x <= 0 {
+		x += 255
+	}
+
+	y := 510 - c0 - x
+	if y > 255 {
+		y -= 255
+	}
+
+	return uint16(x)<<8 | uint16(y)
+}
+
+func (t LSType) String() string {
+	switch t {
+	case Router, RouterV3:
+		return "router"
+	case Network, NetworkV3:
+		return "network"
+	case Summary, InterAreaPrefix:
+		return "summary"
+	case ASBRSummary, InterAreaRouter:
+		return "asbr-summary"
+	case External, ExternalV3:
+		return "external"
+	case NSSA, NSSAV3:
+		return "nssa"
+	case Link:
+		return "link"
+	case IntraAreaPrefix:
+		return "intra-area-prefix"
+	default:
+		return fmt.Sprintf("0x%x", uint16(t))
+	}
+}
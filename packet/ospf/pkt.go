@@ -0,0 +1,337 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for OSPFv2 (RFC2328) and OSPFv3 (RFC5340)
+// packets.
+//
+// The type specific part of the packet is stored in the Body field (e.g. a
+// *Hello or a *LSUpdate), and the packet Type is derived from it when packing.
+package ospf
+
+import "fmt"
+import "net"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+
+type Packet struct {
+	Version     uint8 `string:"ver"`
+	Type        Type
+	Length      uint16        `string:"len"`
+	RouterID    net.IP        `string:"router"`
+	AreaID      net.IP        `string:"area"`
+	Checksum    uint16        `string:"sum"`
+	AuType      AuType        `string:"autype"`
+	Auth        [8]byte       `string:"skip"`
+	InstanceID  uint8         `string:"instance"`
+	Body        Body          `cmp:"skip" string:"skip"`
+	csum_seed   uint32        `cmp:"skip" string:"skip"`
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+type Type uint8
+
+const (
+	HelloType     Type = 1
+	DBDescType         = 2
+	LSRequestType      = 3
+	LSUpdateType       = 4
+	LSAckType          = 5
+)
+
+type AuType uint16
+
+const (
+	NullAuth   AuType = 0
+	SimpleAuth        = 1
+	CryptoAuth        = 2
+)
+
+// Options represents the OSPF options field. OSPFv2 only uses the lower 8
+// bits, while OSPFv3 uses 24 bits.
+type Options uint32
+
+const (
+	MT Options = 0x01 /* OSPFv2 */
+	V6         = 0x01 /* OSPFv3 */
+	E          = 0x02
+	MC         = 0x04
+	NP         = 0x08
+	N          = 0x08 /* OSPFv3 */
+	EA         = 0x10 /* OSPFv2 */
+	R          = 0x10 /* OSPFv3 */
+	DC         = 0x20
+	AF         = 0x0100 /* OSPFv3 */
+)
+
+// Body is the interface implemented by the type specific part of OSPF packets
+// (i.e. Hello, DBDesc, LSRequest, LSUpdate and LSAck).
+type Body interface {
+	/* Return the type of the packet carrying the body */
+	Type() Type
+
+	length(version uint8) uint16
+	pack(buf *packet.Buffer, version uint8)
+	unpack(buf *packet.Buffer, version uint8) error
+}
+
+// AllSPFRouters and AllDRouters are the multicast addresses OSPF packets are
+// sent to.
+var (
+	AllSPFRouters   = net.IPv4(224, 0, 0, 5)
+	AllDRouters     = net.IPv4(224, 0, 0, 6)
+	AllSPFRoutersV6 = net.ParseIP("ff02::5")
+	AllDRoutersV6   = net.ParseIP("ff02::6")
+)
+
+func Make() *Packet {
+	return &Packet{
+		Version:  2,
+		RouterID: net.IPv4zero,
+		AreaID:   net.IPv4zero,
+	}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.OSPF
+}
+
+func (p *Packet) GetLength() uint16 {
+	length := p.header_len()
+
+	if p.Body != nil {
+		length += p.Body.length(p.Version)
+	}
+
+	return length
+}
+
+func (p *Packet) header_len() uint16 {
+	if p.Version == 3 {
+		return 16
+	}
+
+	return 24
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	if other == nil || other.GetType() != packet.OSPF {
+		return false
+	}
+
+	req := other.(*Packet)
+
+	if p.Version != req.Version || p.Body == nil || req.Body == nil {
+		return false
+	}
+
+	switch body := p.Body.(type) {
+	case *Hello:
+		if req.Body.Type() != HelloType {
+			return false
+		}
+
+		for _, n := range body.Neighbors {
+			if n.Equal(req.RouterID) {
+				return true
+			}
+		}
+
+	case *DBDesc:
+		req_body, ok := req.Body.(*DBDesc)
+
+		return ok && body.Seq == req_body.Seq
+
+	case *LSUpdate:
+		return req.Body.Type() == LSRequestType
+
+	case *LSAck:
+		return req.Body.Type() == LSUpdateType
+	}
+
+	return false
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	if p.Body != nil {
+		p.Type = p.Body.Type()
+	}
+
+	p.Length = p.GetLength()
+
+	buf.WriteN(p.Version)
+	buf.WriteN(p.Type)
+	buf.WriteN(p.Length)
+	write_addr(buf, p.RouterID)
+	write_addr(buf, p.AreaID)
+	buf.WriteN(uint16(0x0000))
+
+	if p.Version == 3 {
+		buf.WriteN(p.InstanceID)
+		buf.WriteN(uint8(0x00))
+	} else {
+		buf.WriteN(p.AuType)
+		buf.WriteN([8]byte{})
+	}
+
+	if p.Body != nil {
+		p.Body.pack(buf, p.Version)
+	}
+
+	switch {
+	case p.Version == 3:
+		p.Checksum = ipv4.CalculateChecksum(buf.LayerBytes()[:p.Length],
+			p.csum_seed)
+
+	case p.AuType != CryptoAuth:
+		/* the authentication field is excluded from the checksum */
+		p.Checksum = ipv4.CalculateChecksum(buf.LayerBytes()[:p.Length],
+			0)
+
+	default:
+		p.Checksum = 0
+	}
+
+	buf.PutUint16N(12, p.Checksum)
+
+	if p.Version != 3 {
+		copy(buf.LayerBytes()[16:24], p.Auth[:])
+	}
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	buf.ReadN(&p.Version)
+	buf.ReadN(&p.Type)
+	buf.ReadN(&p.Length)
+
+	p.RouterID = net.IP(buf.Next(4))
+	p.AreaID = net.IP(buf.Next(4))
+
+	buf.ReadN(&p.Checksum)
+
+	if p.Version == 3 {
+		buf.ReadN(&p.InstanceID)
+		buf.Next(1)
+	} else {
+		buf.ReadN(&p.AuType)
+		buf.ReadN(&p.Auth)
+	}
+
+	if p.Length < p.header_len() ||
+		int(p.Length-p.header_len()) > buf.Len() {
+		return fmt.Errorf("Invalid length: %d", p.Length)
+	}
+
+	switch p.Type {
+	case HelloType:
+		p.Body = &Hello{}
+	case DBDescType:
+		p.Body = &DBDesc{}
+	case LSRequestType:
+		p.Body = &LSRequest{}
+	case LSUpdateType:
+		p.Body = &LSUpdate{}
+	case LSAckType:
+		p.Body = &LSAck{}
+	default:
+		return fmt.Errorf("Unknown packet type: %d", p.Type)
+	}
+
+	var body packet.Buffer
+	body.Init(buf.Next(int(p.Length - p.header_len())))
+
+	return p.Body.unpack(&body, p.Version)
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	return packet.None
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+	p.csum_seed = csum
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+func write_addr(buf *packet.Buffer, addr net.IP) {
+	if addr.To4() == nil {
+		addr = net.IPv4zero
+	}
+
+	buf.Write(addr.To4())
+}
+
+func (t Type) String() string {
+	switch t {
+	case HelloType:
+		return "hello"
+	case DBDescType:
+		return "db-desc"
+	case LSRequestType:
+		return "ls-request"
+	case LSUpdateType:
+		return "ls-update"
+	case LSAckType:
+		return "ls-ack"
+	default:
+		return "unknown"
+	}
+}
+
+func (t AuType) String() string {
+	switch t {
+	case NullAuth:
+		return ""
+	case SimpleAuth:
+		return "simple"
+	case CryptoAuth:
+		return "crypto"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,319 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package ospf_test
+
+import "bytes"
+import "net"
+import "reflect"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/layers"
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/ipv6"
+import "github.com/scs-solution/go.pkt2/packet/ospf"
+
+var test_simple = []byte{
+	0x02, 0x01, 0x00, 0x30, 0x01, 0x01, 0x01, 0x01, 0x00, 0x00, 0x00, 0x00,
+	0xec, 0x93, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x00, 0x00, 0x0a, 0x02, 0x01, 0x00, 0x00, 0x00, 0x28,
+	0x0a, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x02, 0x02, 0x02, 0x02,
+}
+
+func MakeTestSimple() *ospf.Packet {
+	hello := ospf.MakeHello()
+	hello.NetworkMask = net.IPv4(255, 255, 255, 0).To4()
+	hello.DR = net.IPv4(10, 0, 0, 1).To4()
+	hello.BDR = net.IPv4zero.To4()
+	hello.Neighbors = []net.IP{net.IPv4(2, 2, 2, 2).To4()}
+
+	return &ospf.Packet{
+		Version:  2,
+		Type:     ospf.HelloType,
+		Length:   48,
+		RouterID: net.IPv4(1, 1, 1, 1),
+		AreaID:   net.IPv4zero,
+		Checksum: 0xec93,
+		Body:     hello,
+	}
+}
+
+func TestPack(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_simple, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPack(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	var p ospf.Packet
+
+	cmp := MakeTestSimple()
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpack(bn *testing.B) {
+	var p ospf.Packet
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_simple)
+		p.Unpack(&b)
+	}
+}
+
+func TestUnpackHello(t *testing.T) {
+	var p ospf.Packet
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !reflect.DeepEqual(p.Body, MakeTestSimple().Body) {
+		t.Fatalf("Body mismatch: %+v", p.Body)
+	}
+}
+
+func TestPackUnpackLSUpdate(t *testing.T) {
+	for _, version := range []uint8{2, 3} {
+		p := ospf.Make()
+		p.Version = version
+		p.RouterID = net.IPv4(1, 1, 1, 1).To4()
+		p.AreaID = net.IPv4zero.To4()
+		p.Body = &ospf.LSUpdate{
+			LSAs: []ospf.LSA{
+				MakeTestRouterLSA(version),
+				MakeTestExternalLSA(version),
+			},
+		}
+
+		var b packet.Buffer
+		b.Init(make([]byte, p.GetLength()))
+
+		err := p.Pack(&b)
+		if err != nil {
+			t.Fatalf("Error packing: %s", err)
+		}
+
+		for _, lsa := range p.Body.(*ospf.LSUpdate).LSAs {
+			if lsa.Checksum == 0 {
+				t.Fatalf("LSA checksum not set")
+			}
+		}
+
+		raw_lsa := b.Buffer()[p.GetLength()-p.Body.(*ospf.LSUpdate).LSAs[1].Length:]
+		if !fletcher_valid(raw_lsa[2:]) {
+			t.Fatalf("Invalid LSA checksum: %x", raw_lsa)
+		}
+
+		var q ospf.Packet
+		b.Init(b.Buffer())
+
+		err = q.Unpack(&b)
+		if err != nil {
+			t.Fatalf("Error unpacking: %s", err)
+		}
+
+		if !q.Equals(p) || !reflect.DeepEqual(q.Body, p.Body) {
+			t.Fatalf("Packet mismatch (v%d):\n%+v\n%+v", version,
+				q.Body, p.Body)
+		}
+	}
+}
+
+func MakeTestRouterLSA(version uint8) ospf.LSA {
+	lsa := ospf.LSA{
+		LSAHeader: ospf.LSAHeader{
+			Age:       1,
+			Options:   ospf.E,
+			Type:      ospf.Router,
+			ID:        net.IPv4(1, 1, 1, 1).To4(),
+			AdvRouter: net.IPv4(1, 1, 1, 1).To4(),
+			Seq:       0x80000001,
+		},
+	}
+
+	link := ospf.RouterLink{
+		Type:   ospf.PointToPoint,
+		Metric: 10,
+	}
+
+	if version == 3 {
+		lsa.Options = 0
+		lsa.Type = ospf.RouterV3
+		link.InterfaceID = 5
+		link.NbrInterfaceID = 6
+		link.NbrRouterID = net.IPv4(2, 2, 2, 2).To4()
+	} else {
+		link.ID = net.IPv4(2, 2, 2, 2).To4()
+		link.Data = net.IPv4(10, 0, 0, 1).To4()
+	}
+
+	lsa.Body = &ospf.RouterLSA{
+		Flags: ospf.ASBoundary,
+		Links: []ospf.RouterLink{link},
+	}
+
+	return lsa
+}
+
+func MakeTestExternalLSA(version uint8) ospf.LSA {
+	lsa := ospf.LSA{
+		LSAHeader: ospf.LSAHeader{
+			Age:       1,
+			Type:      ospf.External,
+			ID:        net.IPv4(192, 168, 0, 0).To4(),
+			AdvRouter: net.IPv4(1, 1, 1, 1).To4(),
+			Seq:       0x80000002,
+		},
+	}
+
+	if version == 3 {
+		_, prefix, _ := net.ParseCIDR("2001:db8:1::/48")
+
+		lsa.Type = ospf.ExternalV3
+		lsa.Body = &ospf.ExternalLSA{
+			External: true,
+			Metric:   20,
+			Prefix:   prefix,
+			Tag:      42,
+		}
+	} else {
+		lsa.Body = &ospf.ExternalLSA{
+			Mask:     net.IPv4(255, 255, 0, 0).To4(),
+			External: true,
+			Metric:   20,
+			Forward:  net.IPv4zero.To4(),
+			Tag:      42,
+		}
+	}
+
+	return lsa
+}
+
+func fletcher_valid(raw_bytes []byte) bool {
+	var c0, c1 int
+
+	for _, b := range raw_bytes {
+		c0 = (c0 + int(b)) % 255
+		c1 = (c1 + c0) % 255
+	}
+
+	return c0 == 0 && c1 == 0
+}
+
+func TestAnswersDBDesc(t *testing.T) {
+	master := ospf.Make()
+	master.Body = &ospf.DBDesc{
+		MTU:   1500,
+		Flags: ospf.Initial | ospf.More | ospf.MasterSlave,
+		Seq:   1234,
+	}
+
+	slave := ospf.Make()
+	slave.Body = &ospf.DBDesc{
+		MTU:   1500,
+		Flags: ospf.More,
+		Seq:   1234,
+	}
+
+	if !slave.Answers(master) {
+		t.Fatalf("DBD does not answer")
+	}
+}
+
+func TestPackWithIPv6(t *testing.T) {
+	ip6 := ipv6.Make()
+	ip6.SrcAddr = net.ParseIP("fe80::1")
+	ip6.DstAddr = ospf.AllSPFRoutersV6
+
+	hello := ospf.MakeHello()
+	hello.InterfaceID = 3
+	hello.Options = ospf.V6 | ospf.E | ospf.R
+
+	p := ospf.Make()
+	p.Version = 3
+	p.RouterID = net.IPv4(1, 1, 1, 1)
+	p.Body = hello
+
+	buf, err := layers.Pack(ip6, p)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if p.GetLength() != 36 || p.Checksum == 0 {
+		t.Fatalf("Packet mismatch: %s", p)
+	}
+
+	pkt, err := layers.UnpackAll(buf, packet.IPv6)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	q := pkt.Payload().(*ospf.Packet)
+	if !q.Equals(p) || q.Body.(*ospf.Hello).InterfaceID != 3 {
+		t.Fatalf("Packet mismatch:\n%s\n%s", q, p)
+	}
+}
@@ -44,32 +44,46 @@ type Type uint16
 const (
     None Type = iota
     ARP
-    Bluetooth /* TODO */
+    ATT
+    BTLE
+    Bluetooth
+    CDP
+    DNS
+    DestOpts
+    ERSPAN
     Eth
-    GRE       /* TODO */
+    Fragment
+    GRE
+    HopByHop
     ICMPv4
     ICMPv6
-    IGMP      /* TODO */
-    IPSec     /* TODO */
+    IGMP
+    IPSecAH
+    IPSecESP
     IPv4
     IPv6
-    ISIS      /* TODO */
-    L2TP      /* TODO */
+    ISIS
+    L2CAP
+    L2TP
+    L2TPv3
     LLC
-    LLDP      /* TODO */
-    OSPF      /* TODO */
-    RadioTap  /* TODO */
+    LLDP
+    Mobility
+    OSPF
+    PPP
+    RadioTap
     Raw
-    SCTP      /* TODO */
+    Routing
+    SCTP
     SLL
     SNAP
     TCP
-    TRILL     /* TODO */
+    TRILL
     UDP
-    UDPLite   /* TODO */
+    UDPLite
     VLAN
-    WiFi      /* TODO */
-    WoL       /* TODO */
+    WiFi
+    WoL
 )
 
 // Packet is the interface used internally to implement packet encoding and
@@ -109,11 +123,13 @@ type Packet interface {
 }
 
 var pcap_link_type_to_type_map = [][2]uint32{
-    {   1, uint32(Eth)      },
-    { 113, uint32(SLL)      },
-    { 127, uint32(RadioTap) },
-    { 228, uint32(IPv4)     },
-    { 229, uint32(IPv6)     },
+    {   1, uint32(Eth)       },
+    { 113, uint32(SLL)       },
+    { 127, uint32(RadioTap)  },
+    { 201, uint32(Bluetooth) },
+    { 228, uint32(IPv4)      },
+    { 229, uint32(IPv6)      },
+    { 256, uint32(BTLE)      },
 }
 
 // Create a new type from the given PCAP link type.
@@ -141,22 +157,36 @@ func (pkttype Type) ToLinkType() uint32 {
 func (t Type) String() string {
     switch t {
     case ARP:       return "ARP"
+    case ATT:       return "ATT"
+    case BTLE:      return "Bluetooth LE"
     case Bluetooth: return "Bluetooth"
+    case CDP:       return "CDP"
+    case DNS:       return "DNS"
+    case DestOpts:  return "IPv6 DestOpts"
+    case ERSPAN:    return "ERSPAN"
     case Eth:       return "Ethernet"
+    case Fragment:  return "IPv6 Fragment"
     case GRE:       return "GRE"
+    case HopByHop:  return "IPv6 HopByHop"
     case ICMPv4:    return "ICMPv4"
     case ICMPv6:    return "ICMPv6"
     case IGMP:      return "IGMP"
-    case IPSec:     return "IPSec"
+    case IPSecAH:   return "IPSecAH"
+    case IPSecESP:  return "IPSecESP"
     case IPv4:      return "IPv4"
     case IPv6:      return "IPv6"
     case ISIS:      return "IS-IS"
+    case L2CAP:     return "L2CAP"
     case L2TP:      return "L2TP"
+    case L2TPv3:    return "L2TPv3"
     case LLC:       return "LLC"
     case LLDP:      return "LLDP"
+    case Mobility:  return "IPv6 Mobility"
     case None:      return "None"
     case OSPF:      return "OSPF"
+    case PPP:       return "PPP"
     case RadioTap:  return "RadioTap"
+    case Routing:   return "IPv6 Routing"
     case SCTP:      return "SCTP"
     case SNAP:      return "SNAP"
     case SLL:       return "SLL"
@@ -184,6 +214,10 @@ func Compare(a, b Packet) bool {
     aval := reflect.ValueOf(a).Elem()
     bval := reflect.ValueOf(b).Elem()
 
+    return compare_struct(aval, bval)
+}
+
+func compare_struct(aval, bval reflect.Value) bool {
     for i := 0; i < aval.NumField(); i++ {
         ftype := aval.Type().Field(i)
 
@@ -218,6 +252,15 @@ func compare_value(a, b reflect.Value) bool {
     case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
         return a.Uint() == b.Uint()
 
+    case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return a.Int() == b.Int()
+
+    case reflect.String:
+        return a.String() == b.String()
+
+    case reflect.Struct:
+        return compare_struct(a, b)
+
     case reflect.Array:
         for i := 0; i < a.Len(); i++ {
             if !compare_value(a.Index(i), b.Index(i)) {
@@ -260,6 +303,18 @@ func Stringify(p Packet) string {
     value := reflect.ValueOf(p).Elem()
     name  := strings.ToLower(p.GetType().String())
 
+    fields := stringify_struct(value)
+
+    s := fmt.Sprintf("%s(%s)", name, strings.Join(fields, ", "))
+
+    if p.Payload() != nil {
+        s = strings.Join([]string{s, p.Payload().String()}, " | ")
+    }
+
+    return s
+}
+
+func stringify_struct(value reflect.Value) []string {
     var fields []string
     for i := 0; i < value.NumField(); i++ {
         field := value.Field(i)
@@ -275,19 +330,19 @@ func Stringify(p Packet) string {
             continue
         }
 
+        /* flatten embedded structs */
+        if ftype.Anonymous && field.Kind() == reflect.Struct {
+            fields = append(fields, stringify_struct(field)...)
+            continue
+        }
+
         val := stringify_value(key, field)
         if val != "" {
             fields = append(fields, fmt.Sprintf("%s=%s", key, val))
         }
     }
 
-    s := fmt.Sprintf("%s(%s)", name, strings.Join(fields, ", "))
-
-    if p.Payload() != nil {
-        s = strings.Join([]string{s, p.Payload().String()}, " | ")
-    }
-
-    return s
+    return fields
 }
 
 func stringify_value(key string, val reflect.Value) string {
@@ -308,7 +363,12 @@ func stringify_value(key string, val reflect.Value) string {
             }
         }
 
-    case reflect.Interface, reflect.Slice, reflect.Struct:
+    case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        if val.Int() != 0 {
+            s = strconv.FormatInt(val.Int(), 10)
+        }
+
+    case reflect.Interface, reflect.Slice:
         if val.IsNil() {
             goto end
         }
@@ -317,6 +377,11 @@ func stringify_value(key string, val reflect.Value) string {
         if val.Bool() {
             s = "true"
         }
+
+    case reflect.String:
+        if val.Len() > 0 {
+            s = strconv.Quote(val.String())
+        }
     }
 
     m = val.MethodByName("String")
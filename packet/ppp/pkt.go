@@ -0,0 +1,205 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for PPP (Point-to-Point Protocol) frames, as
+// carried inside tunnels (e.g. L2TP or PPTP).
+package ppp
+
+import "fmt"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type Packet struct {
+	Address     uint8
+	Control     uint8         `string:"ctrl"`
+	Protocol    Protocol      `string:"proto"`
+	PFC         bool          `string:"pfc"`
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+type Protocol uint16
+
+const (
+	IPv4   Protocol = 0x0021
+	IPv6            = 0x0057
+	IPCP            = 0x8021
+	IPv6CP          = 0x8057
+	LCP             = 0xc021
+	PAP             = 0xc023
+	CHAP            = 0xc223
+)
+
+func Make() *Packet {
+	return &Packet{
+		Address: 0xff,
+		Control: 0x03,
+	}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.PPP
+}
+
+func (p *Packet) GetLength() uint16 {
+	length := p.proto_len()
+
+	/* address and control fields may be compressed away (ACFC) */
+	if p.Address != 0x00 {
+		length += 2
+	}
+
+	if p.pkt_payload != nil {
+		length += p.pkt_payload.GetLength()
+	}
+
+	return length
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	return false
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	if p.Address != 0x00 {
+		buf.WriteN(p.Address)
+		buf.WriteN(p.Control)
+	}
+
+	if p.proto_len() == 1 {
+		buf.WriteN(uint8(p.Protocol))
+	} else {
+		buf.WriteN(p.Protocol)
+	}
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	p.Address = 0x00
+	p.Control = 0x00
+
+	if buf.Len() >= 2 && buf.Bytes()[0] == 0xff && buf.Bytes()[1] == 0x03 {
+		buf.ReadN(&p.Address)
+		buf.ReadN(&p.Control)
+	}
+
+	if buf.Len() < 1 {
+		return fmt.Errorf("Invalid PPP frame")
+	}
+
+	/* protocol field may be compressed to a single byte (PFC) */
+	p.PFC = buf.Bytes()[0]&0x01 != 0
+
+	if p.PFC {
+		var proto uint8
+		buf.ReadN(&proto)
+
+		p.Protocol = Protocol(proto)
+	} else {
+		if buf.Len() < 2 {
+			return fmt.Errorf("Invalid PPP frame")
+		}
+
+		buf.ReadN(&p.Protocol)
+	}
+
+	return nil
+}
+
+/* The protocol field can only be compressed if its high byte is zero */
+func (p *Packet) proto_len() uint16 {
+	if p.PFC && p.Protocol <= 0xff {
+		return 1
+	}
+
+	return 2
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	switch p.Protocol {
+	case IPv4:
+		return packet.IPv4
+
+	case IPv6:
+		return packet.IPv6
+	}
+
+	return packet.Raw
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	switch pl.GetType() {
+	case packet.IPv4:
+		p.Protocol = IPv4
+
+	case packet.IPv6:
+		p.Protocol = IPv6
+	}
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+func (p Protocol) String() string {
+	switch p {
+	case IPv4:
+		return "ipv4"
+	case IPv6:
+		return "ipv6"
+	case IPCP:
+		return "ipcp"
+	case IPv6CP:
+		return "ipv6cp"
+	case LCP:
+		return "lcp"
+	case PAP:
+		return "pap"
+	case CHAP:
+		return "chap"
+	}
+
+	return fmt.Sprintf("0x%04x", uint16(p))
+}
@@ -0,0 +1,346 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package radiotap
+
+import "fmt"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+// Fields of a radiotap namespace. Each field is only valid if the
+// corresponding bit is set in the present bitmap.
+type Fields struct {
+	TSFT            uint64
+	Flags           FrameFlags
+	Rate            uint8
+	ChannelFreq     uint16       `string:"freq"`
+	ChannelFlags    ChannelFlags `string:"chan-flags"`
+	FHSS            uint16
+	DbmAntSignal    int8 `string:"signal"`
+	DbmAntNoise     int8 `string:"noise"`
+	LockQuality     uint16
+	TXAttenuation   uint16
+	DbTXAttenuation uint16
+	DbmTXPower      int8 `string:"tx-power"`
+	Antenna         uint8
+	DbAntSignal     uint8
+	DbAntNoise      uint8
+	RXFlags         uint16
+	TXFlags         uint16
+	RTSRetries      uint8
+	DataRetries     uint8
+	XChannel        XChannelInfo `string:"skip"`
+	MCS             MCSInfo
+	AMPDU           AMPDUStatus `string:"skip"`
+	VHT             VHTInfo
+	Timestamp       TimestampInfo     `string:"skip"`
+	HE              HEInfo            `string:"skip"`
+	HEMU            HEMUInfo          `string:"skip"`
+	HEMUOtherUser   HEMUOtherUserInfo `string:"skip"`
+	ZeroLenPSDU     uint8             `string:"skip"`
+	LSIG            LSIGInfo          `string:"skip"`
+}
+
+type FrameFlags uint8
+
+const (
+	FlagCFP           FrameFlags = 0x01
+	FlagShortPreamble            = 0x02
+	FlagWEP                      = 0x04
+	FlagFrag                     = 0x08
+	FlagFCS                      = 0x10
+	FlagDataPad                  = 0x20
+	FlagBadFCS                   = 0x40
+	FlagShortGI                  = 0x80
+)
+
+type ChannelFlags uint16
+
+const (
+	ChanTurbo   ChannelFlags = 0x0010
+	ChanCCK                  = 0x0020
+	ChanOFDM                 = 0x0040
+	Chan2GHz                 = 0x0080
+	Chan5GHz                 = 0x0100
+	ChanPassive              = 0x0200
+	ChanDynamic              = 0x0400
+	ChanGFSK                 = 0x0800
+)
+
+type XChannelInfo struct {
+	Flags    uint32
+	Freq     uint16
+	Channel  uint8
+	MaxPower uint8
+}
+
+type MCSInfo struct {
+	Known uint8
+	Flags uint8
+	Index uint8
+}
+
+/* MCS known bits */
+const (
+	MCSKnownBandwidth uint8 = 0x01
+	MCSKnownIndex           = 0x02
+	MCSKnownGI              = 0x04
+	MCSKnownFormat          = 0x08
+	MCSKnownFEC             = 0x10
+	MCSKnownSTBC            = 0x20
+	MCSKnownNESS            = 0x40
+)
+
+/* MCS flags */
+const (
+	MCSBandwidthMask uint8 = 0x03
+	MCSShortGI             = 0x04
+	MCSGreenfield          = 0x08
+	MCSLDPC                = 0x10
+	MCSSTBCMask            = 0x60
+)
+
+type AMPDUStatus struct {
+	Reference uint32
+	Flags     uint16
+	DelimCRC  uint8
+	Reserved  uint8
+}
+
+type VHTInfo struct {
+	Known      uint16
+	Flags      uint8
+	Bandwidth  uint8
+	MCSNSS     [4]uint8
+	Coding     uint8
+	GroupID    uint8
+	PartialAID uint16
+}
+
+type TimestampInfo struct {
+	Timestamp    uint64
+	Accuracy     uint16
+	UnitPosition uint8
+	Flags        uint8
+}
+
+type HEInfo struct {
+	Data [6]uint16
+}
+
+type HEMUInfo struct {
+	Flags1     uint16
+	Flags2     uint16
+	RUChannel1 [4]uint8
+	RUChannel2 [4]uint8
+}
+
+type HEMUOtherUserInfo struct {
+	PerUser1        uint16
+	PerUser2        uint16
+	PerUserPosition uint8
+	PerUserKnown    uint8
+}
+
+type LSIGInfo struct {
+	Data1 uint16
+	Data2 uint16
+}
+
+// Return a pointer to the field corresponding to the given present bit and
+// its natural alignment, or nil if the field is not known.
+func (f *Fields) field(bit Present) (interface{}, int) {
+	switch bit {
+	case TSFT:
+		return &f.TSFT, 8
+	case Flags:
+		return &f.Flags, 1
+	case Rate:
+		return &f.Rate, 1
+	case Channel:
+		return &[2]uint16{f.ChannelFreq, uint16(f.ChannelFlags)}, 2
+	case FHSS:
+		return &f.FHSS, 2
+	case DbmAntSignal:
+		return &f.DbmAntSignal, 1
+	case DbmAntNoise:
+		return &f.DbmAntNoise, 1
+	case LockQuality:
+		return &f.LockQuality, 2
+	case TXAttenuation:
+		return &f.TXAttenuation, 2
+	case DbTXAttenuation:
+		return &f.DbTXAttenuation, 2
+	case DbmTXPower:
+		return &f.DbmTXPower, 1
+	case Antenna:
+		return &f.Antenna, 1
+	case DbAntSignal:
+		return &f.DbAntSignal, 1
+	case DbAntNoise:
+		return &f.DbAntNoise, 1
+	case RXFlags:
+		return &f.RXFlags, 2
+	case TXFlags:
+		return &f.TXFlags, 2
+	case RTSRetries:
+		return &f.RTSRetries, 1
+	case DataRetries:
+		return &f.DataRetries, 1
+	case XChannel:
+		return &f.XChannel, 4
+	case MCS:
+		return &f.MCS, 1
+	case AMPDU:
+		return &f.AMPDU, 4
+	case VHT:
+		return &f.VHT, 2
+	case Timestamp:
+		return &f.Timestamp, 8
+	case HE:
+		return &f.HE, 2
+	case HEMU:
+		return &f.HEMU, 2
+	case HEMUOtherUser:
+		return &f.HEMUOtherUser, 2
+	case ZeroLenPSDU:
+		return &f.ZeroLenPSDU, 1
+	case LSIG:
+		return &f.LSIG, 2
+	}
+
+	return nil, 0
+}
+
+func (f *Fields) pack(buf *packet.Buffer, present Present) {
+	for bit := TSFT; bit < TLV; bit <<= 1 {
+		if present&bit == 0 {
+			continue
+		}
+
+		data, width := f.field(bit)
+		buf.WriteLAligned(data, uintptr(width))
+	}
+}
+
+// Decode the fields of a namespace. Returns false if the remaining data can't
+// be decoded (i.e. a TLV or unknown field is present).
+func (f *Fields) unpack(buf *packet.Buffer, present Present, ext []Present) (bool, error) {
+	for bit := TSFT; bit < TLV; bit <<= 1 {
+		if present&bit == 0 {
+			continue
+		}
+
+		data, width := f.field(bit)
+
+		buf.Align(width)
+		if buf.Len() < size(data) {
+			return false, fmt.Errorf("Invalid field length: %d", buf.Len())
+		}
+
+		buf.ReadL(data)
+
+		if bit == Channel {
+			ch := data.(*[2]uint16)
+
+			f.ChannelFreq = ch[0]
+			f.ChannelFlags = ChannelFlags(ch[1])
+		}
+	}
+
+	if present&TLV != 0 {
+		return false, nil
+	}
+
+	for _, word := range ext {
+		if word != 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (f *Fields) length(off int, present Present) int {
+	for bit := TSFT; bit < TLV; bit <<= 1 {
+		if present&bit == 0 {
+			continue
+		}
+
+		data, width := f.field(bit)
+		off = align(off, width) + size(data)
+	}
+
+	return off
+}
+
+// Return the legacy data rate in Mbps.
+func (f *Fields) RateMbps() float64 {
+	return float64(f.Rate) / 2
+}
+
+// Return the channel bandwidth in MHz, or 0 if unknown.
+func (m MCSInfo) Bandwidth() int {
+	if m.Known&MCSKnownBandwidth == 0 {
+		return 0
+	}
+
+	if m.Flags&MCSBandwidthMask == 1 {
+		return 40
+	}
+
+	return 20
+}
+
+func (m MCSInfo) String() string {
+	if m.Known&MCSKnownIndex == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d", m.Index)
+}
+
+// Return the MCS index of the given user (0-3).
+func (v VHTInfo) MCSIndex(user int) uint8 {
+	return v.MCSNSS[user] >> 4
+}
+
+// Return the number of spatial streams of the given user (0-3).
+func (v VHTInfo) NSS(user int) uint8 {
+	return v.MCSNSS[user] & 0x0f
+}
+
+func (v VHTInfo) String() string {
+	if v.Known == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%d/%d", v.MCSIndex(0), v.NSS(0))
+}
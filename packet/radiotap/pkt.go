@@ -29,16 +29,25 @@
  */
 
 // Provides encoding and decoding for RadioTap packets.
+//
+// The fields of the default radiotap namespace are stored in the embedded
+// Fields struct, and are only encoded if the corresponding bit is set in the
+// Present bitmap. The EXT and namespace bits of the present words are managed
+// automatically based on the Ext and Namespaces fields.
 package radiotap
 
+import "encoding/binary"
 import "fmt"
 
 import "github.com/scs-solution/go.pkt2/packet"
 
 type Packet struct {
-	Version     uint8
-	Length      uint16
-	Present     Present
+	Version uint8
+	Length  uint16
+	Present Present
+	Ext     []Present `string:"skip"`
+	Fields
+	Namespaces  []Namespace   `cmp:"skip" string:"skip"`
 	Data        []byte        `cmp:"skip" string:"skip"`
 	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
 }
@@ -60,23 +69,64 @@ const (
 	Antenna
 	DbAntSignal
 	DbAntNoise
+	RXFlags
+	TXFlags
+	RTSRetries
+	DataRetries
+	XChannel
+	MCS
+	AMPDU
+	VHT
+	Timestamp
+	HE
+	HEMU
+	HEMUOtherUser
+	ZeroLenPSDU
+	LSIG
+	TLV
+	RadiotapNS
+	VendorNS
 	EXT
 )
 
+// Additional namespace following the default radiotap namespace. Vendor
+// namespaces are kept undecoded in Data, while further radiotap namespaces
+// (e.g. used for per-antenna information) are decoded in Fields.
+type Namespace struct {
+	Present []Present
+	Vendor  bool
+	OUI     [3]byte
+	SubNS   uint8
+	Data    []byte
+	Fields  Fields
+}
+
 func Make() *Packet {
 	return &Packet{}
 }
 
+// Create a new RadioTap header for injecting frames with the given rate (in
+// 500 kbps units) and transmit power (in dBm).
+func MakeTX(rate uint8, power int8) *Packet {
+	return &Packet{
+		Present: Rate | DbmTXPower,
+		Fields: Fields{
+			Rate:       rate,
+			DbmTXPower: power,
+		},
+	}
+}
+
 func (p *Packet) GetType() packet.Type {
 	return packet.RadioTap
 }
 
 func (p *Packet) GetLength() uint16 {
 	if p.pkt_payload != nil {
-		return p.pkt_payload.GetLength() + 8 + uint16(len(p.Data))
+		return p.pkt_payload.GetLength() + p.header_len()
 	}
 
-	return 8 + uint16(len(p.Data))
+	return p.header_len()
 }
 
 func (p *Packet) Equals(other packet.Packet) bool {
@@ -88,28 +138,155 @@ func (p *Packet) Answers(other packet.Packet) bool {
 }
 
 func (p *Packet) Pack(buf *packet.Buffer) error {
+	p.Length = p.header_len()
+
 	buf.WriteL(p.Version)
 	buf.WriteL(uint8(0x00))
 	buf.WriteL(p.Length)
-	buf.WriteL(p.Present)
 
-	/* TODO: actually decode fields */
+	for _, word := range p.present_words() {
+		buf.WriteL(word)
+	}
+
+	p.Fields.pack(buf, p.Present)
+
+	for _, ns := range p.Namespaces {
+		if ns.Vendor {
+			buf.WriteLAligned(ns.OUI, 2)
+			buf.WriteL(ns.SubNS)
+			buf.WriteL(uint16(len(ns.Data)))
+			buf.Write(ns.Data)
+		} else if len(ns.Present) > 0 {
+			ns.Fields.pack(buf, ns.Present[0])
+		}
+	}
+
 	buf.Write(p.Data)
 
 	return nil
 }
 
 func (p *Packet) Unpack(buf *packet.Buffer) error {
-	buf.ReadN(&p.Version)
+	p.Ext = nil
+	p.Namespaces = nil
+	p.Data = nil
+	p.Fields = Fields{}
+
+	if buf.Len() < 8 {
+		return fmt.Errorf("Invalid length: %d", buf.Len())
+	}
+
+	buf.ReadL(&p.Version)
 
 	var pad uint8
 	buf.ReadL(&pad)
 
 	buf.ReadL(&p.Length)
-	buf.ReadL(&p.Present)
 
-	/* TODO: actually decode fields */
-	p.Data = buf.Next(int(p.Length) - 8)
+	if p.Length < 8 || int(p.Length) > buf.Len()+4 {
+		return fmt.Errorf("Invalid length: %d", p.Length)
+	}
+
+	var hdr packet.Buffer
+	hdr.Init(buf.LayerBytes()[:p.Length])
+	hdr.SetOffset(4)
+
+	buf.Next(int(p.Length) - 4)
+
+	var words []Present
+
+	for {
+		var word Present
+		if hdr.Len() < 4 {
+			return fmt.Errorf("Invalid present bitmap")
+		}
+
+		hdr.ReadL(&word)
+
+		words = append(words, word)
+
+		if word&EXT == 0 {
+			break
+		}
+	}
+
+	/* split the present words by namespace */
+	vendor := false
+	var ns *Namespace
+
+	for i, word := range words {
+		switch {
+		case i == 0:
+			p.Present = word &^ (EXT | RadiotapNS | VendorNS)
+
+		case ns == nil:
+			p.Ext = append(p.Ext, word&^(EXT|RadiotapNS|VendorNS))
+
+		default:
+			ns.Present = append(ns.Present,
+				word&^(EXT|RadiotapNS|VendorNS))
+		}
+
+		if word&(RadiotapNS|VendorNS) != 0 && word&EXT != 0 {
+			vendor = word&VendorNS != 0
+
+			p.Namespaces = append(p.Namespaces,
+				Namespace{Vendor: vendor})
+			ns = &p.Namespaces[len(p.Namespaces)-1]
+		}
+	}
+
+	known, err := p.Fields.unpack(&hdr, p.Present, p.Ext)
+	if err != nil {
+		return err
+	}
+
+	if !known {
+		p.Data = hdr.Bytes()
+		return nil
+	}
+
+	for i := range p.Namespaces {
+		ns := &p.Namespaces[i]
+
+		if ns.Vendor {
+			var skip uint16
+
+			hdr.Align(2)
+			if hdr.Len() < 6 {
+				return fmt.Errorf("Invalid namespace length: %d",
+					hdr.Len())
+			}
+
+			hdr.ReadL(&ns.OUI)
+			hdr.ReadL(&ns.SubNS)
+			hdr.ReadL(&skip)
+
+			ns.Data = hdr.Next(int(skip))
+			continue
+		}
+
+		var first Present
+		if len(ns.Present) > 0 {
+			first = ns.Present[0]
+		}
+
+		var ext []Present
+		if len(ns.Present) > 1 {
+			ext = ns.Present[1:]
+		}
+
+		known, err := ns.Fields.unpack(&hdr, first, ext)
+		if err != nil {
+			return err
+		}
+
+		if !known {
+			break
+		}
+	}
+
+	p.Data = hdr.Bytes()
 
 	return nil
 }
@@ -124,7 +301,7 @@ func (p *Packet) GuessPayloadType() packet.Type {
 
 func (p *Packet) SetPayload(pl packet.Packet) error {
 	p.pkt_payload = pl
-	p.Length = p.GetLength()
+	p.Length = p.header_len()
 
 	return nil
 }
@@ -136,6 +313,65 @@ func (p *Packet) String() string {
 	return packet.Stringify(p)
 }
 
+// Return whether the 802.11 frame following the header includes the FCS.
+func (p *Packet) HasFCS() bool {
+	return p.Present&Flags != 0 && p.Fields.Flags&FlagFCS != 0
+}
+
+// Return the present words as encoded on the wire, with the EXT and namespace
+// bits set.
+func (p *Packet) present_words() []Present {
+	words := append([]Present{p.Present}, p.Ext...)
+
+	for _, ns := range p.Namespaces {
+		last := &words[len(words)-1]
+
+		*last |= EXT
+
+		if ns.Vendor {
+			*last |= VendorNS
+		} else {
+			*last |= RadiotapNS
+		}
+
+		if len(ns.Present) > 0 {
+			words = append(words, ns.Present...)
+		} else {
+			words = append(words, 0)
+		}
+	}
+
+	for i := 0; i < len(words)-1; i++ {
+		words[i] |= EXT
+	}
+
+	return words
+}
+
+func (p *Packet) header_len() uint16 {
+	off := 4 + 4*len(p.present_words())
+
+	off = p.Fields.length(off, p.Present)
+
+	for _, ns := range p.Namespaces {
+		if ns.Vendor {
+			off = align(off, 2) + 6 + len(ns.Data)
+		} else if len(ns.Present) > 0 {
+			off = ns.Fields.length(off, ns.Present[0])
+		}
+	}
+
+	return uint16(off + len(p.Data))
+}
+
+func align(off, width int) int {
+	return (off + width - 1) &^ (width - 1)
+}
+
+func size(data interface{}) int {
+	return binary.Size(data)
+}
+
 func (p Present) String() string {
 	return fmt.Sprintf("0x%x", uint32(p))
 }
@@ -31,6 +31,7 @@
 package radiotap_test
 
 import "bytes"
+import "reflect"
 import "testing"
 
 import "github.com/scs-solution/go.pkt2/packet"
@@ -47,9 +48,19 @@ func MakeTestSimple() *radiotap.Packet {
 		Version: 0,
 		Length:  32,
 		Present: 264295,
-		Data: []byte{0x54, 0xc6, 0xb8, 0x24, 0x00, 0x00, 0x00, 0x00,
-			0x22, 0x0c, 0xda, 0xa0, 0x02, 0x00, 0x00, 0x00,
-			0x40, 0x01, 0x00, 0x00, 0x3c, 0x14, 0x24, 0x11,
+		Fields: radiotap.Fields{
+			TSFT:         0x24b8c654,
+			Flags:        radiotap.FlagShortPreamble | radiotap.FlagDataPad,
+			Rate:         12,
+			DbmAntSignal: -38,
+			DbmAntNoise:  -96,
+			Antenna:      2,
+			XChannel: radiotap.XChannelInfo{
+				Flags:    0x140,
+				Freq:     5180,
+				Channel:  36,
+				MaxPower: 17,
+			},
 		},
 	}
 }
@@ -108,3 +119,183 @@ func BenchmarkUnpack(bn *testing.B) {
 		p.Unpack(&b)
 	}
 }
+
+/* the FHSS field is 2-byte aligned after the 1-byte flags */
+var test_fhss = []byte{
+	0x00, 0x00, 0x0c, 0x00, 0x12, 0x00, 0x00, 0x00, 0x10, 0x00, 0x03, 0x05,
+}
+
+func MakeTestFHSS() *radiotap.Packet {
+	return &radiotap.Packet{
+		Length:  12,
+		Present: radiotap.Flags | radiotap.FHSS,
+		Fields: radiotap.Fields{
+			Flags: radiotap.FlagFCS,
+			FHSS:  0x0503,
+		},
+	}
+}
+
+func TestPackFHSS(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_fhss)))
+
+	p := MakeTestFHSS()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_fhss, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func TestUnpackFHSS(t *testing.T) {
+	var p radiotap.Packet
+
+	cmp := MakeTestFHSS()
+
+	var b packet.Buffer
+	b.Init(test_fhss)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func TestUnpackTruncated(t *testing.T) {
+	tests := [][]byte{
+		/* truncated header */
+		{0x00, 0x00, 0x08},
+		/* channel with 2 bytes of data */
+		{0x00, 0x00, 0x0a, 0x00, 0x08, 0x00, 0x00, 0x00, 0x6c, 0x09},
+		/* vendor namespace without OUI */
+		{
+			0x00, 0x00, 0x0c, 0x00, 0x00, 0x00, 0x00, 0xc0, 0x00, 0x00,
+			0x00, 0x00,
+		},
+	}
+
+	for _, raw := range tests {
+		var p radiotap.Packet
+
+		var b packet.Buffer
+		b.Init(raw)
+
+		err := p.Unpack(&b)
+		if err == nil {
+			t.Fatalf("Truncated header not detected: %x", raw)
+		}
+	}
+}
+
+var test_namespaces = []byte{
+	0x00, 0x00, 0x20, 0x00, 0x22, 0x00, 0x08, 0xc0, 0x01, 0x00, 0x00, 0xa0,
+	0x20, 0x08, 0x00, 0x00, 0x10, 0xd8, 0x07, 0x05, 0x07, 0x00, 0x00, 0x11,
+	0x22, 0x01, 0x02, 0x00, 0xaa, 0xbb, 0xd6, 0x01,
+}
+
+func MakeTestNamespaces() *radiotap.Packet {
+	return &radiotap.Packet{
+		Present: radiotap.Flags | radiotap.DbmAntSignal | radiotap.MCS,
+		Fields: radiotap.Fields{
+			Flags:        radiotap.FlagFCS,
+			DbmAntSignal: -40,
+			MCS: radiotap.MCSInfo{
+				Known: radiotap.MCSKnownBandwidth |
+					radiotap.MCSKnownIndex | radiotap.MCSKnownGI,
+				Flags: 1 | radiotap.MCSShortGI,
+				Index: 7,
+			},
+		},
+		Namespaces: []radiotap.Namespace{
+			{
+				Present: []radiotap.Present{0x1},
+				Vendor:  true,
+				OUI:     [3]byte{0x00, 0x11, 0x22},
+				SubNS:   1,
+				Data:    []byte{0xaa, 0xbb},
+			},
+			{
+				Present: []radiotap.Present{
+					radiotap.DbmAntSignal | radiotap.Antenna,
+				},
+				Fields: radiotap.Fields{
+					DbmAntSignal: -42,
+					Antenna:      1,
+				},
+			},
+		},
+	}
+}
+
+func TestPackNamespaces(t *testing.T) {
+	p := MakeTestNamespaces()
+
+	if p.GetLength() != uint16(len(test_namespaces)) {
+		t.Fatalf("Length mismatch: %d", p.GetLength())
+	}
+
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_namespaces)))
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_namespaces, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func TestUnpackNamespaces(t *testing.T) {
+	var p radiotap.Packet
+
+	cmp := MakeTestNamespaces()
+	cmp.Length = uint16(len(test_namespaces))
+
+	var b packet.Buffer
+	b.Init(test_namespaces)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+
+	if !p.HasFCS() || p.MCS.Bandwidth() != 40 {
+		t.Fatalf("Flags mismatch: %s", &p)
+	}
+
+	if !reflect.DeepEqual(p.Namespaces, cmp.Namespaces) {
+		t.Fatalf("Namespaces mismatch: %v", p.Namespaces)
+	}
+}
+
+func TestPackTX(t *testing.T) {
+	p := radiotap.MakeTX(2, -10)
+
+	var b packet.Buffer
+	b.Init(make([]byte, p.GetLength()))
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	tx := []byte{0x00, 0x00, 0x0a, 0x00, 0x04, 0x04, 0x00, 0x00, 0x02, 0xf6}
+	if !bytes.Equal(tx, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
@@ -0,0 +1,691 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sctp
+
+import "fmt"
+import "strings"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+// Chunk is the interface implemented by all the SCTP chunk types. Chunks that
+// are not known are decoded as RawChunk.
+type Chunk interface {
+	/* Return the type of the chunk */
+	Type() ChunkType
+
+	flags() uint8
+	length() uint16
+	pack(buf *packet.Buffer)
+	unpack(buf *packet.Buffer, flags uint8) error
+}
+
+type ChunkType uint8
+
+const (
+	Data             ChunkType = 0
+	Init                       = 1
+	InitAck                    = 2
+	SAck                       = 3
+	Heartbeat                  = 4
+	HeartbeatAck               = 5
+	Abort                      = 6
+	Shutdown                   = 7
+	ShutdownAck                = 8
+	Error                      = 9
+	CookieEcho                 = 10
+	CookieAck                  = 11
+	ShutdownComplete           = 14
+)
+
+// Variable-length parameter, as used by INIT and INIT-ACK chunks.
+type Param struct {
+	Type ParamType
+	Data []byte
+}
+
+type ParamType uint16
+
+const (
+	HeartbeatInfo      ParamType = 1
+	IPv4Addr                     = 5
+	IPv6Addr                     = 6
+	StateCookie                  = 7
+	UnrecognizedParam            = 8
+	CookiePreservative           = 9
+	HostName                     = 11
+	SupportedAddrTypes           = 12
+	ECNCapable                   = 0x8000
+	ForwardTSN                   = 0xc000
+)
+
+// Error cause, as used by ABORT and ERROR chunks.
+type Cause struct {
+	Code CauseCode
+	Data []byte
+}
+
+type CauseCode uint16
+
+const (
+	InvalidStream       CauseCode = 1
+	MissingParam                  = 2
+	StaleCookie                   = 3
+	OutOfResource                 = 4
+	UnresolvableAddr              = 5
+	UnrecognizedChunk             = 6
+	InvalidParam                  = 7
+	UnrecognizedParams            = 8
+	NoUserData                    = 9
+	CookieWhileShutdown           = 10
+	RestartWithNewAddrs           = 11
+	UserAbort                     = 12
+	ProtocolViolation             = 13
+)
+
+type DataFlags uint8
+
+const (
+	End       DataFlags = 1 << 0
+	Begin               = 1 << 1
+	Unordered           = 1 << 2
+	Immediate           = 1 << 3
+)
+
+type DataChunk struct {
+	Flags     DataFlags
+	TSN       uint32
+	StreamID  uint16
+	StreamSeq uint16
+	PPID      uint32
+	Data      []byte
+}
+
+type InitChunk struct {
+	InitTag    uint32
+	Window     uint32
+	OutStreams uint16
+	InStreams  uint16
+	InitTSN    uint32
+	Params     []Param
+}
+
+type InitAckChunk struct {
+	InitChunk
+}
+
+type GapBlock struct {
+	Start uint16
+	End   uint16
+}
+
+type SAckChunk struct {
+	CumTSN    uint32
+	Window    uint32
+	GapBlocks []GapBlock
+	DupTSNs   []uint32
+}
+
+type HeartbeatChunk struct {
+	Info []byte
+}
+
+type HeartbeatAckChunk struct {
+	HeartbeatChunk
+}
+
+type AbortChunk struct {
+	/* the verification tag was reflected from the received packet */
+	Reflected bool
+	Causes    []Cause
+}
+
+type ShutdownChunk struct {
+	CumTSN uint32
+}
+
+type ShutdownAckChunk struct {
+}
+
+type ErrorChunk struct {
+	Causes []Cause
+}
+
+type CookieEchoChunk struct {
+	Cookie []byte
+}
+
+type CookieAckChunk struct {
+}
+
+type ShutdownCompleteChunk struct {
+	Reflected bool
+}
+
+type RawChunk struct {
+	ChunkType ChunkType
+	Flags     uint8
+	Data      []byte
+}
+
+func (c *DataChunk) Type() ChunkType {
+	return Data
+}
+
+func (c *DataChunk) flags() uint8 {
+	return uint8(c.Flags)
+}
+
+func (c *DataChunk) length() uint16 {
+	return 12 + uint16(len(c.Data))
+}
+
+func (c *DataChunk) pack(buf *packet.Buffer) {
+	buf.WriteN(c.TSN)
+	buf.WriteN(c.StreamID)
+	buf.WriteN(c.StreamSeq)
+	buf.WriteN(c.PPID)
+	buf.Write(c.Data)
+}
+
+func (c *DataChunk) unpack(buf *packet.Buffer, flags uint8) error {
+	c.Flags = DataFlags(flags)
+
+	buf.ReadN(&c.TSN)
+	buf.ReadN(&c.StreamID)
+	buf.ReadN(&c.StreamSeq)
+	buf.ReadN(&c.PPID)
+
+	c.Data = buf.Next(buf.Len())
+
+	return nil
+}
+
+func (c *InitChunk) Type() ChunkType {
+	return Init
+}
+
+func (c *InitChunk) flags() uint8 {
+	return 0
+}
+
+func (c *InitChunk) length() uint16 {
+	return 16 + params_length(c.Params)
+}
+
+func (c *InitChunk) pack(buf *packet.Buffer) {
+	buf.WriteN(c.InitTag)
+	buf.WriteN(c.Window)
+	buf.WriteN(c.OutStreams)
+	buf.WriteN(c.InStreams)
+	buf.WriteN(c.InitTSN)
+
+	pack_params(buf, c.Params)
+}
+
+func (c *InitChunk) unpack(buf *packet.Buffer, flags uint8) error {
+	buf.ReadN(&c.InitTag)
+	buf.ReadN(&c.Window)
+	buf.ReadN(&c.OutStreams)
+	buf.ReadN(&c.InStreams)
+	buf.ReadN(&c.InitTSN)
+
+	c.Params = unpack_params(buf)
+
+	return nil
+}
+
+// Return the first parameter of the given type, or nil if the chunk doesn't
+// contain one.
+func (c *InitChunk) FindParam(typ ParamType) *Param {
+	for i := range c.Params {
+		if c.Params[i].Type == typ {
+			return &c.Params[i]
+		}
+	}
+
+	return nil
+}
+
+func (c *InitAckChunk) Type() ChunkType {
+	return InitAck
+}
+
+// Return the state cookie carried by the INIT-ACK, or nil if not present.
+func (c *InitAckChunk) Cookie() []byte {
+	param := c.FindParam(StateCookie)
+	if param == nil {
+		return nil
+	}
+
+	return param.Data
+}
+
+func (c *SAckChunk) Type() ChunkType {
+	return SAck
+}
+
+func (c *SAckChunk) flags() uint8 {
+	return 0
+}
+
+func (c *SAckChunk) length() uint16 {
+	return 12 + 4*uint16(len(c.GapBlocks)) + 4*uint16(len(c.DupTSNs))
+}
+
+func (c *SAckChunk) pack(buf *packet.Buffer) {
+	buf.WriteN(c.CumTSN)
+	buf.WriteN(c.Window)
+	buf.WriteN(uint16(len(c.GapBlocks)))
+	buf.WriteN(uint16(len(c.DupTSNs)))
+
+	for _, b := range c.GapBlocks {
+		buf.WriteN(b.Start)
+		buf.WriteN(b.End)
+	}
+
+	for _, tsn := range c.DupTSNs {
+		buf.WriteN(tsn)
+	}
+}
+
+func (c *SAckChunk) unpack(buf *packet.Buffer, flags uint8) error {
+	buf.ReadN(&c.CumTSN)
+	buf.ReadN(&c.Window)
+
+	var gaps, dups uint16
+	buf.ReadN(&gaps)
+	buf.ReadN(&dups)
+
+	if buf.Len() < 4*int(gaps)+4*int(dups) {
+		return fmt.Errorf("Invalid SACK length: %d", buf.Len())
+	}
+
+	c.GapBlocks = make([]GapBlock, gaps)
+	for i := range c.GapBlocks {
+		buf.ReadN(&c.GapBlocks[i].Start)
+		buf.ReadN(&c.GapBlocks[i].End)
+	}
+
+	c.DupTSNs = make([]uint32, dups)
+	for i := range c.DupTSNs {
+		buf.ReadN(&c.DupTSNs[i])
+	}
+
+	return nil
+}
+
+func (c *HeartbeatChunk) Type() ChunkType {
+	return Heartbeat
+}
+
+func (c *HeartbeatChunk) flags() uint8 {
+	return 0
+}
+
+func (c *HeartbeatChunk) length() uint16 {
+	return params_length([]Param{{HeartbeatInfo, c.Info}})
+}
+
+func (c *HeartbeatChunk) pack(buf *packet.Buffer) {
+	pack_params(buf, []Param{{HeartbeatInfo, c.Info}})
+}
+
+func (c *HeartbeatChunk) unpack(buf *packet.Buffer, flags uint8) error {
+	for _, param := range unpack_params(buf) {
+		if param.Type == HeartbeatInfo {
+			c.Info = param.Data
+		}
+	}
+
+	return nil
+}
+
+func (c *HeartbeatAckChunk) Type() ChunkType {
+	return HeartbeatAck
+}
+
+func (c *AbortChunk) Type() ChunkType {
+	return Abort
+}
+
+func (c *AbortChunk) flags() uint8 {
+	if c.Reflected {
+		return 0x01
+	}
+
+	return 0
+}
+
+func (c *AbortChunk) length() uint16 {
+	return causes_length(c.Causes)
+}
+
+func (c *AbortChunk) pack(buf *packet.Buffer) {
+	pack_causes(buf, c.Causes)
+}
+
+func (c *AbortChunk) unpack(buf *packet.Buffer, flags uint8) error {
+	c.Reflected = flags&0x01 != 0
+	c.Causes = unpack_causes(buf)
+
+	return nil
+}
+
+func (c *ShutdownChunk) Type() ChunkType {
+	return Shutdown
+}
+
+func (c *ShutdownChunk) flags() uint8 {
+	return 0
+}
+
+func (c *ShutdownChunk) length() uint16 {
+	return 4
+}
+
+func (c *ShutdownChunk) pack(buf *packet.Buffer) {
+	buf.WriteN(c.CumTSN)
+}
+
+func (c *ShutdownChunk) unpack(buf *packet.Buffer, flags uint8) error {
+	buf.ReadN(&c.CumTSN)
+
+	return nil
+}
+
+func (c *ShutdownAckChunk) Type() ChunkType {
+	return ShutdownAck
+}
+
+func (c *ShutdownAckChunk) flags() uint8 {
+	return 0
+}
+
+func (c *ShutdownAckChunk) length() uint16 {
+	return 0
+}
+
+func (c *ShutdownAckChunk) pack(buf *packet.Buffer) {
+}
+
+func (c *ShutdownAckChunk) unpack(buf *packet.Buffer, flags uint8) error {
+	return nil
+}
+
+func (c *ErrorChunk) Type() ChunkType {
+	return Error
+}
+
+func (c *ErrorChunk) flags() uint8 {
+	return 0
+}
+
+func (c *ErrorChunk) length() uint16 {
+	return causes_length(c.Causes)
+}
+
+func (c *ErrorChunk) pack(buf *packet.Buffer) {
+	pack_causes(buf, c.Causes)
+}
+
+func (c *ErrorChunk) unpack(buf *packet.Buffer, flags uint8) error {
+	c.Causes = unpack_causes(buf)
+
+	return nil
+}
+
+func (c *CookieEchoChunk) Type() ChunkType {
+	return CookieEcho
+}
+
+func (c *CookieEchoChunk) flags() uint8 {
+	return 0
+}
+
+func (c *CookieEchoChunk) length() uint16 {
+	return uint16(len(c.Cookie))
+}
+
+func (c *CookieEchoChunk) pack(buf *packet.Buffer) {
+	buf.Write(c.Cookie)
+}
+
+func (c *CookieEchoChunk) unpack(buf *packet.Buffer, flags uint8) error {
+	c.Cookie = buf.Next(buf.Len())
+
+	return nil
+}
+
+func (c *CookieAckChunk) Type() ChunkType {
+	return CookieAck
+}
+
+func (c *CookieAckChunk) flags() uint8 {
+	return 0
+}
+
+func (c *CookieAckChunk) length() uint16 {
+	return 0
+}
+
+func (c *CookieAckChunk) pack(buf *packet.Buffer) {
+}
+
+func (c *CookieAckChunk) unpack(buf *packet.Buffer, flags uint8) error {
+	return nil
+}
+
+func (c *ShutdownCompleteChunk) Type() ChunkType {
+	return ShutdownComplete
+}
+
+func (c *ShutdownCompleteChunk) flags() uint8 {
+	if c.Reflected {
+		return 0x01
+	}
+
+	return 0
+}
+
+func (c *ShutdownCompleteChunk) length() uint16 {
+	return 0
+}
+
+func (c *ShutdownCompleteChunk) pack(buf *packet.Buffer) {
+}
+
+func (c *ShutdownCompleteChunk) unpack(buf *packet.Buffer, flags uint8) error {
+	c.Reflected = flags&0x01 != 0
+
+	return nil
+}
+
+func (c *RawChunk) Type() ChunkType {
+	return c.ChunkType
+}
+
+func (c *RawChunk) flags() uint8 {
+	return c.Flags
+}
+
+func (c *RawChunk) length() uint16 {
+	return uint16(len(c.Data))
+}
+
+func (c *RawChunk) pack(buf *packet.Buffer) {
+	buf.Write(c.Data)
+}
+
+func (c *RawChunk) unpack(buf *packet.Buffer, flags uint8) error {
+	c.Flags = flags
+	c.Data = buf.Next(buf.Len())
+
+	return nil
+}
+
+func params_length(params []Param) uint16 {
+	var length uint16
+
+	for i, param := range params {
+		/* the last parameter is not padded */
+		if i == len(params)-1 {
+			length += 4 + uint16(len(param.Data))
+		} else {
+			length += 4 + pad(uint16(len(param.Data)))
+		}
+	}
+
+	return length
+}
+
+func pack_params(buf *packet.Buffer, params []Param) {
+	for i, param := range params {
+		length := uint16(len(param.Data))
+
+		buf.WriteN(param.Type)
+		buf.WriteN(4 + length)
+		buf.Write(param.Data)
+
+		if i == len(params)-1 {
+			break
+		}
+
+		for j := length; j < pad(length); j++ {
+			buf.WriteN(uint8(0x00))
+		}
+	}
+}
+
+func unpack_params(buf *packet.Buffer) []Param {
+	var params []Param
+
+	for buf.Len() >= 4 {
+		var param Param
+		buf.ReadN(&param.Type)
+
+		var length uint16
+		buf.ReadN(&length)
+
+		if length < 4 {
+			break
+		}
+
+		param.Data = buf.Next(int(length) - 4)
+		params = append(params, param)
+
+		buf.Next(int(pad(length) - length))
+	}
+
+	return params
+}
+
+func causes_length(causes []Cause) uint16 {
+	params := make([]Param, len(causes))
+	for i, cause := range causes {
+		params[i] = Param{ParamType(cause.Code), cause.Data}
+	}
+
+	return params_length(params)
+}
+
+func pack_causes(buf *packet.Buffer, causes []Cause) {
+	params := make([]Param, len(causes))
+	for i, cause := range causes {
+		params[i] = Param{ParamType(cause.Code), cause.Data}
+	}
+
+	pack_params(buf, params)
+}
+
+func unpack_causes(buf *packet.Buffer) []Cause {
+	var causes []Cause
+
+	for _, param := range unpack_params(buf) {
+		causes = append(causes, Cause{CauseCode(param.Type), param.Data})
+	}
+
+	return causes
+}
+
+func (t ChunkType) String() string {
+	switch t {
+	case Data:
+		return "data"
+	case Init:
+		return "init"
+	case InitAck:
+		return "init-ack"
+	case SAck:
+		return "sack"
+	case Heartbeat:
+		return "heartbeat"
+	case HeartbeatAck:
+		return "heartbeat-ack"
+	case Abort:
+		return "abort"
+	case Shutdown:
+		return "shutdown"
+	case ShutdownAck:
+		return "shutdown-ack"
+	case Error:
+		return "error"
+	case CookieEcho:
+		return "cookie-echo"
+	case CookieAck:
+		return "cookie-ack"
+	case ShutdownComplete:
+		return "shutdown-complete"
+	default:
+		return fmt.Sprintf("0x%x", uint8(t))
+	}
+}
+
+func (f DataFlags) String() string {
+	var flags []string
+
+	if f&End != 0 {
+		flags = append(flags, "end")
+	}
+
+	if f&Begin != 0 {
+		flags = append(flags, "begin")
+	}
+
+	if f&Unordered != 0 {
+		flags = append(flags, "unordered")
+	}
+
+	if f&Immediate != 0 {
+		flags = append(flags, "immediate")
+	}
+
+	return strings.Join(flags, "|")
+}
@@ -0,0 +1,261 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for SCTP packets.
+package sctp
+
+import "encoding/binary"
+import "hash/crc32"
+import "reflect"
+import "strings"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type Packet struct {
+	SrcPort     uint16 `string:"sport"`
+	DstPort     uint16 `string:"dport"`
+	Tag         uint32 `string:"vtag"`
+	Checksum    uint32 `string:"sum"`
+	Chunks      Chunks
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+type Chunks []Chunk
+
+var crc32c_table = crc32.MakeTable(crc32.Castagnoli)
+
+func Make() *Packet {
+	return &Packet{}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.SCTP
+}
+
+func (p *Packet) GetLength() uint16 {
+	length := uint16(12)
+
+	for _, c := range p.Chunks {
+		length += 4 + pad(c.length())
+	}
+
+	return length
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	if other == nil || other.GetType() != packet.SCTP {
+		return false
+	}
+
+	if p.SrcPort != other.(*Packet).DstPort ||
+		p.DstPort != other.(*Packet).SrcPort {
+		return false
+	}
+
+	init, ok := other.(*Packet).FindChunk(Init).(*InitChunk)
+	if !ok {
+		return true
+	}
+
+	/* the INIT-ACK is sent with the initiate tag of the INIT */
+	return p.FindChunk(InitAck) != nil && p.Tag == init.InitTag
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	buf.WriteN(p.SrcPort)
+	buf.WriteN(p.DstPort)
+	buf.WriteN(p.Tag)
+	buf.WriteN(uint32(0x00))
+
+	for _, c := range p.Chunks {
+		length := c.length()
+
+		buf.WriteN(c.Type())
+		buf.WriteN(c.flags())
+		buf.WriteN(4 + length)
+
+		c.pack(buf)
+
+		for i := length; i < pad(length); i++ {
+			buf.WriteN(uint8(0x00))
+		}
+	}
+
+	p.Checksum = CalculateChecksum(buf.LayerBytes()[:buf.LayerLen()])
+	binary.LittleEndian.PutUint32(buf.LayerBytes()[8:], p.Checksum)
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	buf.ReadN(&p.SrcPort)
+	buf.ReadN(&p.DstPort)
+	buf.ReadN(&p.Tag)
+	buf.ReadL(&p.Checksum)
+
+	p.Chunks = nil
+
+	for buf.Len() >= 4 {
+		var typ ChunkType
+		buf.ReadN(&typ)
+
+		var flags uint8
+		buf.ReadN(&flags)
+
+		var length uint16
+		buf.ReadN(&length)
+
+		/* most likely link-layer padding */
+		if length < 4 {
+			break
+		}
+
+		var c Chunk
+
+		switch typ {
+		case Data:
+			c = &DataChunk{}
+		case Init:
+			c = &InitChunk{}
+		case InitAck:
+			c = &InitAckChunk{}
+		case SAck:
+			c = &SAckChunk{}
+		case Heartbeat:
+			c = &HeartbeatChunk{}
+		case HeartbeatAck:
+			c = &HeartbeatAckChunk{}
+		case Abort:
+			c = &AbortChunk{}
+		case Shutdown:
+			c = &ShutdownChunk{}
+		case ShutdownAck:
+			c = &ShutdownAckChunk{}
+		case Error:
+			c = &ErrorChunk{}
+		case CookieEcho:
+			c = &CookieEchoChunk{}
+		case CookieAck:
+			c = &CookieAckChunk{}
+		case ShutdownComplete:
+			c = &ShutdownCompleteChunk{}
+		default:
+			c = &RawChunk{ChunkType: typ}
+		}
+
+		var value packet.Buffer
+		value.Init(buf.Next(int(length) - 4))
+
+		err := c.unpack(&value, flags)
+		if err != nil {
+			return err
+		}
+
+		p.Chunks = append(p.Chunks, c)
+
+		buf.Next(int(pad(length-4) - (length - 4)))
+	}
+
+	return nil
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	return packet.None
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+// Return the first chunk of the given type, or nil if the packet doesn't
+// contain one.
+func (p *Packet) FindChunk(typ ChunkType) Chunk {
+	for _, c := range p.Chunks {
+		if c.Type() == typ {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// Check whether the given chunks have the same types and contents. This is
+// used by Equals(), as chunks are stored as interfaces.
+func (c Chunks) Equal(other Chunks) bool {
+	if len(c) != len(other) {
+		return false
+	}
+
+	for i := range c {
+		if c[i].Type() != other[i].Type() ||
+			!reflect.DeepEqual(c[i], other[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c Chunks) String() string {
+	var chunks []string
+
+	for _, chunk := range c {
+		chunks = append(chunks, chunk.Type().String())
+	}
+
+	return strings.Join(chunks, "|")
+}
+
+// Calculate the CRC32c checksum of the given SCTP packet. The checksum field
+// of the packet must be set to zero.
+func CalculateChecksum(raw_bytes []byte) uint32 {
+	return crc32.Checksum(raw_bytes, crc32c_table)
+}
+
+func pad(length uint16) uint16 {
+	return (length + 3) &^ 3
+}
@@ -0,0 +1,207 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package sctp_test
+
+import "bytes"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/sctp"
+
+var test_simple = []byte{
+	0x13, 0x88, 0x0f, 0x1c, 0x00, 0x00, 0x00, 0x00, 0xed, 0x49, 0x4c, 0xa7,
+	0x01, 0x00, 0x00, 0x1a, 0x12, 0x34, 0x56, 0x78, 0x00, 0x01, 0x00, 0x00,
+	0x00, 0x0a, 0xff, 0xff, 0x00, 0x00, 0x00, 0x01, 0x00, 0x0c, 0x00, 0x06,
+	0x00, 0x05, 0x00, 0x00,
+}
+
+func MakeTestSimple() *sctp.Packet {
+	return &sctp.Packet{
+		SrcPort:  5000,
+		DstPort:  3868,
+		Checksum: 0xa74c49ed,
+		Chunks: sctp.Chunks{
+			&sctp.InitChunk{
+				InitTag:    0x12345678,
+				Window:     65536,
+				OutStreams: 10,
+				InStreams:  65535,
+				InitTSN:    1,
+				Params: []sctp.Param{
+					{sctp.SupportedAddrTypes, []byte{0x00, 0x05}},
+				},
+			},
+		},
+	}
+}
+
+func TestPack(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_simple, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPack(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	var p sctp.Packet
+
+	cmp := MakeTestSimple()
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpack(bn *testing.B) {
+	var p sctp.Packet
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_simple)
+		p.Unpack(&b)
+	}
+}
+
+func TestPackUnpackDataSAck(t *testing.T) {
+	p := sctp.Make()
+	p.SrcPort = 36412
+	p.DstPort = 36412
+	p.Tag = 0xdeadbeef
+	p.Chunks = sctp.Chunks{
+		&sctp.DataChunk{
+			Flags:    sctp.Begin | sctp.End,
+			TSN:      42,
+			StreamID: 1,
+			PPID:     18,
+			Data:     []byte{0x00, 0x11, 0x00},
+		},
+		&sctp.SAckChunk{
+			CumTSN:    41,
+			Window:    1024,
+			GapBlocks: []sctp.GapBlock{{2, 3}},
+			DupTSNs:   []uint32{40},
+		},
+		&sctp.ShutdownCompleteChunk{Reflected: true},
+	}
+
+	var b packet.Buffer
+	b.Init(make([]byte, p.GetLength()))
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if len(b.Buffer()) != 60 {
+		t.Fatalf("Length mismatch: %d", len(b.Buffer()))
+	}
+
+	var q sctp.Packet
+	b.Init(b.Buffer())
+
+	err = q.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !q.Equals(p) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &q, p)
+	}
+
+	q.Chunks[0].(*sctp.DataChunk).TSN = 43
+
+	if q.Equals(p) {
+		t.Fatalf("Chunk mismatch not detected")
+	}
+}
+
+func TestAnswers(t *testing.T) {
+	init_pkt := MakeTestSimple()
+
+	ack_pkt := sctp.Make()
+	ack_pkt.SrcPort = 3868
+	ack_pkt.DstPort = 5000
+	ack_pkt.Tag = 0x12345678
+	ack_pkt.Chunks = sctp.Chunks{
+		&sctp.InitAckChunk{
+			sctp.InitChunk{
+				InitTag: 0x87654321,
+				Params: []sctp.Param{
+					{sctp.StateCookie, []byte("cookie")},
+				},
+			},
+		},
+	}
+
+	if !ack_pkt.Answers(init_pkt) {
+		t.Fatalf("INIT-ACK does not answer INIT")
+	}
+
+	ack_pkt.Tag = 0
+
+	if ack_pkt.Answers(init_pkt) {
+		t.Fatalf("INIT-ACK with wrong tag answers INIT")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	if sctp.CalculateChecksum([]byte("123456789")) != 0xe3069283 {
+		t.Fatalf("CRC32c mismatch")
+	}
+}
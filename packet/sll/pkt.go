@@ -121,7 +121,7 @@ func (p *Packet) GuessPayloadType() packet.Type {
 
 func (p *Packet) SetPayload(pl packet.Packet) error {
 	p.pkt_payload = pl
-	p.EtherType = eth.TypeToEtherType(pl.GetType())
+	p.EtherType = eth.PayloadToEtherType(p.EtherType, pl)
 
 	return nil
 }
@@ -41,6 +41,14 @@ type Packet struct {
 	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
 }
 
+var (
+	EncapsulatedEthernet = [3]byte{0x00, 0x00, 0x00}
+	Cisco                = [3]byte{0x00, 0x00, 0x0c}
+)
+
+/* protocol types under the Cisco OUI */
+const CDP eth.EtherType = 0x2000
+
 func Make() *Packet {
 	return &Packet{}
 }
@@ -84,16 +92,29 @@ func (p *Packet) Payload() packet.Packet {
 }
 
 func (p *Packet) GuessPayloadType() packet.Type {
-	if p.OUI[0] == 0x00 && p.OUI[1] == 0x00 && p.OUI[2] == 0x00 {
+	switch {
+	case p.OUI == EncapsulatedEthernet:
 		return eth.EtherTypeToType(p.Type)
-	} else {
+
+	case p.OUI == Cisco && p.Type == CDP:
+		return packet.CDP
+
+	default:
 		return packet.Raw
 	}
 }
 
 func (p *Packet) SetPayload(pl packet.Packet) error {
 	p.pkt_payload = pl
-	p.Type = eth.TypeToEtherType(pl.GetType())
+
+	switch pl.GetType() {
+	case packet.CDP:
+		p.OUI = Cisco
+		p.Type = CDP
+
+	default:
+		p.Type = eth.PayloadToEtherType(p.Type, pl)
+	}
 
 	return nil
 }
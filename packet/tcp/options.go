@@ -0,0 +1,297 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package tcp
+
+import "encoding/binary"
+
+type Option struct {
+	Type OptType
+	Len  uint8
+	Data []byte
+}
+
+type OptType uint8
+
+const (
+	End         OptType = 0x00
+	Nop                 = 0x01
+	MSS                 = 0x02
+	WindowScale         = 0x03
+	SAckOk              = 0x04
+	SAck                = 0x05
+	Timestamp           = 0x08
+	MD5Sig              = 0x13
+	TCPAO               = 0x1d
+	MPTCP               = 0x1e
+	FastOpen            = 0x22
+)
+
+// Edges of a block of data selectively acknowledged by the SAck option.
+type SAckBlock struct {
+	Left  uint32
+	Right uint32
+}
+
+// Subtype of the Multipath TCP option (RFC8684).
+type MPTCPSubtype uint8
+
+const (
+	MPCapable   MPTCPSubtype = 0x0
+	MPJoin                   = 0x1
+	DSS                      = 0x2
+	AddAddr                  = 0x3
+	RemoveAddr               = 0x4
+	MPPrio                   = 0x5
+	MPFail                   = 0x6
+	MPFastClose              = 0x7
+	MPTCPRst                 = 0x8
+)
+
+/* maximum length of the options area */
+const max_opts_len = 40
+
+// Create a new Maximum Segment Size option.
+func MakeMSS(mss uint16) Option {
+	return Option{
+		Type: MSS,
+		Len:  4,
+		Data: []byte{byte(mss >> 8), byte(mss)},
+	}
+}
+
+// Create a new Window Scale option with the given shift count.
+func MakeWindowScale(shift uint8) Option {
+	return Option{Type: WindowScale, Len: 3, Data: []byte{shift}}
+}
+
+// Create a new SACK-Permitted option.
+func MakeSAckOk() Option {
+	return Option{Type: SAckOk, Len: 2}
+}
+
+// Create a new SAck option for the given blocks (at most 4).
+func MakeSAck(blocks ...SAckBlock) Option {
+	data := make([]byte, len(blocks)*8)
+
+	for i, block := range blocks {
+		binary.BigEndian.PutUint32(data[i*8:], block.Left)
+		binary.BigEndian.PutUint32(data[i*8+4:], block.Right)
+	}
+
+	return Option{Type: SAck, Len: uint8(2 + len(data)), Data: data}
+}
+
+// Create a new Timestamp option with the given timestamp value and echo reply.
+func MakeTimestamp(val, echo uint32) Option {
+	data := make([]byte, 8)
+
+	binary.BigEndian.PutUint32(data, val)
+	binary.BigEndian.PutUint32(data[4:], echo)
+
+	return Option{Type: Timestamp, Len: 10, Data: data}
+}
+
+// Create a new TCP Fast Open option (RFC7413). An empty cookie requests a new
+// cookie from the server.
+func MakeFastOpen(cookie []byte) Option {
+	return Option{Type: FastOpen, Len: uint8(2 + len(cookie)), Data: cookie}
+}
+
+// Create a new Multipath TCP option of the given subtype. The lower 4 bits of
+// the first byte of data are the subtype specific bits that follow the
+// subtype (e.g. the version of MP_CAPABLE).
+func MakeMPTCP(subtype MPTCPSubtype, data []byte) Option {
+	opt_data := make([]byte, 1)
+
+	if len(data) > 0 {
+		opt_data = append([]byte(nil), data...)
+	}
+
+	opt_data[0] = uint8(subtype)<<4 | opt_data[0]&0x0f
+
+	return Option{Type: MPTCP, Len: uint8(2 + len(opt_data)), Data: opt_data}
+}
+
+// Create a new MP_CAPABLE option with the given version, flags and keys. A SYN
+// carries no key, the SYN/ACK the key of the receiver and the third ACK both
+// the key of the sender and the one of the receiver.
+func MakeMPCapable(version, flags uint8, keys ...uint64) Option {
+	data := make([]byte, 2+len(keys)*8)
+	data[0] = version & 0x0f
+	data[1] = flags
+
+	for i, key := range keys {
+		binary.BigEndian.PutUint64(data[2+i*8:], key)
+	}
+
+	return MakeMPTCP(MPCapable, data)
+}
+
+// Create a new TCP MD5 Signature option (RFC2385) with the given digest.
+func MakeMD5Sig(digest []byte) Option {
+	return Option{Type: MD5Sig, Len: uint8(2 + len(digest)), Data: digest}
+}
+
+// Create a new TCP Authentication option (RFC5925) with the given key IDs and
+// message authentication code.
+func MakeTCPAO(key_id, rnext_key_id uint8, mac []byte) Option {
+	data := append([]byte{key_id, rnext_key_id}, mac...)
+
+	return Option{Type: TCPAO, Len: uint8(2 + len(data)), Data: data}
+}
+
+// Return the first option of the given type.
+func (p *Packet) FindOption(typ OptType) (Option, bool) {
+	for _, opt := range p.Options {
+		if opt.Type == typ {
+			return opt, true
+		}
+	}
+
+	return Option{}, false
+}
+
+// Return the value of a Maximum Segment Size option.
+func (o Option) MSS() uint16 {
+	if o.Type != MSS || len(o.Data) < 2 {
+		return 0
+	}
+
+	return binary.BigEndian.Uint16(o.Data)
+}
+
+// Return the shift count of a Window Scale option.
+func (o Option) WindowScale() uint8 {
+	if o.Type != WindowScale || len(o.Data) < 1 {
+		return 0
+	}
+
+	return o.Data[0]
+}
+
+// Return the blocks of a SAck option.
+func (o Option) SAckBlocks() []SAckBlock {
+	var blocks []SAckBlock
+
+	if o.Type != SAck {
+		return nil
+	}
+
+	for i := 0; i+8 <= len(o.Data); i += 8 {
+		blocks = append(blocks, SAckBlock{
+			Left:  binary.BigEndian.Uint32(o.Data[i:]),
+			Right: binary.BigEndian.Uint32(o.Data[i+4:]),
+		})
+	}
+
+	return blocks
+}
+
+// Return the timestamp value and echo reply of a Timestamp option.
+func (o Option) Timestamps() (uint32, uint32) {
+	if o.Type != Timestamp || len(o.Data) < 8 {
+		return 0, 0
+	}
+
+	return binary.BigEndian.Uint32(o.Data), binary.BigEndian.Uint32(o.Data[4:])
+}
+
+// Return the cookie of a TCP Fast Open option.
+func (o Option) FastOpenCookie() []byte {
+	if o.Type != FastOpen {
+		return nil
+	}
+
+	return o.Data
+}
+
+// Return the subtype of a Multipath TCP option.
+func (o Option) MPTCPSubtype() MPTCPSubtype {
+	if o.Type != MPTCP || len(o.Data) < 1 {
+		return 0
+	}
+
+	return MPTCPSubtype(o.Data[0] >> 4)
+}
+
+// Return the digest of a TCP MD5 Signature option.
+func (o Option) MD5Sig() []byte {
+	if o.Type != MD5Sig {
+		return nil
+	}
+
+	return o.Data
+}
+
+// Return the key ID, the receive next key ID and the message authentication
+// code of a TCP Authentication option.
+func (o Option) TCPAO() (uint8, uint8, []byte) {
+	if o.Type != TCPAO || len(o.Data) < 2 {
+		return 0, 0, nil
+	}
+
+	return o.Data[0], o.Data[1], o.Data[2:]
+}
+
+/* check the length of an option against the one defined for its type */
+func (o Option) valid_len() bool {
+	switch o.Type {
+	case MSS:
+		return o.Len == 4
+
+	case WindowScale:
+		return o.Len == 3
+
+	case SAckOk:
+		return o.Len == 2
+
+	case SAck:
+		return o.Len >= 10 && o.Len <= 34 && (o.Len-2)%8 == 0
+
+	case Timestamp:
+		return o.Len == 10
+
+	case MD5Sig:
+		return o.Len == 18
+
+	case TCPAO:
+		return o.Len >= 4
+
+	case MPTCP:
+		return o.Len >= 3
+
+	case FastOpen:
+		return o.Len == 2 ||
+			(o.Len >= 6 && o.Len <= 18 && o.Len%2 == 0)
+	}
+
+	return o.Len >= 2
+}
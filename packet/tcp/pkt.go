@@ -31,9 +31,12 @@
 // Provides encoding and decoding for TCP packets.
 package tcp
 
+import "encoding/binary"
+import "fmt"
 import "strings"
 
 import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/dns"
 import "github.com/scs-solution/go.pkt2/packet/ipv4"
 
 type Packet struct {
@@ -48,6 +51,7 @@ type Packet struct {
 	Urgent      uint16        `string:"urg"`
 	Options     []Option      `cmp:"skip" string:"skip"`
 	csum_seed   uint32        `cmp:"skip" string:"skip"`
+	pl_data     []byte        `cmp:"skip" string:"skip"`
 	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
 }
 
@@ -65,24 +69,6 @@ const (
 	NS        = 1 << 9
 )
 
-type Option struct {
-	Type OptType
-	Len  uint8
-	Data []byte
-}
-
-type OptType uint8
-
-const (
-	End         OptType = 0x00
-	Nop                 = 0x01
-	MSS                 = 0x02
-	WindowScale         = 0x03
-	SAckOk              = 0x04
-	SAck                = 0x05
-	Timestamp           = 0x08
-)
-
 func Make() *Packet {
 	return &Packet{
 		Flags:      Syn,
@@ -97,10 +83,26 @@ func (p *Packet) GetType() packet.Type {
 
 func (p *Packet) GetLength() uint16 {
 	if p.pkt_payload != nil {
-		return p.pkt_payload.GetLength() + uint16(p.DataOff)*4
+		return p.pkt_payload.GetLength() + p.header_len()
+	}
+
+	return p.header_len()
+}
+
+func (p *Packet) header_len() uint16 {
+	length := uint16(20)
+
+	for _, opt := range p.Options {
+		switch opt.Type {
+		case End, Nop:
+			length += 1
+
+		default:
+			length += 2 + uint16(len(opt.Data))
+		}
 	}
 
-	return uint16(p.DataOff) * 4
+	return (length + 3) &^ 3
 }
 
 func (p *Packet) Equals(other packet.Packet) bool {
@@ -121,6 +123,13 @@ func (p *Packet) Answers(other packet.Packet) bool {
 }
 
 func (p *Packet) Pack(buf *packet.Buffer) error {
+	hdr_len := int(p.header_len())
+	if hdr_len > 20+max_opts_len {
+		return fmt.Errorf("Invalid options length: %d", hdr_len-20)
+	}
+
+	p.DataOff = uint8(hdr_len / 4)
+
 	buf.WriteN(p.SrcPort)
 	buf.WriteN(p.DstPort)
 	buf.WriteN(p.Seq)
@@ -172,8 +181,18 @@ func (p *Packet) Pack(buf *packet.Buffer) error {
 
 	for _, opt := range p.Options {
 		buf.WriteN(opt.Type)
-		buf.WriteN(opt.Len)
-		buf.WriteN(opt.Data)
+
+		if opt.Type == End || opt.Type == Nop {
+			continue
+		}
+
+		buf.WriteN(uint8(2 + len(opt.Data)))
+		buf.Write(opt.Data)
+	}
+
+	/* add padding */
+	for buf.LayerLen() < hdr_len {
+		buf.WriteN(uint8(End))
 	}
 
 	if p.csum_seed != 0 {
@@ -183,15 +202,14 @@ func (p *Packet) Pack(buf *packet.Buffer) error {
 
 	buf.PutUint16N(16, p.Checksum)
 
-	/* add padding */
-	for buf.LayerLen() < int(p.DataOff)*4 {
-		buf.WriteN(uint8(0x00))
-	}
-
 	return nil
 }
 
 func (p *Packet) Unpack(buf *packet.Buffer) error {
+	if buf.Len() < 20 {
+		return fmt.Errorf("Invalid header length: %d", buf.Len())
+	}
+
 	buf.ReadN(&p.SrcPort)
 	buf.ReadN(&p.DstPort)
 	buf.ReadN(&p.Seq)
@@ -202,6 +220,12 @@ func (p *Packet) Unpack(buf *packet.Buffer) error {
 
 	p.DataOff = offns >> 4
 
+	if p.DataOff < 5 || buf.Len()+13 < int(p.DataOff)*4 {
+		return fmt.Errorf("Invalid data offset: %d", p.DataOff)
+	}
+
+	p.Flags = 0
+
 	if offns&0x01 != 0 {
 		p.Flags |= NS
 	}
@@ -245,6 +269,8 @@ func (p *Packet) Unpack(buf *packet.Buffer) error {
 	buf.ReadN(&p.Checksum)
 	buf.ReadN(&p.Urgent)
 
+	p.Options = nil
+
 options:
 	for buf.LayerLen() < int(p.DataOff)*4 {
 		var opt_type OptType
@@ -255,12 +281,22 @@ options:
 			break options
 
 		case Nop: /* padding */
-			continue
+			p.Options = append(p.Options, Option{Type: Nop})
 
 		default:
 			opt := Option{Type: opt_type}
 
+			if buf.LayerLen()+1 > int(p.DataOff)*4 {
+				return fmt.Errorf("Invalid option: %d", opt_type)
+			}
+
 			buf.ReadN(&opt.Len)
+
+			if !opt.valid_len() ||
+				buf.LayerLen()+int(opt.Len)-2 > int(p.DataOff)*4 {
+				return fmt.Errorf("Invalid option length: %d", opt.Len)
+			}
+
 			opt.Data = buf.Next(int(opt.Len) - 2)
 
 			p.Options = append(p.Options, opt)
@@ -272,6 +308,9 @@ options:
 		buf.Next(int(p.DataOff)*4 - buf.LayerLen())
 	}
 
+	/* keep the payload around to validate the guessed payload type */
+	p.pl_data = buf.Bytes()
+
 	return nil
 }
 
@@ -280,7 +319,21 @@ func (p *Packet) Payload() packet.Packet {
 }
 
 func (p *Packet) GuessPayloadType() packet.Type {
-	return packet.Raw
+	t := PortToType(p.DstPort)
+	if t == packet.Raw {
+		t = PortToType(p.SrcPort)
+	}
+
+	/* only unpacked segments have a payload to check */
+	if p.pl_data == nil {
+		return t
+	}
+
+	if t == packet.DNS && !is_dns(p.pl_data) {
+		return packet.Raw
+	}
+
+	return t
 }
 
 func (p *Packet) SetPayload(pl packet.Packet) error {
@@ -338,3 +391,30 @@ func (f Flags) String() string {
 
 	return strings.Join(flags, "|")
 }
+
+var port_to_type_map = map[uint16]packet.Type{
+	53: packet.DNS,
+}
+
+// Create a new Type from the given well-known TCP port.
+func PortToType(port uint16) packet.Type {
+	if t, ok := port_to_type_map[port]; ok {
+		return t
+	}
+
+	return packet.Raw
+}
+
+/* Segments are not reassembled, so only decode DNS messages that fill exactly
+ * one segment, as told by their length prefix */
+func is_dns(data []byte) bool {
+	if len(data) < 2 {
+		return false
+	}
+
+	if int(binary.BigEndian.Uint16(data))+2 != len(data) {
+		return false
+	}
+
+	return dns.IsMessage(data[2:])
+}
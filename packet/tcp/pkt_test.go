@@ -253,3 +253,155 @@ func TestUnpackOptions(t *testing.T) {
 		t.Fatalf("Option WindowScale mismatch: %x", p.Options[3].Data)
 	}
 }
+
+var test_linux_syn = []byte{
+	0xa2, 0x5a, 0x00, 0x50, 0x3b, 0x9a, 0xca, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xa0, 0x02, 0xfa, 0xf0, 0x00, 0x00, 0x00, 0x00, 0x02, 0x04, 0x05, 0xb4,
+	0x04, 0x02, 0x08, 0x0a, 0x00, 0x01, 0xe2, 0x40, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x03, 0x03, 0x07,
+}
+
+func MakeTestLinuxSyn() *tcp.Packet {
+	p := tcp.Make()
+	p.SrcPort = 41562
+	p.DstPort = 80
+	p.Seq = 1000000000
+	p.WindowSize = 64240
+	p.Options = []tcp.Option{
+		tcp.MakeMSS(1460),
+		tcp.MakeSAckOk(),
+		tcp.MakeTimestamp(123456, 0),
+		{Type: tcp.Nop},
+		tcp.MakeWindowScale(7),
+	}
+
+	return p
+}
+
+func TestPackLinuxSyn(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_linux_syn)))
+
+	p := MakeTestLinuxSyn()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_linux_syn, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+
+	if p.DataOff != 10 {
+		t.Fatalf("Data offset mismatch: %d", p.DataOff)
+	}
+}
+
+func TestUnpackLinuxSyn(t *testing.T) {
+	var p tcp.Packet
+
+	var b packet.Buffer
+	b.Init(test_linux_syn)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	types := []tcp.OptType{
+		tcp.MSS, tcp.SAckOk, tcp.Timestamp, tcp.Nop, tcp.WindowScale,
+	}
+
+	if len(p.Options) != len(types) {
+		t.Fatalf("Options number mismatch: %d", len(p.Options))
+	}
+
+	for i, typ := range types {
+		if p.Options[i].Type != typ {
+			t.Fatalf("Option mismatch: %v", p.Options[i])
+		}
+	}
+
+	if p.Options[0].MSS() != 1460 || p.Options[4].WindowScale() != 7 {
+		t.Fatalf("Options mismatch: %v", p.Options)
+	}
+
+	val, echo := p.Options[2].Timestamps()
+	if val != 123456 || echo != 0 {
+		t.Fatalf("Timestamp mismatch: %d %d", val, echo)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	blocks := []tcp.SAckBlock{{Left: 1000, Right: 2000}, {Left: 3000, Right: 4000}}
+
+	opts := []tcp.Option{
+		tcp.MakeSAck(blocks...),
+		tcp.MakeFastOpen([]byte{0xde, 0xad, 0xbe, 0xef}),
+		tcp.MakeMPCapable(1, 0x81, 0x0102030405060708),
+	}
+
+	p := MakeTestSimple()
+	p.Options = opts
+
+	var b packet.Buffer
+	b.Init(make([]byte, p.GetLength()))
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	var u tcp.Packet
+	b.Init(b.Buffer())
+
+	err = u.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	sack, _ := u.FindOption(tcp.SAck)
+	if len(sack.SAckBlocks()) != 2 || sack.SAckBlocks()[1] != blocks[1] {
+		t.Fatalf("SAck mismatch: %v", sack.SAckBlocks())
+	}
+
+	tfo, _ := u.FindOption(tcp.FastOpen)
+	if !bytes.Equal(tfo.FastOpenCookie(), []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Fatalf("Fast Open mismatch: %x", tfo.FastOpenCookie())
+	}
+
+	mp, _ := u.FindOption(tcp.MPTCP)
+	if mp.MPTCPSubtype() != tcp.MPCapable || mp.Len != 12 {
+		t.Fatalf("MPTCP mismatch: %v", mp)
+	}
+
+	ao := tcp.MakeTCPAO(1, 2, make([]byte, 12))
+
+	key_id, rnext_key_id, mac := ao.TCPAO()
+	if ao.Len != 16 || key_id != 1 || rnext_key_id != 2 || len(mac) != 12 {
+		t.Fatalf("TCP-AO mismatch: %v", ao)
+	}
+
+	p.Options = append(p.Options, tcp.MakeMD5Sig(make([]byte, 16)))
+
+	err = p.Pack(&b)
+	if err == nil {
+		t.Fatalf("Packed options longer than 40 bytes")
+	}
+}
+
+func TestUnpackInvalidOption(t *testing.T) {
+	var p tcp.Packet
+
+	raw := append([]byte(nil), test_linux_syn...)
+	raw[21] = 0x05 /* MSS with wrong length */
+
+	var b packet.Buffer
+	b.Init(raw)
+
+	err := p.Unpack(&b)
+	if err == nil {
+		t.Fatalf("Unpacked invalid option: %s", &p)
+	}
+}
@@ -32,7 +32,9 @@
 package udp
 
 import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/dns"
 import "github.com/scs-solution/go.pkt2/packet/ipv4"
+import "github.com/scs-solution/go.pkt2/packet/wol"
 
 type Packet struct {
 	SrcPort     uint16        `string:"sport"`
@@ -40,6 +42,7 @@ type Packet struct {
 	Length      uint16        `string:"len"`
 	Checksum    uint16        `string:"sum"`
 	csum_seed   uint32        `cmp:"skip" string:"skip"`
+	pl_data     []byte        `cmp:"skip" string:"skip"`
 	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
 }
 
@@ -99,6 +102,9 @@ func (p *Packet) Unpack(buf *packet.Buffer) error {
 	buf.ReadN(&p.Length)
 	buf.ReadN(&p.Checksum)
 
+	/* keep the payload around to validate the guessed payload type */
+	p.pl_data = buf.Bytes()
+
 	return nil
 }
 
@@ -107,7 +113,28 @@ func (p *Packet) Payload() packet.Packet {
 }
 
 func (p *Packet) GuessPayloadType() packet.Type {
-	return packet.Raw
+	t := PortToType(p.DstPort)
+	if t == packet.Raw {
+		t = PortToType(p.SrcPort)
+	}
+
+	/* only unpacked datagrams have a payload to check */
+	if p.pl_data == nil {
+		return t
+	}
+
+	switch {
+	case t == packet.DNS && !dns.IsMessage(p.pl_data),
+		t == packet.L2TP && !is_l2tp(p.pl_data):
+		t = packet.Raw
+	}
+
+	/* magic packets can be sent to any port, so look at the content */
+	if t == packet.Raw && wol.IsMagic(p.pl_data) {
+		return packet.WoL
+	}
+
+	return t
 }
 
 func (p *Packet) SetPayload(pl packet.Packet) error {
@@ -124,3 +151,29 @@ func (p *Packet) InitChecksum(csum uint32) {
 func (p *Packet) String() string {
 	return packet.Stringify(p)
 }
+
+var port_to_type_map = map[uint16]packet.Type{
+	53:   packet.DNS,
+	1701: packet.L2TP,
+	5353: packet.DNS,
+}
+
+// Create a new Type from the given well-known UDP port.
+func PortToType(port uint16) packet.Type {
+	if t, ok := port_to_type_map[port]; ok {
+		return t
+	}
+
+	return packet.Raw
+}
+
+/* Check the version of L2TP headers, so that other traffic on the L2TP port is
+ * not mistaken for it */
+func is_l2tp(data []byte) bool {
+	if len(data) < 2 {
+		return false
+	}
+
+	version := data[1] & 0x0f
+	return version == 2 || version == 3
+}
@@ -34,8 +34,10 @@ import "bytes"
 import "net"
 import "testing"
 
+import "github.com/scs-solution/go.pkt2/layers"
 import "github.com/scs-solution/go.pkt2/packet"
 import "github.com/scs-solution/go.pkt2/packet/ipv4"
+import "github.com/scs-solution/go.pkt2/packet/raw"
 import "github.com/scs-solution/go.pkt2/packet/udp"
 
 var test_simple = []byte{
@@ -156,3 +158,62 @@ func TestUnpackWithIPv4(t *testing.T) {
 		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
 	}
 }
+
+/* Unpack an IPv4 datagram to the given port and return the UDP payload */
+func unpack_with_port(t *testing.T, port uint16, data []byte) packet.Packet {
+	ip4_pkt := ipv4.Make()
+	ip4_pkt.SrcAddr = net.ParseIP(ipsrc_str)
+	ip4_pkt.DstAddr = net.ParseIP(ipdst_str)
+
+	udp_pkt := udp.Make()
+	udp_pkt.SrcPort = 52134
+	udp_pkt.DstPort = port
+
+	raw_pkt := raw.Make()
+	raw_pkt.Data = data
+
+	buf, err := layers.Pack(ip4_pkt, udp_pkt, raw_pkt)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	pkt, err := layers.UnpackAll(buf, packet.IPv4)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	return layers.FindLayer(pkt, packet.UDP).Payload()
+}
+
+func TestUnpackNotL2TP(t *testing.T) {
+	pl := unpack_with_port(t, 1701, []byte{0x00, 0x05, 0x00, 0x00, 0x00, 0x00})
+
+	if pl == nil || pl.GetType() != packet.Raw {
+		t.Fatalf("Payload mismatch: %s", pl)
+	}
+
+	pl = unpack_with_port(t, 1701, []byte{0x00, 0x02, 0x00, 0x01, 0x00, 0x02})
+
+	if pl == nil || pl.GetType() != packet.L2TP {
+		t.Fatalf("Payload mismatch: %s", pl)
+	}
+}
+
+func TestUnpackDiscard(t *testing.T) {
+	pl := unpack_with_port(t, 9, []byte("hello world"))
+
+	if pl == nil || pl.GetType() != packet.Raw {
+		t.Fatalf("Payload mismatch: %s", pl)
+	}
+
+	magic := bytes.Repeat([]byte{0xff}, 6)
+	for i := 0; i < 16; i++ {
+		magic = append(magic, 0x4c, 0x72, 0xb9, 0x54, 0xe5, 0x3d)
+	}
+
+	pl = unpack_with_port(t, 9, magic)
+
+	if pl == nil || pl.GetType() != packet.WoL {
+		t.Fatalf("Payload mismatch: %s", pl)
+	}
+}
@@ -0,0 +1,162 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for UDP-Lite packets.
+//
+// The checksum only covers the first Coverage bytes of the packet (including
+// the header), or the whole packet if Coverage is 0.
+package udplite
+
+import "fmt"
+
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+import "github.com/scs-solution/go.pkt2/packet/udp"
+
+type Packet struct {
+	SrcPort     uint16        `string:"sport"`
+	DstPort     uint16        `string:"dport"`
+	Coverage    uint16        `string:"cov"`
+	Checksum    uint16        `string:"sum"`
+	csum_seed   uint32        `cmp:"skip" string:"skip"`
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+func Make() *Packet {
+	return &Packet{}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.UDPLite
+}
+
+func (p *Packet) GetLength() uint16 {
+	if p.pkt_payload != nil {
+		return p.pkt_payload.GetLength() + 8
+	}
+
+	return 8
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	if other == nil || other.GetType() != packet.UDPLite {
+		return false
+	}
+
+	if p.SrcPort != other.(*Packet).DstPort ||
+		p.DstPort != other.(*Packet).SrcPort {
+		return false
+	}
+
+	return true
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	length := p.GetLength()
+
+	if p.Coverage != 0 && (p.Coverage < 8 || p.Coverage > length) {
+		return fmt.Errorf("Invalid checksum coverage: %d", p.Coverage)
+	}
+
+	buf.WriteN(p.SrcPort)
+	buf.WriteN(p.DstPort)
+	buf.WriteN(p.Coverage)
+	buf.WriteN(uint16(0x00))
+
+	if p.csum_seed != 0 {
+		covered := length
+		if p.Coverage != 0 {
+			covered = p.Coverage
+		}
+
+		p.Checksum = CalculateChecksum(buf.LayerBytes()[:covered],
+			p.csum_seed)
+	}
+
+	buf.PutUint16N(6, p.Checksum)
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	buf.ReadN(&p.SrcPort)
+	buf.ReadN(&p.DstPort)
+	buf.ReadN(&p.Coverage)
+	buf.ReadN(&p.Checksum)
+
+	return nil
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	if t := udp.PortToType(p.DstPort); t != packet.Raw {
+		return t
+	}
+
+	return udp.PortToType(p.SrcPort)
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+	p.csum_seed = csum
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+// Calculate the UDP-Lite checksum of the given covered bytes, seeded with the
+// IPv4/IPv6 pseudo-header checksum. Unlike UDP, a computed checksum of zero is
+// transmitted as all ones, since zero checksums are not allowed.
+func CalculateChecksum(covered []byte, csum uint32) uint16 {
+	/* pad odd coverage with a zero byte */
+	if len(covered)%2 != 0 {
+		csum += uint32(covered[len(covered)-1]) << 8
+	}
+
+	sum := ipv4.CalculateChecksum(covered[:len(covered)&^1], csum)
+	if sum == 0 {
+		sum = 0xffff
+	}
+
+	return sum
+}
@@ -0,0 +1,176 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package udplite_test
+
+import "bytes"
+import "net"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/layers"
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/ipv4"
+import "github.com/scs-solution/go.pkt2/packet/raw"
+import "github.com/scs-solution/go.pkt2/packet/udplite"
+
+var test_simple = []byte{
+	0xa2, 0x5a, 0x20, 0x92, 0x00, 0x08, 0xe9, 0x04,
+}
+
+func MakeTestSimple() *udplite.Packet {
+	return &udplite.Packet{
+		SrcPort:  41562,
+		DstPort:  8338,
+		Coverage: 8,
+		Checksum: 0xe904,
+	}
+}
+
+func TestPack(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_simple, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPack(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	var p udplite.Packet
+
+	cmp := MakeTestSimple()
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpack(bn *testing.B) {
+	var p udplite.Packet
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_simple)
+		p.Unpack(&b)
+	}
+}
+
+var test_ipv4_full = []byte{
+	0x45, 0x00, 0x00, 0x21, 0x00, 0x01, 0x00, 0x00, 0x40, 0x88, 0x26, 0xe4,
+	0xc0, 0xa8, 0x01, 0x87, 0xc1, 0x1b, 0xd0, 0x25, 0xa2, 0x5a, 0x20, 0x92,
+	0x00, 0x00, 0xa5, 0x3a, 0x68, 0x65, 0x6c, 0x6c, 0x6f,
+}
+
+var test_ipv4_partial = []byte{
+	0x45, 0x00, 0x00, 0x21, 0x00, 0x01, 0x00, 0x00, 0x40, 0x88, 0x26, 0xe4,
+	0xc0, 0xa8, 0x01, 0x87, 0xc1, 0x1b, 0xd0, 0x25, 0xa2, 0x5a, 0x20, 0x92,
+	0x00, 0x08, 0xe9, 0x04, 0x68, 0x65, 0x6c, 0x6c, 0x6f,
+}
+
+func TestPackWithIPv4(t *testing.T) {
+	tests := []struct {
+		coverage uint16
+		raw      []byte
+	}{
+		{0, test_ipv4_full},
+		{8, test_ipv4_partial},
+	}
+
+	for _, test := range tests {
+		ip4_pkt := ipv4.Make()
+		ip4_pkt.SrcAddr = net.ParseIP("192.168.1.135")
+		ip4_pkt.DstAddr = net.ParseIP("193.27.208.37")
+
+		udplite_pkt := udplite.Make()
+		udplite_pkt.SrcPort = 41562
+		udplite_pkt.DstPort = 8338
+		udplite_pkt.Coverage = test.coverage
+
+		raw_pkt := raw.Make()
+		raw_pkt.Data = []byte("hello")
+
+		buf, err := layers.Pack(ip4_pkt, udplite_pkt, raw_pkt)
+		if err != nil {
+			t.Fatalf("Error packing: %s", err)
+		}
+
+		if !bytes.Equal(test.raw, buf) {
+			t.Fatalf("Raw packet mismatch: %x", buf)
+		}
+
+		pkt, err := layers.UnpackAll(buf, packet.IPv4)
+		if err != nil {
+			t.Fatalf("Error unpacking: %s", err)
+		}
+
+		pkt = layers.FindLayer(pkt, packet.UDPLite)
+		if pkt == nil || !pkt.Equals(udplite_pkt) {
+			t.Fatalf("Packet mismatch: %s", pkt)
+		}
+	}
+}
+
+func TestPackInvalidCoverage(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, 8))
+
+	p := udplite.Make()
+	p.Coverage = 4
+
+	if p.Pack(&b) == nil {
+		t.Fatalf("Invalid coverage accepted")
+	}
+}
@@ -29,6 +29,11 @@
  */
 
 // Provides encoding and decoding for VLAN packets.
+//
+// Both 802.1Q customer tags and 802.1ad service tags are supported. The tag
+// protocol identifier (TPID) of a tag is carried by the header that precedes
+// it (e.g. the Type field of the Ethernet header, or of the outer tag for
+// stacked tags), and is set automatically when composing packets.
 package vlan
 
 import "github.com/scs-solution/go.pkt2/packet"
@@ -82,7 +87,7 @@ func (p *Packet) Answers(other packet.Packet) bool {
 func (p *Packet) Pack(buf *packet.Buffer) error {
 	tci := uint16(p.Priority)<<13 | p.VLAN
 	if p.DropEligible {
-		tci |= 0x10
+		tci |= 0x1000
 	}
 
 	buf.WriteN(tci)
@@ -96,7 +101,7 @@ func (p *Packet) Unpack(buf *packet.Buffer) error {
 	buf.ReadN(&tci)
 
 	p.Priority = (uint8(tci>>8) & 0xE0) >> 5
-	p.DropEligible = uint8(tci)&0x10 != 0
+	p.DropEligible = tci&0x1000 != 0
 	p.VLAN = tci & 0x0FFF
 
 	buf.ReadN(&p.Type)
@@ -114,7 +119,7 @@ func (p *Packet) GuessPayloadType() packet.Type {
 
 func (p *Packet) SetPayload(pl packet.Packet) error {
 	p.pkt_payload = pl
-	p.Type = eth.TypeToEtherType(pl.GetType())
+	p.Type = eth.PayloadToEtherType(p.Type, pl)
 
 	return nil
 }
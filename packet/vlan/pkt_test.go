@@ -103,3 +103,34 @@ func BenchmarkUnpack(bn *testing.B) {
 		p.Unpack(&b)
 	}
 }
+
+func TestDropEligible(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, 4))
+
+	p := vlan.Make()
+	p.VLAN = 16
+	p.DropEligible = true
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal([]byte{0x10, 0x10, 0x00, 0x00}, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+
+	var q vlan.Packet
+
+	b.Init(b.Buffer())
+
+	err = q.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !q.Equals(p) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &q, p)
+	}
+}
@@ -0,0 +1,344 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package wifi
+
+import "fmt"
+import "net"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+// Body is the interface implemented by the fixed parameters of management
+// frames (e.g. *BeaconBody).
+type Body interface {
+	length() uint16
+	pack(buf *packet.Buffer)
+	unpack(buf *packet.Buffer) error
+}
+
+// Fixed parameters of beacons and probe responses.
+type BeaconBody struct {
+	Timestamp  uint64
+	Interval   uint16
+	Capability Capability
+}
+
+type AssocReqBody struct {
+	Capability     Capability
+	ListenInterval uint16
+}
+
+type ReassocReqBody struct {
+	Capability     Capability
+	ListenInterval uint16
+	CurrentAP      net.HardwareAddr
+}
+
+// Fixed parameters of association and reassociation responses.
+type AssocRespBody struct {
+	Capability Capability
+	Status     uint16
+	AID        uint16
+}
+
+type AuthBody struct {
+	Algorithm uint16
+	Seq       uint16
+	Status    uint16
+}
+
+// Fixed parameters of deauthentication and disassociation frames.
+type DeauthBody struct {
+	Reason uint16
+}
+
+type ActionBody struct {
+	Category uint8
+	Data     []byte
+}
+
+type Capability uint16
+
+const (
+	ESS           Capability = 1 << 0
+	IBSS                     = 1 << 1
+	Privacy                  = 1 << 4
+	ShortPreamble            = 1 << 5
+	SpectrumMgmt             = 1 << 8
+	QoS                      = 1 << 9
+	ShortSlot                = 1 << 10
+	RadioMeasure             = 1 << 12
+)
+
+// Information element.
+type IE struct {
+	ID   IEID
+	Data []byte
+}
+
+type IEID uint8
+
+const (
+	SSIDIE           IEID = 0
+	SupportedRatesIE      = 1
+	DSParamsIE            = 3
+	TIMIE                 = 5
+	CountryIE             = 7
+	BSSLoadIE             = 11
+	ChallengeIE           = 16
+	ERPIE                 = 42
+	HTCapsIE              = 45
+	RSNIE                 = 48
+	ExtRatesIE            = 50
+	HTOperationIE         = 61
+	ExtCapsIE             = 127
+	VHTCapsIE             = 191
+	VHTOperationIE        = 192
+	VendorIE              = 221
+	ExtensionIE           = 255
+)
+
+// Create a new beacon body with the usual 100 TU interval.
+func MakeBeacon() *BeaconBody {
+	return &BeaconBody{
+		Interval:   100,
+		Capability: ESS,
+	}
+}
+
+func (b *BeaconBody) length() uint16 {
+	return 12
+}
+
+func (b *BeaconBody) pack(buf *packet.Buffer) {
+	buf.WriteL(b.Timestamp)
+	buf.WriteL(b.Interval)
+	buf.WriteL(b.Capability)
+}
+
+func (b *BeaconBody) unpack(buf *packet.Buffer) error {
+	if buf.Len() < 12 {
+		return fmt.Errorf("Invalid beacon length: %d", buf.Len())
+	}
+
+	buf.ReadL(&b.Timestamp)
+	buf.ReadL(&b.Interval)
+	buf.ReadL(&b.Capability)
+
+	return nil
+}
+
+func (b *AssocReqBody) length() uint16 {
+	return 4
+}
+
+func (b *AssocReqBody) pack(buf *packet.Buffer) {
+	buf.WriteL(b.Capability)
+	buf.WriteL(b.ListenInterval)
+}
+
+func (b *AssocReqBody) unpack(buf *packet.Buffer) error {
+	buf.ReadL(&b.Capability)
+	buf.ReadL(&b.ListenInterval)
+
+	return nil
+}
+
+func (b *ReassocReqBody) length() uint16 {
+	return 10
+}
+
+func (b *ReassocReqBody) pack(buf *packet.Buffer) {
+	buf.WriteL(b.Capability)
+	buf.WriteL(b.ListenInterval)
+	write_addr(buf, b.CurrentAP)
+}
+
+func (b *ReassocReqBody) unpack(buf *packet.Buffer) error {
+	buf.ReadL(&b.Capability)
+	buf.ReadL(&b.ListenInterval)
+	b.CurrentAP = read_addr(buf)
+
+	return nil
+}
+
+func (b *AssocRespBody) length() uint16 {
+	return 6
+}
+
+func (b *AssocRespBody) pack(buf *packet.Buffer) {
+	buf.WriteL(b.Capability)
+	buf.WriteL(b.Status)
+	buf.WriteL(b.AID | 0xc000)
+}
+
+func (b *AssocRespBody) unpack(buf *packet.Buffer) error {
+	buf.ReadL(&b.Capability)
+	buf.ReadL(&b.Status)
+	buf.ReadL(&b.AID)
+
+	/* the two most significant bits are always set */
+	b.AID &= 0x3fff
+
+	return nil
+}
+
+func (b *AuthBody) length() uint16 {
+	return 6
+}
+
+func (b *AuthBody) pack(buf *packet.Buffer) {
+	buf.WriteL(b.Algorithm)
+	buf.WriteL(b.Seq)
+	buf.WriteL(b.Status)
+}
+
+func (b *AuthBody) unpack(buf *packet.Buffer) error {
+	buf.ReadL(&b.Algorithm)
+	buf.ReadL(&b.Seq)
+	buf.ReadL(&b.Status)
+
+	return nil
+}
+
+func (b *DeauthBody) length() uint16 {
+	return 2
+}
+
+func (b *DeauthBody) pack(buf *packet.Buffer) {
+	buf.WriteL(b.Reason)
+}
+
+func (b *DeauthBody) unpack(buf *packet.Buffer) error {
+	buf.ReadL(&b.Reason)
+
+	return nil
+}
+
+func (b *ActionBody) length() uint16 {
+	return 1 + uint16(len(b.Data))
+}
+
+func (b *ActionBody) pack(buf *packet.Buffer) {
+	buf.WriteN(b.Category)
+	buf.Write(b.Data)
+}
+
+func (b *ActionBody) unpack(buf *packet.Buffer) error {
+	buf.ReadN(&b.Category)
+	b.Data = buf.Next(buf.Len())
+
+	return nil
+}
+
+func (p *Packet) unpack_ies(buf *packet.Buffer) error {
+	for buf.Len() >= 2 {
+		var ie IE
+		buf.ReadN(&ie.ID)
+
+		var length uint8
+		buf.ReadN(&length)
+
+		if int(length) > buf.Len() {
+			return fmt.Errorf("Invalid IE length: %d", length)
+		}
+
+		ie.Data = buf.Next(int(length))
+
+		p.IEs = append(p.IEs, ie)
+	}
+
+	return nil
+}
+
+// Return the first information element with the given ID, or nil if not
+// present.
+func (p *Packet) FindIE(id IEID) *IE {
+	for i := range p.IEs {
+		if p.IEs[i].ID == id {
+			return &p.IEs[i]
+		}
+	}
+
+	return nil
+}
+
+// Return the SSID advertised or requested by the frame.
+func (p *Packet) SSID() string {
+	ie := p.FindIE(SSIDIE)
+	if ie == nil {
+		return ""
+	}
+
+	return string(ie.Data)
+}
+
+// Return the supported (and extended supported) rates, in units of 500 kbps.
+func (p *Packet) Rates() []uint8 {
+	var rates []uint8
+
+	for _, ie := range p.IEs {
+		if ie.ID != SupportedRatesIE && ie.ID != ExtRatesIE {
+			continue
+		}
+
+		for _, rate := range ie.Data {
+			rates = append(rates, rate&0x7f)
+		}
+	}
+
+	return rates
+}
+
+// Return the current channel from the DS parameter set, or 0 if not present.
+func (p *Packet) Channel() uint8 {
+	ie := p.FindIE(DSParamsIE)
+	if ie == nil || len(ie.Data) < 1 {
+		return 0
+	}
+
+	return ie.Data[0]
+}
+
+// Create a new SSID information element.
+func MakeSSID(ssid string) IE {
+	return IE{SSIDIE, []byte(ssid)}
+}
+
+// Create a new supported rates information element. Rates are in units of 500
+// kbps, with the most significant bit set for basic rates.
+func MakeRates(rates ...uint8) IE {
+	return IE{SupportedRatesIE, rates}
+}
+
+// Create a new DS parameter set information element.
+func MakeDSParams(channel uint8) IE {
+	return IE{DSParamsIE, []byte{channel}}
+}
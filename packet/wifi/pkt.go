@@ -0,0 +1,569 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for IEEE 802.11 frames.
+//
+// The fixed parameters of management frames are stored in the Body field
+// (e.g. a *Beacon for beacons and probe responses), while their information
+// elements are stored in the IEs field. Data frames carry their payload in the
+// next layer (usually LLC).
+//
+// When the frame is captured with its FCS (e.g. as reported by the radiotap
+// header), the HasFCS field must be set so that the FCS is removed from the
+// frame before decoding it. The FCS is not written when packing frames.
+package wifi
+
+import "encoding/binary"
+import "fmt"
+import "net"
+import "strings"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type Packet struct {
+	Version     uint8
+	Type        Type
+	Flags       Flags
+	Duration    uint16
+	Addr1       net.HardwareAddr
+	Addr2       net.HardwareAddr
+	Addr3       net.HardwareAddr
+	SeqNum      uint16
+	FragNum     uint8
+	Addr4       net.HardwareAddr
+	QoS         uint16
+	HTControl   uint32
+	FCS         uint32        `cmp:"skip" string:"fcs"`
+	HasFCS      bool          `cmp:"skip" string:"skip"`
+	Body        Body          `cmp:"skip" string:"skip"`
+	IEs         []IE          `string:"skip"`
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+// Frame type and subtype, encoded as type << 4 | subtype.
+type Type uint8
+
+const (
+	AssocReq    Type = 0x00
+	AssocResp        = 0x01
+	ReassocReq       = 0x02
+	ReassocResp      = 0x03
+	ProbeReq         = 0x04
+	ProbeResp        = 0x05
+	TimingAdv        = 0x06
+	Beacon           = 0x08
+	ATIM             = 0x09
+	Disassoc         = 0x0a
+	Auth             = 0x0b
+	Deauth           = 0x0c
+	Action           = 0x0d
+	ActionNoAck      = 0x0e
+
+	BlockAckReq = 0x18
+	BlockAck    = 0x19
+	PSPoll      = 0x1a
+	RTS         = 0x1b
+	CTS         = 0x1c
+	Ack         = 0x1d
+	CFEnd       = 0x1e
+	CFEndAck    = 0x1f
+
+	Data             = 0x20
+	DataCFAck        = 0x21
+	DataCFPoll       = 0x22
+	DataCFAckPoll    = 0x23
+	Null             = 0x24
+	CFAck            = 0x25
+	CFPoll           = 0x26
+	CFAckPoll        = 0x27
+	QoSData          = 0x28
+	QoSDataCFAck     = 0x29
+	QoSDataCFPoll    = 0x2a
+	QoSDataCFAckPoll = 0x2b
+	QoSNull          = 0x2c
+	QoSCFPoll        = 0x2e
+	QoSCFAckPoll     = 0x2f
+)
+
+// Frame class (the type part of Type).
+type Class uint8
+
+const (
+	Management Class = 0
+	Control          = 1
+	DataClass        = 2
+	Extension        = 3
+)
+
+type Flags uint8
+
+const (
+	ToDS      Flags = 1 << 0
+	FromDS          = 1 << 1
+	MoreFrag        = 1 << 2
+	Retry           = 1 << 3
+	PowerMgmt       = 1 << 4
+	MoreData        = 1 << 5
+	Protected       = 1 << 6
+	Order           = 1 << 7
+)
+
+func Make() *Packet {
+	return &Packet{
+		Type: Data,
+	}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.WiFi
+}
+
+func (p *Packet) GetLength() uint16 {
+	length := p.header_len()
+
+	if p.Body != nil {
+		length += p.Body.length()
+	}
+
+	for _, ie := range p.IEs {
+		length += 2 + uint16(len(ie.Data))
+	}
+
+	if p.pkt_payload != nil {
+		length += p.pkt_payload.GetLength()
+	}
+
+	return length
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	req, ok := other.(*Packet)
+	if !ok || !addr_equal(p.Addr1, req.Addr2) {
+		return false
+	}
+
+	switch p.Type {
+	case Ack:
+		return req.Type != Ack && req.Type != CTS
+
+	case CTS:
+		return req.Type == RTS
+
+	case ProbeResp:
+		return req.Type == ProbeReq
+
+	case AssocResp:
+		return req.Type == AssocReq
+
+	case ReassocResp:
+		return req.Type == ReassocReq
+
+	case Auth:
+		body, ok := p.Body.(*AuthBody)
+		req_body, req_ok := req.Body.(*AuthBody)
+
+		return req.Type == Auth && ok && req_ok &&
+			body.Seq == req_body.Seq+1
+	}
+
+	return false
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	buf.WriteN(uint8(p.Type&0x0f)<<4 | uint8(p.Type>>4)<<2 |
+		p.Version&0x03)
+	buf.WriteN(p.Flags)
+	buf.WriteL(p.Duration)
+
+	write_addr(buf, p.Addr1)
+
+	if p.has_addr2() {
+		write_addr(buf, p.Addr2)
+	}
+
+	if p.Type.Class() == Management || p.Type.Class() == DataClass {
+		write_addr(buf, p.Addr3)
+		buf.WriteL(p.SeqNum<<4 | uint16(p.FragNum&0x0f))
+	}
+
+	if p.has_addr4() {
+		write_addr(buf, p.Addr4)
+	}
+
+	if p.Type.IsQoS() {
+		buf.WriteL(p.QoS)
+	}
+
+	if p.has_ht_control() {
+		buf.WriteL(p.HTControl)
+	}
+
+	if p.Body != nil {
+		p.Body.pack(buf)
+	}
+
+	for _, ie := range p.IEs {
+		buf.WriteN(ie.ID)
+		buf.WriteN(uint8(len(ie.Data)))
+		buf.Write(ie.Data)
+	}
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	p.Body = nil
+	p.IEs = nil
+	p.Addr2 = nil
+	p.Addr3 = nil
+	p.Addr4 = nil
+	p.SeqNum = 0
+	p.FragNum = 0
+	p.QoS = 0
+	p.HTControl = 0
+	p.FCS = 0
+
+	if p.HasFCS {
+		if buf.Len() < 14 {
+			return fmt.Errorf("Invalid frame length: %d", buf.Len())
+		}
+
+		data := buf.Bytes()
+		p.FCS = binary.LittleEndian.Uint32(data[len(data)-4:])
+
+		buf.Truncate(len(data) - 4)
+	}
+
+	if buf.Len() < 10 {
+		return fmt.Errorf("Invalid frame length: %d", buf.Len())
+	}
+
+	var fc uint8
+	buf.ReadN(&fc)
+
+	p.Version = fc & 0x03
+	p.Type = Type((fc>>2)&0x03)<<4 | Type(fc>>4)
+
+	buf.ReadN(&p.Flags)
+	buf.ReadL(&p.Duration)
+
+	p.Addr1 = read_addr(buf)
+
+	if p.has_addr2() {
+		p.Addr2 = read_addr(buf)
+	}
+
+	if p.Type.Class() == Management || p.Type.Class() == DataClass {
+		p.Addr3 = read_addr(buf)
+
+		var seq uint16
+		buf.ReadL(&seq)
+
+		p.SeqNum = seq >> 4
+		p.FragNum = uint8(seq & 0x0f)
+	}
+
+	if p.has_addr4() {
+		p.Addr4 = read_addr(buf)
+	}
+
+	if p.Type.IsQoS() {
+		buf.ReadL(&p.QoS)
+	}
+
+	if p.has_ht_control() {
+		buf.ReadL(&p.HTControl)
+	}
+
+	if p.Type.Class() != Management || p.Flags&Protected != 0 {
+		return nil
+	}
+
+	switch p.Type {
+	case AssocReq:
+		p.Body = &AssocReqBody{}
+	case ReassocReq:
+		p.Body = &ReassocReqBody{}
+	case AssocResp, ReassocResp:
+		p.Body = &AssocRespBody{}
+	case ProbeResp, Beacon:
+		p.Body = &BeaconBody{}
+	case Auth:
+		p.Body = &AuthBody{}
+	case Deauth, Disassoc:
+		p.Body = &DeauthBody{}
+	case Action, ActionNoAck:
+		p.Body = &ActionBody{}
+	}
+
+	if p.Body != nil {
+		err := p.Body.unpack(buf)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch p.Type {
+	case Action, ActionNoAck, Deauth, Disassoc:
+		return nil
+	}
+
+	return p.unpack_ies(buf)
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	if p.Type.Class() != DataClass || p.Type&0x04 != 0 {
+		return packet.None
+	}
+
+	if p.Flags&Protected != 0 {
+		return packet.Raw
+	}
+
+	return packet.LLC
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+// Return the destination address of the frame, depending on the DS bits.
+func (p *Packet) DstAddr() net.HardwareAddr {
+	if p.Type.Class() == DataClass && p.Flags&ToDS != 0 {
+		return p.Addr3
+	}
+
+	return p.Addr1
+}
+
+// Return the source address of the frame, depending on the DS bits.
+func (p *Packet) SrcAddr() net.HardwareAddr {
+	if p.Type.Class() != DataClass {
+		return p.Addr2
+	}
+
+	switch p.Flags & (ToDS | FromDS) {
+	case FromDS:
+		return p.Addr3
+
+	case ToDS | FromDS:
+		return p.Addr4
+
+	default:
+		return p.Addr2
+	}
+}
+
+// Return the BSSID of the frame, or nil if the frame carries none (e.g. in
+// WDS frames or most control frames).
+func (p *Packet) BSSID() net.HardwareAddr {
+	switch p.Type.Class() {
+	case Management:
+		return p.Addr3
+
+	case DataClass:
+		switch p.Flags & (ToDS | FromDS) {
+		case 0:
+			return p.Addr3
+
+		case ToDS:
+			return p.Addr1
+
+		case FromDS:
+			return p.Addr2
+		}
+	}
+
+	return nil
+}
+
+// Return the traffic identifier of QoS data frames.
+func (p *Packet) TID() uint8 {
+	return uint8(p.QoS & 0x0f)
+}
+
+func (p *Packet) header_len() uint16 {
+	length := uint16(10)
+
+	if p.has_addr2() {
+		length += 6
+	}
+
+	if p.Type.Class() == Management || p.Type.Class() == DataClass {
+		length += 8
+	}
+
+	if p.has_addr4() {
+		length += 6
+	}
+
+	if p.Type.IsQoS() {
+		length += 2
+	}
+
+	if p.has_ht_control() {
+		length += 4
+	}
+
+	return length
+}
+
+func (p *Packet) has_addr2() bool {
+	return p.Type != CTS && p.Type != Ack
+}
+
+func (p *Packet) has_addr4() bool {
+	return p.Type.Class() == DataClass &&
+		p.Flags&(ToDS|FromDS) == ToDS|FromDS
+}
+
+func (p *Packet) has_ht_control() bool {
+	return p.Flags&Order != 0 &&
+		(p.Type.IsQoS() || p.Type.Class() == Management)
+}
+
+// Return the class of the frame type.
+func (t Type) Class() Class {
+	return Class(t >> 4)
+}
+
+// Return whether the frame type is a QoS data type.
+func (t Type) IsQoS() bool {
+	return t.Class() == DataClass && t&0x08 != 0
+}
+
+func write_addr(buf *packet.Buffer, addr net.HardwareAddr) {
+	if len(addr) != 6 {
+		addr = make(net.HardwareAddr, 6)
+	}
+
+	buf.Write(addr)
+}
+
+func read_addr(buf *packet.Buffer) net.HardwareAddr {
+	return net.HardwareAddr(buf.Next(6))
+}
+
+func addr_equal(a, b net.HardwareAddr) bool {
+	return len(a) == len(b) && string(a) == string(b)
+}
+
+func (t Type) String() string {
+	switch t {
+	case AssocReq:
+		return "assoc-req"
+	case AssocResp:
+		return "assoc-resp"
+	case ReassocReq:
+		return "reassoc-req"
+	case ReassocResp:
+		return "reassoc-resp"
+	case ProbeReq:
+		return "probe-req"
+	case ProbeResp:
+		return "probe-resp"
+	case TimingAdv:
+		return "timing-adv"
+	case Beacon:
+		return "beacon"
+	case ATIM:
+		return "atim"
+	case Disassoc:
+		return "disassoc"
+	case Auth:
+		return "auth"
+	case Deauth:
+		return "deauth"
+	case Action:
+		return "action"
+	case ActionNoAck:
+		return "action-noack"
+	case BlockAckReq:
+		return "block-ack-req"
+	case BlockAck:
+		return "block-ack"
+	case PSPoll:
+		return "ps-poll"
+	case RTS:
+		return "rts"
+	case CTS:
+		return "cts"
+	case Ack:
+		return "ack"
+	case CFEnd:
+		return "cf-end"
+	case CFEndAck:
+		return "cf-end-ack"
+	case Data:
+		return "data"
+	case Null:
+		return "null"
+	case QoSData:
+		return "qos-data"
+	case QoSNull:
+		return "qos-null"
+	}
+
+	return fmt.Sprintf("0x%02x", uint8(t))
+}
+
+func (f Flags) String() string {
+	var flags []string
+
+	names := []string{
+		"to-ds", "from-ds", "more-frag", "retry", "pwr-mgmt",
+		"more-data", "protected", "order",
+	}
+
+	for i, name := range names {
+		if f&(1<<uint(i)) != 0 {
+			flags = append(flags, name)
+		}
+	}
+
+	return strings.Join(flags, "|")
+}
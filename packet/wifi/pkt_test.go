@@ -0,0 +1,242 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+package wifi_test
+
+import "bytes"
+import "net"
+import "testing"
+
+import "github.com/scs-solution/go.pkt2/layers"
+import "github.com/scs-solution/go.pkt2/packet"
+import "github.com/scs-solution/go.pkt2/packet/wifi"
+
+var test_simple = []byte{
+	0x80, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x11,
+	0x22, 0x33, 0x44, 0x55, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x40, 0x06,
+	0x56, 0x34, 0x12, 0x00, 0x00, 0x00, 0x00, 0x00, 0x64, 0x00, 0x31, 0x04,
+	0x00, 0x04, 0x74, 0x65, 0x73, 0x74, 0x01, 0x04, 0x82, 0x84, 0x8b, 0x96,
+	0x03, 0x01, 0x06,
+}
+
+var bssid = net.HardwareAddr{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+
+func MakeTestSimple() *wifi.Packet {
+	return &wifi.Packet{
+		Type:   wifi.Beacon,
+		Addr1:  net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		Addr2:  bssid,
+		Addr3:  bssid,
+		SeqNum: 100,
+		Body: &wifi.BeaconBody{
+			Timestamp: 0x123456,
+			Interval:  100,
+			Capability: wifi.ESS | wifi.Privacy | wifi.ShortPreamble |
+				wifi.ShortSlot,
+		},
+		IEs: []wifi.IE{
+			wifi.MakeSSID("test"),
+			wifi.MakeRates(0x82, 0x84, 0x8b, 0x96),
+			wifi.MakeDSParams(6),
+		},
+	}
+}
+
+func TestPack(t *testing.T) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if !bytes.Equal(test_simple, b.Buffer()) {
+		t.Fatalf("Raw packet mismatch: %x", b.Buffer())
+	}
+}
+
+func BenchmarkPack(bn *testing.B) {
+	var b packet.Buffer
+	b.Init(make([]byte, len(test_simple)))
+
+	p := MakeTestSimple()
+
+	for n := 0; n < bn.N; n++ {
+		p.Pack(&b)
+	}
+}
+
+func TestUnpack(t *testing.T) {
+	var p wifi.Packet
+
+	cmp := MakeTestSimple()
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p.Equals(cmp) {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p, cmp)
+	}
+}
+
+func BenchmarkUnpack(bn *testing.B) {
+	var p wifi.Packet
+	var b packet.Buffer
+
+	for n := 0; n < bn.N; n++ {
+		b.Init(test_simple)
+		p.Unpack(&b)
+	}
+}
+
+func TestUnpackBeacon(t *testing.T) {
+	var p wifi.Packet
+
+	var b packet.Buffer
+	b.Init(test_simple)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	body, ok := p.Body.(*wifi.BeaconBody)
+	if !ok || *body != *MakeTestSimple().Body.(*wifi.BeaconBody) {
+		t.Fatalf("Body mismatch: %v", p.Body)
+	}
+
+	if p.SSID() != "test" || p.Channel() != 6 {
+		t.Fatalf("IEs mismatch: %v", p.IEs)
+	}
+
+	if !bytes.Equal(p.Rates(), []uint8{0x02, 0x04, 0x0b, 0x16}) {
+		t.Fatalf("Rates mismatch: %v", p.Rates())
+	}
+
+	if !bytes.Equal(p.BSSID(), bssid) {
+		t.Fatalf("BSSID mismatch: %s", p.BSSID())
+	}
+}
+
+func TestUnpackBeaconFCS(t *testing.T) {
+	/* radiotap header with the FCS flag set */
+	raw := []byte{
+		0x00, 0x00, 0x09, 0x00, 0x02, 0x00, 0x00, 0x00, 0x10,
+	}
+
+	raw = append(raw, test_simple...)
+	raw = append(raw, 0xaa, 0xbb, 0xcc, 0xdd)
+
+	pkt, err := layers.UnpackAll(raw, packet.RadioTap)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	p := layers.FindLayer(pkt, packet.WiFi).(*wifi.Packet)
+
+	if !p.Equals(MakeTestSimple()) || p.FCS != 0xddccbbaa {
+		t.Fatalf("Packet mismatch:\n%s\n%s", p, MakeTestSimple())
+	}
+}
+
+var test_ack = []byte{
+	0xd4, 0x00, 0x00, 0x00, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55,
+}
+
+func TestAck(t *testing.T) {
+	var p wifi.Packet
+
+	var b packet.Buffer
+	b.Init(test_ack)
+
+	err := p.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if p.Type != wifi.Ack || p.GetLength() != uint16(len(test_ack)) {
+		t.Fatalf("Packet mismatch: %s", &p)
+	}
+
+	req := &wifi.Packet{Type: wifi.Data, Addr2: bssid}
+	if !p.Answers(req) {
+		t.Fatalf("Ack doesn't answer data frame")
+	}
+}
+
+func TestPackQoSData(t *testing.T) {
+	p := wifi.Make()
+	p.Type = wifi.QoSData
+	p.Flags = wifi.ToDS | wifi.FromDS
+	p.Addr1 = bssid
+	p.Addr2 = bssid
+	p.Addr3 = bssid
+	p.Addr4 = bssid
+	p.SeqNum = 0xabc
+	p.FragNum = 1
+	p.QoS = 5
+
+	var b packet.Buffer
+	b.Init(make([]byte, p.GetLength()))
+
+	err := p.Pack(&b)
+	if err != nil {
+		t.Fatalf("Error packing: %s", err)
+	}
+
+	if len(b.Buffer()) != 32 {
+		t.Fatalf("Invalid length: %d", len(b.Buffer()))
+	}
+
+	var p2 wifi.Packet
+
+	b.Init(b.Buffer())
+
+	err = p2.Unpack(&b)
+	if err != nil {
+		t.Fatalf("Error unpacking: %s", err)
+	}
+
+	if !p2.Equals(p) || p2.TID() != 5 {
+		t.Fatalf("Packet mismatch:\n%s\n%s", &p2, p)
+	}
+
+	if p2.GuessPayloadType() != packet.LLC {
+		t.Fatalf("Payload type mismatch: %s", p2.GuessPayloadType())
+	}
+}
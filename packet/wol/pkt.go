@@ -0,0 +1,161 @@
+/*
+ * Network packet analysis framework.
+ *
+ * Copyright (c) 2014, Alessandro Ghedini
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions are
+ * met:
+ *
+ *     * Redistributions of source code must retain the above copyright
+ *       notice, this list of conditions and the following disclaimer.
+ *
+ *     * Redistributions in binary form must reproduce the above copyright
+ *       notice, this list of conditions and the following disclaimer in the
+ *       documentation and/or other materials provided with the distribution.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS "AS
+ * IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT LIMITED TO,
+ * THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR A PARTICULAR
+ * PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT HOLDER OR
+ * CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL, SPECIAL,
+ * EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED TO,
+ * PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ */
+
+// Provides encoding and decoding for Wake-on-LAN magic packets.
+//
+// Magic packets can be sent either directly over Ethernet (EtherType 0x0842)
+// or inside UDP datagrams (usually to port 7 or 9).
+package wol
+
+import "bytes"
+import "fmt"
+import "net"
+
+import "github.com/scs-solution/go.pkt2/packet"
+
+type Packet struct {
+	Target      net.HardwareAddr
+	Password    []byte        `string:"pass"`
+	pkt_payload packet.Packet `cmp:"skip" string:"skip"`
+}
+
+var sync_stream = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+func Make() *Packet {
+	return &Packet{}
+}
+
+func (p *Packet) GetType() packet.Type {
+	return packet.WoL
+}
+
+func (p *Packet) GetLength() uint16 {
+	return 6 + 16*6 + uint16(len(p.Password))
+}
+
+func (p *Packet) Equals(other packet.Packet) bool {
+	return packet.Compare(p, other)
+}
+
+func (p *Packet) Answers(other packet.Packet) bool {
+	return false
+}
+
+func (p *Packet) Pack(buf *packet.Buffer) error {
+	if len(p.Target) != 6 {
+		return fmt.Errorf("Invalid target address: %s", p.Target)
+	}
+
+	/* SecureOn passwords are either 4 or 6 bytes long */
+	if len(p.Password) != 0 && len(p.Password) != 4 &&
+		len(p.Password) != 6 {
+		return fmt.Errorf("Invalid password length: %d", len(p.Password))
+	}
+
+	buf.Write(sync_stream)
+
+	for i := 0; i < 16; i++ {
+		buf.Write(p.Target)
+	}
+
+	buf.Write(p.Password)
+
+	return nil
+}
+
+func (p *Packet) Unpack(buf *packet.Buffer) error {
+	if buf.Len() < 6+16*6 {
+		return fmt.Errorf("Invalid packet length: %d", buf.Len())
+	}
+
+	if !bytes.Equal(buf.Next(6), sync_stream) {
+		return fmt.Errorf("Invalid synchronization stream")
+	}
+
+	p.Target = net.HardwareAddr(buf.Next(6))
+
+	for i := 1; i < 16; i++ {
+		if !bytes.Equal(buf.Next(6), p.Target) {
+			return fmt.Errorf("Invalid target repetition: %d", i)
+		}
+	}
+
+	switch {
+	case buf.Len() >= 6:
+		p.Password = buf.Next(6)
+
+	case buf.Len() >= 4:
+		p.Password = buf.Next(4)
+
+	default:
+		p.Password = nil
+	}
+
+	return nil
+}
+
+func (p *Packet) Payload() packet.Packet {
+	return p.pkt_payload
+}
+
+func (p *Packet) GuessPayloadType() packet.Type {
+	return packet.None
+}
+
+func (p *Packet) SetPayload(pl packet.Packet) error {
+	p.pkt_payload = pl
+
+	return nil
+}
+
+func (p *Packet) InitChecksum(csum uint32) {
+}
+
+func (p *Packet) String() string {
+	return packet.Stringify(p)
+}
+
+// Return whether the given data starts with a magic packet, that is the
+// synchronization stream followed by 16 repetitions of the same address.
+func IsMagic(data []byte) bool {
+	if len(data) < 6+16*6 || !bytes.Equal(data[:6], sync_stream) {
+		return false
+	}
+
+	target := data[6:12]
+
+	for i := 1; i < 16; i++ {
+		if !bytes.Equal(data[6+i*6:12+i*6], target) {
+			return false
+		}
+	}
+
+	return true
+}